@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
@@ -18,6 +19,7 @@ import (
 	"github.com/RedHatInsights/cloud-connector/internal/mqtt"
 	"github.com/RedHatInsights/cloud-connector/internal/platform/db"
 	"github.com/RedHatInsights/cloud-connector/internal/platform/logger"
+	"github.com/RedHatInsights/cloud-connector/internal/platform/queue"
 	"github.com/RedHatInsights/cloud-connector/internal/platform/utils"
 	"github.com/RedHatInsights/cloud-connector/internal/platform/utils/jwt_utils"
 	"github.com/RedHatInsights/cloud-connector/internal/platform/utils/tls_utils"
@@ -26,6 +28,8 @@ import (
 
 	MQTT "github.com/eclipse/paho.mqtt.golang"
 	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+	"github.com/redis/go-redis/v9"
 	"github.com/sirupsen/logrus"
 )
 
@@ -130,7 +134,7 @@ func startCloudConnectorApiServer(mgmtAddr string) {
 	mqttConnectionFailedChan := make(chan error)
 	brokerOptions = buildOnConnectionLostMqttOptions(cfg, mqttConnectionFailedChan, brokerOptions)
 
-	mqttClient, err := mqtt.CreateBrokerConnection(cfg.MqttBrokerAddress, brokerOptions...)
+	mqttClient, err := mqtt.CreateBrokerConnection(cfg.MqttBrokerAddress, cfg.MqttProtocolVersion, brokerOptions...)
 	if err != nil {
 		logger.LogFatalError("Unable to establish MQTT Broker connection", err)
 	}
@@ -144,7 +148,12 @@ func startCloudConnectorApiServer(mgmtAddr string) {
 
 	mqttTopicBuilder := mqtt.NewTopicBuilder(cfg.MqttTopicPrefix)
 
-	proxyFactory, err := mqtt.NewConnectorClientMQTTProxyFactory(cfg, mqttClient, mqttTopicBuilder)
+	messageLedger, err := connection_repository.NewSqlMessageLedger(cfg, database)
+	if err != nil {
+		logger.LogFatalError("Unable to create message ledger", err)
+	}
+
+	proxyFactory, err := mqtt.NewConnectorClientMQTTProxyFactory(cfg, mqttClient, mqttTopicBuilder, messageLedger)
 	if err != nil {
 		logger.LogFatalError("Unable to create proxy factory", err)
 	}
@@ -154,7 +163,13 @@ func startCloudConnectorApiServer(mgmtAddr string) {
 		logger.LogFatalError("Unable to create tenant translator", err)
 	}
 
-	managementGetConnectionByOrgID, err := connection_repository.NewSqlGetConnectionByClientID(cfg, database)
+	connectionRepository, err := connection_repository.NewSqlConnectionRepository(cfg, database)
+	if err != nil {
+		logger.LogFatalError("Unable to create connection repository", err)
+	}
+	defer connectionRepository.Close()
+
+	managementGetConnectionByOrgID, err := connectionRepository.GetConnectionByClientID()
 	if err != nil {
 		logger.LogFatalError("Unable to create getConnectionByClientID impl", err)
 	}
@@ -165,29 +180,79 @@ func startCloudConnectorApiServer(mgmtAddr string) {
 	apiSpecServer := api.NewApiSpecServer(apiMux, cfg.UrlBasePath, cfg.OpenApiSpecFilePath)
 	apiSpecServer.Routes()
 
-	monitoringServer := api.NewMonitoringServer(apiMux, cfg)
+	monitoringServer := api.NewMonitoringServer(apiMux, cfg,
+		buildDatabaseReadinessCheck(database),
+		buildMqttReadinessCheck(mqttClient))
 	monitoringServer.Routes()
 
+	// The connection cache is closed after the api server has shutdown
+	connectionCacheCtx, connectionCacheCtxCancel := context.WithCancel(context.Background())
+	defer connectionCacheCtxCancel()
+
 	var getConnectionFunction connection_repository.GetConnectionByClientID
-	getConnectionFunction = buildConnectionLookupInstances(cfg, database)
+	getConnectionFunction = buildConnectionLookupInstances(connectionCacheCtx, cfg, connectionRepository)
 
 	jr := api.NewMessageReceiver(getConnectionFunction, tenantTranslator, proxyFactory, apiMux, cfg.UrlBasePath, cfg)
 	jr.Routes()
 
-	getConnectionListByOrgIDFunction, err := connection_repository.NewSqlGetConnectionsByOrgID(cfg, database)
+	getConnectionListByOrgIDFunction, err := connectionRepository.GetConnectionsByOrgID()
 	if err != nil {
 		logger.LogFatalError("Unable to create connection_repository.GetConnectionsByOrgID() function", err)
 	}
 
-	getAllConnections, err := connection_repository.NewGetAllConnections(cfg, database)
+	getAllConnections, err := connectionRepository.GetAllConnections()
 	if err != nil {
 		logger.LogFatalError("Unable to create connection_repository.GetAllConnections() function", err)
 	}
 
-	mgmtServer := api.NewManagementServer(managementGetConnectionByOrgID, getConnectionListByOrgIDFunction, getAllConnections, tenantTranslator, proxyFactory, apiMux, cfg.UrlBasePath, cfg)
+	// Without notifications the ping endpoint polls for the client's response
+	var databaseListener *pq.Listener
+	if cfg.MessageResponseNotificationsEnabled {
+		databaseListener, err = db.InitializeListener(cfg)
+		if err != nil {
+			logger.LogFatalError("Unable to create database listener", err)
+		}
+	}
+
+	messageResponseListener, err := connection_repository.NewSqlMessageResponseListener(cfg, database, databaseListener)
+	if err != nil {
+		logger.LogFatalError("Unable to listen for message responses", err)
+	}
+
+	mgmtServer := api.NewManagementServer(managementGetConnectionByOrgID, getConnectionListByOrgIDFunction, getAllConnections, tenantTranslator, proxyFactory, messageResponseListener, apiMux, cfg.UrlBasePath, cfg)
 	mgmtServer.Routes()
 
-	connectionMediator := api.NewConnectionMediatorV2(getConnectionFunction, getConnectionListByOrgIDFunction, proxyFactory, apiMux, cfg.UrlBasePath, cfg)
+	getMessageEventsFunction, err := connection_repository.NewSqlGetMessageEventsByMessageID(cfg, database)
+	if err != nil {
+		logger.LogFatalError("Unable to create connection_repository.GetMessageEventsByMessageID() function", err)
+	}
+
+	getMessageFunction, err := connection_repository.NewSqlGetMessageByMessageID(cfg, database)
+	if err != nil {
+		logger.LogFatalError("Unable to create connection_repository.GetMessageByMessageID() function", err)
+	}
+
+	getConnectionsBySelectorFunction, err := connection_repository.NewSqlGetConnectionsBySelector(cfg, database)
+	if err != nil {
+		logger.LogFatalError("Unable to create connection_repository.GetConnectionsBySelector() function", err)
+	}
+
+	getConnectionEventsFunction, err := connection_repository.NewSqlGetConnectionEventsByClientID(cfg, database)
+	if err != nil {
+		logger.LogFatalError("Unable to create connection_repository.GetConnectionEventsByClientID() function", err)
+	}
+
+	pendingMessageStore, err := connection_repository.NewSqlPendingMessageStore(cfg, database)
+	if err != nil {
+		logger.LogFatalError("Unable to create pending message store", err)
+	}
+
+	getConnectionStatusFunction, err := connectionRepository.GetConnectionStatusByClientID()
+	if err != nil {
+		logger.LogFatalError("Unable to create connection_repository.GetConnectionStatusByClientID() function", err)
+	}
+
+	connectionMediator := api.NewConnectionMediatorV2(getConnectionFunction, getConnectionStatusFunction, getConnectionListByOrgIDFunction, getConnectionsBySelectorFunction, getConnectionEventsFunction, getMessageEventsFunction, getMessageFunction, pendingMessageStore, proxyFactory, apiMux, cfg.UrlBasePath, cfg)
 	connectionMediator.Routes()
 
 	apiSrv := utils.StartHTTPServer(mgmtAddr, "management", apiMux)
@@ -208,20 +273,26 @@ func startCloudConnectorApiServer(mgmtAddr string) {
 
 	utils.ShutdownHTTPServer(ctx, "management", apiSrv)
 
+	messageResponseListener.Close()
+
+	connectionCacheCtxCancel()
+
 	mqttClient.Disconnect(cfg.MqttDisconnectQuiesceTime)
 
 	logger.Log.Info("Cloud-Connector shutting down")
 }
 
-func buildConnectionLookupInstances(cfg *config.Config, database *sql.DB) connection_repository.GetConnectionByClientID {
+func buildConnectionLookupInstances(ctx context.Context, cfg *config.Config, connectionRepository *connection_repository.SqlConnectionRepository) connection_repository.GetConnectionByClientID {
 
 	var getConnectionFunction connection_repository.GetConnectionByClientID
 	var err error
 
-	if cfg.ApiServerConnectionLookupImpl == "relaxed" {
+	lookupImpl := strings.TrimPrefix(cfg.ApiServerConnectionLookupImpl, "cached_")
+
+	if lookupImpl == "relaxed" {
 		logger.Log.Info("Using \"relaxed\" connection lookup mechanism")
 
-		getConnectionFunction, err = connection_repository.NewPermittedTenantSqlGetConnectionByClientID(cfg, database)
+		getConnectionFunction, err = connectionRepository.PermittedTenantGetConnectionByClientID()
 		if err != nil {
 			logger.LogFatalError("Unable to create connection_repository.GetConnection() function", err)
 		}
@@ -229,15 +300,78 @@ func buildConnectionLookupInstances(cfg *config.Config, database *sql.DB) connec
 
 		logger.Log.Info("Using \"strict\" connection lookup mechanism")
 
-		getConnectionFunction, err = connection_repository.NewSqlGetConnectionByClientID(cfg, database)
+		getConnectionFunction, err = connectionRepository.GetConnectionByClientID()
 		if err != nil {
 			logger.LogFatalError("Unable to create connection_repository.GetConnection() function", err)
 		}
 	}
 
+	if strings.HasPrefix(cfg.ApiServerConnectionLookupImpl, "cached_") {
+		connectionCache, err := buildConnectionCacheInstance(ctx, cfg)
+		if err != nil {
+			logger.LogFatalError("Unable to create connection cache", err)
+		}
+
+		getConnectionFunction = connection_repository.NewCachingGetConnectionByClientID(connectionCache, getConnectionFunction)
+	}
+
 	return getConnectionFunction
 }
 
+// buildConnectionCacheInstance creates the connection cache.  The redis client is closed
+// once the context is cancelled.
+func buildConnectionCacheInstance(ctx context.Context, cfg *config.Config) (connection_repository.ConnectionCache, error) {
+
+	if cfg.ConnectionCacheImpl == "redis" {
+		logger.Log.Infof("Using \"redis\" connection cache with a TTL of %s", cfg.ConnectionCacheTTL)
+
+		redisClient := redis.NewClient(&redis.Options{
+			Addr:     cfg.ConnectionCacheRedisAddress,
+			Password: cfg.ConnectionCacheRedisPassword,
+		})
+
+		if err := redisClient.Ping(ctx).Err(); err != nil {
+			redisClient.Close()
+			return nil, fmt.Errorf("unable to connect to redis: %w", err)
+		}
+
+		go func() {
+			<-ctx.Done()
+			redisClient.Close()
+		}()
+
+		return connection_repository.NewRedisConnectionCache(redisClient, cfg.ConnectionCacheTTL), nil
+	}
+
+	// The kafka consumer can not invalidate connections that are cached in the api server's
+	// memory, so a disconnected connection can be returned until the cache entry expires
+	if cfg.ConnectionCacheAllowStale == false {
+		return nil, fmt.Errorf("the \"memory\" connection cache can return stale connections, use the \"redis\" connection cache or set %s to allow stale connections", config.CONNECTION_CACHE_ALLOW_STALE)
+	}
+
+	logger.Log.Infof("Using \"memory\" connection cache with a TTL of %s", cfg.ConnectionCacheTTL)
+
+	return connection_repository.NewInMemoryConnectionCache(cfg.ConnectionCacheSize, cfg.ConnectionCacheTTL)
+}
+
+func buildDatabaseReadinessCheck(database *sql.DB) api.ReadinessCheck {
+	return api.ReadinessCheck{Name: "database", Check: database.PingContext}
+}
+
+func buildMqttReadinessCheck(mqttClient MQTT.Client) api.ReadinessCheck {
+	return api.ReadinessCheck{Name: "mqtt", Check: mqtt.BuildConnectionCheck(mqttClient)}
+}
+
+func buildKafkaReadinessCheck(brokers []string, saslCfg *queue.SaslConfig) api.ReadinessCheck {
+
+	kafkaCheck, err := queue.NewBrokerConnectivityCheck(brokers, saslCfg)
+	if err != nil {
+		logger.LogFatalError("Unable to create kafka readiness check", err)
+	}
+
+	return api.ReadinessCheck{Name: "kafka", Check: kafkaCheck}
+}
+
 func buildTenantTranslatorInstance(cfg *config.Config) (tenantid.Translator, error) {
 
 	logger.Log.Infof("Using \"%s\" tenant translator impl", cfg.TenantTranslatorImpl)
@@ -16,6 +16,14 @@ var (
 			Help: "Number of connections",
 		},
 	)
+
+	connectionCountByClientVersionMetric = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "cloud_connector_connection_count_by_client_version",
+			Help: "Number of connections per client name and client version",
+		},
+		[]string{"client_name", "client_version"},
+	)
 )
 
 func startConnectionCount(mgmtAddr string) {
@@ -31,15 +39,36 @@ func startConnectionCount(mgmtAddr string) {
 	}
 
 	var count int
-	err = database.QueryRow("SELECT COUNT(*) FROM connections").Scan(&count)
+	err = database.QueryRow("SELECT COUNT(*) FROM connections WHERE state = 'online'").Scan(&count)
 	if err != nil {
 		logger.LogFatalError("Error Executing the query:", err)
 	}
 
 	connectionCountMetric.Add(float64(count))
 
+	rows, err := database.Query(`SELECT COALESCE(client_name, ''), COALESCE(client_version, ''), COUNT(*) FROM connections
+                                     WHERE state = 'online'
+                                     GROUP BY 1, 2`)
+	if err != nil {
+		logger.LogFatalError("Error Executing the query:", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var clientName string
+		var clientVersion string
+		var versionCount int
+
+		if err := rows.Scan(&clientName, &clientVersion, &versionCount); err != nil {
+			logger.LogFatalError("Error scanning the query results:", err)
+		}
+
+		connectionCountByClientVersionMetric.WithLabelValues(clientName, clientVersion).Set(float64(versionCount))
+	}
+
 	if err := push.New(cfg.PrometheusPushGateway, "cloud_connector").
 		Collector(connectionCountMetric).
+		Collector(connectionCountByClientVersionMetric).
 		Push(); err != nil {
 		logger.LogFatalError("Error pushing metric to the Push Gateway:", err)
 	}
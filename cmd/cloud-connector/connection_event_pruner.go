@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/RedHatInsights/cloud-connector/internal/config"
+	"github.com/RedHatInsights/cloud-connector/internal/connection_repository"
+	"github.com/RedHatInsights/cloud-connector/internal/platform/db"
+	"github.com/RedHatInsights/cloud-connector/internal/platform/logger"
+
+	"github.com/sirupsen/logrus"
+)
+
+func startConnectionEventPruner() {
+
+	logger.Log.Info("Starting Cloud-Connector Connection Event Pruner")
+
+	cfg := config.GetConfig()
+	logger.Log.Info("Cloud-Connector configuration:\n", cfg)
+
+	databaseConn, err := db.InitializeDatabaseConnection(cfg)
+	if err != nil {
+		logger.LogFatalError("Failed to connect to the database", err)
+	}
+
+	cutoff := time.Now().Add(-1 * cfg.ConnectionEventRetention)
+
+	logger.Log.Debug("Connection events recorded before ", cutoff.UTC(), " will be removed")
+
+	if cfg.ConnectionEventPruneBatchSize < 1 {
+		logger.Log.Fatalf("%s must be greater than zero", config.CONNECTION_EVENT_PRUNE_BATCH_SIZE)
+	}
+
+	prunedEvents, err := connection_repository.PruneConnectionEvents(context.TODO(), databaseConn, cfg.ConnectionDatabaseQueryTimeout, cutoff, cfg.ConnectionEventPruneBatchSize)
+	if err != nil {
+		logger.LogFatalError("Failed to prune connection events", err)
+	}
+
+	logger.Log.WithFields(logrus.Fields{"pruned_events": prunedEvents}).Info("Pruned connection events")
+
+	offlineCutoff := time.Now().Add(-1 * cfg.OfflineConnectionRetention)
+
+	logger.Log.Debug("Connections marked as offline before ", offlineCutoff.UTC(), " will be removed")
+
+	prunedConnections, err := connection_repository.PruneOfflineConnections(context.TODO(), databaseConn, cfg.ConnectionDatabaseQueryTimeout, offlineCutoff, cfg.ConnectionEventPruneBatchSize)
+	if err != nil {
+		logger.LogFatalError("Failed to prune offline connections", err)
+	}
+
+	logger.Log.WithFields(logrus.Fields{"pruned_connections": prunedConnections}).Info("Pruned offline connections")
+
+	prunedPendingMessages, err := connection_repository.PruneExpiredPendingMessages(context.TODO(), databaseConn, cfg.ConnectionDatabaseQueryTimeout, time.Now())
+	if err != nil {
+		logger.LogFatalError("Failed to prune expired pending messages", err)
+	}
+
+	logger.Log.WithFields(logrus.Fields{"pruned_pending_messages": prunedPendingMessages}).Info("Pruned expired pending messages")
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"context"
+
+	"github.com/RedHatInsights/cloud-connector/internal/cloud_connector"
+	"github.com/RedHatInsights/cloud-connector/internal/config"
+	"github.com/RedHatInsights/cloud-connector/internal/platform/db"
+	"github.com/RedHatInsights/cloud-connector/internal/platform/logger"
+	"github.com/RedHatInsights/cloud-connector/internal/platform/queue"
+	"github.com/RedHatInsights/cloud-connector/internal/platform/utils/tls_utils"
+
+	kafka "github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+)
+
+func startDeadLetterReplayer(reasons []string, clientIDs []string, dryRun bool) {
+
+	logger.Log.Info("Starting Cloud-Connector dead letter replayer")
+
+	cfg := config.GetConfig()
+	logger.Log.Info("Cloud-Connector configuration:\n", cfg)
+
+	if cfg.DeadLetterKafkaTopic == "" {
+		logger.Log.Fatal("No dead letter topic configured")
+	}
+
+	// A dry run only reads the dead letter topic, so it does not need the database,
+	// the mqtt broker or the producers
+	var messageProcessor func(*logrus.Entry, *kafka.Message) error
+	if dryRun == false {
+		var closeMessageProcessor func()
+		messageProcessor, closeMessageProcessor = buildDeadLetterMessageProcessor(cfg)
+		defer closeMessageProcessor()
+	}
+
+	consumerCfg := buildDeadLetterKafkaConsumerConfig(cfg)
+
+	ctx := context.Background()
+
+	// Only replay the messages that were on the topic when the replay started.  Otherwise,
+	// messages that get sent back to the dead letter topic would be replayed forever.
+	partitionOffsets, err := queue.LookupPartitionOffsets(ctx, consumerCfg)
+	if err != nil {
+		logger.LogFatalError("Unable to lookup dead letter topic offsets", err)
+	}
+
+	partitions := make([]int, 0, len(partitionOffsets))
+	for _, offsets := range partitionOffsets {
+		partitions = append(partitions, offsets.Partition)
+	}
+
+	// The replay position is committed for the replay consumer group, so running the
+	// replayer again does not replay the same messages twice
+	committedOffsets, err := queue.FetchCommittedOffsets(ctx, consumerCfg, partitions)
+	if err != nil {
+		logger.LogFatalError("Unable to fetch the dead letter replay offsets", err)
+	}
+
+	var replayed, skipped int
+
+	for _, offsets := range partitionOffsets {
+		if committedOffset, found := committedOffsets[offsets.Partition]; found && committedOffset > offsets.First {
+			offsets.First = committedOffset
+		}
+
+		if offsets.Last <= offsets.First {
+			continue
+		}
+
+		partitionLogger := logger.Log.WithFields(logrus.Fields{"partition": offsets.Partition, "first_offset": offsets.First, "last_offset": offsets.Last})
+		partitionLogger.Info("Replaying dead letter partition")
+
+		partitionReplayed, partitionSkipped, err := replayDeadLetterPartition(ctx, consumerCfg, offsets, messageProcessor, reasons, clientIDs, dryRun)
+		if err != nil {
+			logger.LogFatalError("Failed to replay dead letter partition", err)
+		}
+
+		replayed += partitionReplayed
+		skipped += partitionSkipped
+	}
+
+	logger.Log.WithFields(logrus.Fields{"replayed": replayed, "skipped": skipped, "dry_run": dryRun}).Info("Finished replaying dead letters")
+}
+
+func buildDeadLetterMessageProcessor(cfg *config.Config) (func(*logrus.Entry, *kafka.Message) error, func()) {
+
+	database, err := db.InitializeDatabaseConnection(cfg)
+	if err != nil {
+		logger.LogFatalError("Unable to connect to database: ", err)
+	}
+
+	tlsConfigFuncs, err := buildBrokerTlsConfigFuncList(cfg)
+	if err != nil {
+		logger.LogFatalError("TLS configuration error for MQTT Broker connection", err)
+	}
+
+	tlsConfig, err := tls_utils.NewTlsConfig(tlsConfigFuncs...)
+	if err != nil {
+		logger.LogFatalError("Unable to configure TLS for MQTT Broker connection", err)
+	}
+
+	dataMessageProducer, dataMessageKafkaProducer := buildDataMessageProducer(cfg)
+
+	// Messages that still can not be processed are sent back to the dead letter topic
+	deadLetterProducer, deadLetterKafkaProducer := buildDeadLetterProducer(cfg)
+
+	brokerOptions, err := buildDefaultMqttBrokerConfigFuncList(cfg.MqttBrokerAddress, tlsConfig, cfg)
+	if err != nil {
+		logger.LogFatalError("Unable to configure MQTT Broker connection", err)
+	}
+
+	mqttClient := connectToMqttBroker(cfg, brokerOptions)
+
+	registrarCtx, registrarCtxCancel := context.WithCancel(context.Background())
+
+	messageProcessor := buildKafkaMessageProcessor(
+		registrarCtx,
+		cfg,
+		database,
+		mqttClient,
+		dataMessageProducer,
+		deadLetterProducer)
+
+	closeMessageProcessor := func() {
+		registrarCtxCancel()
+		mqttClient.Disconnect(cfg.MqttDisconnectQuiesceTime)
+		deadLetterKafkaProducer.Close()
+		if dataMessageKafkaProducer != nil {
+			dataMessageKafkaProducer.Close()
+		}
+		database.Close()
+	}
+
+	return messageProcessor, closeMessageProcessor
+}
+
+func replayDeadLetterPartition(ctx context.Context, consumerCfg *queue.ConsumerConfig, offsets queue.PartitionOffsets, process func(*logrus.Entry, *kafka.Message) error, reasons []string, clientIDs []string, dryRun bool) (int, int, error) {
+
+	partitionCfg := *consumerCfg
+	partitionCfg.ConsumerOffset = offsets.First
+
+	kafkaReader, err := queue.StartPartitionConsumer(&partitionCfg, offsets.Partition)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer kafkaReader.Close()
+
+	var replayed, skipped int
+
+	for {
+		m, err := kafkaReader.FetchMessage(ctx)
+		if err != nil {
+			return replayed, skipped, err
+		}
+
+		if m.Offset >= offsets.Last {
+			return replayed, skipped, nil
+		}
+
+		log := logger.Log.WithFields(logrus.Fields{
+			"client_id":  string(m.Key),
+			"partition":  m.Partition,
+			"offset":     m.Offset,
+			"dlq_reason": getHeaderValueAsString(m.Headers, cloud_connector.DeadLetterReasonKafkaHeaderKey)})
+
+		if isSelectedDeadLetter(&m, reasons, clientIDs) == false {
+			skipped++
+		} else if dryRun {
+			log.Info("Dry run...not replaying dead letter")
+			replayed++
+		} else {
+			log.Info("Replaying dead letter")
+
+			msg := kafka.Message{
+				Key:     m.Key,
+				Value:   m.Value,
+				Headers: removeHeader(m.Headers, cloud_connector.DeadLetterReasonKafkaHeaderKey),
+			}
+
+			if err := process(log, &msg); err != nil {
+				return replayed, skipped, err
+			}
+
+			replayed++
+		}
+
+		if dryRun == false {
+			if err := queue.CommitPartitionOffset(ctx, consumerCfg, offsets.Partition, m.Offset+1); err != nil {
+				return replayed, skipped, err
+			}
+		}
+
+		if m.Offset >= offsets.Last-1 {
+			return replayed, skipped, nil
+		}
+	}
+}
+
+func buildDeadLetterKafkaConsumerConfig(cfg *config.Config) *queue.ConsumerConfig {
+	var kafkaSaslCfg *queue.SaslConfig
+
+	if cfg.KafkaSASLMechanism != "" {
+		kafkaSaslCfg = &queue.SaslConfig{
+			SaslMechanism: cfg.KafkaSASLMechanism,
+			SaslUsername:  cfg.KafkaUsername,
+			SaslPassword:  cfg.KafkaPassword,
+			KafkaCA:       cfg.KafkaCA,
+		}
+	}
+
+	deadLetterKafkaConsumer := queue.ConsumerConfig{
+		Brokers:        cfg.RhcMessageKafkaBrokers,
+		SaslConfig:     kafkaSaslCfg,
+		Topic:          cfg.DeadLetterKafkaTopic,
+		GroupID:        cfg.DeadLetterReplayConsumerGroup,
+		ConsumerOffset: kafka.FirstOffset,
+	}
+
+	return &deadLetterKafkaConsumer
+}
+
+func isSelectedDeadLetter(msg *kafka.Message, reasons []string, clientIDs []string) bool {
+
+	if len(reasons) > 0 && containsString(reasons, getHeaderValueAsString(msg.Headers, cloud_connector.DeadLetterReasonKafkaHeaderKey)) == false {
+		return false
+	}
+
+	if len(clientIDs) > 0 && containsString(clientIDs, string(msg.Key)) == false {
+		return false
+	}
+
+	return true
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func removeHeader(headers []kafka.Header, headerName string) []kafka.Header {
+	filteredHeaders := make([]kafka.Header, 0, len(headers))
+
+	for _, header := range headers {
+		if header.Key != headerName {
+			filteredHeaders = append(filteredHeaders, header)
+		}
+	}
+
+	return filteredHeaders
+}
@@ -2,9 +2,12 @@ package main
 
 import (
 	"context"
+	"database/sql"
 	"errors"
+	"hash/fnv"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
@@ -50,30 +53,13 @@ func startKafkaMessageConsumer(mgmtAddr string) {
 		logger.LogFatalError("Unable to configure TLS for MQTT Broker connection", err)
 	}
 
-	connectionRegistrar, err := connection_repository.NewSqlConnectionRegistrar(cfg, database)
-	if err != nil {
-		logger.LogFatalError("Failed to create SQL Connection Registrar", err)
-	}
+	dataMessageProducer, dataMessageKafkaProducer := buildDataMessageProducer(cfg)
 
-	accountResolver, err := controller.NewAccountIdResolver(cfg.ClientIdToAccountIdImpl, cfg)
-	if err != nil {
-		logger.LogFatalError("Failed to create Account ID Resolver", err)
-	}
+	deadLetterProducer, deadLetterKafkaProducer := buildDeadLetterProducer(cfg)
 
-	connectedClientRecorder, err := controller.NewConnectedClientRecorder(cfg.ConnectedClientRecorderImpl, cfg)
-	if err != nil {
-		logger.LogFatalError("Failed to create Connected Client Recorder", err)
-	}
+	rhcMessageKafkaConsumerConfig := buildRhcMessageKafkaConsumerConfig(cfg)
 
-	sourcesRecorder, err := controller.NewSourcesRecorder(cfg.SourcesRecorderImpl, cfg)
-	if err != nil {
-		logger.LogFatalError("Failed to create Sources Recorder", err)
-	}
-
-	mqttTopicBuilder := mqtt.NewTopicBuilder(cfg.MqttTopicPrefix)
-	mqttTopicVerifier := mqtt.NewTopicVerifier(cfg.MqttTopicPrefix)
-
-	kafkaReader, err := queue.StartConsumer(buildRhcMessageKafkaConsumerConfig(cfg))
+	kafkaReader, err := queue.StartConsumer(rhcMessageKafkaConsumerConfig)
 	if err != nil {
 		logger.LogFatalError("Unable to start kafka consumer", err)
 	}
@@ -83,45 +69,42 @@ func startKafkaMessageConsumer(mgmtAddr string) {
 		logger.LogFatalError("Unable to configure MQTT Broker connection", err)
 	}
 
-	connectedChan := make(chan struct{})
-	brokerOptions = append(brokerOptions, mqtt.WithOnConnectHandler(notifyOnIntialMqttConnection(connectedChan)))
-
 	mqttConnectionFailedChan := make(chan error)
 	brokerOptions = buildOnConnectionLostMqttOptions(cfg, mqttConnectionFailedChan, brokerOptions)
 
-	mqttClient, err := mqtt.CreateBrokerConnection(cfg.MqttBrokerAddress, brokerOptions...)
-	if err != nil {
-		logger.LogFatalError("Unable to establish MQTT broker connection", err)
-	}
+	mqttClient := connectToMqttBroker(cfg, brokerOptions)
 
-	select {
-	case <-connectedChan:
-		logger.Log.Debug("Successfully connected to MQTT broker")
-		break
-	case <-time.After(2 * time.Second):
-		logger.Log.Fatal("Failed to connect to MQTT broker")
-	}
+	// The connection registrar and the connection cache are stopped after the consumer workers have finished
+	registrarCtx, registrarCtxCancel := context.WithCancel(context.Background())
+	defer registrarCtxCancel()
 
-	messageProcessor := handleMessage(
+	messageProcessor := buildKafkaMessageProcessor(
+		registrarCtx,
 		cfg,
+		database,
 		mqttClient,
-		mqttTopicVerifier,
-		mqttTopicBuilder,
-		connectionRegistrar,
-		accountResolver,
-		connectedClientRecorder,
-		sourcesRecorder)
+		dataMessageProducer,
+		deadLetterProducer)
 
 	shutdownCtx, shutdownCtxCancel := context.WithCancel(context.Background())
 	// If the kafka consumer runs into a fatal error, notify the
-	// main thread so that it can shutdown the process
-	fatalProcessingError := make(chan struct{})
+	// main thread so that it can shutdown the process.  The channel is buffered
+	// so that the consumer does not block if the main thread is already shutting down.
+	fatalProcessingError := make(chan struct{}, 1)
+
+	consumerDone := make(chan struct{})
 
-	go consumeMqttMessagesFromKafka(kafkaReader, messageProcessor, shutdownCtx, fatalProcessingError)
+	go func() {
+		defer close(consumerDone)
+		consumeMqttMessagesFromKafka(kafkaReader, messageProcessor, cfg.KafkaMessageConsumerConcurrency, shutdownCtx, fatalProcessingError)
+	}()
 
 	apiMux := mux.NewRouter()
 
-	monitoringServer := api.NewMonitoringServer(apiMux, cfg)
+	monitoringServer := api.NewMonitoringServer(apiMux, cfg,
+		buildDatabaseReadinessCheck(database),
+		buildMqttReadinessCheck(mqttClient),
+		buildKafkaReadinessCheck(rhcMessageKafkaConsumerConfig.Brokers, rhcMessageKafkaConsumerConfig.SaslConfig))
 	monitoringServer.Routes()
 
 	apiSrv := utils.StartHTTPServer(mgmtAddr, "management", apiMux)
@@ -133,14 +116,20 @@ func startKafkaMessageConsumer(mgmtAddr string) {
 	select {
 	case sig := <-signalChan:
 		logger.Log.Info("Received signal to shutdown: ", sig)
-		shutdownCtxCancel() // Notify the consumer to shutdown
 	case <-fatalProcessingError:
 		logger.Log.Info("Received a fatal processing error...shutting down!")
 	case err = <-mqttConnectionFailedChan:
 		logger.Log.Info("MQTT connection dropped: ", err)
-		shutdownCtxCancel() // Notify the consumer to shutdown
 	}
 
+	shutdownCtxCancel() // Notify the consumer to shutdown
+
+	// Wait for the workers to stop and the final commit to finish before the
+	// MQTT client and the kafka producers are closed
+	<-consumerDone
+
+	registrarCtxCancel()
+
 	ctx, cancel := context.WithTimeout(context.Background(), cfg.HttpShutdownTimeout)
 	defer cancel()
 
@@ -148,9 +137,125 @@ func startKafkaMessageConsumer(mgmtAddr string) {
 
 	mqttClient.Disconnect(cfg.MqttDisconnectQuiesceTime)
 
+	if dataMessageKafkaProducer != nil {
+		dataMessageKafkaProducer.Close()
+	}
+
+	if deadLetterKafkaProducer != nil {
+		deadLetterKafkaProducer.Close()
+	}
+
 	logger.Log.Info("Cloud-Connector shutting down")
 }
 
+func connectToMqttBroker(cfg *config.Config, brokerOptions []mqtt.MqttClientOptionsFunc) MQTT.Client {
+
+	connectedChan := make(chan struct{})
+	brokerOptions = append(brokerOptions, mqtt.WithOnConnectHandler(notifyOnIntialMqttConnection(connectedChan)))
+
+	mqttClient, err := mqtt.CreateBrokerConnection(cfg.MqttBrokerAddress, cfg.MqttProtocolVersion, brokerOptions...)
+	if err != nil {
+		logger.LogFatalError("Unable to establish MQTT broker connection", err)
+	}
+
+	select {
+	case <-connectedChan:
+		logger.Log.Debug("Successfully connected to MQTT broker")
+		break
+	case <-time.After(2 * time.Second):
+		logger.Log.Fatal("Failed to connect to MQTT broker")
+	}
+
+	return mqttClient
+}
+
+func buildKafkaMessageProcessor(ctx context.Context, cfg *config.Config, database *sql.DB, mqttClient MQTT.Client, dataMessageProducer cloud_connector.DataMessageProducer, deadLetterProducer cloud_connector.DeadLetterProducer) func(*logrus.Entry, *kafka.Message) error {
+
+	connectionRegistrar := buildConnectionRegistrarInstance(ctx, cfg, database)
+
+	messageEventRecorder, err := connection_repository.NewSqlMessageEventRecorder(cfg, database)
+	if err != nil {
+		logger.LogFatalError("Failed to create SQL Message Event Recorder", err)
+	}
+
+	pendingMessageStore, err := connection_repository.NewSqlPendingMessageStore(cfg, database)
+	if err != nil {
+		logger.LogFatalError("Failed to create SQL Pending Message Store", err)
+	}
+
+	connectionEventRecorder, err := connection_repository.NewSqlConnectionEventRecorder(cfg, database)
+	if err != nil {
+		logger.LogFatalError("Failed to create SQL Connection Event Recorder", err)
+	}
+
+	accountResolver, err := controller.NewAccountIdResolver(cfg.ClientIdToAccountIdImpl, cfg)
+	if err != nil {
+		logger.LogFatalError("Failed to create Account ID Resolver", err)
+	}
+
+	connectedClientRecorder, err := controller.NewConnectedClientRecorder(cfg.ConnectedClientRecorderImpl, cfg)
+	if err != nil {
+		logger.LogFatalError("Failed to create Connected Client Recorder", err)
+	}
+
+	sourcesRecorder, err := controller.NewSourcesRecorder(cfg.SourcesRecorderImpl, cfg)
+	if err != nil {
+		logger.LogFatalError("Failed to create Sources Recorder", err)
+	}
+
+	mqttTopicBuilder := mqtt.NewTopicBuilder(cfg.MqttTopicPrefix)
+	mqttTopicVerifier := mqtt.NewTopicVerifier(cfg.MqttTopicPrefix)
+
+	return handleMessage(
+		cfg,
+		mqttClient,
+		mqttTopicVerifier,
+		mqttTopicBuilder,
+		connectionRegistrar,
+		accountResolver,
+		connectedClientRecorder,
+		sourcesRecorder,
+		messageEventRecorder,
+		pendingMessageStore,
+		connectionEventRecorder,
+		dataMessageProducer,
+		deadLetterProducer)
+}
+
+func buildDeadLetterProducer(cfg *config.Config) (cloud_connector.DeadLetterProducer, *kafka.Writer) {
+
+	if cfg.DeadLetterKafkaTopic == "" {
+		logger.Log.Info("No dead letter topic configured.  Unprocessable messages will be dropped.")
+		return cloud_connector.BuildDroppingDeadLetterProducer(), nil
+	}
+
+	logger.Log.Infof("Using dead letter topic: %s", cfg.DeadLetterKafkaTopic)
+
+	deadLetterKafkaProducer, err := queue.StartProducer(buildDeadLetterKafkaProducerConfig(cfg))
+	if err != nil {
+		logger.LogFatalError("Unable to start dead letter kafka producer", err)
+	}
+
+	return cloud_connector.BuildDeadLetterProducer(deadLetterKafkaProducer), deadLetterKafkaProducer
+}
+
+// buildDataMessageProducer only starts the data message producer when there are data
+// message topics configured.  The mqtt consumer does not forward data messages otherwise.
+func buildDataMessageProducer(cfg *config.Config) (cloud_connector.DataMessageProducer, *kafka.Writer) {
+
+	if len(cfg.DataMessageKafkaTopics) == 0 {
+		logger.Log.Info("No data message topics configured.  Data messages will be dropped.")
+		return cloud_connector.BuildDroppingDataMessageProducer(), nil
+	}
+
+	dataMessageKafkaProducer, err := queue.StartProducer(buildDataMessageKafkaProducerConfig(cfg))
+	if err != nil {
+		logger.LogFatalError("Unable to start data message kafka producer", err)
+	}
+
+	return cloud_connector.BuildDataMessageProducer(dataMessageKafkaProducer), dataMessageKafkaProducer
+}
+
 func getHeaderValueAsString(headers []kafka.Header, headerName string) string {
 
 	for _, header := range headers {
@@ -162,7 +267,7 @@ func getHeaderValueAsString(headers []kafka.Header, headerName string) string {
 	return ""
 }
 
-func handleMessage(cfg *config.Config, mqttClient MQTT.Client, topicVerifier *mqtt.TopicVerifier, topicBuilder *mqtt.TopicBuilder, connectionRegistrar connection_repository.ConnectionRegistrar, accountResolver controller.AccountIdResolver, connectedClientRecorder controller.ConnectedClientRecorder, sourcesRecorder controller.SourcesRecorder) func(*logrus.Entry, *kafka.Message) error {
+func handleMessage(cfg *config.Config, mqttClient MQTT.Client, topicVerifier *mqtt.TopicVerifier, topicBuilder *mqtt.TopicBuilder, connectionRegistrar connection_repository.ConnectionRegistrar, accountResolver controller.AccountIdResolver, connectedClientRecorder controller.ConnectedClientRecorder, sourcesRecorder controller.SourcesRecorder, messageEventRecorder connection_repository.MessageEventRecorder, pendingMessageStore connection_repository.PendingMessageStore, connectionEventRecorder connection_repository.ConnectionEventRecorder, dataMessageProducer cloud_connector.DataMessageProducer, deadLetterProducer cloud_connector.DeadLetterProducer) func(*logrus.Entry, *kafka.Message) error {
 
 	controlMessageHandler := cloud_connector.HandleControlMessage(
 		cfg,
@@ -171,7 +276,15 @@ func handleMessage(cfg *config.Config, mqttClient MQTT.Client, topicVerifier *mq
 		connectionRegistrar,
 		accountResolver,
 		connectedClientRecorder,
-		sourcesRecorder)
+		sourcesRecorder,
+		messageEventRecorder,
+		pendingMessageStore,
+		connectionEventRecorder)
+
+	dataMessageHandler := cloud_connector.HandleDataMessage(
+		cfg,
+		connectionRegistrar,
+		dataMessageProducer)
 
 	return func(log *logrus.Entry, msg *kafka.Message) error {
 
@@ -179,7 +292,7 @@ func handleMessage(cfg *config.Config, mqttClient MQTT.Client, topicVerifier *mq
 
 		if msg.Headers == nil {
 			logger.Log.Debug("Unable to process message.  Message does not have headers!")
-			return nil
+			return deadLetterProducer(context.Background(), log, msg, cloud_connector.DeadLetterReasonMissingHeaders)
 		}
 
 		topic := getHeaderValueAsString(msg.Headers, mqtt.TopicKafkaHeaderKey)
@@ -196,43 +309,111 @@ func handleMessage(cfg *config.Config, mqttClient MQTT.Client, topicVerifier *mq
 
 		if len(topic) == 0 {
 			log.Debug("Unable to process message.  Message does not have topic header!")
-			return nil
+			return deadLetterProducer(context.Background(), log, msg, cloud_connector.DeadLetterReasonMissingTopic)
 		}
 
 		payload := string(msg.Value)
 
-		log.Debugf("Received control message on topic: %s\nMessage: %s\n", topic, payload)
+		log.Debugf("Received message on topic: %s\nMessage: %s\n", topic, payload)
 
 		topicType, clientID, err := topicVerifier.VerifyIncomingTopic(topic)
 
 		if err != nil {
 			log.WithFields(logrus.Fields{"error": err}).Debug("Unable to process message.  Unable to parse topic!")
-			return nil
+			return deadLetterProducer(context.Background(), log, msg, cloud_connector.DeadLetterReasonInvalidTopic)
 		}
 
-		if topicType != mqtt.ControlTopicType {
+		switch topicType {
+		case mqtt.ControlTopicType:
+			err = controlMessageHandler(mqttClient, clientID, payload)
+		case mqtt.DataTopicType:
+			err = dataMessageHandler(clientID, payload)
+		default:
 			log.Debug("Invalid topic type read from kafka.  Skipping message...")
-			return nil
+			return deadLetterProducer(context.Background(), log, msg, cloud_connector.DeadLetterReasonInvalidTopicType)
+		}
+
+		if reason, unprocessable := cloud_connector.IsUnprocessableMessageError(err); unprocessable {
+			return deadLetterProducer(context.Background(), log, msg, reason)
 		}
 
-		return controlMessageHandler(mqttClient, clientID, payload)
+		return err
 	}
 }
 
-func consumeMqttMessagesFromKafka(kafkaReader *kafka.Reader, process func(*logrus.Entry, *kafka.Message) error, ctx context.Context, fatalProcessingError chan struct{}) {
+// consumeMqttMessagesFromKafka fetches messages from kafka and hands them off to a pool of
+// workers.  Messages with the same key (client id) are always handled by the same worker so
+// that the messages for a client are processed in order.  A message is only committed
+// once it, and every message before it on the same partition, has been processed.
+func consumeMqttMessagesFromKafka(kafkaReader *kafka.Reader, process func(*logrus.Entry, *kafka.Message) error, concurrency int, ctx context.Context, fatalProcessingError chan struct{}) {
 
 	// Track consecutive errors to distinguish transient blips from persistent
 	// failures; only shut down after hitting the threshold.
 	const maxConsecutiveFetchErrors = 10
-	const maxConsecutiveCommitErrors = 10
 	const fetchErrorBackoff = 2 * time.Second
-	const commitErrorBackoff = 2 * time.Second
+	const workerQueueSize = 100
 	consecutiveFetchErrors := 0
-	consecutiveCommitErrors := 0
+
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	workerCtx, cancelWorkers := context.WithCancel(ctx)
+	defer cancelWorkers()
+
+	var fatalErrorOnce sync.Once
+	notifyFatalError := func() {
+		fatalErrorOnce.Do(func() {
+			cancelWorkers()
+			// Notify the main thread to shutdown
+			fatalProcessingError <- struct{}{}
+		})
+	}
+
+	offsetTracker := queue.NewOffsetTracker()
+
+	stopCommitter := make(chan struct{})
+	committerDone := make(chan struct{})
+	go commitCompletedKafkaMessages(workerCtx, kafkaReader, offsetTracker, notifyFatalError, stopCommitter, committerDone)
+
+	var workersDone sync.WaitGroup
+	workerQueues := make([]chan kafka.Message, concurrency)
+
+	for i := range workerQueues {
+		workerQueues[i] = make(chan kafka.Message, workerQueueSize)
+		workersDone.Add(1)
+
+		go func(messages chan kafka.Message) {
+			defer workersDone.Done()
+
+			for m := range messages {
+				if workerCtx.Err() != nil {
+					// Shutting down...leave the message uncommitted so that it gets processed again
+					metrics.kafkaMessageInFlightGauge.Dec()
+					continue
+				}
+
+				log := logger.Log.WithFields(logrus.Fields{
+					"client_id": string(m.Key),
+					"partition": m.Partition,
+					"offset":    m.Offset})
+
+				err := process(log, &m)
+				metrics.kafkaMessageInFlightGauge.Dec()
+				if err != nil {
+					logger.LogWithError(log, "Error handling message:", err)
+					notifyFatalError()
+					continue
+				}
+
+				offsetTracker.Complete(m)
+			}
+		}(workerQueues[i])
+	}
 
 consumeLoop:
 	for {
-		m, err := kafkaReader.FetchMessage(ctx)
+		m, err := kafkaReader.FetchMessage(workerCtx)
 		if err != nil {
 			if errors.Is(err, context.Canceled) {
 				break
@@ -248,14 +429,14 @@ consumeLoop:
 			if consecutiveFetchErrors >= maxConsecutiveFetchErrors {
 				logger.Log.Errorf("Reached %d consecutive kafka fetch errors, shutting down", maxConsecutiveFetchErrors)
 				// Signal the main goroutine to initiate process shutdown.
-				fatalProcessingError <- struct{}{}
+				notifyFatalError()
 				break
 			}
 
 			backoffTimer := time.NewTimer(fetchErrorBackoff)
 			select {
 			case <-backoffTimer.C:
-			case <-ctx.Done():
+			case <-workerCtx.Done():
 				backoffTimer.Stop()
 				break consumeLoop
 			}
@@ -264,35 +445,105 @@ consumeLoop:
 
 		consecutiveFetchErrors = 0
 
-		log := logger.Log.WithFields(logrus.Fields{
-			"client_id": string(m.Key),
-			"partition": m.Partition,
-			"offset":    m.Offset})
-
 		metrics.kafkaMessageReceivedCounter.Inc()
+		metrics.kafkaMessageInFlightGauge.Inc()
 
-		err = process(log, &m)
-		if err != nil {
-			logger.LogWithError(log, "Error handling message:", err)
-			// Notify the main thread to shutdown
-			fatalProcessingError <- struct{}{}
-			break
+		offsetTracker.Add(m)
+
+		select {
+		case workerQueues[selectKafkaMessageWorker(m.Key, concurrency)] <- m:
+		case <-workerCtx.Done():
+			metrics.kafkaMessageInFlightGauge.Dec()
+			break consumeLoop
 		}
+	}
+
+	logger.Log.Infof("Stopped reading kafka messages")
+
+	for _, workerQueue := range workerQueues {
+		close(workerQueue)
+	}
+
+	workersDone.Wait()
+
+	close(stopCommitter)
+	<-committerDone
+
+	if err := kafkaReader.Close(); err != nil {
+		logger.LogError("Failed to close kafka reader", err)
+	}
+}
 
-		// explicitly commit the message
-		for {
-			err = kafkaReader.CommitMessages(ctx, m)
+func selectKafkaMessageWorker(key []byte, concurrency int) int {
+	hash := fnv.New32a()
+	hash.Write(key)
+	return int(hash.Sum32() % uint32(concurrency))
+}
+
+// commitCompletedKafkaMessages periodically commits the messages that have been completely
+// processed.  When the stop channel is closed, one last attempt is made to commit the
+// completed messages.
+func commitCompletedKafkaMessages(ctx context.Context, kafkaReader *kafka.Reader, offsetTracker *queue.OffsetTracker, notifyFatalError func(), stop chan struct{}, done chan struct{}) {
+
+	defer close(done)
+
+	const maxConsecutiveCommitErrors = 10
+	const commitInterval = 1 * time.Second
+	const finalCommitTimeout = 5 * time.Second
+	consecutiveCommitErrors := 0
+
+	// Commits that failed are retried along with any newer offsets
+	pendingCommits := make(map[int]kafka.Message)
+
+	commit := func(ctx context.Context) error {
+		for _, m := range offsetTracker.Committable() {
+			pendingCommits[m.Partition] = m
+		}
+
+		if len(pendingCommits) == 0 {
+			return nil
+		}
+
+		messages := make([]kafka.Message, 0, len(pendingCommits))
+		for _, m := range pendingCommits {
+			messages = append(messages, m)
+		}
+
+		if err := kafkaReader.CommitMessages(ctx, messages...); err != nil {
+			return err
+		}
+
+		pendingCommits = make(map[int]kafka.Message)
+		return nil
+	}
+
+	ticker := time.NewTicker(commitInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			finalCtx, cancel := context.WithTimeout(context.Background(), finalCommitTimeout)
+			defer cancel()
+
+			if err := commit(finalCtx); err != nil {
+				logger.LogError("Failed to commit messages to kafka during shutdown", err)
+			}
+			return
+
+		case <-ticker.C:
+			err := commit(ctx)
 			if err == nil {
 				consecutiveCommitErrors = 0
-				break
+				continue
 			}
 
 			if errors.Is(err, context.Canceled) {
-				break consumeLoop
+				continue
 			}
 
 			consecutiveCommitErrors++
-			log.WithFields(logrus.Fields{
+			logger.Log.WithFields(logrus.Fields{
 				"consecutive_errors": consecutiveCommitErrors,
 				"max_errors":         maxConsecutiveCommitErrors,
 				"error":              err,
@@ -300,25 +551,10 @@ consumeLoop:
 
 			if consecutiveCommitErrors >= maxConsecutiveCommitErrors {
 				logger.Log.Errorf("Reached %d consecutive kafka commit errors, shutting down", maxConsecutiveCommitErrors)
-				fatalProcessingError <- struct{}{}
-				break consumeLoop
-			}
-
-			backoffTimer := time.NewTimer(commitErrorBackoff)
-			select {
-			case <-backoffTimer.C:
-			case <-ctx.Done():
-				backoffTimer.Stop()
-				break consumeLoop
+				notifyFatalError()
 			}
 		}
 	}
-
-	logger.Log.Infof("Stopped reading kafka messages")
-
-	if err := kafkaReader.Close(); err != nil {
-		logger.LogError("Failed to close kafka reader", err)
-	}
 }
 
 func buildRhcMessageKafkaConsumerConfig(cfg *config.Config) *queue.ConsumerConfig {
@@ -343,6 +579,54 @@ func buildRhcMessageKafkaConsumerConfig(cfg *config.Config) *queue.ConsumerConfi
 	return &rhcMessageKafkaConsumer
 }
 
+func buildDeadLetterKafkaProducerConfig(cfg *config.Config) *queue.ProducerConfig {
+	var kafkaSaslCfg *queue.SaslConfig
+
+	if cfg.KafkaSASLMechanism != "" {
+		kafkaSaslCfg = &queue.SaslConfig{
+			SaslMechanism: cfg.KafkaSASLMechanism,
+			SaslUsername:  cfg.KafkaUsername,
+			SaslPassword:  cfg.KafkaPassword,
+			KafkaCA:       cfg.KafkaCA,
+		}
+	}
+
+	kafkaProducerCfg := &queue.ProducerConfig{
+		Brokers:    cfg.RhcMessageKafkaBrokers,
+		SaslConfig: kafkaSaslCfg,
+		Topic:      cfg.DeadLetterKafkaTopic,
+		BatchSize:  1,
+		Balancer:   "hash",
+	}
+
+	return kafkaProducerCfg
+}
+
+func buildDataMessageKafkaProducerConfig(cfg *config.Config) *queue.ProducerConfig {
+	var kafkaSaslCfg *queue.SaslConfig
+
+	if cfg.KafkaSASLMechanism != "" {
+		kafkaSaslCfg = &queue.SaslConfig{
+			SaslMechanism: cfg.KafkaSASLMechanism,
+			SaslUsername:  cfg.KafkaUsername,
+			SaslPassword:  cfg.KafkaPassword,
+			KafkaCA:       cfg.KafkaCA,
+		}
+	}
+
+	// The topic is left empty on purpose.  The topic is chosen per message
+	// based on the directive of the data message.
+	kafkaProducerCfg := &queue.ProducerConfig{
+		Brokers:    cfg.DataMessageKafkaBrokers,
+		SaslConfig: kafkaSaslCfg,
+		BatchSize:  cfg.DataMessageKafkaBatchSize,
+		BatchBytes: cfg.DataMessageKafkaBatchBytes,
+		Balancer:   "hash",
+	}
+
+	return kafkaProducerCfg
+}
+
 func recordMessageProcessingLatency(messageReceivedTime string) {
 	messageReceivedTimestamp, err := time.Parse(time.RFC3339Nano, messageReceivedTime)
 	if err != nil {
@@ -354,6 +638,7 @@ func recordMessageProcessingLatency(messageReceivedTime string) {
 type mqttMetrics struct {
 	kafkaMessageReceivedCounter prometheus.Counter
 	kafkaMessageWaitTime        prometheus.Histogram
+	kafkaMessageInFlightGauge   prometheus.Gauge
 }
 
 func newMqttMetrics() *mqttMetrics {
@@ -369,9 +654,56 @@ func newMqttMetrics() *mqttMetrics {
 		Help: "The amount of time messages are setting in kafka waiting to be processed",
 	})
 
+	metrics.kafkaMessageInFlightGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "cloud_connector_kafka_message_in_flight_count",
+		Help: "The number of kafka messages that have been fetched and are waiting to be processed",
+	})
+
 	return metrics
 }
 
 var (
 	metrics = newMqttMetrics()
 )
+
+func buildConnectionRegistrarInstance(ctx context.Context, cfg *config.Config, database *sql.DB) connection_repository.ConnectionRegistrar {
+
+	var connectionRegistrar connection_repository.ConnectionRegistrar
+
+	connectionRepository, err := connection_repository.NewSqlConnectionRepository(cfg, database)
+	if err != nil {
+		logger.LogFatalError("Failed to create SQL Connection Repository", err)
+	}
+
+	if cfg.ConnectionRegistrarImpl == "soft_delete" {
+		logger.Log.Info("Using \"soft_delete\" connection registrar")
+
+		connectionRegistrar = connectionRepository.SoftDeleteConnectionRegistrar()
+	} else {
+
+		logger.Log.Info("Using \"delete\" connection registrar")
+
+		connectionRegistrar = connectionRepository.ConnectionRegistrar()
+	}
+
+	if cfg.ConnectionRegistrarBatchWindow > 0 {
+		logger.Log.Infof("Batching connection registrations every %s", cfg.ConnectionRegistrarBatchWindow)
+
+		connectionRegistrar, err = connection_repository.NewSqlBatchingConnectionRegistrar(ctx, cfg, database, connectionRegistrar)
+		if err != nil {
+			logger.LogFatalError("Failed to create SQL Batching Connection Registrar", err)
+		}
+	}
+
+	if cfg.ConnectionCacheImpl == "redis" {
+		// The api servers share the redis connection cache with the kafka consumer
+		connectionCache, err := buildConnectionCacheInstance(ctx, cfg)
+		if err != nil {
+			logger.LogFatalError("Failed to create connection cache", err)
+		}
+
+		connectionRegistrar = connection_repository.NewCacheInvalidatingConnectionRegistrar(connectionRegistrar, connectionCache)
+	}
+
+	return connectionRegistrar
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/RedHatInsights/cloud-connector/internal/config"
+	"github.com/RedHatInsights/cloud-connector/internal/connection_repository"
+	"github.com/RedHatInsights/cloud-connector/internal/controller/api"
+	"github.com/RedHatInsights/cloud-connector/internal/domain"
+	"github.com/RedHatInsights/cloud-connector/internal/liveness_sweeper"
+	"github.com/RedHatInsights/cloud-connector/internal/mqtt"
+	"github.com/RedHatInsights/cloud-connector/internal/platform/db"
+	"github.com/RedHatInsights/cloud-connector/internal/platform/logger"
+	"github.com/RedHatInsights/cloud-connector/internal/platform/utils"
+	"github.com/RedHatInsights/cloud-connector/internal/platform/utils/tls_utils"
+
+	MQTT "github.com/eclipse/paho.mqtt.golang"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+func startLivenessSweeper(mgmtAddr string) {
+
+	logger.Log.Info("Starting Cloud-Connector Liveness Sweeper")
+
+	cfg := config.GetConfig()
+	logger.Log.Info("Cloud-Connector configuration:\n", cfg)
+
+	database, err := db.InitializeDatabaseConnection(cfg)
+	if err != nil {
+		logger.LogFatalError("Unable to connect to database: ", err)
+	}
+
+	tlsConfigFuncs, err := buildBrokerTlsConfigFuncList(cfg)
+	if err != nil {
+		logger.LogFatalError("TLS configuration error for MQTT Broker connection", err)
+	}
+
+	tlsConfig, err := tls_utils.NewTlsConfig(tlsConfigFuncs...)
+	if err != nil {
+		logger.LogFatalError("Unable to configure TLS for MQTT Broker connection", err)
+	}
+
+	brokerOptions, err := buildDefaultMqttBrokerConfigFuncList(cfg.MqttBrokerAddress, tlsConfig, cfg)
+	if err != nil {
+		logger.LogFatalError("Unable to configure MQTT Broker connection", err)
+	}
+
+	mqttClient := connectToMqttBroker(cfg, brokerOptions)
+
+	// The connection registrar and the connection cache are stopped after the sweeper has finished
+	registrarCtx, registrarCtxCancel := context.WithCancel(context.Background())
+	defer registrarCtxCancel()
+
+	sweeper := buildLivenessSweeper(registrarCtx, cfg, database, mqttClient)
+
+	apiMux := mux.NewRouter()
+
+	monitoringServer := api.NewMonitoringServer(apiMux, cfg,
+		buildDatabaseReadinessCheck(database),
+		buildMqttReadinessCheck(mqttClient))
+	monitoringServer.Routes()
+
+	apiSrv := utils.StartHTTPServer(mgmtAddr, "management", apiMux)
+
+	shutdownCtx, shutdownCtxCancel := context.WithCancel(context.Background())
+	defer shutdownCtxCancel()
+
+	sweepCompleted := make(chan struct{})
+
+	go func() {
+		defer close(sweepCompleted)
+		runLivenessSweeps(shutdownCtx, cfg, database, sweeper)
+	}()
+
+	signalChan := make(chan os.Signal, 1)
+
+	signal.Notify(signalChan, syscall.SIGINT, syscall.SIGTERM)
+
+	sig := <-signalChan
+	logger.Log.Info("Received signal to shutdown: ", sig)
+
+	shutdownCtxCancel() // Notify the sweeper to shutdown
+
+	<-sweepCompleted
+
+	registrarCtxCancel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.HttpShutdownTimeout)
+	defer cancel()
+
+	utils.ShutdownHTTPServer(ctx, "management", apiSrv)
+
+	mqttClient.Disconnect(cfg.MqttDisconnectQuiesceTime)
+
+	logger.Log.Info("Cloud-Connector shutting down")
+}
+
+func buildLivenessSweeper(ctx context.Context, cfg *config.Config, database *sql.DB, mqttClient MQTT.Client) *liveness_sweeper.LivenessSweeper {
+
+	mqttTopicBuilder := mqtt.NewTopicBuilder(cfg.MqttTopicPrefix)
+
+	messageLedger, err := connection_repository.NewSqlMessageLedger(cfg, database)
+	if err != nil {
+		logger.LogFatalError("Unable to create message ledger", err)
+	}
+
+	proxyFactory, err := mqtt.NewConnectorClientMQTTProxyFactory(cfg, mqttClient, mqttTopicBuilder, messageLedger)
+	if err != nil {
+		logger.LogFatalError("Unable to create proxy factory", err)
+	}
+
+	getMessageEventsFunction, err := connection_repository.NewSqlGetMessageEventsByMessageID(cfg, database)
+	if err != nil {
+		logger.LogFatalError("Unable to create connection_repository.GetMessageEventsByMessageID() function", err)
+	}
+
+	connectionRegistrar := buildConnectionRegistrarInstance(ctx, cfg, database)
+
+	connectionEventRecorder, err := connection_repository.NewSqlConnectionEventRecorder(cfg, database)
+	if err != nil {
+		logger.LogFatalError("Failed to create SQL Connection Event Recorder", err)
+	}
+
+	recordLivenessCheck := func(ctx context.Context, clientID domain.ClientID) error {
+		return connection_repository.RecordSuccessfulLivenessCheck(ctx, database, cfg.ConnectionDatabaseQueryTimeout, clientID)
+	}
+
+	return liveness_sweeper.NewLivenessSweeper(cfg, proxyFactory, getMessageEventsFunction, connectionRegistrar, connectionEventRecorder, recordLivenessCheck)
+}
+
+func runLivenessSweeps(ctx context.Context, cfg *config.Config, database *sql.DB, sweeper *liveness_sweeper.LivenessSweeper) {
+
+	ticker := time.NewTicker(cfg.LivenessSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		cutoff := time.Now().Add(-1 * cfg.LivenessSweepConnectionAge)
+
+		connections, err := connection_repository.GetConnectionsForLivenessCheck(ctx, database, cfg.ConnectionDatabaseQueryTimeout, cutoff, cfg.LivenessSweepChunkSize)
+		if err != nil {
+			logger.Log.WithFields(logrus.Fields{"error": err}).Error("Unable to locate connections for liveness check")
+		} else {
+			logger.Log.WithFields(logrus.Fields{"connections": len(connections), "cutoff": cutoff.UTC()}).Info("Starting liveness sweep")
+
+			result := sweeper.Sweep(ctx, connections)
+
+			logger.Log.WithFields(logrus.Fields{"alive": result.Alive, "reaped": result.Reaped, "failed": result.Failed, "skipped": result.Skipped}).Info("Finished liveness sweep")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
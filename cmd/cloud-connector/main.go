@@ -17,6 +17,11 @@ func NewRootCommand() *cobra.Command {
 	var listenAddr string
 	var excludeAccounts string
 	var reportMode string
+	var deadLetterReasons []string
+	var deadLetterClientIDs []string
+	var deadLetterDryRun bool
+	var purgeTenantlessConnections bool
+	var purgeDryRun bool
 
 	// rootCmd represents the base command when called without any subcommands
 	var rootCmd = &cobra.Command{
@@ -61,9 +66,39 @@ func NewRootCommand() *cobra.Command {
 		Use:   "tenantless_connection_updater",
 		Short: "Run the tenantless connection updater",
 		Run: func(cmd *cobra.Command, args []string) {
-			startTenantlessConnectionUpdater()
+			startTenantlessConnectionUpdater(purgeTenantlessConnections, purgeDryRun)
 		},
 	}
+	tenantlessConnectionUpdaterCmd.Flags().BoolVarP(&purgeTenantlessConnections, "purge", "p", false, "Purge connections whose tenant lookup has failed too many times")
+	tenantlessConnectionUpdaterCmd.Flags().BoolVarP(&purgeDryRun, "dry-run", "d", false, "Print the connections that would be purged without updating or purging any connections")
+
+	var connectionEventPrunerCmd = &cobra.Command{
+		Use:   "connection_event_pruner",
+		Short: "Remove connection history and offline connections that are older than the retention period along with expired pending messages",
+		Run: func(cmd *cobra.Command, args []string) {
+			startConnectionEventPruner()
+		},
+	}
+
+	var replayDeadLettersCmd = &cobra.Command{
+		Use:   "replay_dead_letters",
+		Short: "Replay messages from the dead letter topic through the kafka message handler",
+		Run: func(cmd *cobra.Command, args []string) {
+			startDeadLetterReplayer(deadLetterReasons, deadLetterClientIDs, deadLetterDryRun)
+		},
+	}
+	replayDeadLettersCmd.Flags().StringSliceVarP(&deadLetterReasons, "reason", "r", nil, "Only replay messages with these dlq_reason values")
+	replayDeadLettersCmd.Flags().StringSliceVarP(&deadLetterClientIDs, "client-id", "c", nil, "Only replay messages for these client ids")
+	replayDeadLettersCmd.Flags().BoolVarP(&deadLetterDryRun, "dry-run", "d", false, "Log the selected messages without replaying them")
+
+	var livenessSweeperCmd = &cobra.Command{
+		Use:   "liveness_sweeper",
+		Short: "Ping old connections and unregister the connections that do not respond",
+		Run: func(cmd *cobra.Command, args []string) {
+			startLivenessSweeper(listenAddr)
+		},
+	}
+	livenessSweeperCmd.Flags().StringVarP(&listenAddr, "listen-addr", "l", ":8081", "Hostname:port")
 
 	var apiServerCmd = &cobra.Command{
 		Use:   "api_server",
@@ -92,8 +127,11 @@ func NewRootCommand() *cobra.Command {
 	rootCmd.AddCommand(mqttMessageConsumerCmd)
 	rootCmd.AddCommand(inventoryStaleTimestampeUpdaterCmd)
 	rootCmd.AddCommand(tenantlessConnectionUpdaterCmd)
+	rootCmd.AddCommand(connectionEventPrunerCmd)
 	rootCmd.AddCommand(apiServerCmd)
 	rootCmd.AddCommand(kafkaMessageConsumerCmd)
+	rootCmd.AddCommand(replayDeadLettersCmd)
+	rootCmd.AddCommand(livenessSweeperCmd)
 	rootCmd.AddCommand(connectedAccountReportCmd)
 	rootCmd.AddCommand(connectionCountCmd)
 
@@ -19,6 +19,7 @@ import (
 
 	MQTT "github.com/eclipse/paho.mqtt.golang"
 	"github.com/gorilla/mux"
+	kafka "github.com/segmentio/kafka-go"
 	"github.com/sirupsen/logrus"
 )
 
@@ -53,16 +54,35 @@ func startMqttMessageConsumer(mgmtAddr string) {
 		logger.LogFatalError("Unable to configure TLS for MQTT Broker connection", err)
 	}
 
-	mqttTopicBuilder := mqtt.NewTopicBuilder(cfg.MqttTopicPrefix)
+	mqttTopicBuilder := mqtt.NewTopicBuilder(cfg.MqttTopicPrefix).WithSharedSubscriptionGroup(cfg.MqttSharedSubscriptionGroup)
 	mqttTopicVerifier := mqtt.NewTopicVerifier(cfg.MqttTopicPrefix)
 
-	kafkaProducer, err := queue.StartProducer(buildRhcMessageKafkaProducerConfig(cfg))
+	if cfg.MqttSharedSubscriptionGroup != "" {
+		logger.Log.Infof("Using MQTT shared subscription group: %s", cfg.MqttSharedSubscriptionGroup)
+	}
+
+	rhcMessageKafkaProducerConfig := buildRhcMessageKafkaProducerConfig(cfg)
+
+	kafkaProducer, err := queue.StartProducer(rhcMessageKafkaProducerConfig)
 	if err != nil {
 		logger.LogFatalError("Unable to start kafka producer", err)
 	}
 
-	controlMsgHandler := mqtt.ControlMessageHandler(context.TODO(), kafkaProducer, mqttTopicVerifier)
+	kafkaWriter, kafkaSpool := buildMqttMessageKafkaWriter(cfg, kafkaProducer)
+
+	replayCtx, cancelReplay := context.WithCancel(context.Background())
+	defer cancelReplay()
+
+	if kafkaSpool != nil {
+		kafkaSpool.StartReplayer(replayCtx)
+	}
+
+	controlMsgHandler := mqtt.ControlMessageHandler(context.TODO(), kafkaWriter, mqttTopicVerifier)
 	dataMsgHandler := mqtt.DataMessageHandler()
+	if len(cfg.DataMessageKafkaTopics) > 0 {
+		// Data messages only need to be passed along when there is somewhere to route them
+		dataMsgHandler = mqtt.ForwardDataMessageHandler(context.TODO(), kafkaWriter, mqttTopicVerifier)
+	}
 
 	defaultMsgHandler := mqtt.DefaultMessageHandler(mqttTopicVerifier, controlMsgHandler, dataMsgHandler)
 
@@ -95,14 +115,16 @@ func startMqttMessageConsumer(mgmtAddr string) {
 	// See "Common Problems" here: https://github.com/eclipse/paho.mqtt.golang#common-problems
 	brokerOptions = append(brokerOptions, mqtt.WithDefaultPublishHandler(defaultMsgHandler))
 
-	mqttClient, err := mqtt.CreateBrokerConnection(cfg.MqttBrokerAddress, brokerOptions...)
+	mqttClient, err := mqtt.CreateBrokerConnection(cfg.MqttBrokerAddress, cfg.MqttProtocolVersion, brokerOptions...)
 	if err != nil {
 		logger.LogFatalError("Failed to connect to MQTT broker", err)
 	}
 
 	apiMux := mux.NewRouter()
 
-	monitoringServer := api.NewMonitoringServer(apiMux, cfg)
+	monitoringServer := api.NewMonitoringServer(apiMux, cfg,
+		buildMqttReadinessCheck(mqttClient),
+		buildKafkaReadinessCheck(rhcMessageKafkaProducerConfig.Brokers, rhcMessageKafkaProducerConfig.SaslConfig))
 	monitoringServer.Routes()
 
 	apiSrv := utils.StartHTTPServer(mgmtAddr, "management", apiMux)
@@ -125,6 +147,12 @@ func startMqttMessageConsumer(mgmtAddr string) {
 
 	mqttClient.Disconnect(cfg.MqttDisconnectQuiesceTime)
 
+	cancelReplay()
+
+	if kafkaSpool != nil {
+		kafkaSpool.Close()
+	}
+
 	kafkaProducer.Close()
 
 	logger.FlushLogger()
@@ -181,6 +209,29 @@ func buildRhcMessageKafkaProducerConfig(cfg *config.Config) *queue.ProducerConfi
 	return kafkaProducerCfg
 }
 
+func buildMqttMessageKafkaWriter(cfg *config.Config, kafkaProducer *kafka.Writer) (mqtt.KafkaMessageWriter, *mqtt.SpoolingKafkaWriter) {
+
+	if cfg.MqttKafkaSpoolDirectory == "" {
+		logger.Log.Info("Kafka spool is disabled")
+		return kafkaProducer, nil
+	}
+
+	logger.Log.Infof("Using kafka spool directory: %s", cfg.MqttKafkaSpoolDirectory)
+
+	kafkaSpool, err := mqtt.NewSpoolingKafkaWriter(kafkaProducer, mqtt.KafkaSpoolConfig{
+		Directory:       cfg.MqttKafkaSpoolDirectory,
+		MaxBytes:        int64(cfg.MqttKafkaSpoolMaxBytes),
+		SegmentMaxBytes: int64(cfg.MqttKafkaSpoolSegmentMaxBytes),
+		ReplayInterval:  cfg.MqttKafkaSpoolReplayInterval,
+		ReplayBatchSize: cfg.MqttKafkaSpoolReplayBatchSize,
+	})
+	if err != nil {
+		logger.LogFatalError("Unable to create kafka spool", err)
+	}
+
+	return kafkaSpool, kafkaSpool
+}
+
 func buildOnConnectionLostMqttOptions(cfg *config.Config, mqttConnectionFailedChan chan error, brokerOptions []mqtt.MqttClientOptionsFunc) []mqtt.MqttClientOptionsFunc {
 
 	var autoReconnect = true
@@ -2,19 +2,24 @@ package main
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/RedHatInsights/cloud-connector/internal/config"
 	"github.com/RedHatInsights/cloud-connector/internal/connection_repository"
 	"github.com/RedHatInsights/cloud-connector/internal/controller"
 	"github.com/RedHatInsights/cloud-connector/internal/domain"
+	"github.com/RedHatInsights/cloud-connector/internal/mqtt"
 	"github.com/RedHatInsights/cloud-connector/internal/platform/db"
 	"github.com/RedHatInsights/cloud-connector/internal/platform/logger"
+	"github.com/RedHatInsights/cloud-connector/internal/platform/utils/tls_utils"
 
 	"github.com/sirupsen/logrus"
 )
 
-func startTenantlessConnectionUpdater() {
+func startTenantlessConnectionUpdater(purge bool, dryRun bool) {
 
 	logger.Log.Info("Starting Cloud-Connector Tenantless Connection Updater")
 
@@ -26,6 +31,18 @@ func startTenantlessConnectionUpdater() {
 		logger.LogFatalError("Failed to connect to the database", err)
 	}
 
+	// A dry run only reports the connections that would be purged
+	if dryRun == false {
+		updateTenantlessConnections(cfg, databaseConn)
+	}
+
+	if purge || dryRun {
+		purgeTenantlessConnections(cfg, databaseConn, dryRun)
+	}
+}
+
+func updateTenantlessConnections(cfg *config.Config, databaseConn *sql.DB) {
+
 	accountResolver, err := controller.NewAccountIdResolver(cfg.ClientIdToAccountIdImpl, cfg)
 	if err != nil {
 		logger.LogFatalError("Failed to create Account ID Resolver", err)
@@ -38,7 +55,7 @@ func startTenantlessConnectionUpdater() {
 
 	logger.Log.Debug("Host's should be updated if their tenant_lookup_timestamp is before ", tooOldIfBeforeThisTime.UTC())
 
-	connection_repository.ProcessTenantlessConnections(context.TODO(), databaseConn, sqlTimeout, tooOldIfBeforeThisTime, chunkSize, maxTenantLookupFailures,
+	err = connection_repository.ProcessTenantlessConnections(context.TODO(), databaseConn, sqlTimeout, tooOldIfBeforeThisTime, chunkSize, maxTenantLookupFailures,
 		func(ctx context.Context, rhcClient domain.ConnectorClientState) error {
 
 			log := logger.Log.WithFields(logrus.Fields{"client_id": rhcClient.ClientID, "account": rhcClient.Account, "org_id": rhcClient.OrgID})
@@ -66,4 +83,120 @@ func startTenantlessConnectionUpdater() {
 
 			return nil
 		})
+	if err != nil {
+		// The connections that could not be updated are retried on the next run
+		logger.LogError("Unable to update some of the tenantless connections", err)
+	}
+}
+
+func purgeTenantlessConnections(cfg *config.Config, databaseConn *sql.DB, dryRun bool) {
+
+	sqlTimeout := cfg.ConnectionDatabaseQueryTimeout
+	chunkSize := cfg.TenantlessConnectionUpdaterChunkSize
+	purgeThreshold := cfg.PurgeConnectionOnFailedTenantLookupCount
+
+	// The updater stops looking up the tenant once the max lookup failures is reached, so
+	// the failure count of a connection never goes past that value
+	if purgeThreshold > cfg.TenantlessConnectionMaxLookupFailures {
+		logger.LogFatalError("Invalid purge configuration", fmt.Errorf("the purge threshold (%d) must not be higher than the max tenant lookup failures (%d)", purgeThreshold, cfg.TenantlessConnectionMaxLookupFailures))
+	}
+
+	logger.Log.WithFields(logrus.Fields{"purge_threshold": purgeThreshold, "dry_run": dryRun}).Info("Purging tenantless connections")
+
+	if dryRun {
+		err := connection_repository.ProcessPurgeableTenantlessConnections(context.TODO(), databaseConn, sqlTimeout, chunkSize, purgeThreshold,
+			func(ctx context.Context, rhcClient domain.ConnectorClientState) error {
+				fmt.Printf("Would purge client_id: %s, account: %s, tenant_lookup_failure_count: %d, tenant_lookup_timestamp: %s\n",
+					rhcClient.ClientID, rhcClient.Account, rhcClient.TenantLookupFailureCount, rhcClient.TenantLookupTimestamp.UTC())
+				return nil
+			})
+		if err != nil {
+			logger.LogFatalError("Unable to locate tenantless connections to purge", err)
+		}
+		return
+	}
+
+	connectionEventRecorder, err := connection_repository.NewSqlConnectionEventRecorder(cfg, databaseConn)
+	if err != nil {
+		logger.LogFatalError("Failed to create connection event recorder", err)
+	}
+
+	tlsConfigFuncs, err := buildBrokerTlsConfigFuncList(cfg)
+	if err != nil {
+		logger.LogFatalError("TLS configuration error for MQTT Broker connection", err)
+	}
+
+	tlsConfig, err := tls_utils.NewTlsConfig(tlsConfigFuncs...)
+	if err != nil {
+		logger.LogFatalError("Unable to configure TLS for MQTT Broker connection", err)
+	}
+
+	brokerOptions, err := buildDefaultMqttBrokerConfigFuncList(cfg.MqttBrokerAddress, tlsConfig, cfg)
+	if err != nil {
+		logger.LogFatalError("Unable to configure MQTT Broker connection", err)
+	}
+
+	mqttClient := connectToMqttBroker(cfg, brokerOptions)
+	defer mqttClient.Disconnect(cfg.MqttDisconnectQuiesceTime)
+
+	mqttTopicBuilder := mqtt.NewTopicBuilder(cfg.MqttTopicPrefix)
+
+	var purged int
+
+	err = connection_repository.ProcessPurgeableTenantlessConnections(context.TODO(), databaseConn, sqlTimeout, chunkSize, purgeThreshold,
+		func(ctx context.Context, rhcClient domain.ConnectorClientState) error {
+
+			log := logger.Log.WithFields(logrus.Fields{"client_id": rhcClient.ClientID, "account": rhcClient.Account, "tenant_lookup_failure_count": rhcClient.TenantLookupFailureCount})
+
+			// Leave the connection in place if the client could not be told to disconnect.
+			// The purge is attempted again on the next run.
+			err := mqtt.SendDisconnectMessageToClient(mqttClient, log, mqttTopicBuilder, cfg.MqttControlPublishQoS, cfg.MqttPublishTimeout, rhcClient.ClientID, "Unable to determine the tenant for this connection")
+			if err != nil {
+				log.WithFields(logrus.Fields{"error": err}).Error("Unable to send disconnect message to tenantless connection")
+				return err
+			}
+
+			err = connection_repository.PurgeTenantlessConnection(ctx, databaseConn, sqlTimeout, rhcClient, purgeThreshold)
+			if err != nil {
+				if errors.Is(err, connection_repository.NotFoundError) {
+					log.Debug("Tenantless connection was updated or removed before it could be purged")
+					return nil
+				}
+				log.WithFields(logrus.Fields{"error": err}).Error("Unable to purge tenantless connection")
+				return err
+			}
+
+			purged++
+
+			log.Info("Purged tenantless connection")
+
+			// A tenantless connection has no org, so the purge is only keyed by the client id
+			event := domain.ConnectionEvent{
+				ClientID: rhcClient.ClientID,
+				Account:  rhcClient.Account,
+				State:    domain.ConnectionPurged,
+				Sent:     time.Now(),
+			}
+
+			err = connectionEventRecorder.RecordConnectionEvent(ctx, event)
+			if errors.As(err, &connection_repository.FatalError{}) {
+				return err
+			}
+
+			if err != nil {
+				log.WithFields(logrus.Fields{"error": err}).Error("Unable to record the purge of tenantless connection")
+			}
+
+			return nil
+		})
+	if errors.As(err, &connection_repository.FatalError{}) {
+		logger.LogFatalError("Unable to purge tenantless connections", err)
+	}
+
+	if err != nil {
+		// The connections that could not be purged are left in place and retried on the next run
+		logger.LogError("Unable to purge some of the tenantless connections", err)
+	}
+
+	logger.Log.WithFields(logrus.Fields{"purged": purged}).Info("Finished purging tenantless connections")
 }
@@ -6,10 +6,14 @@ import (
 
 	"github.com/RedHatInsights/cloud-connector/internal/config"
 	"github.com/RedHatInsights/cloud-connector/internal/platform/db"
+	"github.com/RedHatInsights/cloud-connector/internal/platform/logger"
 )
 
 func main() {
 
+	logger.InitLogger()
+	defer logger.FlushLogger()
+
 	cfg := config.GetConfig()
 	log.Println("Starting Cloud-Connector DB migration")
 	log.Println("Cloud-Connector configuration:\n", cfg)
@@ -173,12 +173,12 @@ func startProducer(certFile string, keyFile string, broker string, i int) {
 		time.Sleep(10 * time.Second)
 
 		dispatchers := make(Connector.Dispatchers)
-		dispatchers["rhc-worker-playbook"] = make(map[string]string)
+		dispatchers["rhc-worker-playbook"] = make(map[string]interface{})
 		dispatchers["rhc-worker-playbook"]["ansible-runner-version"] = "1.2.3"
-		dispatchers["package-manager"] = make(map[string]string)
-		dispatchers["echo"] = make(map[string]string)
+		dispatchers["package-manager"] = make(map[string]interface{})
+		dispatchers["echo"] = make(map[string]interface{})
 
-		dispatchers["catalog"] = make(map[string]string)
+		dispatchers["catalog"] = make(map[string]interface{})
 		dispatchers["catalog"]["ApplicationType"] = "/insights/platform/catalog"
 		dispatchers["catalog"]["SourceRef"] = "df2bac3e-c7b4-4a8b-8226-b943b9a12eaf"
 		dispatchers["catalog"]["SrcName"] = "dehort Testing Bulk Create"
@@ -187,7 +187,7 @@ func startProducer(certFile string, keyFile string, broker string, i int) {
 		dispatchers["catalog"]["WorkerSHA"] = "48d28791e3b59f7334d2671c07978113b0d40374"
 		dispatchers["catalog"]["WorkerVersion"] = "v0.1.0"
 
-		dispatchers["foreman_rh_cloud"] = make(map[string]string)
+		dispatchers["foreman_rh_cloud"] = make(map[string]interface{})
 		dispatchers["foreman_rh_cloud"]["version"] = "6.11"
 
 		tags := make(Connector.Tags)
@@ -201,7 +201,7 @@ func startProducer(certFile string, keyFile string, broker string, i int) {
 
 		time.Sleep(40 * time.Second)
 		dispatchers = make(Connector.Dispatchers)
-		dispatchers["IGNORE"] = make(map[string]string)
+		dispatchers["IGNORE"] = make(map[string]interface{})
 		tags = make(Connector.Tags)
 		tags["IGNORE"] = "value1"
 
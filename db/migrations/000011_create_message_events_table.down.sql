@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_message_events_response_to;
+
+DROP TABLE IF EXISTS message_events;
@@ -0,0 +1,12 @@
+CREATE TABLE message_events (
+    id SERIAL PRIMARY KEY,
+    org_id varchar(20) NOT NULL DEFAULT '',
+    client_id varchar(100) NOT NULL,
+    message_id varchar(40) NOT NULL,
+    response_to varchar(40) NOT NULL,
+    content jsonb,
+    message_sent timestamptz NOT NULL,
+    created_at timestamptz NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_message_events_response_to ON message_events (response_to);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_messages_message_id;
+
+DROP TABLE IF EXISTS messages;
@@ -0,0 +1,14 @@
+CREATE TABLE messages (
+    id SERIAL PRIMARY KEY,
+    message_id varchar(40) NOT NULL,
+    org_id varchar(20) NOT NULL DEFAULT '',
+    account varchar(10),
+    client_id varchar(100) NOT NULL,
+    directive varchar(100) NOT NULL,
+    status varchar(20) NOT NULL,
+    error text,
+    created_at timestamptz NOT NULL DEFAULT NOW(),
+    updated_at timestamptz NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX idx_messages_message_id ON messages (message_id);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_pending_messages_client_id;
+
+DROP INDEX IF EXISTS idx_pending_messages_message_id;
+
+DROP TABLE IF EXISTS pending_messages;
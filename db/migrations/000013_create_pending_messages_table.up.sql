@@ -0,0 +1,16 @@
+CREATE TABLE pending_messages (
+    id SERIAL PRIMARY KEY,
+    message_id varchar(40) NOT NULL,
+    org_id varchar(20) NOT NULL,
+    account varchar(10),
+    client_id varchar(100) NOT NULL,
+    directive varchar(100) NOT NULL,
+    metadata jsonb,
+    payload jsonb,
+    created_at timestamptz NOT NULL DEFAULT NOW(),
+    expires_at timestamptz NOT NULL
+);
+
+CREATE UNIQUE INDEX idx_pending_messages_message_id ON pending_messages (message_id);
+
+CREATE INDEX idx_pending_messages_client_id ON pending_messages (client_id);
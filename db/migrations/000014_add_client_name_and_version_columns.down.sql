@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_connections_client_name_version;
+
+ALTER TABLE connections
+    DROP COLUMN client_version;
+
+ALTER TABLE connections
+    DROP COLUMN client_name;
@@ -0,0 +1,7 @@
+ALTER TABLE connections
+    ADD client_name varchar(100);
+
+ALTER TABLE connections
+    ADD client_version varchar(50);
+
+CREATE INDEX idx_connections_client_name_version ON connections (org_id, client_name, client_version);
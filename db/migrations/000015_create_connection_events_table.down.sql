@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_connection_events_created_at;
+
+DROP INDEX IF EXISTS idx_connection_events_client_id;
+
+DROP TABLE IF EXISTS connection_events;
@@ -0,0 +1,16 @@
+CREATE TABLE connection_events (
+    id SERIAL PRIMARY KEY,
+    org_id varchar(20),
+    account varchar(10),
+    client_id varchar(100) NOT NULL,
+    state varchar(20) NOT NULL,
+    message_id varchar(40),
+    sent timestamptz,
+    client_name varchar(100),
+    client_version varchar(50),
+    created_at timestamptz NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_connection_events_client_id ON connection_events (client_id, created_at);
+
+CREATE INDEX idx_connection_events_created_at ON connection_events (created_at);
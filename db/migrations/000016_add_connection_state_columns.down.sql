@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_connections_org_id_state;
+
+ALTER TABLE connections
+    DROP COLUMN disconnected_at;
+
+ALTER TABLE connections
+    DROP COLUMN state;
@@ -0,0 +1,7 @@
+ALTER TABLE connections
+    ADD state varchar(20) NOT NULL DEFAULT 'online';
+
+ALTER TABLE connections
+    ADD disconnected_at timestamptz;
+
+CREATE INDEX idx_connections_org_id_state ON connections (org_id, state);
@@ -0,0 +1 @@
+-- Removed duplicate connections can not be restored
@@ -0,0 +1,4 @@
+DELETE FROM connections a
+    USING connections b
+    WHERE a.client_id = b.client_id
+      AND (a.message_sent < b.message_sent OR (a.message_sent = b.message_sent AND a.id < b.id));
@@ -0,0 +1 @@
+CREATE UNIQUE INDEX CONCURRENTLY idx_connections_client_id_unique ON connections (client_id);
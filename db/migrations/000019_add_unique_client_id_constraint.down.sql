@@ -0,0 +1,4 @@
+ALTER TABLE connections
+    DROP CONSTRAINT IF EXISTS connections_client_id_key;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_connections_client_id_unique ON connections (client_id);
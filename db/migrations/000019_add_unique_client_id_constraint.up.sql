@@ -0,0 +1,2 @@
+ALTER TABLE connections
+    ADD CONSTRAINT connections_client_id_key UNIQUE USING INDEX idx_connections_client_id_unique;
@@ -0,0 +1 @@
+DROP INDEX CONCURRENTLY IF EXISTS idx_connections_client_id;
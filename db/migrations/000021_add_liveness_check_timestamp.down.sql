@@ -0,0 +1,2 @@
+ALTER TABLE connections
+    DROP liveness_check_timestamp;
@@ -0,0 +1,2 @@
+ALTER TABLE connections
+    ADD liveness_check_timestamp timestamptz;
@@ -0,0 +1,61 @@
+package cloud_connector
+
+import (
+	"testing"
+
+	"github.com/RedHatInsights/cloud-connector/internal/config"
+	"github.com/RedHatInsights/cloud-connector/internal/domain"
+	"github.com/RedHatInsights/cloud-connector/internal/mqtt"
+)
+
+// FuzzHandleControlMessage verifies that a malformed control message can never crash the
+// consumer.  Additional inputs live in testdata/fuzz/FuzzHandleControlMessage.
+func FuzzHandleControlMessage(f *testing.F) {
+
+	seeds := []string{
+		``,
+		`null`,
+		`[]`,
+		`"connection-status"`,
+		`{"type": "connection-status", `,
+		`{"type": "connection-status", "message_id": "5678", "version": 1, "sent": "2021-01-12T15:30:08+00:00", "content": {"state": "online", "client_name": "rhc", "client_version": "0.2.1", "canonical_facts": {"insights_id": "e4c8e1b4-3ab8-4b8a-9a8c-1c2d3e4f5a6b", "fqdn": "host.example.com"}, "dispatchers": {"catalog": {"ApplicationType": "app", "SrcName": "name", "SourceRef": "ref", "SrcType": "type"}}, "tags": {"env": "prod"}}}`,
+		`{"type": "connection-status", "message_id": "5678", "version": 1, "sent": "2021-01-12T15:30:08+00:00", "content": {"state": "offline"}}`,
+		`{"type": "connection-status", "message_id": "5678", "version": 1, "content": "online"}`,
+		`{"type": "connection-status", "message_id": "5678", "version": 1, "content": {"state": "online", "dispatchers": {"catalog": "fred"}}}`,
+		`{"type": "connection-status", "message_id": "5678", "version": 1, "content": {"state": "online", "dispatchers": {"catalog": {"SourceRef": 42}}}}`,
+		`{"type": "connection-status", "message_id": "5678", "version": 1, "content": {"state": "online", "canonical_facts": {"insights_id": 42}}}`,
+		`{"type": "connection-status", "message_id": "5678", "version": 1, "content": {"state": "online", "client_name": ["rhc"]}}`,
+		`{"type": "event", "message_id": "5678", "response_to": "1234", "version": 1, "sent": "2021-01-12T15:30:08+00:00", "content": "RECEIVED"}`,
+		`{"type": "event", "message_id": "5678", "response_to": "1234", "version": 1, "content": {"status": "RECEIVED"}}`,
+		`{"type": "command", "message_id": "5678", "version": 1, "content": {"command": "reconnect", "arguments": {"delay": "30"}}}`,
+		`{"type": "connection-status", "message_id": "5678", "version": "1", "content": {"state": "online"}}`,
+		`{"type": "connection-status", "message_id": "5678", "version": 2, "content": {"state": "online"}}`,
+		`{"type": "connection-status", "message_id": "5678", "version": 1, "sent": 42, "content": {"state": "online"}}`,
+	}
+
+	for _, seed := range seeds {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, payload string) {
+
+		var cfg config.Config
+		var clientID domain.ClientID = "1234"
+
+		controlMessageHandler := HandleControlMessage(
+			&cfg,
+			nil,
+			mqtt.NewTopicBuilder("redhat"),
+			&mockConnectionRegistrar{clients: make(map[domain.ClientID]domain.ConnectorClientState)},
+			&mockAccountIdResolver{},
+			&mockConnectedClientRecorder{},
+			&mockSourcesRecorder{},
+			&mockMessageEventRecorder{},
+			&mockPendingMessageStore{},
+			&mockConnectionEventRecorder{},
+		)
+
+		// Any error is acceptable here...the handler must not panic
+		controlMessageHandler(&mockMqttClient{}, clientID, payload)
+	})
+}
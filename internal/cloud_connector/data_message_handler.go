@@ -0,0 +1,121 @@
+package cloud_connector
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/RedHatInsights/cloud-connector/internal/cloud_connector/protocol"
+	"github.com/RedHatInsights/cloud-connector/internal/config"
+	"github.com/RedHatInsights/cloud-connector/internal/connection_repository"
+	"github.com/RedHatInsights/cloud-connector/internal/domain"
+	"github.com/RedHatInsights/cloud-connector/internal/platform/logger"
+
+	kafka "github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	OrgIDKafkaHeaderKey     = "org_id"
+	AccountKafkaHeaderKey   = "account"
+	DirectiveKafkaHeaderKey = "directive"
+)
+
+type DataMessageProducer func(ctx context.Context, log *logrus.Entry, topic string, key []byte, headers []kafka.Header, msg []byte) error
+
+func BuildDataMessageProducer(kafkaWriter *kafka.Writer) DataMessageProducer {
+	return func(ctx context.Context, log *logrus.Entry, topic string, key []byte, headers []kafka.Header, msg []byte) error {
+
+		err := kafkaWriter.WriteMessages(ctx,
+			kafka.Message{
+				Topic:   topic,
+				Headers: headers,
+				Key:     key,
+				Value:   msg,
+			})
+
+		if err != nil {
+			log.WithFields(logrus.Fields{"error": err}).Error("Error writing data message to kafka")
+			return err
+		}
+
+		log.Debug("Data message written to kafka")
+
+		return nil
+	}
+}
+
+// BuildDroppingDataMessageProducer returns a DataMessageProducer that only logs the message.
+// This is used when there are no data message topics configured.
+func BuildDroppingDataMessageProducer() DataMessageProducer {
+	return func(ctx context.Context, log *logrus.Entry, topic string, key []byte, headers []kafka.Header, msg []byte) error {
+		log.Debug("No data message topics configured.  Dropping data message.")
+		return nil
+	}
+}
+
+// HandleDataMessage returns a function that routes data messages to the kafka
+// topic configured for the message's directive.  Just like with HandleControlMessage,
+// the returned function should only return an error in the case where the
+// message should get processed again.
+func HandleDataMessage(cfg *config.Config, connectionRegistrar connection_repository.ConnectionRegistrar, dataMessageProducer DataMessageProducer) func(domain.ClientID, string) error {
+
+	return func(clientID domain.ClientID, payload string) error {
+
+		metrics.dataMessageReceivedCounter.Inc()
+
+		logger := logger.Log.WithFields(logrus.Fields{"client_id": clientID})
+
+		if len(payload) == 0 {
+			logger.Trace("client sent an empty payload")
+			return nil
+		}
+
+		var dataMsg protocol.DataMessage
+
+		if err := json.Unmarshal([]byte(payload), &dataMsg); err != nil {
+			logger.WithFields(logrus.Fields{"error": err}).Error("Failed to unmarshal data message")
+			return nil
+		}
+
+		logger = logger.WithFields(logrus.Fields{"message_id": dataMsg.MessageID, "directive": dataMsg.Directive})
+
+		topic, found := cfg.DataMessageKafkaTopics[dataMsg.Directive]
+		if found == false || len(topic) == 0 {
+			logger.Debug("No kafka topic configured for directive.  Dropping data message.")
+			return nil
+		}
+
+		ctx := context.Background()
+
+		connectionState, err := connectionRegistrar.FindConnectionByClientID(ctx, clientID)
+		if err != nil {
+			if errors.As(err, &connection_repository.FatalError{}) {
+				return err
+			}
+
+			// Without a tenant, there is no way for downstream services to know who
+			// the data message belongs to
+			logger.WithFields(logrus.Fields{"error": err}).Info("Unable to locate connection for data message.  Dropping data message.")
+			return nil
+		}
+
+		logger = logger.WithFields(logrus.Fields{"org_id": connectionState.OrgID, "account": connectionState.Account, "topic": topic})
+
+		headers := []kafka.Header{
+			{Key: OrgIDKafkaHeaderKey, Value: []byte(connectionState.OrgID)},
+			{Key: AccountKafkaHeaderKey, Value: []byte(connectionState.Account)},
+			{Key: DirectiveKafkaHeaderKey, Value: []byte(dataMsg.Directive)},
+		}
+
+		err = dataMessageProducer(ctx, logger, topic, []byte(clientID), headers, []byte(payload))
+		if err != nil {
+			// Do not commit the message...it needs to get forwarded again
+			return err
+		}
+
+		metrics.dataMessageForwardedCounter.Inc()
+
+		return nil
+	}
+}
@@ -0,0 +1,105 @@
+package cloud_connector
+
+import (
+	"context"
+	"testing"
+
+	"github.com/RedHatInsights/cloud-connector/internal/config"
+	"github.com/RedHatInsights/cloud-connector/internal/domain"
+
+	kafka "github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+)
+
+type producedDataMessage struct {
+	topic   string
+	key     []byte
+	headers []kafka.Header
+	msg     []byte
+}
+
+func mockDataMessageProducer(producedMessages *[]producedDataMessage) DataMessageProducer {
+	return func(ctx context.Context, log *logrus.Entry, topic string, key []byte, headers []kafka.Header, msg []byte) error {
+		*producedMessages = append(*producedMessages, producedDataMessage{topic, key, headers, msg})
+		return nil
+	}
+}
+
+func TestHandleDataMessage(t *testing.T) {
+
+	var clientID domain.ClientID = "1234"
+	var cfg = config.Config{
+		DataMessageKafkaTopics: map[string]string{"rhc-worker-playbook": "platform.playbook-dispatcher.runner-updates"},
+	}
+	var connectionRegistrar = &mockConnectionRegistrar{
+		clients: map[domain.ClientID]domain.ConnectorClientState{
+			clientID: {Account: "000111", OrgID: "000001", ClientID: clientID},
+		},
+	}
+
+	testCases := []struct {
+		name          string
+		payload       string
+		expectedTopic string
+	}{
+		{"directive with a configured topic", `{"type": "data", "message_id": "5678", "directive": "rhc-worker-playbook", "content": "ZmVk"}`, "platform.playbook-dispatcher.runner-updates"},
+		{"directive without a configured topic", `{"type": "data", "message_id": "5678", "directive": "echo", "content": "ZmVk"}`, ""},
+		{"invalid payload", `{"type": "data", `, ""},
+		{"empty payload", "", ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			producedMessages := []producedDataMessage{}
+
+			dataMessageHandler := HandleDataMessage(&cfg, connectionRegistrar, mockDataMessageProducer(&producedMessages))
+
+			err := dataMessageHandler(clientID, tc.payload)
+			if err != nil {
+				t.Fatal("data message handler should not have returned an error")
+			}
+
+			if tc.expectedTopic == "" {
+				if len(producedMessages) != 0 {
+					t.Fatal("data message should not have been forwarded")
+				}
+				return
+			}
+
+			if len(producedMessages) != 1 {
+				t.Fatalf("expected 1 forwarded data message, got %d", len(producedMessages))
+			}
+
+			producedMessage := producedMessages[0]
+
+			if producedMessage.topic != tc.expectedTopic {
+				t.Errorf("expected topic %s, got %s", tc.expectedTopic, producedMessage.topic)
+			}
+
+			if string(producedMessage.key) != string(clientID) {
+				t.Errorf("expected key %s, got %s", clientID, producedMessage.key)
+			}
+
+			if string(producedMessage.msg) != tc.payload {
+				t.Error("forwarded payload does not match the original payload")
+			}
+
+			expectedHeaders := map[string]string{
+				OrgIDKafkaHeaderKey:     "000001",
+				AccountKafkaHeaderKey:   "000111",
+				DirectiveKafkaHeaderKey: "rhc-worker-playbook",
+			}
+
+			for _, header := range producedMessage.headers {
+				if expectedHeaders[header.Key] != string(header.Value) {
+					t.Errorf("unexpected value for header %s: %s", header.Key, header.Value)
+				}
+				delete(expectedHeaders, header.Key)
+			}
+
+			if len(expectedHeaders) != 0 {
+				t.Errorf("missing headers: %v", expectedHeaders)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package cloud_connector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	kafka "github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	DeadLetterReasonKafkaHeaderKey = "dlq_reason"
+
+	DeadLetterReasonMissingHeaders     = "missing_headers"
+	DeadLetterReasonMissingTopic       = "missing_topic"
+	DeadLetterReasonInvalidTopic       = "invalid_topic"
+	DeadLetterReasonInvalidTopicType   = "invalid_topic_type"
+	DeadLetterReasonUnmarshalFailure   = "unmarshal_failure"
+	DeadLetterReasonUnknownMessageType = "unknown_message_type"
+	DeadLetterReasonInvalidState       = "invalid_connection_state"
+	DeadLetterReasonInvalidHandshake   = "invalid_handshake"
+	DeadLetterReasonSchemaValidation   = "schema_validation_failure"
+)
+
+// UnprocessableMessageError is returned by the message handlers when a message
+// can never be processed successfully.  These messages should not be processed again,
+// instead they should be handed off to the DeadLetterProducer.
+type UnprocessableMessageError struct {
+	Reason string
+	Err    error
+}
+
+func (e UnprocessableMessageError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("unprocessable message: %s", e.Reason)
+	}
+	return fmt.Sprintf("unprocessable message: %s: %s", e.Reason, e.Err)
+}
+
+func (e UnprocessableMessageError) Unwrap() error {
+	return e.Err
+}
+
+// IsUnprocessableMessageError returns the reason the message could not be processed
+func IsUnprocessableMessageError(err error) (string, bool) {
+	var unprocessableErr UnprocessableMessageError
+	if errors.As(err, &unprocessableErr) {
+		return unprocessableErr.Reason, true
+	}
+	return "", false
+}
+
+type DeadLetterProducer func(ctx context.Context, log *logrus.Entry, msg *kafka.Message, reason string) error
+
+// BuildDeadLetterProducer returns a DeadLetterProducer that forwards the message, along
+// with its original headers and a dlq_reason header, to the kafka writer's topic
+func BuildDeadLetterProducer(kafkaWriter *kafka.Writer) DeadLetterProducer {
+	return func(ctx context.Context, log *logrus.Entry, msg *kafka.Message, reason string) error {
+
+		metrics.deadLetterMessageCounter.WithLabelValues(reason).Inc()
+
+		err := kafkaWriter.WriteMessages(ctx,
+			kafka.Message{
+				Headers: buildDeadLetterHeaders(msg.Headers, reason),
+				Key:     msg.Key,
+				Value:   msg.Value,
+			})
+
+		if err != nil {
+			log.WithFields(logrus.Fields{"error": err, "dlq_reason": reason}).Error("Error writing message to dead letter topic")
+			return err
+		}
+
+		log.WithFields(logrus.Fields{"dlq_reason": reason}).Info("Message written to dead letter topic")
+
+		return nil
+	}
+}
+
+// BuildDroppingDeadLetterProducer returns a DeadLetterProducer that only logs the message.
+// This is used when there is no dead letter topic configured.
+func BuildDroppingDeadLetterProducer() DeadLetterProducer {
+	return func(ctx context.Context, log *logrus.Entry, msg *kafka.Message, reason string) error {
+		metrics.deadLetterMessageCounter.WithLabelValues(reason).Inc()
+		log.WithFields(logrus.Fields{"dlq_reason": reason}).Debug("No dead letter topic configured.  Dropping message.")
+		return nil
+	}
+}
+
+func buildDeadLetterHeaders(headers []kafka.Header, reason string) []kafka.Header {
+	deadLetterHeaders := make([]kafka.Header, 0, len(headers)+1)
+
+	for _, header := range headers {
+		if header.Key != DeadLetterReasonKafkaHeaderKey {
+			deadLetterHeaders = append(deadLetterHeaders, header)
+		}
+	}
+
+	return append(deadLetterHeaders, kafka.Header{Key: DeadLetterReasonKafkaHeaderKey, Value: []byte(reason)})
+}
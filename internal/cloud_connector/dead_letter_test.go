@@ -0,0 +1,85 @@
+package cloud_connector
+
+import (
+	"context"
+	"testing"
+
+	"github.com/RedHatInsights/cloud-connector/internal/config"
+	"github.com/RedHatInsights/cloud-connector/internal/domain"
+	"github.com/RedHatInsights/cloud-connector/internal/mqtt"
+	"github.com/RedHatInsights/cloud-connector/internal/platform/logger"
+
+	kafka "github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+)
+
+func TestHandleControlMessageUnprocessableMessages(t *testing.T) {
+
+	var cfg config.Config
+	var topicBuilder mqtt.TopicBuilder
+	var clientID domain.ClientID = "1234"
+	var mqttClient = &mockMqttClient{}
+
+	controlMessageHandler := HandleControlMessage(&cfg, nil, &topicBuilder, nil, nil, nil, nil, nil, nil, nil)
+
+	testCases := []struct {
+		name           string
+		payload        string
+		expectedReason string
+	}{
+		{"invalid json", `{"type": "connection-status", `, DeadLetterReasonUnmarshalFailure},
+		{"unknown message type", `{"type": "fred", "message_id": "5678", "version": 1, "sent": "2021-01-12T15:30:08+00:00", "content": {}}`, DeadLetterReasonUnknownMessageType},
+		{"missing connection state", `{"type": "connection-status", "message_id": "5678", "version": 1, "sent": "2021-01-12T15:30:08+00:00", "content": {}}`, DeadLetterReasonInvalidState},
+		{"invalid connection state", `{"type": "connection-status", "message_id": "5678", "version": 1, "sent": "2021-01-12T15:30:08+00:00", "content": {"state": "fred"}}`, DeadLetterReasonInvalidState},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := controlMessageHandler(mqttClient, clientID, tc.payload)
+
+			reason, unprocessable := IsUnprocessableMessageError(err)
+			if unprocessable == false {
+				t.Fatalf("expected an unprocessable message error, got %v", err)
+			}
+
+			if reason != tc.expectedReason {
+				t.Fatalf("expected reason %s, got %s", tc.expectedReason, reason)
+			}
+		})
+	}
+
+	if err := controlMessageHandler(mqttClient, clientID, ""); err != nil {
+		t.Fatalf("empty payloads should be ignored, got %v", err)
+	}
+}
+
+func TestBuildDeadLetterHeaders(t *testing.T) {
+
+	originalHeaders := []kafka.Header{
+		{Key: mqtt.TopicKafkaHeaderKey, Value: []byte("redhat/insights/1234/control/out")},
+		{Key: DeadLetterReasonKafkaHeaderKey, Value: []byte(DeadLetterReasonInvalidTopic)},
+	}
+
+	headers := buildDeadLetterHeaders(originalHeaders, DeadLetterReasonUnmarshalFailure)
+
+	if len(headers) != 2 {
+		t.Fatalf("expected 2 headers, got %d", len(headers))
+	}
+
+	if headers[0].Key != mqtt.TopicKafkaHeaderKey || string(headers[0].Value) != "redhat/insights/1234/control/out" {
+		t.Errorf("original header was not preserved: %v", headers[0])
+	}
+
+	if headers[1].Key != DeadLetterReasonKafkaHeaderKey || string(headers[1].Value) != DeadLetterReasonUnmarshalFailure {
+		t.Errorf("unexpected dlq_reason header: %v", headers[1])
+	}
+}
+
+func TestDroppingDeadLetterProducer(t *testing.T) {
+
+	producer := BuildDroppingDeadLetterProducer()
+
+	if err := producer(context.TODO(), logger.Log.WithFields(logrus.Fields{}), &kafka.Message{}, DeadLetterReasonMissingHeaders); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
@@ -3,6 +3,7 @@ package cloud_connector
 import (
 	"testing"
 
+	"github.com/RedHatInsights/cloud-connector/internal/cloud_connector/protocol"
 	"github.com/RedHatInsights/cloud-connector/internal/domain"
 	"github.com/RedHatInsights/cloud-connector/internal/platform/logger"
 	"github.com/sirupsen/logrus"
@@ -55,15 +56,12 @@ func TestProcessDispatchers(t *testing.T) {
 	catalogMap[catalogSourceRef] = expectedSourceRef
 	catalogMap[catalogSourceName] = expectedSourceName
 
-	dispatchersMap := make(map[string]interface{})
-	dispatchersMap[catalogDispatcherKey] = catalogMap
-
-	contentMap := make(map[string]interface{})
-	contentMap[dispatchersKey] = dispatchersMap
+	dispatchers := make(protocol.Dispatchers)
+	dispatchers[catalogDispatcherKey] = catalogMap
 
 	logger := logger.Log.WithFields(logrus.Fields{"client_id": expectedClientId, "account": expectedAccount, "org_id": expectedOrgID})
 
-	processDispatchers(logger, sourcesRecorder, expectedIdentity, expectedAccount, expectedOrgID, expectedClientId, contentMap)
+	processDispatchers(logger, sourcesRecorder, expectedIdentity, expectedAccount, expectedOrgID, expectedClientId, dispatchers)
 
 	// Verify that the SourcesRecorder is called and the parameters were as expected
 
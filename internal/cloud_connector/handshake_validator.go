@@ -0,0 +1,106 @@
+package cloud_connector
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/RedHatInsights/cloud-connector/internal/cloud_connector/protocol"
+	"github.com/RedHatInsights/cloud-connector/internal/config"
+	"github.com/RedHatInsights/cloud-connector/internal/domain"
+	"github.com/RedHatInsights/cloud-connector/internal/mqtt"
+
+	MQTT "github.com/eclipse/paho.mqtt.golang"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	InvalidHandshakeReasonInvalidContent   = "invalid_content"
+	InvalidHandshakeReasonMissingMessageID = "missing_message_id"
+	InvalidHandshakeReasonMissingState     = "missing_state"
+	InvalidHandshakeReasonInvalidState     = "invalid_state"
+)
+
+// InvalidHandshakeError is returned when a connection-status message cannot be used
+// to register or unregister a connection
+type InvalidHandshakeError struct {
+	Reason string
+	Err    error
+}
+
+func (e InvalidHandshakeError) Error() string {
+	return fmt.Sprintf("invalid handshake: %s: %s", e.Reason, e.Err)
+}
+
+func (e InvalidHandshakeError) Unwrap() error {
+	return e.Err
+}
+
+// validateHandshake decodes the content of a connection-status message and verifies
+// that the fields required to process the message are present
+func validateHandshake(cfg *config.Config, msg protocol.ControlMessage) (protocol.ConnectionStatusMessageContent, error) {
+
+	var handshake protocol.ConnectionStatusMessageContent
+
+	content, isObject := msg.Content.(map[string]interface{})
+	if isObject == false {
+		return handshake, InvalidHandshakeError{InvalidHandshakeReasonInvalidContent, fmt.Errorf("content must be an object, got %T", msg.Content)}
+	}
+
+	// The state is checked before the rest of the content so that the rejection
+	// reason is as specific as possible
+	connectionState, gotConnectionState := content["state"]
+	switch {
+	case gotConnectionState == false:
+		return handshake, InvalidHandshakeError{InvalidHandshakeReasonMissingState, errors.New("missing connection state")}
+	case connectionState == domain.ConnectionOnline:
+		// The message id is used to detect duplicate online messages
+		if cfg.HandshakeRequireMessageID && msg.MessageID == "" {
+			return handshake, InvalidHandshakeError{InvalidHandshakeReasonMissingMessageID, errors.New("missing message id")}
+		}
+	case connectionState == domain.ConnectionOffline:
+	default:
+		return handshake, InvalidHandshakeError{InvalidHandshakeReasonInvalidState, fmt.Errorf("invalid connection state %v", connectionState)}
+	}
+
+	handshake, err := protocol.DecodeConnectionStatusMessageContent(msg)
+	if err != nil {
+		return handshake, InvalidHandshakeError{InvalidHandshakeReasonInvalidContent, err}
+	}
+
+	return handshake, nil
+}
+
+// isOnlineHandshake returns true if the message claims to be an online connection-status message
+func isOnlineHandshake(msg protocol.ControlMessage) bool {
+	content, isObject := msg.Content.(map[string]interface{})
+	return isObject && content["state"] == domain.ConnectionOnline
+}
+
+// rejectInvalidHandshake asks the client to reconnect after a delay.  The client sends a
+// new connection-status message after reconnecting.  Offline messages are sent while the
+// client is disconnecting (or by the broker as the client's last will), so there is no
+// connection to reconnect.  Only an online handshake results in a reconnect message.
+func rejectInvalidHandshake(logger *logrus.Entry, client MQTT.Client, clientID domain.ClientID, msg protocol.ControlMessage, cfg *config.Config, topicBuilder *mqtt.TopicBuilder, handshakeErr InvalidHandshakeError) error {
+
+	metrics.invalidHandshakeCounter.WithLabelValues(handshakeErr.Reason).Inc()
+
+	logger = logger.WithFields(logrus.Fields{"reason": handshakeErr.Reason, "error": handshakeErr.Err})
+
+	if isOnlineHandshake(msg) {
+		logger.Info("Rejecting invalid handshake...instructing the client to reconnect")
+
+		err := mqtt.SendReconnectMessageToClient(client, logger, topicBuilder, cfg.MqttControlPublishQoS, cfg.MqttPublishTimeout, clientID, cfg.InvalidHandshakeReconnectDelay)
+		if err != nil {
+			logger.WithFields(logrus.Fields{"error": err}).Error("Unable to send reconnect message to client")
+		}
+	} else {
+		logger.Info("Rejecting invalid handshake")
+	}
+
+	deadLetterReason := DeadLetterReasonInvalidHandshake
+	if handshakeErr.Reason == InvalidHandshakeReasonMissingState || handshakeErr.Reason == InvalidHandshakeReasonInvalidState {
+		deadLetterReason = DeadLetterReasonInvalidState
+	}
+
+	return UnprocessableMessageError{Reason: deadLetterReason, Err: handshakeErr}
+}
@@ -0,0 +1,107 @@
+package cloud_connector
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/RedHatInsights/cloud-connector/internal/cloud_connector/protocol"
+	"github.com/RedHatInsights/cloud-connector/internal/config"
+	"github.com/RedHatInsights/cloud-connector/internal/domain"
+	"github.com/RedHatInsights/cloud-connector/internal/mqtt"
+)
+
+func TestValidateHandshake(t *testing.T) {
+
+	testCases := []struct {
+		name             string
+		payload          string
+		requireMessageID bool
+		expectedReason   string
+	}{
+		{"online", `{"type": "connection-status", "message_id": "5678", "version": 1, "content": {"state": "online", "client_name": "rhc", "dispatchers": {"playbook": {"ansible-runner-version": "1.2.3"}}}}`, false, ""},
+		{"offline", `{"type": "connection-status", "version": 1, "content": {"state": "offline"}}`, false, ""},
+		{"unknown fields from a newer client", `{"type": "connection-status", "message_id": "5678", "version": 1, "content": {"state": "online", "fred": "wilma", "canonical_facts": {"fqdn": "host.example.com", "barney": "rubble"}}}`, false, ""},
+		{"content is not an object", `{"type": "connection-status", "message_id": "5678", "version": 1, "content": "online"}`, false, InvalidHandshakeReasonInvalidContent},
+		{"missing content", `{"type": "connection-status", "message_id": "5678", "version": 1}`, false, InvalidHandshakeReasonInvalidContent},
+		{"invalid field type", `{"type": "connection-status", "message_id": "5678", "version": 1, "content": {"state": "online", "client_name": 42}}`, false, InvalidHandshakeReasonInvalidContent},
+		{"invalid dispatchers", `{"type": "connection-status", "message_id": "5678", "version": 1, "content": {"state": "online", "dispatchers": ["playbook"]}}`, false, InvalidHandshakeReasonInvalidContent},
+		{"missing message id", `{"type": "connection-status", "version": 1, "content": {"state": "online"}}`, true, InvalidHandshakeReasonMissingMessageID},
+		{"missing message id is allowed", `{"type": "connection-status", "version": 1, "content": {"state": "online"}}`, false, ""},
+		{"missing state", `{"type": "connection-status", "message_id": "5678", "version": 1, "content": {}}`, false, InvalidHandshakeReasonMissingState},
+		{"invalid state", `{"type": "connection-status", "message_id": "5678", "version": 1, "content": {"state": "fred"}}`, false, InvalidHandshakeReasonInvalidState},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			var msg protocol.ControlMessage
+			if err := json.Unmarshal([]byte(tc.payload), &msg); err != nil {
+				t.Fatal("unable to unmarshal test payload", err)
+			}
+
+			cfg := config.Config{HandshakeRequireMessageID: tc.requireMessageID}
+
+			_, err := validateHandshake(&cfg, msg)
+
+			if tc.expectedReason == "" {
+				if err != nil {
+					t.Fatalf("expected a valid handshake, got %v", err)
+				}
+				return
+			}
+
+			var handshakeErr InvalidHandshakeError
+			if errors.As(err, &handshakeErr) == false {
+				t.Fatalf("expected an InvalidHandshakeError, got %v", err)
+			}
+
+			if handshakeErr.Reason != tc.expectedReason {
+				t.Fatalf("expected reason %s, got %s", tc.expectedReason, handshakeErr.Reason)
+			}
+		})
+	}
+}
+
+func TestInvalidHandshakeSendsReconnectMessage(t *testing.T) {
+
+	var cfg config.Config
+	var topicBuilder = mqtt.NewTopicBuilder("redhat")
+	var clientID domain.ClientID = "1234"
+	var mqttClient = &mockMqttClient{}
+
+	controlMessageHandler := HandleControlMessage(&cfg, nil, topicBuilder, nil, nil, nil, nil, nil, nil, nil)
+
+	err := controlMessageHandler(mqttClient, clientID, `{"type": "connection-status", "message_id": "5678", "version": 1, "content": {"state": "online", "client_name": 42}}`)
+
+	reason, unprocessable := IsUnprocessableMessageError(err)
+	if unprocessable == false || reason != DeadLetterReasonInvalidHandshake {
+		t.Fatalf("expected an unprocessable message error with reason %s, got %v", DeadLetterReasonInvalidHandshake, err)
+	}
+
+	expectedTopic := topicBuilder.BuildOutgoingControlTopic(clientID)
+	if len(mqttClient.publishedTopics) != 1 || mqttClient.publishedTopics[0] != expectedTopic {
+		t.Fatalf("expected a reconnect message to be published to %s, got %v", expectedTopic, mqttClient.publishedTopics)
+	}
+}
+
+func TestInvalidOfflineHandshakeDoesNotSendReconnectMessage(t *testing.T) {
+
+	var cfg config.Config
+	var topicBuilder = mqtt.NewTopicBuilder("redhat")
+	var clientID domain.ClientID = "1234"
+	var mqttClient = &mockMqttClient{}
+
+	controlMessageHandler := HandleControlMessage(&cfg, nil, topicBuilder, nil, nil, nil, nil, nil, nil, nil)
+
+	err := controlMessageHandler(mqttClient, clientID, `{"type": "connection-status", "message_id": "5678", "version": 1, "content": {"state": "offline", "client_name": 42}}`)
+
+	reason, unprocessable := IsUnprocessableMessageError(err)
+	if unprocessable == false || reason != DeadLetterReasonInvalidHandshake {
+		t.Fatalf("expected an unprocessable message error with reason %s, got %v", DeadLetterReasonInvalidHandshake, err)
+	}
+
+	if len(mqttClient.publishedTopics) != 0 {
+		t.Fatalf("expected no reconnect message to be published, got %v", mqttClient.publishedTopics)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 
 	"github.com/RedHatInsights/cloud-connector/internal/cloud_connector/protocol"
 	"github.com/RedHatInsights/cloud-connector/internal/config"
@@ -14,6 +15,7 @@ import (
 	"github.com/RedHatInsights/cloud-connector/internal/platform/logger"
 
 	MQTT "github.com/eclipse/paho.mqtt.golang"
+	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
 )
 
@@ -22,9 +24,6 @@ var (
 )
 
 const (
-	canonicalFactsKey           = "canonical_facts"
-	dispatchersKey              = "dispatchers"
-	tagsKey                     = "tags"
 	catalogDispatcherKey        = "catalog"
 	catalogApplicationType      = "ApplicationType"
 	catalogSourceName           = "SrcName"
@@ -37,7 +36,9 @@ const (
 // The returned function should only return an error in the case where the
 // message should get processed again.  In other words, if the message
 // processing function returns an error ...do not commit the kafka message.
-func HandleControlMessage(cfg *config.Config, mqttClient MQTT.Client, topicBuilder *mqtt.TopicBuilder, connectionRegistrar connection_repository.ConnectionRegistrar, accountResolver controller.AccountIdResolver, connectedClientRecorder controller.ConnectedClientRecorder, sourcesRecorder controller.SourcesRecorder) func(MQTT.Client, domain.ClientID, string) error {
+// The one exception is UnprocessableMessageError, which is returned for messages
+// that can never be processed and should be sent to the dead letter topic.
+func HandleControlMessage(cfg *config.Config, mqttClient MQTT.Client, topicBuilder *mqtt.TopicBuilder, connectionRegistrar connection_repository.ConnectionRegistrar, accountResolver controller.AccountIdResolver, connectedClientRecorder controller.ConnectedClientRecorder, sourcesRecorder controller.SourcesRecorder, messageEventRecorder connection_repository.MessageEventRecorder, pendingMessageStore connection_repository.PendingMessageStore, connectionEventRecorder connection_repository.ConnectionEventRecorder) func(MQTT.Client, domain.ClientID, string) error {
 
 	return func(client MQTT.Client, clientID domain.ClientID, payload string) error {
 
@@ -51,11 +52,16 @@ func HandleControlMessage(cfg *config.Config, mqttClient MQTT.Client, topicBuild
 			return nil
 		}
 
-		var controlMsg protocol.ControlMessage
+		controlMsg, err := protocol.DecodeControlMessage([]byte(payload))
+		if err != nil {
+			var validationErr *protocol.SchemaValidationError
+			if errors.As(err, &validationErr) {
+				logger.WithFields(logrus.Fields{"error": err, "field_errors": validationErr.FieldErrors}).Error("Control message does not match the schema")
+				return UnprocessableMessageError{Reason: DeadLetterReasonSchemaValidation, Err: err}
+			}
 
-		if err := json.Unmarshal([]byte(payload), &controlMsg); err != nil {
 			logger.WithFields(logrus.Fields{"error": err}).Error("Failed to unmarshal control message")
-			return nil
+			return UnprocessableMessageError{Reason: DeadLetterReasonUnmarshalFailure, Err: err}
 		}
 
 		logger = logger.WithFields(logrus.Fields{"message_id": controlMsg.MessageID})
@@ -63,45 +69,38 @@ func HandleControlMessage(cfg *config.Config, mqttClient MQTT.Client, topicBuild
 		logger.Debug("Got a control message:", controlMsg)
 
 		switch controlMsg.MessageType {
-		case "connection-status":
-			return handleConnectionStatusMessage(logger, client, clientID, controlMsg, cfg, topicBuilder, connectionRegistrar, accountResolver, connectedClientRecorder, sourcesRecorder)
-		case "event":
-			return handleEventMessage(logger, client, clientID, controlMsg)
+		case protocol.ConnectionStatusMessageType:
+			return handleConnectionStatusMessage(logger, client, clientID, controlMsg, cfg, topicBuilder, connectionRegistrar, accountResolver, connectedClientRecorder, sourcesRecorder, pendingMessageStore, connectionEventRecorder)
+		case protocol.EventMessageType:
+			return handleEventMessage(logger, client, clientID, controlMsg, connectionRegistrar, messageEventRecorder)
 		default:
 			logger.Debug("Received an invalid message type:", controlMsg.MessageType)
-			return nil
+			return UnprocessableMessageError{Reason: DeadLetterReasonUnknownMessageType, Err: fmt.Errorf("unknown message type %q", controlMsg.MessageType)}
 		}
 	}
 }
 
-func handleConnectionStatusMessage(logger *logrus.Entry, client MQTT.Client, clientID domain.ClientID, msg protocol.ControlMessage, cfg *config.Config, topicBuilder *mqtt.TopicBuilder, connectionRegistrar connection_repository.ConnectionRegistrar, accountResolver controller.AccountIdResolver, connectedClientRecorder controller.ConnectedClientRecorder, sourcesRecorder controller.SourcesRecorder) error {
+func handleConnectionStatusMessage(logger *logrus.Entry, client MQTT.Client, clientID domain.ClientID, msg protocol.ControlMessage, cfg *config.Config, topicBuilder *mqtt.TopicBuilder, connectionRegistrar connection_repository.ConnectionRegistrar, accountResolver controller.AccountIdResolver, connectedClientRecorder controller.ConnectedClientRecorder, sourcesRecorder controller.SourcesRecorder, pendingMessageStore connection_repository.PendingMessageStore, connectionEventRecorder connection_repository.ConnectionEventRecorder) error {
 
 	logger.Debug("handling connection status control message")
 
-	handshakePayload := msg.Content.(map[string]interface{})
-
-	connectionState, gotConnectionState := handshakePayload["state"]
+	handshake, err := validateHandshake(cfg, msg)
+	if err != nil {
+		var handshakeErr InvalidHandshakeError
+		if errors.As(err, &handshakeErr) == false {
+			logger.WithFields(logrus.Fields{"error": err}).Error("Unable to validate handshake")
+			return UnprocessableMessageError{Reason: DeadLetterReasonInvalidHandshake, Err: err}
+		}
 
-	if gotConnectionState == false {
-		logger.Debug("Client did not send the connection state as part of the online message")
-		// For now, ignore the invalid message.  In the future, maybe ask the client
-		// to resend that online message?
-		return nil
+		return rejectInvalidHandshake(logger, client, clientID, msg, cfg, topicBuilder, handshakeErr)
 	}
 
-	clientName, _ := protocol.GetClientVersionFromConnectionStatusContent(handshakePayload)
-	clientVersion, _ := protocol.GetClientNameFromConnectionStatusContent(handshakePayload)
-
-	logger = logger.WithFields(logrus.Fields{"client_name": clientName, "client_version": clientVersion})
+	logger = logger.WithFields(logrus.Fields{"client_name": handshake.ClientName, "client_version": handshake.ClientVersion})
 
-	var err error
-	if connectionState == "online" {
-		err = handleOnlineMessage(logger, client, clientID, msg, cfg, topicBuilder, accountResolver, connectionRegistrar, connectedClientRecorder, sourcesRecorder)
-	} else if connectionState == "offline" {
-		err = handleOfflineMessage(logger, client, clientID, msg, connectionRegistrar)
+	if handshake.ConnectionState == domain.ConnectionOnline {
+		err = handleOnlineMessage(logger, client, clientID, msg, handshake, cfg, topicBuilder, accountResolver, connectionRegistrar, connectedClientRecorder, sourcesRecorder, pendingMessageStore, connectionEventRecorder)
 	} else {
-		logger.Debug("Invalid connection state from connection-status message.")
-		return nil
+		err = handleOfflineMessage(logger, client, clientID, msg, handshake, connectionRegistrar, connectionEventRecorder)
 	}
 
 	if err == errDuplicateOrOldMQTTMessage {
@@ -112,12 +111,14 @@ func handleConnectionStatusMessage(logger *logrus.Entry, client MQTT.Client, cli
 	return err
 }
 
-func handleOnlineMessage(logger *logrus.Entry, client MQTT.Client, clientID domain.ClientID, msg protocol.ControlMessage, cfg *config.Config, topicBuilder *mqtt.TopicBuilder, accountResolver controller.AccountIdResolver, connectionRegistrar connection_repository.ConnectionRegistrar, connectedClientRecorder controller.ConnectedClientRecorder, sourcesRecorder controller.SourcesRecorder) error {
+func handleOnlineMessage(logger *logrus.Entry, client MQTT.Client, clientID domain.ClientID, msg protocol.ControlMessage, handshake protocol.ConnectionStatusMessageContent, cfg *config.Config, topicBuilder *mqtt.TopicBuilder, accountResolver controller.AccountIdResolver, connectionRegistrar connection_repository.ConnectionRegistrar, connectedClientRecorder controller.ConnectedClientRecorder, sourcesRecorder controller.SourcesRecorder, pendingMessageStore connection_repository.PendingMessageStore, connectionEventRecorder connection_repository.ConnectionEventRecorder) error {
 
 	logger.Debug("handling online connection-status message")
 
 	ctx := context.Background()
 
+	// This check avoids the tenant lookup for duplicate and old messages.  The registrar
+	// makes the final decision atomically when the connection is registered.
 	err := checkForDuplicateOnlineMessage(logger, ctx, connectionRegistrar, clientID, msg)
 	if err != nil {
 		return err
@@ -134,21 +135,26 @@ func handleOnlineMessage(logger *logrus.Entry, client MQTT.Client, clientID doma
 
 	logger = logger.WithFields(logrus.Fields{"account": account, "org_id": orgID})
 
-	handshakePayload := msg.Content.(map[string]interface{})
-
 	rhcClient := domain.ConnectorClientState{ClientID: clientID,
 		Account:        account,
 		OrgID:          orgID,
-		Dispatchers:    handshakePayload[dispatchersKey],
-		CanonicalFacts: sanitizeCanonicalFacts(handshakePayload[canonicalFactsKey]),
-		Tags:           handshakePayload[tagsKey],
+		Dispatchers:    dispatchersToMap(handshake.Dispatchers),
+		CanonicalFacts: sanitizeCanonicalFacts(canonicalFactsToMap(handshake.CanonicalFacts)),
+		Tags:           tagsToMap(handshake.Tags),
 		MessageMetadata: domain.MessageMetadata{LatestMessageID: msg.MessageID,
 			LatestTimestamp: msg.Sent},
 		TenantLookupFailureCount: 0, // Explicitly set the tenant lookup failure count to zero
+		ClientName:               handshake.ClientName,
+		ClientVersion:            handshake.ClientVersion,
 	}
 
 	err = connectionRegistrar.Register(context.Background(), rhcClient)
 	if err != nil {
+		if errors.Is(err, connection_repository.StaleConnectionStateError) {
+			logger.Debug("ignoring message - the connection was registered using a newer message")
+			return errDuplicateOrOldMQTTMessage
+		}
+
 		// If the error is fatal, then "bubble" the error up a level so it can be handled
 		if errors.As(err, &connection_repository.FatalError{}) {
 			return err
@@ -156,6 +162,16 @@ func handleOnlineMessage(logger *logrus.Entry, client MQTT.Client, clientID doma
 		return nil
 	}
 
+	err = recordConnectionEvent(logger, connectionEventRecorder, domain.ConnectionOnline, orgID, account, clientID, msg, handshake)
+	if err != nil {
+		return err
+	}
+
+	err = deliverPendingMessages(logger, client, cfg, topicBuilder, pendingMessageStore, orgID, clientID)
+	if err != nil {
+		return err
+	}
+
 	err = connectedClientRecorder.RecordConnectedClient(context.Background(), identity, rhcClient)
 	if err != nil {
 		logger.WithFields(logrus.Fields{"error": err}).Error("Failed to record client id within the platform")
@@ -164,7 +180,61 @@ func handleOnlineMessage(logger *logrus.Entry, client MQTT.Client, clientID doma
 		return nil
 	}
 
-	processDispatchers(logger, sourcesRecorder, identity, account, orgID, clientID, handshakePayload)
+	processDispatchers(logger, sourcesRecorder, identity, account, orgID, clientID, handshake.Dispatchers)
+
+	return nil
+}
+
+// deliverPendingMessages sends the messages that were queued while the client was offline.
+// Only a fatal error is returned.  A message that could not be published is left
+// in place so that delivery can be attempted again the next time the client comes online.
+func deliverPendingMessages(logger *logrus.Entry, client MQTT.Client, cfg *config.Config, topicBuilder *mqtt.TopicBuilder, pendingMessageStore connection_repository.PendingMessageStore, orgID domain.OrgID, clientID domain.ClientID) error {
+
+	if len(orgID) == 0 {
+		// Messages are queued for an org...a tenant-less connection cannot receive them
+		return nil
+	}
+
+	ctx := context.Background()
+
+	pendingMessages, err := pendingMessageStore.GetPendingMessages(ctx, orgID, clientID)
+	if err != nil {
+		logger.WithFields(logrus.Fields{"error": err}).Error("Unable to lookup pending messages")
+		if errors.As(err, &connection_repository.FatalError{}) {
+			return err
+		}
+		return nil
+	}
+
+	for _, pendingMessage := range pendingMessages {
+
+		messageLogger := logger.WithFields(logrus.Fields{"message_id": pendingMessage.MessageID, "directive": pendingMessage.Directive})
+
+		messageID, err := uuid.Parse(pendingMessage.MessageID)
+		if err != nil {
+			messageLogger.WithFields(logrus.Fields{"error": err}).Error("Invalid message id for pending message")
+			continue
+		}
+
+		err = mqtt.SendDataMessageToClient(client, messageLogger, topicBuilder, cfg.MqttDataPublishQoS, cfg.MqttPublishTimeout, cfg.MqttDataMessageExpiry, clientID, messageID, pendingMessage.Directive, pendingMessage.Metadata, pendingMessage.Payload)
+		if err != nil {
+			messageLogger.WithFields(logrus.Fields{"error": err}).Error("Unable to deliver pending message")
+			continue
+		}
+
+		err = pendingMessageStore.MarkPendingMessageDelivered(ctx, pendingMessage.MessageID)
+		if err != nil {
+			messageLogger.WithFields(logrus.Fields{"error": err}).Error("Unable to mark pending message as delivered")
+			if errors.As(err, &connection_repository.FatalError{}) {
+				return err
+			}
+			continue
+		}
+
+		metrics.pendingMessageDeliveredCounter.Inc()
+
+		messageLogger.Info("Delivered pending message")
+	}
 
 	return nil
 }
@@ -208,10 +278,9 @@ func isDuplicateOrOldMessage(currentConnectionState domain.ConnectorClientState,
 	return false
 }
 
-func processDispatchers(logger *logrus.Entry, sourcesRecorder controller.SourcesRecorder, identity domain.Identity, account domain.AccountID, orgID domain.OrgID, clientId domain.ClientID, handshakePayload map[string]interface{}) {
-	dispatchers, gotDispatchers := handshakePayload[dispatchersKey]
+func processDispatchers(logger *logrus.Entry, sourcesRecorder controller.SourcesRecorder, identity domain.Identity, account domain.AccountID, orgID domain.OrgID, clientId domain.ClientID, dispatchers protocol.Dispatchers) {
 
-	if gotDispatchers == false {
+	if dispatchers == nil {
 		logger.Debug("No dispatchers found")
 		return
 	} else {
@@ -221,21 +290,17 @@ func processDispatchers(logger *logrus.Entry, sourcesRecorder controller.Sources
 		}
 	}
 
-	dispatchersMap := dispatchers.(map[string]interface{})
-
-	catalog, gotCatalog := dispatchersMap[catalogDispatcherKey]
+	catalogMap, gotCatalog := dispatchers[catalogDispatcherKey]
 
 	if gotCatalog == false {
 		logger.Debug("No catalog dispatcher found")
 		return
 	}
 
-	catalogMap := catalog.(map[string]interface{})
-
-	applicationType, gotApplicationType := catalogMap[catalogApplicationType]
-	sourceType, gotSourceType := catalogMap[catalogSourceType]
-	sourceRef, gotSourceRef := catalogMap[catalogSourceRef]
-	sourceName, gotSourceName := catalogMap[catalogSourceName]
+	applicationType, gotApplicationType := catalogMap[catalogApplicationType].(string)
+	sourceType, gotSourceType := catalogMap[catalogSourceType].(string)
+	sourceRef, gotSourceRef := catalogMap[catalogSourceRef].(string)
+	sourceName, gotSourceName := catalogMap[catalogSourceName].(string)
 
 	if gotApplicationType != true || gotSourceType != true || gotSourceRef != true || gotSourceName != true {
 		// MISSING FIELDS
@@ -243,24 +308,129 @@ func processDispatchers(logger *logrus.Entry, sourcesRecorder controller.Sources
 		return
 	}
 
-	err := sourcesRecorder.RegisterWithSources(identity, account, orgID, clientId, sourceRef.(string), sourceName.(string), sourceType.(string), applicationType.(string))
+	err := sourcesRecorder.RegisterWithSources(identity, account, orgID, clientId, sourceRef, sourceName, sourceType, applicationType)
 	if err != nil {
 		logger.WithFields(logrus.Fields{"error": err}).Error("Failed to register catalog with sources")
 	}
 }
 
-func handleOfflineMessage(logger *logrus.Entry, client MQTT.Client, clientID domain.ClientID, msg protocol.ControlMessage, connectionRegistrar connection_repository.ConnectionRegistrar) error {
+func handleOfflineMessage(logger *logrus.Entry, client MQTT.Client, clientID domain.ClientID, msg protocol.ControlMessage, handshake protocol.ConnectionStatusMessageContent, connectionRegistrar connection_repository.ConnectionRegistrar, connectionEventRecorder connection_repository.ConnectionEventRecorder) error {
 	logger.Debug("handling offline connection-status message")
 
-	err := connectionRegistrar.Unregister(context.Background(), clientID)
+	ctx := context.Background()
+
+	// Look up the connection before it is removed so that the offline event
+	// can be recorded against the org that owned the connection
+	connectionState, err := connectionRegistrar.FindConnectionByClientID(ctx, clientID)
+	if err != nil {
+		if errors.As(err, &connection_repository.FatalError{}) {
+			return err
+		}
+
+		logger.WithFields(logrus.Fields{"error": err}).Debug("Unable to locate connection for offline message")
+	}
+
+	latestMessage := domain.MessageMetadata{LatestMessageID: msg.MessageID, LatestTimestamp: msg.Sent}
+
+	err = connectionRegistrar.Unregister(ctx, clientID, latestMessage)
+	if errors.Is(err, connection_repository.NotFoundError) {
+		logger.Debug("No connection to unregister...the client id is unknown or the offline message is a duplicate")
+		return nil
+	}
+
 	if errors.As(err, &connection_repository.FatalError{}) {
 		return err
 	}
 
+	if len(connectionState.OrgID) == 0 {
+		// The event could never be read...the connection history is looked up by org
+		logger.Info("No connection owns the client id.  Not recording offline event.")
+		return nil
+	}
+
+	return recordConnectionEvent(logger, connectionEventRecorder, domain.ConnectionOffline, connectionState.OrgID, connectionState.Account, clientID, msg, handshake)
+}
+
+// recordConnectionEvent adds the connection-status message to the connection's history.
+// Only a fatal error is returned.
+func recordConnectionEvent(logger *logrus.Entry, connectionEventRecorder connection_repository.ConnectionEventRecorder, state string, orgID domain.OrgID, account domain.AccountID, clientID domain.ClientID, msg protocol.ControlMessage, handshake protocol.ConnectionStatusMessageContent) error {
+
+	event := domain.ConnectionEvent{
+		OrgID:         orgID,
+		Account:       account,
+		ClientID:      clientID,
+		State:         state,
+		MessageID:     msg.MessageID,
+		Sent:          msg.Sent,
+		ClientName:    handshake.ClientName,
+		ClientVersion: handshake.ClientVersion,
+	}
+
+	err := connectionEventRecorder.RecordConnectionEvent(context.Background(), event)
+	if err != nil {
+		logger.WithFields(logrus.Fields{"error": err}).Error("Failed to record connection event")
+
+		if errors.As(err, &connection_repository.FatalError{}) {
+			return err
+		}
+	}
+
 	return nil
 }
 
-func handleEventMessage(logger *logrus.Entry, client MQTT.Client, clientID domain.ClientID, msg protocol.ControlMessage) error {
+func handleEventMessage(logger *logrus.Entry, client MQTT.Client, clientID domain.ClientID, msg protocol.ControlMessage, connectionRegistrar connection_repository.ConnectionRegistrar, messageEventRecorder connection_repository.MessageEventRecorder) error {
 	logger.Debugf("Received an event message from client: %v\n", msg)
+
+	if _, err := protocol.DecodeEventMessageContent(msg); err != nil {
+		logger.WithFields(logrus.Fields{"error": err}).Error("Event message does not match the schema")
+		return UnprocessableMessageError{Reason: DeadLetterReasonSchemaValidation, Err: err}
+	}
+
+	if len(msg.ResponseTo) == 0 {
+		logger.Debug("Event message is not a response to a message.  Ignoring event.")
+		return nil
+	}
+
+	logger = logger.WithFields(logrus.Fields{"response_to": msg.ResponseTo})
+
+	ctx := context.Background()
+
+	// The event is recorded against the org that currently owns the connection
+	// so that the event can only be read by that org
+	connectionState, err := connectionRegistrar.FindConnectionByClientID(ctx, clientID)
+	if err != nil {
+		if errors.As(err, &connection_repository.FatalError{}) {
+			return err
+		}
+
+		logger.WithFields(logrus.Fields{"error": err}).Debug("Unable to locate connection for event message")
+	}
+
+	if len(connectionState.OrgID) == 0 {
+		// The event could never be read...the events are looked up by org
+		logger.Info("No connection owns the client id.  Ignoring event.")
+		return nil
+	}
+
+	event := domain.MessageEvent{
+		OrgID:      connectionState.OrgID,
+		ClientID:   clientID,
+		MessageID:  msg.MessageID,
+		ResponseTo: msg.ResponseTo,
+		Content:    msg.Content,
+		Sent:       msg.Sent,
+	}
+
+	err = messageEventRecorder.RecordMessageEvent(ctx, event)
+	if err != nil {
+		// If the error is fatal, then "bubble" the error up a level so it can be handled
+		if errors.As(err, &connection_repository.FatalError{}) {
+			return err
+		}
+		return nil
+	}
+
+	metrics.eventMessageRecordedCounter.Inc()
+
 	return nil
 }
@@ -9,6 +9,7 @@ import (
 
 	"github.com/RedHatInsights/cloud-connector/internal/cloud_connector/protocol"
 	"github.com/RedHatInsights/cloud-connector/internal/config"
+	"github.com/RedHatInsights/cloud-connector/internal/connection_repository"
 	"github.com/RedHatInsights/cloud-connector/internal/controller"
 	"github.com/RedHatInsights/cloud-connector/internal/domain"
 	"github.com/RedHatInsights/cloud-connector/internal/mqtt"
@@ -31,7 +32,11 @@ func (mcr *mockConnectionRegistrar) Register(ctx context.Context, rhcClient doma
 	return nil
 }
 
-func (mcr *mockConnectionRegistrar) Unregister(ctx context.Context, clientID domain.ClientID) error {
+func (mcr *mockConnectionRegistrar) Unregister(ctx context.Context, clientID domain.ClientID, latestMessage domain.MessageMetadata) error {
+	return nil
+}
+
+func (mcr *mockConnectionRegistrar) UnregisterIfUnchanged(ctx context.Context, clientID domain.ClientID, latestMessage domain.MessageMetadata) error {
 	return nil
 }
 
@@ -39,6 +44,16 @@ func (mcr *mockConnectionRegistrar) FindConnectionByClientID(ctx context.Context
 	return mcr.clients[clientID], nil
 }
 
+// mockStaleConnectionRegistrar simulates the database rejecting a registration
+// because the connection was registered using a newer message
+type mockStaleConnectionRegistrar struct {
+	mockConnectionRegistrar
+}
+
+func (mscr *mockStaleConnectionRegistrar) Register(ctx context.Context, rhcClient domain.ConnectorClientState) error {
+	return connection_repository.StaleConnectionStateError
+}
+
 type mockAccountIdResolver struct {
 	accounts map[domain.ClientID]domain.AccountID
 }
@@ -61,6 +76,66 @@ func (this *mockConnectedClientRecorder) RecordConnectedClient(ctx context.Conte
 	return nil
 }
 
+type mockMessageEventRecorder struct {
+	events []domain.MessageEvent
+}
+
+func (this *mockMessageEventRecorder) RecordMessageEvent(ctx context.Context, event domain.MessageEvent) error {
+	this.events = append(this.events, event)
+	return nil
+}
+
+type mockPendingMessageStore struct {
+	pendingMessages   []domain.PendingMessage
+	deliveredMessages []string
+}
+
+func (this *mockPendingMessageStore) StorePendingMessage(ctx context.Context, message domain.PendingMessage) error {
+	this.pendingMessages = append(this.pendingMessages, message)
+	return nil
+}
+
+func (this *mockPendingMessageStore) GetPendingMessages(ctx context.Context, orgID domain.OrgID, clientID domain.ClientID) ([]domain.PendingMessage, error) {
+	pendingMessages := []domain.PendingMessage{}
+	for _, message := range this.pendingMessages {
+		if message.OrgID == orgID && message.ClientID == clientID {
+			pendingMessages = append(pendingMessages, message)
+		}
+	}
+	return pendingMessages, nil
+}
+
+func (this *mockPendingMessageStore) MarkPendingMessageDelivered(ctx context.Context, messageID string) error {
+	this.deliveredMessages = append(this.deliveredMessages, messageID)
+	return nil
+}
+
+type mockConnectionEventRecorder struct {
+	events []domain.ConnectionEvent
+}
+
+func (this *mockConnectionEventRecorder) RecordConnectionEvent(ctx context.Context, event domain.ConnectionEvent) error {
+	this.events = append(this.events, event)
+	return nil
+}
+
+type mockPublishToken struct {
+	MQTT.Token
+}
+
+func (this *mockPublishToken) WaitTimeout(time.Duration) bool { return true }
+func (this *mockPublishToken) Error() error                   { return nil }
+
+type mockMqttClient struct {
+	MQTT.Client
+	publishedTopics []string
+}
+
+func (this *mockMqttClient) Publish(topic string, qos byte, retained bool, payload interface{}) MQTT.Token {
+	this.publishedTopics = append(this.publishedTopics, topic)
+	return &mockPublishToken{}
+}
+
 func TestHandleOnlineMessagesNoExistingConnection(t *testing.T) {
 
 	var mqttClient MQTT.Client
@@ -73,12 +148,14 @@ func TestHandleOnlineMessagesNoExistingConnection(t *testing.T) {
 	}
 	var connectedClientRecorder = &mockConnectedClientRecorder{}
 	var sourcesRecorder controller.SourcesRecorder
+	var pendingMessageStore = &mockPendingMessageStore{}
+	var connectionEventRecorder = &mockConnectionEventRecorder{}
 
 	incomingMessage := buildOnlineMessage(t, "56789", time.Now())
 
 	logger := logger.Log.WithFields(logrus.Fields{"clientID": clientID, "messageID": incomingMessage.MessageID})
 
-	err := handleOnlineMessage(logger, mqttClient, clientID, incomingMessage, &cfg, &topicBuilder, accountResolver, connectionRegistrar, connectedClientRecorder, sourcesRecorder)
+	err := handleOnlineMessage(logger, mqttClient, clientID, incomingMessage, decodeHandshake(t, incomingMessage), &cfg, &topicBuilder, accountResolver, connectionRegistrar, connectedClientRecorder, sourcesRecorder, pendingMessageStore, connectionEventRecorder)
 
 	if err != nil {
 		t.Fatal("handleOnlineMessage should not have returned an error")
@@ -91,6 +168,44 @@ func TestHandleOnlineMessagesNoExistingConnection(t *testing.T) {
 	}
 }
 
+func TestHandleOnlineMessagesStoresClientNameAndVersion(t *testing.T) {
+
+	var mqttClient MQTT.Client
+	var clientID domain.ClientID = "1234"
+	var cfg config.Config
+	var topicBuilder mqtt.TopicBuilder
+	var accountResolver = &mockAccountIdResolver{}
+	var connectionRegistrar = &mockConnectionRegistrar{
+		clients: make(map[domain.ClientID]domain.ConnectorClientState),
+	}
+	var connectedClientRecorder = &mockConnectedClientRecorder{}
+	var sourcesRecorder controller.SourcesRecorder
+	var pendingMessageStore = &mockPendingMessageStore{}
+	var connectionEventRecorder = &mockConnectionEventRecorder{}
+
+	incomingMessage := buildOnlineMessage(t, "56789", time.Now())
+	incomingMessage.Content.(map[string]interface{})["client_name"] = "rhc"
+	incomingMessage.Content.(map[string]interface{})["client_version"] = "0.2.4"
+
+	logger := logger.Log.WithFields(logrus.Fields{"clientID": clientID, "messageID": incomingMessage.MessageID})
+
+	err := handleOnlineMessage(logger, mqttClient, clientID, incomingMessage, decodeHandshake(t, incomingMessage), &cfg, &topicBuilder, accountResolver, connectionRegistrar, connectedClientRecorder, sourcesRecorder, pendingMessageStore, connectionEventRecorder)
+
+	if err != nil {
+		t.Fatal("handleOnlineMessage should not have returned an error")
+	}
+
+	recordedConnectionState, _ := connectionRegistrar.FindConnectionByClientID(context.Background(), clientID)
+
+	if recordedConnectionState.ClientName != "rhc" {
+		t.Errorf("unexpected client name stored: %s", recordedConnectionState.ClientName)
+	}
+
+	if recordedConnectionState.ClientVersion != "0.2.4" {
+		t.Errorf("unexpected client version stored: %s", recordedConnectionState.ClientVersion)
+	}
+}
+
 func TestHandleOnlineMessagesUpdateExistingConnection(t *testing.T) {
 
 	var mqttClient MQTT.Client
@@ -103,6 +218,8 @@ func TestHandleOnlineMessagesUpdateExistingConnection(t *testing.T) {
 	}
 	var connectedClientRecorder = &mockConnectedClientRecorder{}
 	var sourcesRecorder controller.SourcesRecorder
+	var pendingMessageStore = &mockPendingMessageStore{}
+	var connectionEventRecorder = &mockConnectionEventRecorder{}
 
 	var connectionState = domain.ConnectorClientState{
 		Account:  "000111",
@@ -124,7 +241,7 @@ func TestHandleOnlineMessagesUpdateExistingConnection(t *testing.T) {
 
 	logger := logger.Log.WithFields(logrus.Fields{"clientID": clientID, "messageID": incomingMessage.MessageID})
 
-	err := handleOnlineMessage(logger, mqttClient, clientID, incomingMessage, &cfg, &topicBuilder, accountResolver, connectionRegistrar, connectedClientRecorder, sourcesRecorder)
+	err := handleOnlineMessage(logger, mqttClient, clientID, incomingMessage, decodeHandshake(t, incomingMessage), &cfg, &topicBuilder, accountResolver, connectionRegistrar, connectedClientRecorder, sourcesRecorder, pendingMessageStore, connectionEventRecorder)
 
 	if err != nil {
 		t.Fatal("handleOnlineMessage should not have returned an error")
@@ -151,6 +268,8 @@ func TestHandleDuplicateAndOldOnlineMessages(t *testing.T) {
 	var accountResolver = &mockAccountIdResolver{}
 	var connectedClientRecorder = &mockConnectedClientRecorder{}
 	var sourcesRecorder controller.SourcesRecorder
+	var pendingMessageStore = &mockPendingMessageStore{}
+	var connectionEventRecorder = &mockConnectionEventRecorder{}
 
 	now := time.Now()
 
@@ -192,7 +311,7 @@ func TestHandleDuplicateAndOldOnlineMessages(t *testing.T) {
 
 			logger = logger.WithFields(logrus.Fields{"messageID": incomingMessage.MessageID})
 
-			err := handleOnlineMessage(logger, mqttClient, clientID, incomingMessage, &cfg, &topicBuilder, accountResolver, connectionRegistrar, connectedClientRecorder, sourcesRecorder)
+			err := handleOnlineMessage(logger, mqttClient, clientID, incomingMessage, decodeHandshake(t, incomingMessage), &cfg, &topicBuilder, accountResolver, connectionRegistrar, connectedClientRecorder, sourcesRecorder, pendingMessageStore, connectionEventRecorder)
 
 			if err != tc.expectedError {
 				t.Fatal("handleOnlineMesssage did not return the expected error!")
@@ -202,6 +321,32 @@ func TestHandleDuplicateAndOldOnlineMessages(t *testing.T) {
 	}
 }
 
+func TestHandleOnlineMessageRejectedByRegistrar(t *testing.T) {
+
+	var mqttClient MQTT.Client
+	var clientID domain.ClientID = "1234"
+	var cfg config.Config
+	var topicBuilder mqtt.TopicBuilder
+	var accountResolver = &mockAccountIdResolver{}
+	var connectionRegistrar = &mockStaleConnectionRegistrar{
+		mockConnectionRegistrar{clients: make(map[domain.ClientID]domain.ConnectorClientState)},
+	}
+	var connectedClientRecorder = &mockConnectedClientRecorder{}
+	var sourcesRecorder controller.SourcesRecorder
+	var pendingMessageStore = &mockPendingMessageStore{}
+	var connectionEventRecorder = &mockConnectionEventRecorder{}
+
+	incomingMessage := buildOnlineMessage(t, "56789", time.Now())
+
+	logger := logger.Log.WithFields(logrus.Fields{"clientID": clientID, "messageID": incomingMessage.MessageID})
+
+	err := handleOnlineMessage(logger, mqttClient, clientID, incomingMessage, decodeHandshake(t, incomingMessage), &cfg, &topicBuilder, accountResolver, connectionRegistrar, connectedClientRecorder, sourcesRecorder, pendingMessageStore, connectionEventRecorder)
+
+	if err != errDuplicateOrOldMQTTMessage {
+		t.Fatal("handleOnlineMesssage should have treated the message as an old message", err)
+	}
+}
+
 func TestHandleOnlineMessagesFromTenantLessClient(t *testing.T) {
 
 	// Tenant-less test
@@ -212,6 +357,8 @@ func TestHandleOnlineMessagesFromTenantLessClient(t *testing.T) {
 	var accountResolver = &mockAccountIdResolverReturnError{}
 	var connectedClientRecorder = &mockConnectedClientRecorder{}
 	var sourcesRecorder controller.SourcesRecorder
+	var pendingMessageStore = &mockPendingMessageStore{}
+	var connectionEventRecorder = &mockConnectionEventRecorder{}
 
 	now := time.Now()
 
@@ -235,7 +382,7 @@ func TestHandleOnlineMessagesFromTenantLessClient(t *testing.T) {
 
 	logger = logger.WithFields(logrus.Fields{"messageID": incomingMessage.MessageID})
 
-	err := handleOnlineMessage(logger, mqttClient, clientID, incomingMessage, &cfg, &topicBuilder, accountResolver, connectionRegistrar, connectedClientRecorder, sourcesRecorder)
+	err := handleOnlineMessage(logger, mqttClient, clientID, incomingMessage, decodeHandshake(t, incomingMessage), &cfg, &topicBuilder, accountResolver, connectionRegistrar, connectedClientRecorder, sourcesRecorder, pendingMessageStore, connectionEventRecorder)
 
 	if err != nil {
 		t.Fatal("handleOnlineMesssage did not return the expected error!")
@@ -246,6 +393,184 @@ func TestHandleOnlineMessagesFromTenantLessClient(t *testing.T) {
 	}
 }
 
+func TestHandleOnlineMessagesDeliversPendingMessages(t *testing.T) {
+
+	var mqttClient = &mockMqttClient{}
+	var clientID domain.ClientID = "1234"
+	var cfg config.Config
+	var topicBuilder = mqtt.NewTopicBuilder("redhat")
+	var accountResolver = &mockAccountIdResolver{}
+	var connectionRegistrar = &mockConnectionRegistrar{
+		clients: make(map[domain.ClientID]domain.ConnectorClientState),
+	}
+	var connectedClientRecorder = &mockConnectedClientRecorder{}
+	var sourcesRecorder controller.SourcesRecorder
+	var pendingMessageStore = &mockPendingMessageStore{
+		pendingMessages: []domain.PendingMessage{
+			{MessageID: "6f7a5d1e-4c1b-4d63-9cb1-1a4e0e4bdb6a", OrgID: "000001", ClientID: clientID, Directive: "rhc-worker-playbook"},
+			{MessageID: "8d3f9c2b-0e6a-4f3e-b5d4-2c9a7e1f0b3c", OrgID: "000002", ClientID: clientID, Directive: "rhc-worker-playbook"},
+		},
+	}
+
+	var connectionEventRecorder = &mockConnectionEventRecorder{}
+
+	incomingMessage := buildOnlineMessage(t, "56789", time.Now())
+
+	logger := logger.Log.WithFields(logrus.Fields{"clientID": clientID, "messageID": incomingMessage.MessageID})
+
+	err := handleOnlineMessage(logger, mqttClient, clientID, incomingMessage, decodeHandshake(t, incomingMessage), &cfg, topicBuilder, accountResolver, connectionRegistrar, connectedClientRecorder, sourcesRecorder, pendingMessageStore, connectionEventRecorder)
+	if err != nil {
+		t.Fatal("handleOnlineMessage should not have returned an error")
+	}
+
+	// Only the message queued for the org that owns the connection should get delivered
+	if len(pendingMessageStore.deliveredMessages) != 1 || pendingMessageStore.deliveredMessages[0] != "6f7a5d1e-4c1b-4d63-9cb1-1a4e0e4bdb6a" {
+		t.Fatalf("unexpected pending messages delivered: %v", pendingMessageStore.deliveredMessages)
+	}
+
+	if len(mqttClient.publishedTopics) != 1 || mqttClient.publishedTopics[0] != topicBuilder.BuildOutgoingDataTopic(clientID) {
+		t.Fatalf("unexpected topics published to: %v", mqttClient.publishedTopics)
+	}
+}
+
+func TestHandleEventMessages(t *testing.T) {
+
+	var mqttClient MQTT.Client
+	var clientID domain.ClientID = "1234"
+	var connectionRegistrar = &mockConnectionRegistrar{
+		clients: map[domain.ClientID]domain.ConnectorClientState{
+			clientID: {OrgID: "000001", ClientID: clientID},
+		},
+	}
+
+	testCases := []struct {
+		name           string
+		clientID       domain.ClientID
+		responseTo     string
+		expectedEvents int
+	}{
+		{"event in response to a message", clientID, "abcd-1234", 1},
+		{"event not in response to a message", clientID, "", 0},
+		{"event from an unknown connection", "5678", "abcd-1234", 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var messageEventRecorder = &mockMessageEventRecorder{}
+
+			incomingMessage := protocol.ControlMessage{
+				MessageType: "event",
+				MessageID:   "56789",
+				ResponseTo:  tc.responseTo,
+				Version:     1,
+				Sent:        time.Now(),
+				Content:     "running",
+			}
+
+			logger := logger.Log.WithFields(logrus.Fields{"clientID": clientID, "messageID": incomingMessage.MessageID})
+
+			err := handleEventMessage(logger, mqttClient, tc.clientID, incomingMessage, connectionRegistrar, messageEventRecorder)
+			if err != nil {
+				t.Fatal("handleEventMessage should not have returned an error")
+			}
+
+			if len(messageEventRecorder.events) != tc.expectedEvents {
+				t.Fatalf("expected %d recorded events, got %d", tc.expectedEvents, len(messageEventRecorder.events))
+			}
+
+			if tc.expectedEvents > 0 {
+				event := messageEventRecorder.events[0]
+				if event.OrgID != "000001" || event.ResponseTo != tc.responseTo || event.MessageID != incomingMessage.MessageID {
+					t.Errorf("recorded event does not match incoming message: %+v", event)
+				}
+			}
+		})
+	}
+}
+
+func TestConnectionEventsRecordedForOnlineAndOfflineMessages(t *testing.T) {
+
+	var mqttClient MQTT.Client
+	var clientID domain.ClientID = "1234"
+	var cfg config.Config
+	var topicBuilder mqtt.TopicBuilder
+	var accountResolver = &mockAccountIdResolver{}
+	var connectionRegistrar = &mockConnectionRegistrar{
+		clients: make(map[domain.ClientID]domain.ConnectorClientState),
+	}
+	var connectedClientRecorder = &mockConnectedClientRecorder{}
+	var sourcesRecorder controller.SourcesRecorder
+	var pendingMessageStore = &mockPendingMessageStore{}
+	var connectionEventRecorder = &mockConnectionEventRecorder{}
+
+	onlineMessage := buildOnlineMessage(t, "56789", time.Now())
+	onlineMessage.Content.(map[string]interface{})["client_name"] = "rhc"
+	onlineMessage.Content.(map[string]interface{})["client_version"] = "0.2.1"
+
+	logger := logger.Log.WithFields(logrus.Fields{"clientID": clientID})
+
+	err := handleOnlineMessage(logger, mqttClient, clientID, onlineMessage, decodeHandshake(t, onlineMessage), &cfg, &topicBuilder, accountResolver, connectionRegistrar, connectedClientRecorder, sourcesRecorder, pendingMessageStore, connectionEventRecorder)
+	if err != nil {
+		t.Fatal("handleOnlineMessage should not have returned an error")
+	}
+
+	offlineMessage := protocol.ControlMessage{
+		MessageType: "connection-status",
+		MessageID:   "98765",
+		Version:     1,
+		Sent:        time.Now(),
+		Content:     map[string]interface{}{"state": "offline"},
+	}
+
+	err = handleOfflineMessage(logger, mqttClient, clientID, offlineMessage, decodeHandshake(t, offlineMessage), connectionRegistrar, connectionEventRecorder)
+	if err != nil {
+		t.Fatal("handleOfflineMessage should not have returned an error")
+	}
+
+	if len(connectionEventRecorder.events) != 2 {
+		t.Fatalf("expected 2 connection events, got %d", len(connectionEventRecorder.events))
+	}
+
+	onlineEvent := connectionEventRecorder.events[0]
+	if onlineEvent.State != domain.ConnectionOnline || onlineEvent.MessageID != "56789" || onlineEvent.ClientName != "rhc" || onlineEvent.ClientVersion != "0.2.1" {
+		t.Fatalf("unexpected online event: %+v", onlineEvent)
+	}
+
+	offlineEvent := connectionEventRecorder.events[1]
+	if offlineEvent.State != domain.ConnectionOffline || offlineEvent.MessageID != "98765" || offlineEvent.OrgID != onlineEvent.OrgID {
+		t.Fatalf("unexpected offline event: %+v", offlineEvent)
+	}
+}
+
+func TestOfflineMessageFromUnknownConnectionIsNotRecorded(t *testing.T) {
+
+	var mqttClient MQTT.Client
+	var clientID domain.ClientID = "1234"
+	var connectionRegistrar = &mockConnectionRegistrar{
+		clients: make(map[domain.ClientID]domain.ConnectorClientState),
+	}
+	var connectionEventRecorder = &mockConnectionEventRecorder{}
+
+	offlineMessage := protocol.ControlMessage{
+		MessageType: "connection-status",
+		MessageID:   "98765",
+		Version:     1,
+		Sent:        time.Now(),
+		Content:     map[string]interface{}{"state": "offline"},
+	}
+
+	logger := logger.Log.WithFields(logrus.Fields{"clientID": clientID})
+
+	err := handleOfflineMessage(logger, mqttClient, clientID, offlineMessage, decodeHandshake(t, offlineMessage), connectionRegistrar, connectionEventRecorder)
+	if err != nil {
+		t.Fatal("handleOfflineMessage should not have returned an error")
+	}
+
+	if len(connectionEventRecorder.events) != 0 {
+		t.Fatalf("expected no connection events, got %d", len(connectionEventRecorder.events))
+	}
+}
+
 func buildOnlineMessage(t *testing.T, messageID string, sentTime time.Time) protocol.ControlMessage {
 	var connectionStatusPayload = "{\"state\":\"online\"}"
 	content := make(map[string]interface{})
@@ -265,3 +590,12 @@ func buildOnlineMessage(t *testing.T, messageID string, sentTime time.Time) prot
 
 	return incomingMessage
 }
+
+func decodeHandshake(t *testing.T, msg protocol.ControlMessage) protocol.ConnectionStatusMessageContent {
+	handshake, err := protocol.DecodeConnectionStatusMessageContent(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return handshake
+}
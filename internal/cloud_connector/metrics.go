@@ -6,7 +6,13 @@ import (
 )
 
 type kafkaMetrics struct {
-	controlMessageReceivedCounter prometheus.Counter
+	controlMessageReceivedCounter  prometheus.Counter
+	eventMessageRecordedCounter    prometheus.Counter
+	dataMessageReceivedCounter     prometheus.Counter
+	dataMessageForwardedCounter    prometheus.Counter
+	pendingMessageDeliveredCounter prometheus.Counter
+	deadLetterMessageCounter       *prometheus.CounterVec
+	invalidHandshakeCounter        *prometheus.CounterVec
 }
 
 func newKafkaMetrics() *kafkaMetrics {
@@ -17,6 +23,36 @@ func newKafkaMetrics() *kafkaMetrics {
 		Help: "The number of control messages received from the kafka topic",
 	})
 
+	metrics.eventMessageRecordedCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "cloud_connector_event_message_recorded_count",
+		Help: "The number of event messages recorded",
+	})
+
+	metrics.dataMessageReceivedCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "cloud_connector_data_message_received_from_kafka_count",
+		Help: "The number of data messages received from the kafka topic",
+	})
+
+	metrics.dataMessageForwardedCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "cloud_connector_data_message_forwarded_count",
+		Help: "The number of data messages forwarded to a directive's kafka topic",
+	})
+
+	metrics.pendingMessageDeliveredCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "cloud_connector_pending_message_delivered_count",
+		Help: "The number of pending messages delivered to clients when they came online",
+	})
+
+	metrics.deadLetterMessageCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cloud_connector_dead_letter_message_count",
+		Help: "The number of unprocessable messages per reason",
+	}, []string{"reason"})
+
+	metrics.invalidHandshakeCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cloud_connector_invalid_handshake_count",
+		Help: "The number of connection-status messages rejected per reason",
+	}, []string{"reason"})
+
 	return metrics
 }
 
@@ -11,10 +11,6 @@ func GetClientVersionFromConnectionStatusContent(payload map[string]interface{})
 }
 
 func getStringFromContentPayload(fieldName string, payload map[string]interface{}) (string, bool) {
-	data, found := payload[fieldName]
-	if found {
-		return data.(string), found
-	}
-
-	return "", found
+	data, found := payload[fieldName].(string)
+	return data, found
 }
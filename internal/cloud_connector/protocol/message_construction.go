@@ -18,6 +18,13 @@ func BuildReconnectMessage(delay int) (*uuid.UUID, *ControlMessage, error) {
 	return BuildControlMessage("command", &content)
 }
 
+func BuildDisconnectMessage(message string) (*uuid.UUID, *ControlMessage, error) {
+
+	content := CommandMessageContent{Command: "disconnect", Message: message}
+
+	return BuildControlMessage("command", &content)
+}
+
 func BuildControlMessage(messageType string, content *CommandMessageContent) (*uuid.UUID, *ControlMessage, error) {
 
 	messageID, err := uuid.NewRandom()
@@ -43,7 +50,17 @@ func BuildDataMessage(directive string, metadata interface{}, payload interface{
 		return nil, nil, err
 	}
 
-	message := DataMessage{
+	message := BuildDataMessageWithID(messageID, directive, metadata, payload)
+
+	//metrics.sentMessageDirectiveCounter.With(prometheus.Labels{"directive": directive}).Inc()
+
+	return &messageID, message, err
+}
+
+// BuildDataMessageWithID builds a data message using a message id that was
+// handed out earlier, i.e. for a message that was queued for later delivery
+func BuildDataMessageWithID(messageID uuid.UUID, directive string, metadata interface{}, payload interface{}) *DataMessage {
+	return &DataMessage{
 		MessageType: "data",
 		MessageID:   messageID.String(),
 		Version:     1,
@@ -52,8 +69,4 @@ func BuildDataMessage(directive string, metadata interface{}, payload interface{
 		Directive:   directive,
 		Content:     payload,
 	}
-
-	//metrics.sentMessageDirectiveCounter.With(prometheus.Labels{"directive": directive}).Inc()
-
-	return &messageID, &message, err
 }
@@ -0,0 +1,313 @@
+package protocol
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"github.com/santhosh-tekuri/jsonschema/v6/kind"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+const (
+	ConnectionStatusMessageType = "connection-status"
+	CommandMessageType          = "command"
+	EventMessageType            = "event"
+)
+
+const (
+	controlMessageSchema   = "control_message.json"
+	connectionStatusSchema = "connection_status.json"
+	commandSchema          = "command.json"
+	eventSchema            = "event.json"
+)
+
+// The schemas for each version of the protocol live in schemas/v<version>
+//
+//go:embed schemas
+var schemaFiles embed.FS
+
+var schemas = mustCompileSchemas()
+
+var errorPrinter = message.NewPrinter(language.English)
+
+// FieldError describes a single field that does not match the schema.  Field is a
+// JSON pointer to the offending field within the message.
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+func (fe FieldError) String() string {
+	return fmt.Sprintf("%s: %s", fe.Field, fe.Message)
+}
+
+// SchemaValidationError is returned when a message or its content does not match the
+// schema for the message's type and version
+type SchemaValidationError struct {
+	MessageType string
+	Version     int
+	FieldErrors []FieldError
+}
+
+func (e *SchemaValidationError) Error() string {
+	fieldErrors := make([]string, len(e.FieldErrors))
+	for i, fieldError := range e.FieldErrors {
+		fieldErrors[i] = fieldError.String()
+	}
+
+	return fmt.Sprintf("invalid %s message (version %d): %s", e.MessageType, e.Version, strings.Join(fieldErrors, "; "))
+}
+
+// UnknownMessageTypeError is returned when there is no schema for a message type
+var UnknownMessageTypeError = errors.New("unknown message type")
+
+// DecodeControlMessage validates the message envelope against the schema for the
+// message's version and decodes it.  The content is left undecoded.  Use the
+// DecodeXxxMessageContent functions to validate and decode the content.
+func DecodeControlMessage(payload []byte) (ControlMessage, error) {
+
+	var msg ControlMessage
+
+	document, err := jsonschema.UnmarshalJSON(bytes.NewReader(payload))
+	if err != nil {
+		return msg, err
+	}
+
+	envelope, _ := document.(map[string]interface{})
+	messageType, _ := envelope["type"].(string)
+
+	version, err := getMessageVersion(envelope)
+	if err != nil {
+		return msg, &SchemaValidationError{MessageType: messageType, FieldErrors: []FieldError{{Field: "/version", Message: err.Error()}}}
+	}
+
+	if err := validate(document, version, controlMessageSchema, ""); err != nil {
+		return msg, &SchemaValidationError{MessageType: messageType, Version: version, FieldErrors: err}
+	}
+
+	if err := decodeTyped(payload, &msg, true); err != nil {
+		return msg, &SchemaValidationError{MessageType: messageType, Version: version, FieldErrors: err}
+	}
+
+	return msg, nil
+}
+
+func DecodeConnectionStatusMessageContent(msg ControlMessage) (ConnectionStatusMessageContent, error) {
+	var content ConnectionStatusMessageContent
+	err := decodeMessageContent(msg, ConnectionStatusMessageType, connectionStatusSchema, &content)
+	return content, err
+}
+
+func DecodeCommandMessageContent(msg ControlMessage) (CommandMessageContent, error) {
+	var content CommandMessageContent
+	err := decodeMessageContent(msg, CommandMessageType, commandSchema, &content)
+	return content, err
+}
+
+func DecodeEventMessageContent(msg ControlMessage) (EventMessageContent, error) {
+	var content EventMessageContent
+	err := decodeMessageContent(msg, EventMessageType, eventSchema, &content)
+	return content, err
+}
+
+func decodeMessageContent(msg ControlMessage, messageType string, schemaName string, content interface{}) error {
+
+	if msg.MessageType != messageType {
+		return fmt.Errorf("%w: expected %q, got %q", UnknownMessageTypeError, messageType, msg.MessageType)
+	}
+
+	// Round trip the content so that it is validated exactly as it was received
+	serializedContent, err := json.Marshal(msg.Content)
+	if err != nil {
+		return err
+	}
+
+	document, err := jsonschema.UnmarshalJSON(bytes.NewReader(serializedContent))
+	if err != nil {
+		return err
+	}
+
+	if fieldErrors := validate(document, msg.Version, schemaName, "/content"); fieldErrors != nil {
+		return &SchemaValidationError{MessageType: messageType, Version: msg.Version, FieldErrors: fieldErrors}
+	}
+
+	// Newer clients may add fields to the content.  Only the fields that are known
+	// to this version of the schema are checked.
+	if fieldErrors := decodeTyped(serializedContent, content, false); fieldErrors != nil {
+		for i := range fieldErrors {
+			fieldErrors[i].Field = "/content" + fieldErrors[i].Field
+		}
+		return &SchemaValidationError{MessageType: messageType, Version: msg.Version, FieldErrors: fieldErrors}
+	}
+
+	return nil
+}
+
+func getMessageVersion(envelope map[string]interface{}) (int, error) {
+
+	rawVersion, found := envelope["version"]
+	if found == false {
+		return 0, errors.New("missing version")
+	}
+
+	number, isNumber := rawVersion.(json.Number)
+	if isNumber == false {
+		return 0, fmt.Errorf("version must be an integer, got %v", rawVersion)
+	}
+
+	version, err := number.Int64()
+	if err != nil {
+		return 0, fmt.Errorf("version must be an integer, got %v", rawVersion)
+	}
+
+	if _, supported := schemas[int(version)]; supported == false {
+		return 0, fmt.Errorf("unsupported version %d", version)
+	}
+
+	return int(version), nil
+}
+
+func validate(document interface{}, version int, schemaName string, fieldPrefix string) []FieldError {
+
+	versionSchemas, supported := schemas[version]
+	if supported == false {
+		return []FieldError{{Field: "/version", Message: fmt.Sprintf("unsupported version %d", version)}}
+	}
+
+	err := versionSchemas[schemaName].Validate(document)
+	if err == nil {
+		return nil
+	}
+
+	var validationErr *jsonschema.ValidationError
+	if errors.As(err, &validationErr) == false {
+		return []FieldError{{Field: fieldPrefix, Message: err.Error()}}
+	}
+
+	var fieldErrors []FieldError
+	collectFieldErrors(validationErr, fieldPrefix, &fieldErrors)
+
+	return fieldErrors
+}
+
+// collectFieldErrors flattens the tree of validation errors into the errors for the
+// individual fields
+func collectFieldErrors(validationErr *jsonschema.ValidationError, fieldPrefix string, fieldErrors *[]FieldError) {
+
+	if len(validationErr.Causes) == 0 {
+		field := fieldPrefix
+		for _, token := range validationErr.InstanceLocation {
+			field += "/" + token
+		}
+
+		// Report each unknown field individually so that the error points at the field
+		if additionalProperties, isAdditionalProperties := validationErr.ErrorKind.(*kind.AdditionalProperties); isAdditionalProperties {
+			for _, property := range additionalProperties.Properties {
+				*fieldErrors = append(*fieldErrors, FieldError{Field: field + "/" + property, Message: "unknown field"})
+			}
+			return
+		}
+
+		*fieldErrors = append(*fieldErrors, FieldError{Field: field, Message: validationErr.ErrorKind.LocalizedString(errorPrinter)})
+		return
+	}
+
+	for _, cause := range validationErr.Causes {
+		collectFieldErrors(cause, fieldPrefix, fieldErrors)
+	}
+}
+
+// decodeTyped decodes the payload into the typed message and reports type mismatches as
+// field errors.  Fields that are not part of the typed message are only reported when
+// disallowUnknownFields is set.
+func decodeTyped(payload []byte, v interface{}, disallowUnknownFields bool) []FieldError {
+
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	if disallowUnknownFields {
+		decoder.DisallowUnknownFields()
+	}
+
+	err := decoder.Decode(v)
+	if err == nil {
+		if _, err := decoder.Token(); err != io.EOF {
+			return []FieldError{{Field: "", Message: "unexpected data after the message"}}
+		}
+		return nil
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		field := ""
+		if typeErr.Field != "" {
+			field = "/" + strings.ReplaceAll(typeErr.Field, ".", "/")
+		}
+		return []FieldError{{Field: field, Message: fmt.Sprintf("expected %s, got %s", typeErr.Type, typeErr.Value)}}
+	}
+
+	// encoding/json does not export a type for unknown field errors
+	if unknownField, found := strings.CutPrefix(err.Error(), "json: unknown field "); found {
+		field, _ := strconv.Unquote(unknownField)
+		return []FieldError{{Field: "/" + field, Message: "unknown field"}}
+	}
+
+	var parseErr *time.ParseError
+	if errors.As(err, &parseErr) {
+		return []FieldError{{Field: "/sent", Message: parseErr.Error()}}
+	}
+
+	return []FieldError{{Field: "", Message: err.Error()}}
+}
+
+func mustCompileSchemas() map[int]map[string]*jsonschema.Schema {
+
+	compiled := make(map[int]map[string]*jsonschema.Schema)
+
+	versionDirs, err := schemaFiles.ReadDir("schemas")
+	if err != nil {
+		panic(err)
+	}
+
+	for _, versionDir := range versionDirs {
+
+		var version int
+		if _, err := fmt.Sscanf(versionDir.Name(), "v%d", &version); err != nil {
+			panic(fmt.Sprintf("invalid schema directory %q", versionDir.Name()))
+		}
+
+		compiler := jsonschema.NewCompiler()
+		compiled[version] = make(map[string]*jsonschema.Schema)
+
+		for _, schemaName := range []string{controlMessageSchema, connectionStatusSchema, commandSchema, eventSchema} {
+
+			path := "schemas/" + versionDir.Name() + "/" + schemaName
+
+			schemaFile, err := schemaFiles.Open(path)
+			if err != nil {
+				panic(err)
+			}
+
+			document, err := jsonschema.UnmarshalJSON(schemaFile)
+			schemaFile.Close()
+			if err != nil {
+				panic(fmt.Sprintf("invalid schema %s: %s", path, err))
+			}
+
+			if err := compiler.AddResource(path, document); err != nil {
+				panic(err)
+			}
+
+			compiled[version][schemaName] = compiler.MustCompile(path)
+		}
+	}
+
+	return compiled
+}
@@ -0,0 +1,220 @@
+package protocol
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestDecodeControlMessage(t *testing.T) {
+
+	testCases := []struct {
+		testName      string
+		payload       string
+		expectedField string
+	}{
+		{"valid message", `{"type": "connection-status", "message_id": "5678", "version": 1, "sent": "2021-01-12T15:30:08+00:00", "content": {}}`, ""},
+		{"missing type", `{"message_id": "5678", "version": 1, "content": {}}`, ""},
+		{"invalid type", `{"type": 42, "message_id": "5678", "version": 1, "content": {}}`, "/type"},
+		{"invalid message id", `{"type": "event", "message_id": 5678, "version": 1, "content": "RECEIVED"}`, "/message_id"},
+		{"missing version", `{"type": "event", "message_id": "5678", "content": "RECEIVED"}`, "/version"},
+		{"unsupported version", `{"type": "event", "message_id": "5678", "version": 99, "content": "RECEIVED"}`, "/version"},
+		{"fractional version", `{"type": "event", "message_id": "5678", "version": 1.5, "content": "RECEIVED"}`, "/version"},
+		{"invalid sent timestamp", `{"type": "event", "message_id": "5678", "version": 1, "sent": "yesterday", "content": "RECEIVED"}`, "/sent"},
+		{"not an object", `["connection-status"]`, "/version"},
+		{"unknown field", `{"type": "event", "message_id": "5678", "version": 1, "content": "RECEIVED", "fred": "wilma"}`, "/fred"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+
+			msg, err := DecodeControlMessage([]byte(tc.payload))
+
+			if tc.testName == "valid message" {
+				if err != nil {
+					t.Fatalf("expected a valid message, got %s", err)
+				}
+
+				if msg.MessageType != ConnectionStatusMessageType || msg.MessageID != "5678" || msg.Version != 1 {
+					t.Fatalf("message was not decoded correctly: %+v", msg)
+				}
+				return
+			}
+
+			var validationErr *SchemaValidationError
+			if errors.As(err, &validationErr) == false {
+				t.Fatalf("expected a SchemaValidationError, got %v", err)
+			}
+
+			if tc.expectedField == "" {
+				return
+			}
+
+			if findFieldError(validationErr, tc.expectedField) == false {
+				t.Fatalf("expected a field error for %s, got %s", tc.expectedField, validationErr)
+			}
+		})
+	}
+}
+
+func TestDecodeControlMessageInvalidJson(t *testing.T) {
+
+	_, err := DecodeControlMessage([]byte(`{"type": "connection-status", `))
+
+	var validationErr *SchemaValidationError
+	if err == nil || errors.As(err, &validationErr) {
+		t.Fatalf("expected a json syntax error, got %v", err)
+	}
+}
+
+func TestDecodeConnectionStatusMessageContent(t *testing.T) {
+
+	testCases := []struct {
+		testName      string
+		content       string
+		expectedField string
+	}{
+		{"valid content", `{"state": "online", "client_name": "rhc", "client_version": "0.2.1", "canonical_facts": {"fqdn": "host.example.com", "ip_addresses": ["10.0.0.1"]}, "dispatchers": {"rhc-worker-playbook": {"ansible-runner-version": "1.2.3"}, "echo": {}}, "tags": {"env": "prod"}}`, ""},
+		{"null optional fields", `{"state": "offline", "canonical_facts": null, "dispatchers": null, "tags": null}`, ""},
+		{"missing state", `{}`, "/content"},
+		{"invalid state", `{"state": "fred"}`, "/content/state"},
+		{"invalid client name", `{"state": "online", "client_name": 42}`, "/content/client_name"},
+		{"invalid canonical facts", `{"state": "online", "canonical_facts": {"ip_addresses": "10.0.0.1"}}`, "/content/canonical_facts/ip_addresses"},
+		{"invalid dispatchers", `{"state": "online", "dispatchers": ["catalog"]}`, "/content/dispatchers"},
+		{"invalid dispatcher", `{"state": "online", "dispatchers": {"catalog": "fred"}}`, "/content/dispatchers/catalog"},
+		{"non-string dispatcher value", `{"state": "online", "dispatchers": {"catalog": {"SrcName": 42, "features": ["ansible"]}}}`, ""},
+		{"non-string tags", `{"state": "online", "tags": {"env": ["prod"], "count": 3}}`, ""},
+		{"unknown field", `{"state": "online", "fred": "wilma"}`, ""},
+		{"unknown canonical fact", `{"state": "online", "canonical_facts": {"fred": "wilma", "fqdn": "host.example.com"}}`, ""},
+		{"invalid known canonical fact next to an unknown fact", `{"state": "online", "canonical_facts": {"fred": "wilma", "fqdn": 42}}`, "/content/canonical_facts/fqdn"},
+		{"not an object", `"online"`, "/content"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+
+			msg := buildControlMessage(t, ConnectionStatusMessageType, tc.content)
+
+			content, err := DecodeConnectionStatusMessageContent(msg)
+
+			if tc.expectedField == "" {
+				if err != nil {
+					t.Fatalf("expected valid content, got %s", err)
+				}
+
+				if content.ConnectionState == "" {
+					t.Fatalf("content was not decoded correctly: %+v", content)
+				}
+				return
+			}
+
+			var validationErr *SchemaValidationError
+			if errors.As(err, &validationErr) == false {
+				t.Fatalf("expected a SchemaValidationError, got %v", err)
+			}
+
+			if findFieldError(validationErr, tc.expectedField) == false {
+				t.Fatalf("expected a field error for %s, got %s", tc.expectedField, validationErr)
+			}
+		})
+	}
+}
+
+func TestDecodeCommandMessageContent(t *testing.T) {
+
+	_, reconnectMessage, err := BuildReconnectMessage(30)
+	if err != nil {
+		t.Fatal("unable to build reconnect message", err)
+	}
+
+	// Round trip the message the same way a client would receive it
+	serializedMessage, _ := json.Marshal(reconnectMessage)
+
+	msg, err := DecodeControlMessage(serializedMessage)
+	if err != nil {
+		t.Fatalf("expected a valid reconnect message, got %s", err)
+	}
+
+	content, err := DecodeCommandMessageContent(msg)
+	if err != nil {
+		t.Fatalf("expected valid reconnect content, got %s", err)
+	}
+
+	if content.Command != "reconnect" {
+		t.Fatalf("expected a reconnect command, got %s", content.Command)
+	}
+
+	testCases := []struct {
+		testName      string
+		content       string
+		expectedField string
+	}{
+		{"ping", `{"command": "ping", "arguments": null}`, ""},
+		{"unknown field", `{"command": "disconnect", "message": "bye", "fred": "wilma"}`, ""},
+		{"missing command", `{"arguments": {"delay": "30"}}`, "/content"},
+		{"invalid command", `{"command": "fred"}`, "/content/command"},
+		{"invalid argument", `{"command": "reconnect", "arguments": {"delay": 30}}`, "/content/arguments/delay"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+
+			content, err := DecodeCommandMessageContent(buildControlMessage(t, CommandMessageType, tc.content))
+
+			if tc.expectedField == "" {
+				if err != nil || content.Command == "" {
+					t.Fatalf("expected valid command content, got %+v, %v", content, err)
+				}
+				return
+			}
+
+			var validationErr *SchemaValidationError
+			if errors.As(err, &validationErr) == false || findFieldError(validationErr, tc.expectedField) == false {
+				t.Fatalf("expected a field error for %s, got %v", tc.expectedField, err)
+			}
+		})
+	}
+}
+
+func TestDecodeEventMessageContent(t *testing.T) {
+
+	content, err := DecodeEventMessageContent(buildControlMessage(t, EventMessageType, `"RECEIVED"`))
+	if err != nil || content != "RECEIVED" {
+		t.Fatalf("expected the event content to be decoded, got %q, %v", content, err)
+	}
+
+	_, err = DecodeEventMessageContent(buildControlMessage(t, EventMessageType, `{"status": "RECEIVED"}`))
+
+	var validationErr *SchemaValidationError
+	if errors.As(err, &validationErr) == false || findFieldError(validationErr, "/content") == false {
+		t.Fatalf("expected a field error for the content, got %v", err)
+	}
+}
+
+func TestDecodeMessageContentWrongMessageType(t *testing.T) {
+
+	_, err := DecodeEventMessageContent(buildControlMessage(t, ConnectionStatusMessageType, `{"state": "online"}`))
+	if errors.Is(err, UnknownMessageTypeError) == false {
+		t.Fatalf("expected UnknownMessageTypeError, got %v", err)
+	}
+}
+
+func buildControlMessage(t *testing.T, messageType string, content string) ControlMessage {
+
+	msg := ControlMessage{MessageType: messageType, MessageID: "5678", Version: 1}
+
+	if err := json.Unmarshal([]byte(content), &msg.Content); err != nil {
+		t.Fatal("unable to unmarshal test content", err)
+	}
+
+	return msg
+}
+
+func findFieldError(validationErr *SchemaValidationError, field string) bool {
+	for _, fieldError := range validationErr.FieldErrors {
+		if fieldError.Field == field {
+			return true
+		}
+	}
+	return false
+}
@@ -7,6 +7,7 @@ import (
 type ControlMessage struct {
 	MessageType string      `json:"type"`
 	MessageID   string      `json:"message_id"`
+	ResponseTo  string      `json:"response_to,omitempty"`
 	Version     int         `json:"version"`
 	Sent        time.Time   `json:"sent"`
 	Content     interface{} `json:"content"`
@@ -21,8 +22,8 @@ type ConnectionStatusMessageContent struct {
 	ClientVersion   string         `json:"client_version"`
 }
 
-type Dispatchers map[string]map[string]string
-type Tags map[string]string
+type Dispatchers map[string]map[string]interface{}
+type Tags map[string]interface{}
 
 type CommandMessageContent struct {
 	Command   string      `json:"command"`
@@ -1,6 +1,9 @@
 package cloud_connector
 
 import (
+	"encoding/json"
+
+	"github.com/RedHatInsights/cloud-connector/internal/cloud_connector/protocol"
 	"github.com/google/uuid"
 )
 
@@ -10,12 +13,12 @@ func sanitizeCanonicalFacts(cf interface{}) interface{} {
 		return make(map[string]interface{})
 	}
 
-	canonicalFacts := cf.(map[string]interface{})
+	canonicalFacts, _ := cf.(map[string]interface{})
 	sanitizedCanonicalFacts := make(map[string]interface{})
 
 	for key, value := range canonicalFacts {
 		if key == "insights_id" {
-			if validUUID(value.(string)) {
+			if insightsID, isString := value.(string); isString && validUUID(insightsID) {
 				sanitizedCanonicalFacts[key] = value
 			}
 		} else {
@@ -26,6 +29,46 @@ func sanitizeCanonicalFacts(cf interface{}) interface{} {
 	return sanitizedCanonicalFacts
 }
 
+// canonicalFactsToMap converts the decoded canonical facts into the generic map that
+// is stored with the connection state
+func canonicalFactsToMap(canonicalFacts protocol.CanonicalFacts) interface{} {
+
+	serializedCanonicalFacts, err := json.Marshal(canonicalFacts)
+	if err != nil {
+		return nil
+	}
+
+	var canonicalFactsMap map[string]interface{}
+	if err := json.Unmarshal(serializedCanonicalFacts, &canonicalFactsMap); err != nil {
+		return nil
+	}
+
+	return canonicalFactsMap
+}
+
+func dispatchersToMap(dispatchers protocol.Dispatchers) interface{} {
+
+	if dispatchers == nil {
+		return nil
+	}
+
+	dispatchersMap := make(map[string]interface{}, len(dispatchers))
+	for name, dispatcher := range dispatchers {
+		dispatchersMap[name] = map[string]interface{}(dispatcher)
+	}
+
+	return dispatchersMap
+}
+
+func tagsToMap(tags protocol.Tags) interface{} {
+
+	if tags == nil {
+		return nil
+	}
+
+	return map[string]interface{}(tags)
+}
+
 func validUUID(s string) bool {
 	_, err := uuid.Parse(s)
 	return err == nil
@@ -87,6 +87,15 @@ const (
 	RHC_MESSAGE_KAFKA_BATCH_SIZE                   = "RHC_Message_Kafka_Batch_Size"
 	RHC_MESSAGE_KAFKA_BATCH_BYTES                  = "RHC_Message_Kafka_Batch_Bytes"
 	RHC_MESSAGE_KAFKA_CONSUMER_GROUP               = "RHC_Message_Kafka_Consumer_Group"
+	DATA_MESSAGE_KAFKA_BROKERS                     = "Data_Message_Kafka_Brokers"
+	DATA_MESSAGE_KAFKA_TOPICS                      = "Data_Message_Kafka_Topics"
+	DATA_MESSAGE_KAFKA_BATCH_SIZE                  = "Data_Message_Kafka_Batch_Size"
+	DATA_MESSAGE_KAFKA_BATCH_BYTES                 = "Data_Message_Kafka_Batch_Bytes"
+	MESSAGE_ACKNOWLEDGEMENT_TIMEOUT                = "Message_Acknowledgement_Timeout"
+	PENDING_MESSAGE_DEFAULT_TTL                    = "Pending_Message_Default_TTL"
+	PENDING_MESSAGE_MAX_TTL                        = "Pending_Message_Max_TTL"
+	BULK_MESSAGE_MAX_RECIPIENTS                    = "Bulk_Message_Max_Recipients"
+	BULK_MESSAGE_CONCURRENCY                       = "Bulk_Message_Concurrency"
 	PENDO_API_ENDPOINT                             = "Pendo_Api_Endpoint"
 	PENDO_REQUEST_TIMEOUT                          = "Pendo_Request_Timeout"
 	PENDO_INTEGRATION_KEY                          = "Pendo_Integration_Key"
@@ -101,96 +110,197 @@ const (
 	TENANTLESS_CONNECTION_TIMESTAMP_OFFSET         = "Tenantless_Connection_Timestamp_Offset"
 	TENANTLESS_CONNECTION_UPDATER_CHUNK_SIZE       = "Tenantless_Connection_Updater_Chunk_Size"
 	TENANTLESS_CONNECTION_MAX_LOOKUP_FAILURES      = "Tenantless_Connection_Max_Lookup_Failures"
+	CONNECTION_EVENT_RETENTION                     = "Connection_Event_Retention"
+	CONNECTION_REGISTRAR_IMPL                      = "Connection_Registrar_Impl"
+	SEND_MESSAGE_STRICT_DIRECTIVE_CHECK            = "Send_Message_Strict_Directive_Check"
+	MQTT_PROTOCOL_VERSION                          = "Mqtt_Protocol_Version"
+	MQTT_DATA_MESSAGE_EXPIRY                       = "Mqtt_Data_Message_Expiry"
+	MQTT_SHARED_SUBSCRIPTION_GROUP                 = "Mqtt_Shared_Subscription_Group"
+	MQTT_KAFKA_SPOOL_DIRECTORY                     = "Mqtt_Kafka_Spool_Directory"
+	MQTT_KAFKA_SPOOL_MAX_BYTES                     = "Mqtt_Kafka_Spool_Max_Bytes"
+	MQTT_KAFKA_SPOOL_SEGMENT_MAX_BYTES             = "Mqtt_Kafka_Spool_Segment_Max_Bytes"
+	MQTT_KAFKA_SPOOL_REPLAY_INTERVAL               = "Mqtt_Kafka_Spool_Replay_Interval"
+	MQTT_KAFKA_SPOOL_REPLAY_BATCH_SIZE             = "Mqtt_Kafka_Spool_Replay_Batch_Size"
+	DEAD_LETTER_KAFKA_TOPIC                        = "Dead_Letter_Kafka_Topic"
+	KAFKA_MESSAGE_CONSUMER_CONCURRENCY             = "Kafka_Message_Consumer_Concurrency"
+	CONNECTION_REGISTRAR_BATCH_WINDOW              = "Connection_Registrar_Batch_Window"
+	CONNECTION_REGISTRAR_MAX_BATCH_SIZE            = "Connection_Registrar_Max_Batch_Size"
+	CONNECTION_CACHE_IMPL                          = "Connection_Cache_Impl"
+	CONNECTION_CACHE_SIZE                          = "Connection_Cache_Size"
+	CONNECTION_CACHE_TTL                           = "Connection_Cache_TTL"
+	CONNECTION_CACHE_REDIS_ADDRESS                 = "Connection_Cache_Redis_Address"
+	CONNECTION_CACHE_REDIS_PASSWORD                = "Connection_Cache_Redis_Password"
+	CONNECTION_DATABASE_REUSE_PREPARED_STATEMENTS  = "Connection_Database_Reuse_Prepared_Statements"
+	CONNECTION_DATABASE_MAX_OPEN_CONNECTIONS       = "Connection_Database_Max_Open_Connections"
+	CONNECTION_DATABASE_MAX_IDLE_CONNECTIONS       = "Connection_Database_Max_Idle_Connections"
+	CONNECTION_DATABASE_CONNECTION_MAX_LIFETIME    = "Connection_Database_Connection_Max_Lifetime"
+	CONNECTION_DATABASE_CONNECTION_MAX_IDLE_TIME   = "Connection_Database_Connection_Max_Idle_Time"
+	CONNECTION_DATABASE_STARTUP_PING_ATTEMPTS      = "Connection_Database_Startup_Ping_Attempts"
+	CONNECTION_DATABASE_STARTUP_PING_BACKOFF       = "Connection_Database_Startup_Ping_Backoff"
+	CONNECTION_DATABASE_STARTUP_PING_MAX_BACKOFF   = "Connection_Database_Startup_Ping_Max_Backoff"
+	READINESS_CHECK_TIMEOUT                        = "Readiness_Check_Timeout"
+	LIVENESS_SWEEP_INTERVAL                        = "Liveness_Sweep_Interval"
+	LIVENESS_SWEEP_CONNECTION_AGE                  = "Liveness_Sweep_Connection_Age"
+	LIVENESS_SWEEP_CHUNK_SIZE                      = "Liveness_Sweep_Chunk_Size"
+	LIVENESS_SWEEP_CONCURRENCY                     = "Liveness_Sweep_Concurrency"
+	LIVENESS_SWEEP_PING_ATTEMPTS                   = "Liveness_Sweep_Ping_Attempts"
+	LIVENESS_SWEEP_RESPONSE_TIMEOUT                = "Liveness_Sweep_Response_Timeout"
+	LIVENESS_SWEEP_POLL_INTERVAL                   = "Liveness_Sweep_Poll_Interval"
+	PING_RESPONSE_DEFAULT_TIMEOUT                  = "Ping_Response_Default_Timeout"
+	PING_RESPONSE_MAX_TIMEOUT                      = "Ping_Response_Max_Timeout"
+	CONNECTION_EVENT_PRUNE_BATCH_SIZE              = "Connection_Event_Prune_Batch_Size"
+	OFFLINE_CONNECTION_RETENTION                   = "Offline_Connection_Retention"
+	DEAD_LETTER_REPLAY_CONSUMER_GROUP              = "Dead_Letter_Replay_Consumer_Group"
+	CONNECTION_CACHE_ALLOW_STALE                   = "Connection_Cache_Allow_Stale"
+	HANDSHAKE_REQUIRE_MESSAGE_ID                   = "Handshake_Require_Message_Id"
+	LIVENESS_SWEEP_MAX_REAPED_PERCENT              = "Liveness_Sweep_Max_Reaped_Percent"
+	MESSAGE_RESPONSE_NOTIFICATIONS_ENABLED         = "Message_Response_Notifications_Enabled"
+	MESSAGE_RESPONSE_POLL_INTERVAL                 = "Message_Response_Poll_Interval"
 )
 
 type Config struct {
-	UrlAppName                               string
-	UrlPathPrefix                            string
-	UrlBasePath                              string
-	OpenApiSpecFilePath                      string
-	HttpShutdownTimeout                      time.Duration
-	ServiceToServiceCredentials              map[string]interface{}
-	Profile                                  bool
-	MqttBrokerAddress                        string
-	MqttClientId                             string
-	MqttUseHostnameAsClientId                bool
-	MqttCleanSession                         bool
-	MqttResumeSubs                           bool
-	MqttBrokerTlsCertFile                    string
-	MqttBrokerTlsKeyFile                     string
-	MqttBrokerTlsCACertFile                  string
-	MqttBrokerTlsSkipVerify                  bool
-	MqttBrokerAuthType                       string
-	MqttBrokerUsername                       string
-	MqttBrokerPassword                       string
-	MqttBrokerJwtGeneratorImpl               string
-	MqttBrokerJwtFile                        string
-	MqttTopicPrefix                          string
-	MqttControlSubscriptionQoS               byte
-	MqttControlPublishQoS                    byte
-	MqttDataSubscriptionQoS                  byte
-	MqttDataPublishQoS                       byte
-	MqttDisconnectQuiesceTime                uint
-	MqttPublishTimeout                       time.Duration
-	MqttConsumerShutdownSleepTime            time.Duration
-	ShutdownOnMqttConnectionLost             bool
-	InvalidHandshakeReconnectDelay           int
-	KafkaBrokers                             []string
-	KafkaCA                                  string
-	KafkaUsername                            string
-	KafkaPassword                            string
-	KafkaSASLMechanism                       string
-	ClientIdToAccountIdImpl                  string
-	ClientIdToAccountIdConfigFile            string
-	ClientIdToAccountIdDefaultAccountId      string
-	ClientIdToAccountIdDefaultOrgId          string
-	ClientIdToAccountIdCacheSize             int
-	ClientIdToAccountIdCacheValidRespTTL     time.Duration
-	ClientIdToAccountIdCacheErrorRespTTL     time.Duration
-	ConnectionDatabaseImpl                   string
-	ConnectionDatabaseHost                   string
-	ConnectionDatabasePort                   int
-	ConnectionDatabaseUser                   string
-	ConnectionDatabasePassword               string
-	ConnectionDatabaseName                   string
-	ConnectionDatabaseSslMode                string
-	ConnectionDatabaseSslRootCert            string
-	ConnectionDatabaseQueryTimeout           time.Duration
-	AuthGatewayUrl                           string
-	AuthGatewayHttpClientTimeout             time.Duration
-	ConnectedClientRecorderImpl              string
-	InventoryKafkaBrokers                    []string
-	InventoryKafkaTopic                      string
-	InventoryKafkaBatchSize                  int
-	InventoryKafkaBatchBytes                 int
-	InventoryStaleTimestampOffset            time.Duration
-	InventoryStaleTimestampUpdaterChunkSize  int
-	InventoryReporterName                    string
-	SourcesRecorderImpl                      string
-	SourcesBaseUrl                           string
-	SourcesHttpClientTimeout                 time.Duration
-	JwtTokenExpiry                           int
-	JwtPrivateKeyFile                        string
-	JwtPublicKeyFile                         string
-	RhcMessageKafkaBrokers                   []string
-	RhcMessageKafkaTopic                     string
-	RhcMessageKafkaBatchSize                 int
-	RhcMessageKafkaBatchBytes                int
-	RhcMessageKafkaConsumerGroup             string
-	PendoApiEndpoint                         string
-	PendoRequestTimeout                      time.Duration
-	PendoIntegrationKey                      string
-	PendoRequestSize                         int
-	PrometheusPushGateway                    string
-	ApiServerConnectionLookupImpl            string
-	TenantTranslatorImpl                     string
-	TenantTranslatorMockMapping              map[string]interface{}
-	TenantTranslatorURL                      string
-	TenantTranslatorTimeout                  time.Duration
-	PurgeConnectionOnFailedTenantLookupCount int
-	TenantlessConnectionTimestampOffset      time.Duration
-	TenantlessConnectionUpdaterChunkSize     int
-	TenantlessConnectionMaxLookupFailures    int
+	UrlAppName                                string
+	UrlPathPrefix                             string
+	UrlBasePath                               string
+	OpenApiSpecFilePath                       string
+	HttpShutdownTimeout                       time.Duration
+	ServiceToServiceCredentials               map[string]interface{}
+	Profile                                   bool
+	MqttBrokerAddress                         string
+	MqttClientId                              string
+	MqttUseHostnameAsClientId                 bool
+	MqttCleanSession                          bool
+	MqttResumeSubs                            bool
+	MqttBrokerTlsCertFile                     string
+	MqttBrokerTlsKeyFile                      string
+	MqttBrokerTlsCACertFile                   string
+	MqttBrokerTlsSkipVerify                   bool
+	MqttBrokerAuthType                        string
+	MqttBrokerUsername                        string
+	MqttBrokerPassword                        string
+	MqttBrokerJwtGeneratorImpl                string
+	MqttBrokerJwtFile                         string
+	MqttTopicPrefix                           string
+	MqttControlSubscriptionQoS                byte
+	MqttControlPublishQoS                     byte
+	MqttDataSubscriptionQoS                   byte
+	MqttDataPublishQoS                        byte
+	MqttDisconnectQuiesceTime                 uint
+	MqttPublishTimeout                        time.Duration
+	MqttConsumerShutdownSleepTime             time.Duration
+	ShutdownOnMqttConnectionLost              bool
+	InvalidHandshakeReconnectDelay            int
+	KafkaBrokers                              []string
+	KafkaCA                                   string
+	KafkaUsername                             string
+	KafkaPassword                             string
+	KafkaSASLMechanism                        string
+	ClientIdToAccountIdImpl                   string
+	ClientIdToAccountIdConfigFile             string
+	ClientIdToAccountIdDefaultAccountId       string
+	ClientIdToAccountIdDefaultOrgId           string
+	ClientIdToAccountIdCacheSize              int
+	ClientIdToAccountIdCacheValidRespTTL      time.Duration
+	ClientIdToAccountIdCacheErrorRespTTL      time.Duration
+	ConnectionDatabaseImpl                    string
+	ConnectionDatabaseHost                    string
+	ConnectionDatabasePort                    int
+	ConnectionDatabaseUser                    string
+	ConnectionDatabasePassword                string
+	ConnectionDatabaseName                    string
+	ConnectionDatabaseSslMode                 string
+	ConnectionDatabaseSslRootCert             string
+	ConnectionDatabaseQueryTimeout            time.Duration
+	AuthGatewayUrl                            string
+	AuthGatewayHttpClientTimeout              time.Duration
+	ConnectedClientRecorderImpl               string
+	InventoryKafkaBrokers                     []string
+	InventoryKafkaTopic                       string
+	InventoryKafkaBatchSize                   int
+	InventoryKafkaBatchBytes                  int
+	InventoryStaleTimestampOffset             time.Duration
+	InventoryStaleTimestampUpdaterChunkSize   int
+	InventoryReporterName                     string
+	SourcesRecorderImpl                       string
+	SourcesBaseUrl                            string
+	SourcesHttpClientTimeout                  time.Duration
+	JwtTokenExpiry                            int
+	JwtPrivateKeyFile                         string
+	JwtPublicKeyFile                          string
+	RhcMessageKafkaBrokers                    []string
+	RhcMessageKafkaTopic                      string
+	RhcMessageKafkaBatchSize                  int
+	RhcMessageKafkaBatchBytes                 int
+	RhcMessageKafkaConsumerGroup              string
+	DataMessageKafkaBrokers                   []string
+	DataMessageKafkaTopics                    map[string]string
+	DataMessageKafkaBatchSize                 int
+	DataMessageKafkaBatchBytes                int
+	MessageAcknowledgementTimeout             time.Duration
+	PendingMessageDefaultTTL                  time.Duration
+	PendingMessageMaxTTL                      time.Duration
+	BulkMessageMaxRecipients                  int
+	BulkMessageConcurrency                    int
+	PendoApiEndpoint                          string
+	PendoRequestTimeout                       time.Duration
+	PendoIntegrationKey                       string
+	PendoRequestSize                          int
+	PrometheusPushGateway                     string
+	ApiServerConnectionLookupImpl             string
+	TenantTranslatorImpl                      string
+	TenantTranslatorMockMapping               map[string]interface{}
+	TenantTranslatorURL                       string
+	TenantTranslatorTimeout                   time.Duration
+	PurgeConnectionOnFailedTenantLookupCount  int
+	TenantlessConnectionTimestampOffset       time.Duration
+	TenantlessConnectionUpdaterChunkSize      int
+	TenantlessConnectionMaxLookupFailures     int
+	ConnectionEventRetention                  time.Duration
+	ConnectionRegistrarImpl                   string
+	SendMessageStrictDirectiveCheck           bool
+	MqttProtocolVersion                       int
+	MqttDataMessageExpiry                     time.Duration
+	MqttSharedSubscriptionGroup               string
+	MqttKafkaSpoolDirectory                   string
+	MqttKafkaSpoolMaxBytes                    int
+	MqttKafkaSpoolSegmentMaxBytes             int
+	MqttKafkaSpoolReplayInterval              time.Duration
+	MqttKafkaSpoolReplayBatchSize             int
+	DeadLetterKafkaTopic                      string
+	KafkaMessageConsumerConcurrency           int
+	ConnectionRegistrarBatchWindow            time.Duration
+	ConnectionRegistrarMaxBatchSize           int
+	ConnectionCacheImpl                       string
+	ConnectionCacheSize                       int
+	ConnectionCacheTTL                        time.Duration
+	ConnectionCacheRedisAddress               string
+	ConnectionCacheRedisPassword              string
+	ConnectionDatabaseReusePreparedStatements bool
+	ConnectionDatabaseMaxOpenConnections      int
+	ConnectionDatabaseMaxIdleConnections      int
+	ConnectionDatabaseConnectionMaxLifetime   time.Duration
+	ConnectionDatabaseConnectionMaxIdleTime   time.Duration
+	ConnectionDatabaseStartupPingAttempts     int
+	ConnectionDatabaseStartupPingBackoff      time.Duration
+	ConnectionDatabaseStartupPingMaxBackoff   time.Duration
+	ReadinessCheckTimeout                     time.Duration
+	LivenessSweepInterval                     time.Duration
+	LivenessSweepConnectionAge                time.Duration
+	LivenessSweepChunkSize                    int
+	LivenessSweepConcurrency                  int
+	LivenessSweepPingAttempts                 int
+	LivenessSweepResponseTimeout              time.Duration
+	LivenessSweepPollInterval                 time.Duration
+	PingResponseDefaultTimeout                time.Duration
+	PingResponseMaxTimeout                    time.Duration
+	ConnectionEventPruneBatchSize             int
+	OfflineConnectionRetention                time.Duration
+	DeadLetterReplayConsumerGroup             string
+	ConnectionCacheAllowStale                 bool
+	HandshakeRequireMessageID                 bool
+	LivenessSweepMaxReapedPercent             int
+	MessageResponseNotificationsEnabled       bool
+	MessageResponsePollInterval               time.Duration
 }
 
 func (c Config) String() string {
@@ -262,6 +372,15 @@ func (c Config) String() string {
 	fmt.Fprintf(&b, "%s: %d\n", RHC_MESSAGE_KAFKA_BATCH_SIZE, c.RhcMessageKafkaBatchSize)
 	fmt.Fprintf(&b, "%s: %d\n", RHC_MESSAGE_KAFKA_BATCH_BYTES, c.RhcMessageKafkaBatchBytes)
 	fmt.Fprintf(&b, "%s: %s\n", RHC_MESSAGE_KAFKA_CONSUMER_GROUP, c.RhcMessageKafkaConsumerGroup)
+	fmt.Fprintf(&b, "%s: %s\n", DATA_MESSAGE_KAFKA_BROKERS, c.DataMessageKafkaBrokers)
+	fmt.Fprintf(&b, "%s: %s\n", DATA_MESSAGE_KAFKA_TOPICS, c.DataMessageKafkaTopics)
+	fmt.Fprintf(&b, "%s: %d\n", DATA_MESSAGE_KAFKA_BATCH_SIZE, c.DataMessageKafkaBatchSize)
+	fmt.Fprintf(&b, "%s: %d\n", DATA_MESSAGE_KAFKA_BATCH_BYTES, c.DataMessageKafkaBatchBytes)
+	fmt.Fprintf(&b, "%s: %s\n", MESSAGE_ACKNOWLEDGEMENT_TIMEOUT, c.MessageAcknowledgementTimeout)
+	fmt.Fprintf(&b, "%s: %s\n", PENDING_MESSAGE_DEFAULT_TTL, c.PendingMessageDefaultTTL)
+	fmt.Fprintf(&b, "%s: %s\n", PENDING_MESSAGE_MAX_TTL, c.PendingMessageMaxTTL)
+	fmt.Fprintf(&b, "%s: %d\n", BULK_MESSAGE_MAX_RECIPIENTS, c.BulkMessageMaxRecipients)
+	fmt.Fprintf(&b, "%s: %d\n", BULK_MESSAGE_CONCURRENCY, c.BulkMessageConcurrency)
 	fmt.Fprintf(&b, "%s: %s\n", API_SERVER_CONNECTION_LOOKUP_IMPL, c.ApiServerConnectionLookupImpl)
 	fmt.Fprintf(&b, "%s: %s\n", TENANT_TRANSLATOR_IMPL, c.TenantTranslatorImpl)
 	fmt.Fprintf(&b, "%s: %s\n", TENANT_TRANSLATOR_MOCK_MAPPING, c.TenantTranslatorMockMapping)
@@ -272,6 +391,51 @@ func (c Config) String() string {
 	fmt.Fprintf(&b, "%s: %s\n", TENANTLESS_CONNECTION_TIMESTAMP_OFFSET, c.TenantlessConnectionTimestampOffset)
 	fmt.Fprintf(&b, "%s: %d\n", TENANTLESS_CONNECTION_UPDATER_CHUNK_SIZE, c.TenantlessConnectionUpdaterChunkSize)
 	fmt.Fprintf(&b, "%s: %d\n", TENANTLESS_CONNECTION_MAX_LOOKUP_FAILURES, c.TenantlessConnectionMaxLookupFailures)
+	fmt.Fprintf(&b, "%s: %s\n", CONNECTION_EVENT_RETENTION, c.ConnectionEventRetention)
+	fmt.Fprintf(&b, "%s: %s\n", CONNECTION_REGISTRAR_IMPL, c.ConnectionRegistrarImpl)
+	fmt.Fprintf(&b, "%s: %t\n", SEND_MESSAGE_STRICT_DIRECTIVE_CHECK, c.SendMessageStrictDirectiveCheck)
+	fmt.Fprintf(&b, "%s: %d\n", MQTT_PROTOCOL_VERSION, c.MqttProtocolVersion)
+	fmt.Fprintf(&b, "%s: %s\n", MQTT_DATA_MESSAGE_EXPIRY, c.MqttDataMessageExpiry)
+	fmt.Fprintf(&b, "%s: %s\n", MQTT_SHARED_SUBSCRIPTION_GROUP, c.MqttSharedSubscriptionGroup)
+	fmt.Fprintf(&b, "%s: %s\n", MQTT_KAFKA_SPOOL_DIRECTORY, c.MqttKafkaSpoolDirectory)
+	fmt.Fprintf(&b, "%s: %d\n", MQTT_KAFKA_SPOOL_MAX_BYTES, c.MqttKafkaSpoolMaxBytes)
+	fmt.Fprintf(&b, "%s: %d\n", MQTT_KAFKA_SPOOL_SEGMENT_MAX_BYTES, c.MqttKafkaSpoolSegmentMaxBytes)
+	fmt.Fprintf(&b, "%s: %s\n", MQTT_KAFKA_SPOOL_REPLAY_INTERVAL, c.MqttKafkaSpoolReplayInterval)
+	fmt.Fprintf(&b, "%s: %d\n", MQTT_KAFKA_SPOOL_REPLAY_BATCH_SIZE, c.MqttKafkaSpoolReplayBatchSize)
+	fmt.Fprintf(&b, "%s: %s\n", DEAD_LETTER_KAFKA_TOPIC, c.DeadLetterKafkaTopic)
+	fmt.Fprintf(&b, "%s: %d\n", KAFKA_MESSAGE_CONSUMER_CONCURRENCY, c.KafkaMessageConsumerConcurrency)
+	fmt.Fprintf(&b, "%s: %s\n", CONNECTION_REGISTRAR_BATCH_WINDOW, c.ConnectionRegistrarBatchWindow)
+	fmt.Fprintf(&b, "%s: %d\n", CONNECTION_REGISTRAR_MAX_BATCH_SIZE, c.ConnectionRegistrarMaxBatchSize)
+	fmt.Fprintf(&b, "%s: %s\n", CONNECTION_CACHE_IMPL, c.ConnectionCacheImpl)
+	fmt.Fprintf(&b, "%s: %d\n", CONNECTION_CACHE_SIZE, c.ConnectionCacheSize)
+	fmt.Fprintf(&b, "%s: %s\n", CONNECTION_CACHE_TTL, c.ConnectionCacheTTL)
+	fmt.Fprintf(&b, "%s: %s\n", CONNECTION_CACHE_REDIS_ADDRESS, c.ConnectionCacheRedisAddress)
+	fmt.Fprintf(&b, "%s: %t\n", CONNECTION_DATABASE_REUSE_PREPARED_STATEMENTS, c.ConnectionDatabaseReusePreparedStatements)
+	fmt.Fprintf(&b, "%s: %d\n", CONNECTION_DATABASE_MAX_OPEN_CONNECTIONS, c.ConnectionDatabaseMaxOpenConnections)
+	fmt.Fprintf(&b, "%s: %d\n", CONNECTION_DATABASE_MAX_IDLE_CONNECTIONS, c.ConnectionDatabaseMaxIdleConnections)
+	fmt.Fprintf(&b, "%s: %s\n", CONNECTION_DATABASE_CONNECTION_MAX_LIFETIME, c.ConnectionDatabaseConnectionMaxLifetime)
+	fmt.Fprintf(&b, "%s: %s\n", CONNECTION_DATABASE_CONNECTION_MAX_IDLE_TIME, c.ConnectionDatabaseConnectionMaxIdleTime)
+	fmt.Fprintf(&b, "%s: %d\n", CONNECTION_DATABASE_STARTUP_PING_ATTEMPTS, c.ConnectionDatabaseStartupPingAttempts)
+	fmt.Fprintf(&b, "%s: %s\n", CONNECTION_DATABASE_STARTUP_PING_BACKOFF, c.ConnectionDatabaseStartupPingBackoff)
+	fmt.Fprintf(&b, "%s: %s\n", CONNECTION_DATABASE_STARTUP_PING_MAX_BACKOFF, c.ConnectionDatabaseStartupPingMaxBackoff)
+	fmt.Fprintf(&b, "%s: %s\n", READINESS_CHECK_TIMEOUT, c.ReadinessCheckTimeout)
+	fmt.Fprintf(&b, "%s: %s\n", LIVENESS_SWEEP_INTERVAL, c.LivenessSweepInterval)
+	fmt.Fprintf(&b, "%s: %s\n", LIVENESS_SWEEP_CONNECTION_AGE, c.LivenessSweepConnectionAge)
+	fmt.Fprintf(&b, "%s: %d\n", LIVENESS_SWEEP_CHUNK_SIZE, c.LivenessSweepChunkSize)
+	fmt.Fprintf(&b, "%s: %d\n", LIVENESS_SWEEP_CONCURRENCY, c.LivenessSweepConcurrency)
+	fmt.Fprintf(&b, "%s: %d\n", LIVENESS_SWEEP_PING_ATTEMPTS, c.LivenessSweepPingAttempts)
+	fmt.Fprintf(&b, "%s: %s\n", LIVENESS_SWEEP_RESPONSE_TIMEOUT, c.LivenessSweepResponseTimeout)
+	fmt.Fprintf(&b, "%s: %s\n", LIVENESS_SWEEP_POLL_INTERVAL, c.LivenessSweepPollInterval)
+	fmt.Fprintf(&b, "%s: %s\n", PING_RESPONSE_DEFAULT_TIMEOUT, c.PingResponseDefaultTimeout)
+	fmt.Fprintf(&b, "%s: %s\n", PING_RESPONSE_MAX_TIMEOUT, c.PingResponseMaxTimeout)
+	fmt.Fprintf(&b, "%s: %d\n", CONNECTION_EVENT_PRUNE_BATCH_SIZE, c.ConnectionEventPruneBatchSize)
+	fmt.Fprintf(&b, "%s: %s\n", OFFLINE_CONNECTION_RETENTION, c.OfflineConnectionRetention)
+	fmt.Fprintf(&b, "%s: %s\n", DEAD_LETTER_REPLAY_CONSUMER_GROUP, c.DeadLetterReplayConsumerGroup)
+	fmt.Fprintf(&b, "%s: %t\n", CONNECTION_CACHE_ALLOW_STALE, c.ConnectionCacheAllowStale)
+	fmt.Fprintf(&b, "%s: %t\n", HANDSHAKE_REQUIRE_MESSAGE_ID, c.HandshakeRequireMessageID)
+	fmt.Fprintf(&b, "%s: %d\n", LIVENESS_SWEEP_MAX_REAPED_PERCENT, c.LivenessSweepMaxReapedPercent)
+	fmt.Fprintf(&b, "%s: %t\n", MESSAGE_RESPONSE_NOTIFICATIONS_ENABLED, c.MessageResponseNotificationsEnabled)
+	fmt.Fprintf(&b, "%s: %s\n", MESSAGE_RESPONSE_POLL_INTERVAL, c.MessageResponsePollInterval)
 
 	return b.String()
 }
@@ -340,6 +504,15 @@ func GetConfig() *Config {
 	options.SetDefault(RHC_MESSAGE_KAFKA_BATCH_SIZE, 1)
 	options.SetDefault(RHC_MESSAGE_KAFKA_BATCH_BYTES, 1048576)
 	options.SetDefault(RHC_MESSAGE_KAFKA_CONSUMER_GROUP, "cloud-connector-rhc-message-consumer")
+	options.SetDefault(DATA_MESSAGE_KAFKA_BROKERS, []string{DEFAULT_KAFKA_BROKER_ADDRESS})
+	options.SetDefault(DATA_MESSAGE_KAFKA_TOPICS, "{}") // Map of directive to kafka topic, i.e. {"rhc-worker-playbook": "platform.playbook-dispatcher.runner-updates"}
+	options.SetDefault(DATA_MESSAGE_KAFKA_BATCH_SIZE, 1)
+	options.SetDefault(DATA_MESSAGE_KAFKA_BATCH_BYTES, 1048576)
+	options.SetDefault(MESSAGE_ACKNOWLEDGEMENT_TIMEOUT, 60)
+	options.SetDefault(PENDING_MESSAGE_DEFAULT_TTL, 3600)
+	options.SetDefault(PENDING_MESSAGE_MAX_TTL, 7*24*3600)
+	options.SetDefault(BULK_MESSAGE_MAX_RECIPIENTS, 1000)
+	options.SetDefault(BULK_MESSAGE_CONCURRENCY, 10)
 	options.SetDefault(PENDO_API_ENDPOINT, "https://app.pendo.io/api/v1")
 	options.SetDefault(PENDO_REQUEST_TIMEOUT, 5)
 	options.SetDefault(PENDO_INTEGRATION_KEY, "")
@@ -350,100 +523,201 @@ func GetConfig() *Config {
 	options.SetDefault(TENANT_TRANSLATOR_URL, "http://gateway.3scale-dev.svc.cluster.local:8892")
 	options.SetDefault(TENANT_TRANSLATOR_TIMEOUT, 5)
 	options.SetDefault(PROMETHEUS_PUSH_GATEWAY, "prometheus-push.insights-push-stage.svc.cluster.local:9091")
-	options.SetDefault(PURGE_CONNECTION_ON_FAILED_TENANT_LOOKUP_COUNT, 6*24) // Check runs every 10min ...wait 24 hours before purging a bad connection
+	options.SetDefault(PURGE_CONNECTION_ON_FAILED_TENANT_LOOKUP_COUNT, 30) // Purge once the updater stops looking up the tenant (TENANTLESS_CONNECTION_MAX_LOOKUP_FAILURES)
 	options.SetDefault(TENANTLESS_CONNECTION_TIMESTAMP_OFFSET, 30)
 	options.SetDefault(TENANTLESS_CONNECTION_UPDATER_CHUNK_SIZE, 100)
 	options.SetDefault(TENANTLESS_CONNECTION_MAX_LOOKUP_FAILURES, 30)
+	options.SetDefault(CONNECTION_EVENT_RETENTION, 30*24) // Keep 30 days of connection history
+	options.SetDefault(CONNECTION_REGISTRAR_IMPL, "delete")
+	options.SetDefault(SEND_MESSAGE_STRICT_DIRECTIVE_CHECK, false)
+	options.SetDefault(MQTT_PROTOCOL_VERSION, 4)
+	options.SetDefault(MQTT_DATA_MESSAGE_EXPIRY, 0)
+	options.SetDefault(MQTT_SHARED_SUBSCRIPTION_GROUP, "")
+	options.SetDefault(MQTT_KAFKA_SPOOL_DIRECTORY, "")
+	options.SetDefault(MQTT_KAFKA_SPOOL_MAX_BYTES, 104857600)
+	options.SetDefault(MQTT_KAFKA_SPOOL_SEGMENT_MAX_BYTES, 8388608)
+	options.SetDefault(MQTT_KAFKA_SPOOL_REPLAY_INTERVAL, 5)
+	options.SetDefault(MQTT_KAFKA_SPOOL_REPLAY_BATCH_SIZE, 100)
+	options.SetDefault(DEAD_LETTER_KAFKA_TOPIC, "")
+	options.SetDefault(KAFKA_MESSAGE_CONSUMER_CONCURRENCY, 1)
+	options.SetDefault(CONNECTION_REGISTRAR_BATCH_WINDOW, 0)
+	options.SetDefault(CONNECTION_REGISTRAR_MAX_BATCH_SIZE, 500)
+	options.SetDefault(CONNECTION_CACHE_IMPL, "memory")
+	options.SetDefault(CONNECTION_CACHE_SIZE, 10000)
+	options.SetDefault(CONNECTION_CACHE_TTL, 30)
+	options.SetDefault(CONNECTION_CACHE_REDIS_ADDRESS, "localhost:6379")
+	options.SetDefault(CONNECTION_CACHE_REDIS_PASSWORD, "")
+	options.SetDefault(CONNECTION_DATABASE_REUSE_PREPARED_STATEMENTS, true)
+	options.SetDefault(CONNECTION_DATABASE_MAX_OPEN_CONNECTIONS, 20)
+	options.SetDefault(CONNECTION_DATABASE_MAX_IDLE_CONNECTIONS, 10)
+	options.SetDefault(CONNECTION_DATABASE_CONNECTION_MAX_LIFETIME, 1800)
+	options.SetDefault(CONNECTION_DATABASE_CONNECTION_MAX_IDLE_TIME, 300)
+	options.SetDefault(CONNECTION_DATABASE_STARTUP_PING_ATTEMPTS, 5)
+	options.SetDefault(CONNECTION_DATABASE_STARTUP_PING_BACKOFF, 500)
+	options.SetDefault(CONNECTION_DATABASE_STARTUP_PING_MAX_BACKOFF, 10)
+	options.SetDefault(READINESS_CHECK_TIMEOUT, 2)
+	options.SetDefault(LIVENESS_SWEEP_INTERVAL, 10)
+	options.SetDefault(LIVENESS_SWEEP_CONNECTION_AGE, 24)
+	options.SetDefault(LIVENESS_SWEEP_CHUNK_SIZE, 500)
+	options.SetDefault(LIVENESS_SWEEP_CONCURRENCY, 20)
+	options.SetDefault(LIVENESS_SWEEP_PING_ATTEMPTS, 3)
+	options.SetDefault(LIVENESS_SWEEP_RESPONSE_TIMEOUT, 30)
+	options.SetDefault(LIVENESS_SWEEP_POLL_INTERVAL, 1000)
+	options.SetDefault(PING_RESPONSE_DEFAULT_TIMEOUT, 10)
+	options.SetDefault(PING_RESPONSE_MAX_TIMEOUT, 30)
+	options.SetDefault(CONNECTION_EVENT_PRUNE_BATCH_SIZE, 1000)
+	options.SetDefault(OFFLINE_CONNECTION_RETENTION, 30*24) // Keep offline connections for 30 days
+	options.SetDefault(DEAD_LETTER_REPLAY_CONSUMER_GROUP, "cloud-connector-dead-letter-replayer")
+	options.SetDefault(CONNECTION_CACHE_ALLOW_STALE, false)
+	options.SetDefault(HANDSHAKE_REQUIRE_MESSAGE_ID, false)
+	options.SetDefault(LIVENESS_SWEEP_MAX_REAPED_PERCENT, 50)
+	options.SetDefault(MESSAGE_RESPONSE_NOTIFICATIONS_ENABLED, false)
+	options.SetDefault(MESSAGE_RESPONSE_POLL_INTERVAL, 1000)
 	options.SetEnvPrefix(ENV_PREFIX)
 	options.AutomaticEnv()
 
 	config := &Config{
-		UrlPathPrefix:                            options.GetString(URL_PATH_PREFIX),
-		UrlAppName:                               options.GetString(URL_APP_NAME),
-		UrlBasePath:                              buildUrlBasePath(options.GetString(URL_PATH_PREFIX), options.GetString(URL_APP_NAME)),
-		OpenApiSpecFilePath:                      options.GetString(OPENAPI_SPEC_FILE_PATH),
-		HttpShutdownTimeout:                      options.GetDuration(HTTP_SHUTDOWN_TIMEOUT) * time.Second,
-		ServiceToServiceCredentials:              options.GetStringMap(SERVICE_TO_SERVICE_CREDENTIALS),
-		Profile:                                  options.GetBool(PROFILE),
-		MqttBrokerAddress:                        options.GetString(MQTT_BROKER_ADDRESS),
-		MqttClientId:                             options.GetString(MQTT_CLIENT_ID),
-		MqttUseHostnameAsClientId:                options.GetBool(MQTT_USE_HOSTNAME_AS_CLIENT_ID),
-		MqttCleanSession:                         options.GetBool(MQTT_CLEAN_SESSION),
-		MqttResumeSubs:                           options.GetBool(MQTT_RESUME_SUBS),
-		MqttBrokerTlsCertFile:                    options.GetString(MQTT_BROKER_TLS_CERT_FILE),
-		MqttBrokerTlsKeyFile:                     options.GetString(MQTT_BROKER_TLS_KEY_FILE),
-		MqttBrokerTlsCACertFile:                  options.GetString(MQTT_BROKER_TLS_CA_CERT_FILE),
-		MqttBrokerTlsSkipVerify:                  options.GetBool(MQTT_BROKER_TLS_SKIP_VERIFY),
-		MqttBrokerAuthType:                       options.GetString(MQTT_BROKER_AUTH_TYPE),
-		MqttBrokerUsername:                       options.GetString(MQTT_BROKER_USERNAME),
-		MqttBrokerPassword:                       options.GetString(MQTT_BROKER_PASSWORD),
-		MqttBrokerJwtGeneratorImpl:               options.GetString(MQTT_BROKER_JWT_GENERATOR_IMPL),
-		MqttBrokerJwtFile:                        options.GetString(MQTT_BROKER_JWT_FILE),
-		MqttTopicPrefix:                          options.GetString(MQTT_TOPIC_PREFIX),
-		MqttControlSubscriptionQoS:               byte(options.GetInt(MQTT_CONTROL_SUBSCRIPTION_QOS)),
-		MqttControlPublishQoS:                    byte(options.GetInt(MQTT_CONTROL_PUBLISH_QOS)),
-		MqttDataSubscriptionQoS:                  byte(options.GetInt(MQTT_DATA_SUBSCRIPTION_QOS)),
-		MqttDataPublishQoS:                       byte(options.GetInt(MQTT_DATA_PUBLISH_QOS)),
-		MqttDisconnectQuiesceTime:                options.GetUint(MQTT_DISCONNECT_QUIESCE_TIME),
-		MqttPublishTimeout:                       options.GetDuration(MQTT_PUBLISH_TIMEOUT) * time.Second,
-		MqttConsumerShutdownSleepTime:            options.GetDuration(MQTT_CONSUMER_SHUTDOWN_SLEEP_TIME) * time.Second,
-		ShutdownOnMqttConnectionLost:             options.GetBool(SHUTDOWN_ON_MQTT_CONNECTION_LOST),
-		InvalidHandshakeReconnectDelay:           options.GetInt(INVALID_HANDSHAKE_RECONNECT_DELAY),
-		ClientIdToAccountIdImpl:                  options.GetString(CLIENT_ID_TO_ACCOUNT_ID_IMPL),
-		ClientIdToAccountIdConfigFile:            options.GetString(CLIENT_ID_TO_ACCOUNT_ID_CONFIG_FILE),
-		ClientIdToAccountIdDefaultAccountId:      options.GetString(CLIENT_ID_TO_ACCOUNT_ID_DEFAULT_ACCOUNT_ID),
-		ClientIdToAccountIdDefaultOrgId:          options.GetString(CLIENT_ID_TO_ACCOUNT_ID_DEFAULT_ORG_ID),
-		ClientIdToAccountIdCacheSize:             options.GetInt(CLIENT_ID_TO_ACCOUNT_ID_CACHE_SIZE),
-		ClientIdToAccountIdCacheValidRespTTL:     options.GetDuration(CLIENT_ID_TO_ACCOUNT_ID_CACHE_VALID_RESP_TTL),
-		ClientIdToAccountIdCacheErrorRespTTL:     options.GetDuration(CLIENT_ID_TO_ACCOUNT_ID_CACHE_ERROR_RESP_TTL),
-		ConnectionDatabaseImpl:                   options.GetString(CONNECTION_DATABASE_IMPL),
-		ConnectionDatabaseHost:                   options.GetString(CONNECTION_DATABASE_HOST),
-		ConnectionDatabasePort:                   options.GetInt(CONNECTION_DATABASE_PORT),
-		ConnectionDatabaseUser:                   options.GetString(CONNECTION_DATABASE_USER),
-		ConnectionDatabasePassword:               options.GetString(CONNECTION_DATABASE_PASSWORD),
-		ConnectionDatabaseName:                   options.GetString(CONNECTION_DATABASE_NAME),
-		ConnectionDatabaseSslMode:                options.GetString(CONNECTION_DATABASE_SSL_MODE),
-		ConnectionDatabaseSslRootCert:            options.GetString(CONNECTION_DATABASE_SSL_ROOT_CERT),
-		ConnectionDatabaseQueryTimeout:           options.GetDuration(CONNECTION_DATABASE_QUERY_TIMEOUT) * time.Second,
-		AuthGatewayUrl:                           options.GetString(AUTH_GATEWAY_URL),
-		AuthGatewayHttpClientTimeout:             options.GetDuration(AUTH_GATEWAY_HTTP_CLIENT_TIMEOUT) * time.Second,
-		ConnectedClientRecorderImpl:              options.GetString(CONNECTED_CLIENT_RECORDER_IMPL),
-		KafkaCA:                                  options.GetString(KAFKA_CA),
-		KafkaUsername:                            options.GetString(KAFKA_USERNAME),
-		KafkaPassword:                            options.GetString(KAFKA_PASSWORD),
-		KafkaSASLMechanism:                       options.GetString(KAFKA_SASL_MECHANISM),
-		InventoryKafkaBrokers:                    options.GetStringSlice(INVENTORY_KAFKA_BROKERS),
-		InventoryKafkaTopic:                      options.GetString(INVENTORY_KAFKA_TOPIC),
-		InventoryKafkaBatchSize:                  options.GetInt(INVENTORY_KAFKA_BATCH_SIZE),
-		InventoryKafkaBatchBytes:                 options.GetInt(INVENTORY_KAFKA_BATCH_BYTES),
-		InventoryStaleTimestampOffset:            options.GetDuration(INVENTORY_STALE_TIMESTAMP_OFFSET) * time.Hour,
-		InventoryStaleTimestampUpdaterChunkSize:  options.GetInt(INVENTORY_STALE_TIMESTAMP_UPDATER_CHUNK_SIZE),
-		InventoryReporterName:                    options.GetString(INVENTORY_REPORTER_NAME),
-		SourcesRecorderImpl:                      options.GetString(SOURCES_RECORDER_IMPL),
-		SourcesBaseUrl:                           options.GetString(SOURCES_BASE_URL),
-		SourcesHttpClientTimeout:                 options.GetDuration(SOURCES_HTTP_CLIENT_TIMEOUT) * time.Second,
-		JwtTokenExpiry:                           options.GetInt(JWT_TOKEN_EXPIRY),
-		JwtPrivateKeyFile:                        options.GetString(JWT_PRIVATE_KEY_FILE),
-		JwtPublicKeyFile:                         options.GetString(JWT_PUBLIC_KEY_FILE),
-		RhcMessageKafkaBrokers:                   options.GetStringSlice(RHC_MESSAGE_KAFKA_BROKERS),
-		RhcMessageKafkaTopic:                     options.GetString(RHC_MESSAGE_KAFKA_TOPIC),
-		RhcMessageKafkaBatchSize:                 options.GetInt(RHC_MESSAGE_KAFKA_BATCH_SIZE),
-		RhcMessageKafkaBatchBytes:                options.GetInt(RHC_MESSAGE_KAFKA_BATCH_BYTES),
-		RhcMessageKafkaConsumerGroup:             options.GetString(RHC_MESSAGE_KAFKA_CONSUMER_GROUP),
-		PendoApiEndpoint:                         options.GetString(PENDO_API_ENDPOINT),
-		PendoRequestTimeout:                      options.GetDuration(PENDO_REQUEST_TIMEOUT) * time.Second,
-		PendoIntegrationKey:                      options.GetString(PENDO_INTEGRATION_KEY),
-		PendoRequestSize:                         options.GetInt(PENDO_REQUEST_SIZE),
-		PrometheusPushGateway:                    options.GetString(PROMETHEUS_PUSH_GATEWAY),
-		ApiServerConnectionLookupImpl:            options.GetString(API_SERVER_CONNECTION_LOOKUP_IMPL),
-		TenantTranslatorImpl:                     options.GetString(TENANT_TRANSLATOR_IMPL),
-		TenantTranslatorMockMapping:              options.GetStringMap(TENANT_TRANSLATOR_MOCK_MAPPING),
-		TenantTranslatorURL:                      options.GetString(TENANT_TRANSLATOR_URL),
-		TenantTranslatorTimeout:                  options.GetDuration(TENANT_TRANSLATOR_TIMEOUT) * time.Second,
-		PurgeConnectionOnFailedTenantLookupCount: options.GetInt(PURGE_CONNECTION_ON_FAILED_TENANT_LOOKUP_COUNT),
-		TenantlessConnectionTimestampOffset:      options.GetDuration(TENANTLESS_CONNECTION_TIMESTAMP_OFFSET) * time.Minute,
-		TenantlessConnectionUpdaterChunkSize:     options.GetInt(TENANTLESS_CONNECTION_UPDATER_CHUNK_SIZE),
-		TenantlessConnectionMaxLookupFailures:    options.GetInt(TENANTLESS_CONNECTION_MAX_LOOKUP_FAILURES),
+		UrlPathPrefix:                             options.GetString(URL_PATH_PREFIX),
+		UrlAppName:                                options.GetString(URL_APP_NAME),
+		UrlBasePath:                               buildUrlBasePath(options.GetString(URL_PATH_PREFIX), options.GetString(URL_APP_NAME)),
+		OpenApiSpecFilePath:                       options.GetString(OPENAPI_SPEC_FILE_PATH),
+		HttpShutdownTimeout:                       options.GetDuration(HTTP_SHUTDOWN_TIMEOUT) * time.Second,
+		ServiceToServiceCredentials:               options.GetStringMap(SERVICE_TO_SERVICE_CREDENTIALS),
+		Profile:                                   options.GetBool(PROFILE),
+		MqttBrokerAddress:                         options.GetString(MQTT_BROKER_ADDRESS),
+		MqttClientId:                              options.GetString(MQTT_CLIENT_ID),
+		MqttUseHostnameAsClientId:                 options.GetBool(MQTT_USE_HOSTNAME_AS_CLIENT_ID),
+		MqttCleanSession:                          options.GetBool(MQTT_CLEAN_SESSION),
+		MqttResumeSubs:                            options.GetBool(MQTT_RESUME_SUBS),
+		MqttBrokerTlsCertFile:                     options.GetString(MQTT_BROKER_TLS_CERT_FILE),
+		MqttBrokerTlsKeyFile:                      options.GetString(MQTT_BROKER_TLS_KEY_FILE),
+		MqttBrokerTlsCACertFile:                   options.GetString(MQTT_BROKER_TLS_CA_CERT_FILE),
+		MqttBrokerTlsSkipVerify:                   options.GetBool(MQTT_BROKER_TLS_SKIP_VERIFY),
+		MqttBrokerAuthType:                        options.GetString(MQTT_BROKER_AUTH_TYPE),
+		MqttBrokerUsername:                        options.GetString(MQTT_BROKER_USERNAME),
+		MqttBrokerPassword:                        options.GetString(MQTT_BROKER_PASSWORD),
+		MqttBrokerJwtGeneratorImpl:                options.GetString(MQTT_BROKER_JWT_GENERATOR_IMPL),
+		MqttBrokerJwtFile:                         options.GetString(MQTT_BROKER_JWT_FILE),
+		MqttTopicPrefix:                           options.GetString(MQTT_TOPIC_PREFIX),
+		MqttControlSubscriptionQoS:                byte(options.GetInt(MQTT_CONTROL_SUBSCRIPTION_QOS)),
+		MqttControlPublishQoS:                     byte(options.GetInt(MQTT_CONTROL_PUBLISH_QOS)),
+		MqttDataSubscriptionQoS:                   byte(options.GetInt(MQTT_DATA_SUBSCRIPTION_QOS)),
+		MqttDataPublishQoS:                        byte(options.GetInt(MQTT_DATA_PUBLISH_QOS)),
+		MqttDisconnectQuiesceTime:                 options.GetUint(MQTT_DISCONNECT_QUIESCE_TIME),
+		MqttPublishTimeout:                        options.GetDuration(MQTT_PUBLISH_TIMEOUT) * time.Second,
+		MqttConsumerShutdownSleepTime:             options.GetDuration(MQTT_CONSUMER_SHUTDOWN_SLEEP_TIME) * time.Second,
+		ShutdownOnMqttConnectionLost:              options.GetBool(SHUTDOWN_ON_MQTT_CONNECTION_LOST),
+		InvalidHandshakeReconnectDelay:            options.GetInt(INVALID_HANDSHAKE_RECONNECT_DELAY),
+		ClientIdToAccountIdImpl:                   options.GetString(CLIENT_ID_TO_ACCOUNT_ID_IMPL),
+		ClientIdToAccountIdConfigFile:             options.GetString(CLIENT_ID_TO_ACCOUNT_ID_CONFIG_FILE),
+		ClientIdToAccountIdDefaultAccountId:       options.GetString(CLIENT_ID_TO_ACCOUNT_ID_DEFAULT_ACCOUNT_ID),
+		ClientIdToAccountIdDefaultOrgId:           options.GetString(CLIENT_ID_TO_ACCOUNT_ID_DEFAULT_ORG_ID),
+		ClientIdToAccountIdCacheSize:              options.GetInt(CLIENT_ID_TO_ACCOUNT_ID_CACHE_SIZE),
+		ClientIdToAccountIdCacheValidRespTTL:      options.GetDuration(CLIENT_ID_TO_ACCOUNT_ID_CACHE_VALID_RESP_TTL),
+		ClientIdToAccountIdCacheErrorRespTTL:      options.GetDuration(CLIENT_ID_TO_ACCOUNT_ID_CACHE_ERROR_RESP_TTL),
+		ConnectionDatabaseImpl:                    options.GetString(CONNECTION_DATABASE_IMPL),
+		ConnectionDatabaseHost:                    options.GetString(CONNECTION_DATABASE_HOST),
+		ConnectionDatabasePort:                    options.GetInt(CONNECTION_DATABASE_PORT),
+		ConnectionDatabaseUser:                    options.GetString(CONNECTION_DATABASE_USER),
+		ConnectionDatabasePassword:                options.GetString(CONNECTION_DATABASE_PASSWORD),
+		ConnectionDatabaseName:                    options.GetString(CONNECTION_DATABASE_NAME),
+		ConnectionDatabaseSslMode:                 options.GetString(CONNECTION_DATABASE_SSL_MODE),
+		ConnectionDatabaseSslRootCert:             options.GetString(CONNECTION_DATABASE_SSL_ROOT_CERT),
+		ConnectionDatabaseQueryTimeout:            options.GetDuration(CONNECTION_DATABASE_QUERY_TIMEOUT) * time.Second,
+		AuthGatewayUrl:                            options.GetString(AUTH_GATEWAY_URL),
+		AuthGatewayHttpClientTimeout:              options.GetDuration(AUTH_GATEWAY_HTTP_CLIENT_TIMEOUT) * time.Second,
+		ConnectedClientRecorderImpl:               options.GetString(CONNECTED_CLIENT_RECORDER_IMPL),
+		KafkaCA:                                   options.GetString(KAFKA_CA),
+		KafkaUsername:                             options.GetString(KAFKA_USERNAME),
+		KafkaPassword:                             options.GetString(KAFKA_PASSWORD),
+		KafkaSASLMechanism:                        options.GetString(KAFKA_SASL_MECHANISM),
+		InventoryKafkaBrokers:                     options.GetStringSlice(INVENTORY_KAFKA_BROKERS),
+		InventoryKafkaTopic:                       options.GetString(INVENTORY_KAFKA_TOPIC),
+		InventoryKafkaBatchSize:                   options.GetInt(INVENTORY_KAFKA_BATCH_SIZE),
+		InventoryKafkaBatchBytes:                  options.GetInt(INVENTORY_KAFKA_BATCH_BYTES),
+		InventoryStaleTimestampOffset:             options.GetDuration(INVENTORY_STALE_TIMESTAMP_OFFSET) * time.Hour,
+		InventoryStaleTimestampUpdaterChunkSize:   options.GetInt(INVENTORY_STALE_TIMESTAMP_UPDATER_CHUNK_SIZE),
+		InventoryReporterName:                     options.GetString(INVENTORY_REPORTER_NAME),
+		SourcesRecorderImpl:                       options.GetString(SOURCES_RECORDER_IMPL),
+		SourcesBaseUrl:                            options.GetString(SOURCES_BASE_URL),
+		SourcesHttpClientTimeout:                  options.GetDuration(SOURCES_HTTP_CLIENT_TIMEOUT) * time.Second,
+		JwtTokenExpiry:                            options.GetInt(JWT_TOKEN_EXPIRY),
+		JwtPrivateKeyFile:                         options.GetString(JWT_PRIVATE_KEY_FILE),
+		JwtPublicKeyFile:                          options.GetString(JWT_PUBLIC_KEY_FILE),
+		RhcMessageKafkaBrokers:                    options.GetStringSlice(RHC_MESSAGE_KAFKA_BROKERS),
+		RhcMessageKafkaTopic:                      options.GetString(RHC_MESSAGE_KAFKA_TOPIC),
+		RhcMessageKafkaBatchSize:                  options.GetInt(RHC_MESSAGE_KAFKA_BATCH_SIZE),
+		RhcMessageKafkaBatchBytes:                 options.GetInt(RHC_MESSAGE_KAFKA_BATCH_BYTES),
+		RhcMessageKafkaConsumerGroup:              options.GetString(RHC_MESSAGE_KAFKA_CONSUMER_GROUP),
+		DataMessageKafkaBrokers:                   options.GetStringSlice(DATA_MESSAGE_KAFKA_BROKERS),
+		DataMessageKafkaTopics:                    options.GetStringMapString(DATA_MESSAGE_KAFKA_TOPICS),
+		DataMessageKafkaBatchSize:                 options.GetInt(DATA_MESSAGE_KAFKA_BATCH_SIZE),
+		DataMessageKafkaBatchBytes:                options.GetInt(DATA_MESSAGE_KAFKA_BATCH_BYTES),
+		MessageAcknowledgementTimeout:             options.GetDuration(MESSAGE_ACKNOWLEDGEMENT_TIMEOUT) * time.Minute,
+		PendingMessageDefaultTTL:                  options.GetDuration(PENDING_MESSAGE_DEFAULT_TTL) * time.Second,
+		PendingMessageMaxTTL:                      options.GetDuration(PENDING_MESSAGE_MAX_TTL) * time.Second,
+		BulkMessageMaxRecipients:                  options.GetInt(BULK_MESSAGE_MAX_RECIPIENTS),
+		BulkMessageConcurrency:                    options.GetInt(BULK_MESSAGE_CONCURRENCY),
+		PendoApiEndpoint:                          options.GetString(PENDO_API_ENDPOINT),
+		PendoRequestTimeout:                       options.GetDuration(PENDO_REQUEST_TIMEOUT) * time.Second,
+		PendoIntegrationKey:                       options.GetString(PENDO_INTEGRATION_KEY),
+		PendoRequestSize:                          options.GetInt(PENDO_REQUEST_SIZE),
+		PrometheusPushGateway:                     options.GetString(PROMETHEUS_PUSH_GATEWAY),
+		ApiServerConnectionLookupImpl:             options.GetString(API_SERVER_CONNECTION_LOOKUP_IMPL),
+		TenantTranslatorImpl:                      options.GetString(TENANT_TRANSLATOR_IMPL),
+		TenantTranslatorMockMapping:               options.GetStringMap(TENANT_TRANSLATOR_MOCK_MAPPING),
+		TenantTranslatorURL:                       options.GetString(TENANT_TRANSLATOR_URL),
+		TenantTranslatorTimeout:                   options.GetDuration(TENANT_TRANSLATOR_TIMEOUT) * time.Second,
+		PurgeConnectionOnFailedTenantLookupCount:  options.GetInt(PURGE_CONNECTION_ON_FAILED_TENANT_LOOKUP_COUNT),
+		TenantlessConnectionTimestampOffset:       options.GetDuration(TENANTLESS_CONNECTION_TIMESTAMP_OFFSET) * time.Minute,
+		TenantlessConnectionUpdaterChunkSize:      options.GetInt(TENANTLESS_CONNECTION_UPDATER_CHUNK_SIZE),
+		TenantlessConnectionMaxLookupFailures:     options.GetInt(TENANTLESS_CONNECTION_MAX_LOOKUP_FAILURES),
+		ConnectionEventRetention:                  options.GetDuration(CONNECTION_EVENT_RETENTION) * time.Hour,
+		ConnectionRegistrarImpl:                   options.GetString(CONNECTION_REGISTRAR_IMPL),
+		SendMessageStrictDirectiveCheck:           options.GetBool(SEND_MESSAGE_STRICT_DIRECTIVE_CHECK),
+		MqttProtocolVersion:                       options.GetInt(MQTT_PROTOCOL_VERSION),
+		MqttDataMessageExpiry:                     options.GetDuration(MQTT_DATA_MESSAGE_EXPIRY) * time.Second,
+		MqttSharedSubscriptionGroup:               options.GetString(MQTT_SHARED_SUBSCRIPTION_GROUP),
+		MqttKafkaSpoolDirectory:                   options.GetString(MQTT_KAFKA_SPOOL_DIRECTORY),
+		MqttKafkaSpoolMaxBytes:                    options.GetInt(MQTT_KAFKA_SPOOL_MAX_BYTES),
+		MqttKafkaSpoolSegmentMaxBytes:             options.GetInt(MQTT_KAFKA_SPOOL_SEGMENT_MAX_BYTES),
+		MqttKafkaSpoolReplayInterval:              options.GetDuration(MQTT_KAFKA_SPOOL_REPLAY_INTERVAL) * time.Second,
+		MqttKafkaSpoolReplayBatchSize:             options.GetInt(MQTT_KAFKA_SPOOL_REPLAY_BATCH_SIZE),
+		DeadLetterKafkaTopic:                      options.GetString(DEAD_LETTER_KAFKA_TOPIC),
+		KafkaMessageConsumerConcurrency:           options.GetInt(KAFKA_MESSAGE_CONSUMER_CONCURRENCY),
+		ConnectionRegistrarBatchWindow:            options.GetDuration(CONNECTION_REGISTRAR_BATCH_WINDOW) * time.Millisecond,
+		ConnectionRegistrarMaxBatchSize:           options.GetInt(CONNECTION_REGISTRAR_MAX_BATCH_SIZE),
+		ConnectionCacheImpl:                       options.GetString(CONNECTION_CACHE_IMPL),
+		ConnectionCacheSize:                       options.GetInt(CONNECTION_CACHE_SIZE),
+		ConnectionCacheTTL:                        options.GetDuration(CONNECTION_CACHE_TTL) * time.Second,
+		ConnectionCacheRedisAddress:               options.GetString(CONNECTION_CACHE_REDIS_ADDRESS),
+		ConnectionCacheRedisPassword:              options.GetString(CONNECTION_CACHE_REDIS_PASSWORD),
+		ConnectionDatabaseReusePreparedStatements: options.GetBool(CONNECTION_DATABASE_REUSE_PREPARED_STATEMENTS),
+		ConnectionDatabaseMaxOpenConnections:      options.GetInt(CONNECTION_DATABASE_MAX_OPEN_CONNECTIONS),
+		ConnectionDatabaseMaxIdleConnections:      options.GetInt(CONNECTION_DATABASE_MAX_IDLE_CONNECTIONS),
+		ConnectionDatabaseConnectionMaxLifetime:   options.GetDuration(CONNECTION_DATABASE_CONNECTION_MAX_LIFETIME) * time.Second,
+		ConnectionDatabaseConnectionMaxIdleTime:   options.GetDuration(CONNECTION_DATABASE_CONNECTION_MAX_IDLE_TIME) * time.Second,
+		ConnectionDatabaseStartupPingAttempts:     options.GetInt(CONNECTION_DATABASE_STARTUP_PING_ATTEMPTS),
+		ConnectionDatabaseStartupPingBackoff:      options.GetDuration(CONNECTION_DATABASE_STARTUP_PING_BACKOFF) * time.Millisecond,
+		ConnectionDatabaseStartupPingMaxBackoff:   options.GetDuration(CONNECTION_DATABASE_STARTUP_PING_MAX_BACKOFF) * time.Second,
+		ReadinessCheckTimeout:                     options.GetDuration(READINESS_CHECK_TIMEOUT) * time.Second,
+		LivenessSweepInterval:                     options.GetDuration(LIVENESS_SWEEP_INTERVAL) * time.Minute,
+		LivenessSweepConnectionAge:                options.GetDuration(LIVENESS_SWEEP_CONNECTION_AGE) * time.Hour,
+		LivenessSweepChunkSize:                    options.GetInt(LIVENESS_SWEEP_CHUNK_SIZE),
+		LivenessSweepConcurrency:                  options.GetInt(LIVENESS_SWEEP_CONCURRENCY),
+		LivenessSweepPingAttempts:                 options.GetInt(LIVENESS_SWEEP_PING_ATTEMPTS),
+		LivenessSweepResponseTimeout:              options.GetDuration(LIVENESS_SWEEP_RESPONSE_TIMEOUT) * time.Second,
+		LivenessSweepPollInterval:                 options.GetDuration(LIVENESS_SWEEP_POLL_INTERVAL) * time.Millisecond,
+		PingResponseDefaultTimeout:                options.GetDuration(PING_RESPONSE_DEFAULT_TIMEOUT) * time.Second,
+		PingResponseMaxTimeout:                    options.GetDuration(PING_RESPONSE_MAX_TIMEOUT) * time.Second,
+		ConnectionEventPruneBatchSize:             options.GetInt(CONNECTION_EVENT_PRUNE_BATCH_SIZE),
+		OfflineConnectionRetention:                options.GetDuration(OFFLINE_CONNECTION_RETENTION) * time.Hour,
+		DeadLetterReplayConsumerGroup:             options.GetString(DEAD_LETTER_REPLAY_CONSUMER_GROUP),
+		ConnectionCacheAllowStale:                 options.GetBool(CONNECTION_CACHE_ALLOW_STALE),
+		HandshakeRequireMessageID:                 options.GetBool(HANDSHAKE_REQUIRE_MESSAGE_ID),
+		LivenessSweepMaxReapedPercent:             options.GetInt(LIVENESS_SWEEP_MAX_REAPED_PERCENT),
+		MessageResponseNotificationsEnabled:       options.GetBool(MESSAGE_RESPONSE_NOTIFICATIONS_ENABLED),
+		MessageResponsePollInterval:               options.GetDuration(MESSAGE_RESPONSE_POLL_INTERVAL) * time.Millisecond,
 	}
 
 	if clowder.IsClowderEnabled() {
@@ -461,6 +735,13 @@ func GetConfig() *Config {
 		config.RhcMessageKafkaBrokers = clowder.KafkaServers
 		config.RhcMessageKafkaTopic = clowder.KafkaTopics[RHC_MESSAGE_KAFKA_TOPIC_DEFAULT].Name
 
+		config.DataMessageKafkaBrokers = clowder.KafkaServers
+		for directive, requestedTopic := range config.DataMessageKafkaTopics {
+			if topic, ok := clowder.KafkaTopics[requestedTopic]; ok {
+				config.DataMessageKafkaTopics[directive] = topic.Name
+			}
+		}
+
 		if broker.Authtype != nil {
 			config.KafkaUsername = *broker.Sasl.Username
 			config.KafkaPassword = *broker.Sasl.Password
@@ -490,6 +771,13 @@ func GetConfig() *Config {
 
 			config.ConnectionDatabaseSslRootCert = pathToDBCertFile
 		}
+
+		if cfg.InMemoryDb != nil {
+			config.ConnectionCacheRedisAddress = fmt.Sprintf("%s:%d", cfg.InMemoryDb.Hostname, cfg.InMemoryDb.Port)
+			if cfg.InMemoryDb.Password != nil {
+				config.ConnectionCacheRedisPassword = *cfg.InMemoryDb.Password
+			}
+		}
 	}
 
 	return config
@@ -0,0 +1,248 @@
+package connection_repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/RedHatInsights/cloud-connector/internal/domain"
+	"github.com/RedHatInsights/cloud-connector/internal/platform/logger"
+
+	expirable_lru "github.com/hashicorp/golang-lru/v2/expirable"
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+)
+
+const redisConnectionCacheKeyPrefix = "cloud-connector:connection:"
+
+// ConnectionCache stores the results of connection lookups.  The results are keyed by
+// client id and the org id used to perform the lookup so that a single client id can be
+// invalidated regardless of which tenant looked it up.
+type ConnectionCache interface {
+	Get(context.Context, domain.OrgID, domain.ClientID) (domain.ConnectorClientState, bool, error)
+	Add(context.Context, domain.OrgID, domain.ClientID, domain.ConnectorClientState) error
+	Invalidate(context.Context, domain.ClientID) error
+}
+
+// NewCachingGetConnectionByClientID wraps a connection lookup with a cache.  Only
+// successful lookups are cached.  A connection that can't be found is looked up again
+// on the next request so that new connections are visible right away.
+func NewCachingGetConnectionByClientID(cache ConnectionCache, lookup GetConnectionByClientID) GetConnectionByClientID {
+
+	return func(ctx context.Context, log *logrus.Entry, orgId domain.OrgID, clientId domain.ClientID) (domain.ConnectorClientState, error) {
+
+		clientState, found, err := cache.Get(ctx, orgId, clientId)
+		if err != nil {
+			// Fall back to the database if the cache is unavailable
+			metrics.connectionCacheError.Inc()
+			logger.LogWithError(log, "Unable to read connection from cache", err)
+		} else if found {
+			metrics.connectionCacheHit.Inc()
+			log.Tracef("Found cached connection - org id: %s, client id: %s", orgId, clientId)
+			return clientState, nil
+		}
+
+		metrics.connectionCacheMiss.Inc()
+
+		clientState, err = lookup(ctx, log, orgId, clientId)
+		if err != nil {
+			return clientState, err
+		}
+
+		if err = cache.Add(ctx, orgId, clientId, clientState); err != nil {
+			metrics.connectionCacheError.Inc()
+			logger.LogWithError(log, "Unable to add connection to cache", err)
+		}
+
+		return clientState, nil
+	}
+}
+
+type InMemoryConnectionCache struct {
+	lock  sync.Mutex
+	cache *expirable_lru.LRU[domain.ClientID, map[domain.OrgID]domain.ConnectorClientState]
+}
+
+// NewInMemoryConnectionCache creates a cache that is local to the process.  Connection
+// state changes made by the kafka consumer cannot invalidate this cache, so stale entries
+// are only removed when they expire.
+func NewInMemoryConnectionCache(cacheSize int, cacheTTL time.Duration) (*InMemoryConnectionCache, error) {
+
+	if cacheSize < 1 {
+		return nil, fmt.Errorf("invalid connection cache size: %d", cacheSize)
+	}
+
+	cache := expirable_lru.NewLRU[domain.ClientID, map[domain.OrgID]domain.ConnectorClientState](cacheSize, nil, cacheTTL)
+
+	return &InMemoryConnectionCache{cache: cache}, nil
+}
+
+func (imcc *InMemoryConnectionCache) Get(ctx context.Context, orgId domain.OrgID, clientId domain.ClientID) (domain.ConnectorClientState, bool, error) {
+	imcc.lock.Lock()
+	defer imcc.lock.Unlock()
+
+	lookups, found := imcc.cache.Get(clientId)
+	if found == false {
+		return domain.ConnectorClientState{}, false, nil
+	}
+
+	clientState, found := lookups[orgId]
+	return clientState, found, nil
+}
+
+func (imcc *InMemoryConnectionCache) Add(ctx context.Context, orgId domain.OrgID, clientId domain.ClientID, clientState domain.ConnectorClientState) error {
+	imcc.lock.Lock()
+	defer imcc.lock.Unlock()
+
+	lookups, found := imcc.cache.Peek(clientId)
+	if found == false {
+		lookups = make(map[domain.OrgID]domain.ConnectorClientState)
+	}
+
+	lookups[orgId] = clientState
+
+	imcc.cache.Add(clientId, lookups)
+
+	return nil
+}
+
+func (imcc *InMemoryConnectionCache) Invalidate(ctx context.Context, clientId domain.ClientID) error {
+	imcc.lock.Lock()
+	defer imcc.lock.Unlock()
+
+	if imcc.cache.Remove(clientId) {
+		metrics.connectionCacheInvalidation.Inc()
+	}
+
+	return nil
+}
+
+// RedisConnectionCache stores each client id as a redis hash keyed by the org id used
+// to look up the connection.  The cache is shared between the api servers and the kafka
+// consumer, which allows the kafka consumer to invalidate connections as their state changes.
+type RedisConnectionCache struct {
+	client   *redis.Client
+	cacheTTL time.Duration
+}
+
+func NewRedisConnectionCache(client *redis.Client, cacheTTL time.Duration) *RedisConnectionCache {
+	return &RedisConnectionCache{client: client, cacheTTL: cacheTTL}
+}
+
+func buildRedisConnectionCacheKey(clientId domain.ClientID) string {
+	return redisConnectionCacheKeyPrefix + string(clientId)
+}
+
+func (rcc *RedisConnectionCache) Get(ctx context.Context, orgId domain.OrgID, clientId domain.ClientID) (domain.ConnectorClientState, bool, error) {
+	var clientState domain.ConnectorClientState
+
+	serializedClientState, err := rcc.client.HGet(ctx, buildRedisConnectionCacheKey(clientId), string(orgId)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return clientState, false, nil
+	} else if err != nil {
+		return clientState, false, err
+	}
+
+	if err = json.Unmarshal(serializedClientState, &clientState); err != nil {
+		return clientState, false, err
+	}
+
+	return clientState, true, nil
+}
+
+func (rcc *RedisConnectionCache) Add(ctx context.Context, orgId domain.OrgID, clientId domain.ClientID, clientState domain.ConnectorClientState) error {
+
+	serializedClientState, err := json.Marshal(clientState)
+	if err != nil {
+		return err
+	}
+
+	key := buildRedisConnectionCacheKey(clientId)
+
+	_, err = rcc.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key, string(orgId), serializedClientState)
+		pipe.Expire(ctx, key, rcc.cacheTTL)
+		return nil
+	})
+
+	return err
+}
+
+func (rcc *RedisConnectionCache) Invalidate(ctx context.Context, clientId domain.ClientID) error {
+
+	removed, err := rcc.client.Del(ctx, buildRedisConnectionCacheKey(clientId)).Result()
+	if err != nil {
+		return err
+	}
+
+	if removed > 0 {
+		metrics.connectionCacheInvalidation.Inc()
+	}
+
+	return nil
+}
+
+// CacheInvalidatingConnectionRegistrar removes a connection from the connection cache
+// whenever the connection is registered or unregistered.  A lookup that is in progress
+// while the connection is invalidated can still cache the previous state of the
+// connection.  The cache TTL limits how long that state can be returned.
+type CacheInvalidatingConnectionRegistrar struct {
+	ConnectionRegistrar
+	cache ConnectionCache
+}
+
+func NewCacheInvalidatingConnectionRegistrar(registrar ConnectionRegistrar, cache ConnectionCache) *CacheInvalidatingConnectionRegistrar {
+	return &CacheInvalidatingConnectionRegistrar{
+		ConnectionRegistrar: registrar,
+		cache:               cache,
+	}
+}
+
+func (cicr *CacheInvalidatingConnectionRegistrar) Register(ctx context.Context, rhcClient domain.ConnectorClientState) error {
+
+	err := cicr.ConnectionRegistrar.Register(ctx, rhcClient)
+	if err != nil {
+		return err
+	}
+
+	cicr.invalidate(ctx, rhcClient.ClientID)
+
+	return nil
+}
+
+func (cicr *CacheInvalidatingConnectionRegistrar) Unregister(ctx context.Context, clientID domain.ClientID, latestMessage domain.MessageMetadata) error {
+
+	err := cicr.ConnectionRegistrar.Unregister(ctx, clientID, latestMessage)
+	if err != nil {
+		return err
+	}
+
+	cicr.invalidate(ctx, clientID)
+
+	return nil
+}
+
+func (cicr *CacheInvalidatingConnectionRegistrar) UnregisterIfUnchanged(ctx context.Context, clientID domain.ClientID, latestMessage domain.MessageMetadata) error {
+
+	err := cicr.ConnectionRegistrar.UnregisterIfUnchanged(ctx, clientID, latestMessage)
+	if err != nil {
+		return err
+	}
+
+	cicr.invalidate(ctx, clientID)
+
+	return nil
+}
+
+func (cicr *CacheInvalidatingConnectionRegistrar) invalidate(ctx context.Context, clientID domain.ClientID) {
+
+	// The connection has already been updated in the database.  Failing to invalidate the
+	// cache only means that a stale connection is returned until the cache entry expires.
+	if err := cicr.cache.Invalidate(ctx, clientID); err != nil {
+		metrics.connectionCacheError.Inc()
+		logger.LogWithError(logger.Log.WithFields(logrus.Fields{"client_id": clientID}), "Unable to invalidate cached connection", err)
+	}
+}
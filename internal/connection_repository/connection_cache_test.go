@@ -0,0 +1,166 @@
+package connection_repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/cloud-connector/internal/domain"
+	"github.com/RedHatInsights/cloud-connector/internal/platform/logger"
+
+	"github.com/sirupsen/logrus"
+)
+
+func init() {
+	logger.InitLogger()
+}
+
+type mockConnectionRegistrar struct {
+	ConnectionRegistrar
+	registerErr error
+}
+
+func (mcr *mockConnectionRegistrar) Register(ctx context.Context, rhcClient domain.ConnectorClientState) error {
+	return mcr.registerErr
+}
+
+func (mcr *mockConnectionRegistrar) Unregister(ctx context.Context, clientID domain.ClientID, latestMessage domain.MessageMetadata) error {
+	return nil
+}
+
+func buildCountingConnectionLookup(lookupCount *int, lookupErr error) GetConnectionByClientID {
+	return func(ctx context.Context, log *logrus.Entry, orgId domain.OrgID, clientId domain.ClientID) (domain.ConnectorClientState, error) {
+		*lookupCount++
+		if lookupErr != nil {
+			return domain.ConnectorClientState{}, lookupErr
+		}
+		return domain.ConnectorClientState{OrgID: orgId, ClientID: clientId, State: domain.ConnectionOnline}, nil
+	}
+}
+
+func TestCachingGetConnectionByClientID(t *testing.T) {
+
+	cache, err := NewInMemoryConnectionCache(10, time.Minute)
+	if err != nil {
+		t.Fatal("unexpected error while creating the cache", err)
+	}
+
+	lookupCount := 0
+	getConnection := NewCachingGetConnectionByClientID(cache, buildCountingConnectionLookup(&lookupCount, nil))
+
+	log := logger.Log.WithFields(logrus.Fields{})
+
+	for i := 0; i < 3; i++ {
+		clientState, err := getConnection(context.TODO(), log, "1234", "client-1")
+		if err != nil {
+			t.Fatal("unexpected error while looking up a connection", err)
+		}
+
+		if clientState.ClientID != "client-1" || clientState.OrgID != "1234" {
+			t.Fatalf("unexpected connection: %+v", clientState)
+		}
+	}
+
+	if lookupCount != 1 {
+		t.Fatalf("expected a single lookup, got %d", lookupCount)
+	}
+
+	// Lookups from a different tenant must not be answered from another tenant's lookup
+	if _, err := getConnection(context.TODO(), log, "5678", "client-1"); err != nil {
+		t.Fatal("unexpected error while looking up a connection", err)
+	}
+
+	if lookupCount != 2 {
+		t.Fatalf("expected a second lookup for a different org id, got %d", lookupCount)
+	}
+
+	cache.Invalidate(context.TODO(), "client-1")
+
+	if _, err := getConnection(context.TODO(), log, "1234", "client-1"); err != nil {
+		t.Fatal("unexpected error while looking up a connection", err)
+	}
+
+	if lookupCount != 3 {
+		t.Fatalf("expected a lookup after invalidation, got %d", lookupCount)
+	}
+}
+
+func TestCachingGetConnectionByClientIDDoesNotCacheErrors(t *testing.T) {
+
+	cache, err := NewInMemoryConnectionCache(10, time.Minute)
+	if err != nil {
+		t.Fatal("unexpected error while creating the cache", err)
+	}
+
+	lookupCount := 0
+	getConnection := NewCachingGetConnectionByClientID(cache, buildCountingConnectionLookup(&lookupCount, NotFoundError))
+
+	log := logger.Log.WithFields(logrus.Fields{})
+
+	for i := 0; i < 2; i++ {
+		if _, err := getConnection(context.TODO(), log, "1234", "client-1"); err != NotFoundError {
+			t.Fatalf("expected NotFoundError, got %v", err)
+		}
+	}
+
+	if lookupCount != 2 {
+		t.Fatalf("expected every lookup to reach the database, got %d", lookupCount)
+	}
+}
+
+func TestCacheInvalidatingConnectionRegistrar(t *testing.T) {
+
+	cache, err := NewInMemoryConnectionCache(10, time.Minute)
+	if err != nil {
+		t.Fatal("unexpected error while creating the cache", err)
+	}
+
+	testCases := []struct {
+		name               string
+		registerErr        error
+		invalidate         func(ConnectionRegistrar) error
+		expectedCacheEntry bool
+	}{
+		{
+			name: "register",
+			invalidate: func(r ConnectionRegistrar) error {
+				return r.Register(context.TODO(), domain.ConnectorClientState{ClientID: "client-1"})
+			},
+			expectedCacheEntry: false,
+		},
+		{
+			name: "unregister",
+			invalidate: func(r ConnectionRegistrar) error {
+				return r.Unregister(context.TODO(), "client-1", domain.MessageMetadata{LatestTimestamp: time.Now()})
+			},
+			expectedCacheEntry: false,
+		},
+		{
+			name:        "stale registration",
+			registerErr: StaleConnectionStateError,
+			invalidate: func(r ConnectionRegistrar) error {
+				r.Register(context.TODO(), domain.ConnectorClientState{ClientID: "client-1"})
+				return nil
+			},
+			expectedCacheEntry: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			cache.Add(context.TODO(), "1234", "client-1", domain.ConnectorClientState{ClientID: "client-1"})
+
+			registrar := NewCacheInvalidatingConnectionRegistrar(&mockConnectionRegistrar{registerErr: tc.registerErr}, cache)
+
+			if err := tc.invalidate(registrar); err != nil {
+				t.Fatal("unexpected error", err)
+			}
+
+			_, found, _ := cache.Get(context.TODO(), "1234", "client-1")
+			if found != tc.expectedCacheEntry {
+				t.Fatalf("expected cache entry to be present: %t, got %t", tc.expectedCacheEntry, found)
+			}
+		})
+	}
+}
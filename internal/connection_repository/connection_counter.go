@@ -18,11 +18,11 @@ func ProcessConnectionCounts(ctx context.Context, databaseConn *sql.DB, sqlTimeo
 	queryCtx, cancel := context.WithTimeout(ctx, sqlTimeout)
 	defer cancel()
 
-	queryStmt := `SELECT account, COUNT(1) AS connection_count FROM connections`
+	queryStmt := `SELECT account, COUNT(1) AS connection_count FROM connections WHERE state = 'online'`
 
 	if len(accountsToExclude) > 0 {
 		inClause := strings.Join(accountsToExclude, "','")
-		queryStmt = fmt.Sprintf(" %s WHERE account NOT IN ('%s')", queryStmt, inClause)
+		queryStmt = fmt.Sprintf(" %s AND account NOT IN ('%s')", queryStmt, inClause)
 	}
 
 	queryStmt = fmt.Sprintf(" %s GROUP BY account ORDER BY connection_count DESC", queryStmt)
@@ -41,3 +41,36 @@ func deserializeTags(log *logrus.Entry, serializedTags sql.NullString) domain.Ta
 	}
 	return tags
 }
+
+func deserializeEventContent(log *logrus.Entry, serializedContent sql.NullString) interface{} {
+	var content interface{}
+	if serializedContent.Valid {
+		err := json.Unmarshal([]byte(serializedContent.String), &content)
+		if err != nil {
+			log.WithFields(logrus.Fields{"error": err}).Error("Unable to unmarshal event content from database")
+		}
+	}
+	return content
+}
+
+func deserializeMessageMetadata(log *logrus.Entry, serializedMetadata sql.NullString) interface{} {
+	var metadata interface{}
+	if serializedMetadata.Valid {
+		err := json.Unmarshal([]byte(serializedMetadata.String), &metadata)
+		if err != nil {
+			log.WithFields(logrus.Fields{"error": err}).Error("Unable to unmarshal message metadata from database")
+		}
+	}
+	return metadata
+}
+
+func deserializeMessagePayload(log *logrus.Entry, serializedPayload sql.NullString) interface{} {
+	var payload interface{}
+	if serializedPayload.Valid {
+		err := json.Unmarshal([]byte(serializedPayload.String), &payload)
+		if err != nil {
+			log.WithFields(logrus.Fields{"error": err}).Error("Unable to unmarshal message payload from database")
+		}
+	}
+	return payload
+}
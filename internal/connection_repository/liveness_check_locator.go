@@ -0,0 +1,102 @@
+package connection_repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/RedHatInsights/cloud-connector/internal/domain"
+	"github.com/RedHatInsights/cloud-connector/internal/platform/logger"
+
+	"github.com/sirupsen/logrus"
+)
+
+// GetConnectionsForLivenessCheck returns online connections that have not sent a
+// connection-status message, or been checked for liveness, since the cutoff.  The
+// connections are returned instead of passed to a ConnectionProcessor because checking
+// a connection takes much longer than the query timeout.
+func GetConnectionsForLivenessCheck(ctx context.Context, databaseConn *sql.DB, sqlTimeout time.Duration, cutoff time.Time, chunkSize int) ([]domain.ConnectorClientState, error) {
+
+	queryCtx, cancel := context.WithTimeout(ctx, sqlTimeout)
+	defer cancel()
+
+	statement, err := databaseConn.Prepare(
+		`SELECT account, org_id, client_id, canonical_facts, tags, dispatchers, message_id, message_sent FROM connections
+           WHERE org_id != '' AND
+             state = 'online' AND
+             message_sent < $1 AND
+             ( liveness_check_timestamp IS NULL OR liveness_check_timestamp < $1 )
+             order by COALESCE(liveness_check_timestamp, message_sent) asc
+             limit $2`)
+	if err != nil {
+		return nil, FatalError{err}
+	}
+	defer statement.Close()
+
+	rows, err := statement.QueryContext(queryCtx, cutoff, chunkSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var connections []domain.ConnectorClientState
+
+	for rows.Next() {
+		var account sql.NullString
+		var orgId sql.NullString
+		var clientId domain.ClientID
+		var serializedCanonicalFacts sql.NullString
+		var serializedDispatchers sql.NullString
+		var serializedTags sql.NullString
+		var latestMessageID sql.NullString
+		var latestTimestamp time.Time
+
+		if err := rows.Scan(&account, &orgId, &clientId, &serializedCanonicalFacts, &serializedTags, &serializedDispatchers, &latestMessageID, &latestTimestamp); err != nil {
+			logger.LogError("SQL scan failed.  Skipping row.", err)
+			continue
+		}
+
+		log := logger.Log.WithFields(logrus.Fields{"account": account, "org_id": orgId, "client_id": clientId})
+
+		connectorClientState := domain.ConnectorClientState{
+			OrgID:          domain.OrgID(orgId.String),
+			ClientID:       clientId,
+			CanonicalFacts: deserializeCanonicalFacts(log, serializedCanonicalFacts),
+			Dispatchers:    deserializeDispatchers(log, serializedDispatchers),
+			Tags:           deserializeTags(log, serializedTags),
+			MessageMetadata: domain.MessageMetadata{
+				LatestMessageID: latestMessageID.String,
+				LatestTimestamp: latestTimestamp,
+			},
+		}
+
+		if account.Valid {
+			connectorClientState.Account = domain.AccountID(account.String)
+		}
+
+		connections = append(connections, connectorClientState)
+	}
+
+	return connections, rows.Err()
+}
+
+// RecordSuccessfulLivenessCheck keeps the connection from being checked again until the
+// next time it is older than the liveness check cutoff
+func RecordSuccessfulLivenessCheck(ctx context.Context, databaseConn *sql.DB, sqlTimeout time.Duration, clientID domain.ClientID) error {
+
+	ctx, cancel := context.WithTimeout(ctx, sqlTimeout)
+	defer cancel()
+
+	statement, err := databaseConn.Prepare("UPDATE connections SET liveness_check_timestamp = NOW() WHERE client_id = $1")
+	if err != nil {
+		return FatalError{err}
+	}
+	defer statement.Close()
+
+	_, err = statement.ExecContext(ctx, clientID)
+	if err != nil {
+		return translateSqlError(err)
+	}
+
+	return nil
+}
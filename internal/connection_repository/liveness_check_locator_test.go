@@ -0,0 +1,79 @@
+//go:build sql
+// +build sql
+
+package connection_repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/cloud-connector/internal/config"
+	"github.com/RedHatInsights/cloud-connector/internal/domain"
+	"github.com/RedHatInsights/cloud-connector/internal/platform/db"
+)
+
+func TestGetConnectionsForLivenessCheck(t *testing.T) {
+
+	cfg := config.GetConfig()
+
+	database, err := db.InitializeDatabaseConnection(cfg)
+	if err != nil {
+		t.Fatal("Unable to connect to database: ", err)
+	}
+
+	connectionRegistrar, err := NewSqlConnectionRegistrar(cfg, database)
+	if err != nil {
+		t.Fatal("unexpected error while creating the SqlConnectionRegistrar", err)
+	}
+
+	cutoff := time.Now().Add(-1 * time.Hour)
+
+	testCases := []struct {
+		clientID            domain.ClientID
+		orgID               domain.OrgID
+		messageSent         time.Time
+		livenessChecked     bool
+		expectedToBeChecked bool
+	}{
+		{"liveness-test-client-old", "1234", cutoff.Add(-1 * time.Hour), false, true},
+		{"liveness-test-client-recent", "1234", time.Now(), false, false},
+		{"liveness-test-client-tenantless", "", cutoff.Add(-1 * time.Hour), false, false},
+		{"liveness-test-client-checked", "1234", cutoff.Add(-1 * time.Hour), true, false},
+	}
+
+	for _, tc := range testCases {
+		err := connectionRegistrar.Register(context.TODO(), domain.ConnectorClientState{
+			OrgID:           tc.orgID,
+			ClientID:        tc.clientID,
+			MessageMetadata: domain.MessageMetadata{LatestMessageID: "message", LatestTimestamp: tc.messageSent},
+		})
+		if err != nil {
+			t.Fatal("unexpected error while registering a connection", err)
+		}
+		defer connectionRegistrar.Unregister(context.TODO(), tc.clientID, domain.MessageMetadata{LatestTimestamp: time.Now()})
+
+		if tc.livenessChecked {
+			err = RecordSuccessfulLivenessCheck(context.TODO(), database, cfg.ConnectionDatabaseQueryTimeout, tc.clientID)
+			if err != nil {
+				t.Fatal("unexpected error while recording a liveness check", err)
+			}
+		}
+	}
+
+	connections, err := GetConnectionsForLivenessCheck(context.TODO(), database, cfg.ConnectionDatabaseQueryTimeout, cutoff, 1000)
+	if err != nil {
+		t.Fatal("unexpected error while locating connections for liveness check", err)
+	}
+
+	located := make(map[domain.ClientID]bool)
+	for _, connection := range connections {
+		located[connection.ClientID] = true
+	}
+
+	for _, tc := range testCases {
+		if located[tc.clientID] != tc.expectedToBeChecked {
+			t.Fatalf("expected %s to be located for liveness check: %t, got %t", tc.clientID, tc.expectedToBeChecked, located[tc.clientID])
+		}
+	}
+}
@@ -10,11 +10,34 @@ type connectionRepositoryMetrics struct {
 
 	sqlLookupConnectionByAccountAndClientIDDuration prometheus.Histogram
 	sqlLookupConnectionsByAccountDuration           prometheus.Histogram
+	sqlLookupConnectionsBySelectorDuration          prometheus.Histogram
+	sqlLookupConnectionEventsDuration               prometheus.Histogram
+	sqlConnectionEventRecordDuration                prometheus.Histogram
+	tenantlessConnectionPurgeCount                  prometheus.Counter
 	sqlLookupAllConnectionsDuration                 prometheus.Histogram
 
-	sqlConnectionRegistrationDuration     prometheus.Histogram
-	sqlConnectionUnregistrationDuration   prometheus.Histogram
-	sqlConnectionLookupByClientIDDuration prometheus.Histogram
+	sqlConnectionRegistrationDuration      prometheus.Histogram
+	sqlConnectionBatchRegistrationDuration prometheus.Histogram
+	sqlConnectionRegistrationBatchSize     prometheus.Histogram
+	sqlConnectionUnregistrationDuration    prometheus.Histogram
+	sqlConnectionLookupByClientIDDuration  prometheus.Histogram
+
+	sqlMessageEventRecordDuration  prometheus.Histogram
+	sqlLookupMessageEventsDuration prometheus.Histogram
+
+	sqlMessageRecordDuration prometheus.Histogram
+	sqlLookupMessageDuration prometheus.Histogram
+
+	sqlStatementPrepareCount  prometheus.Counter
+	sqlStatementEvictionCount prometheus.Counter
+
+	connectionCacheHit          prometheus.Counter
+	connectionCacheMiss         prometheus.Counter
+	connectionCacheError        prometheus.Counter
+	connectionCacheInvalidation prometheus.Counter
+
+	sqlPendingMessageStoreDuration  prometheus.Histogram
+	sqlPendingMessageLookupDuration prometheus.Histogram
 }
 
 var metrics *connectionRepositoryMetrics
@@ -37,6 +60,21 @@ func init() {
 		Help: "The amount of time the it took to lookup a connection using account",
 	})
 
+	metrics.sqlLookupConnectionsBySelectorDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name: "cloud_connector_sql_lookup_connections_by_selector",
+		Help: "The amount of time it took to lookup connections using a selector",
+	})
+
+	metrics.sqlLookupConnectionEventsDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name: "cloud_connector_sql_lookup_connection_events",
+		Help: "The amount of time it took to lookup the connection events for a client",
+	})
+
+	metrics.sqlConnectionEventRecordDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name: "cloud_connector_sql_connection_event_record",
+		Help: "The amount of time it took to record a connection event",
+	})
+
 	metrics.sqlLookupAllConnectionsDuration = promauto.NewHistogram(prometheus.HistogramOpts{
 		Name: "cloud_connector_sql_lookup_all_connections",
 		Help: "The amount of time the it took to lookup all connections",
@@ -47,6 +85,17 @@ func init() {
 		Help: "The amount of time the it took to register a connection in the db",
 	})
 
+	metrics.sqlConnectionBatchRegistrationDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name: "cloud_connector_sql_batch_register_connection_duration",
+		Help: "The amount of time the it took to register a batch of connections in the db",
+	})
+
+	metrics.sqlConnectionRegistrationBatchSize = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "cloud_connector_sql_register_connection_batch_size",
+		Help:    "The number of connections registered in a single batch",
+		Buckets: prometheus.ExponentialBuckets(1, 2, 12),
+	})
+
 	metrics.sqlConnectionUnregistrationDuration = promauto.NewHistogram(prometheus.HistogramOpts{
 		Name: "cloud_connector_sql_unregister_connection_duration",
 		Help: "The amount of time the it took to unregister a connection in the db",
@@ -56,4 +105,69 @@ func init() {
 		Name: "cloud_connector_sql_lookup_connection_by_client_id_duration",
 		Help: "The amount of time the it took to register a connection in the db",
 	})
+
+	metrics.sqlMessageEventRecordDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name: "cloud_connector_sql_record_message_event_duration",
+		Help: "The amount of time it took to record a message event in the db",
+	})
+
+	metrics.sqlLookupMessageEventsDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name: "cloud_connector_sql_lookup_message_events_duration",
+		Help: "The amount of time it took to lookup the events for a message",
+	})
+
+	metrics.sqlMessageRecordDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name: "cloud_connector_sql_record_message_duration",
+		Help: "The amount of time it took to record a message in the message ledger",
+	})
+
+	metrics.sqlLookupMessageDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name: "cloud_connector_sql_lookup_message_duration",
+		Help: "The amount of time it took to lookup a message in the message ledger",
+	})
+
+	metrics.tenantlessConnectionPurgeCount = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "cloud_connector_tenantless_connection_purge_count",
+		Help: "The number of tenantless connections purged after repeated tenant lookup failures",
+	})
+
+	metrics.sqlPendingMessageStoreDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name: "cloud_connector_sql_store_pending_message_duration",
+		Help: "The amount of time it took to store a pending message in the db",
+	})
+
+	metrics.sqlPendingMessageLookupDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name: "cloud_connector_sql_lookup_pending_messages_duration",
+		Help: "The amount of time it took to lookup the pending messages for a client",
+	})
+
+	metrics.sqlStatementPrepareCount = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "cloud_connector_sql_statement_prepare_count",
+		Help: "The number of statements prepared by the sql statement cache",
+	})
+
+	metrics.sqlStatementEvictionCount = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "cloud_connector_sql_statement_eviction_count",
+		Help: "The number of prepared statements discarded after the connection to the db was lost",
+	})
+
+	metrics.connectionCacheHit = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "cloud_connector_connection_cache_hit",
+		Help: "The number of connection lookup cache hits",
+	})
+
+	metrics.connectionCacheMiss = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "cloud_connector_connection_cache_miss",
+		Help: "The number of connection lookup cache misses",
+	})
+
+	metrics.connectionCacheError = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "cloud_connector_connection_cache_error",
+		Help: "The number of errors returned by the connection lookup cache",
+	})
+
+	metrics.connectionCacheInvalidation = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "cloud_connector_connection_cache_invalidation",
+		Help: "The number of connections removed from the connection lookup cache",
+	})
 }
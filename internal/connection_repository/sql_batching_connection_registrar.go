@@ -0,0 +1,321 @@
+package connection_repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/RedHatInsights/cloud-connector/internal/config"
+	"github.com/RedHatInsights/cloud-connector/internal/domain"
+	"github.com/RedHatInsights/cloud-connector/internal/platform/logger"
+
+	"github.com/jackc/pgerrcode"
+	"github.com/lib/pq"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+const batchRegistrationColumnCount = 11
+
+var errBatchingRegistrarStopped = errors.New("batching connection registrar has been stopped")
+
+type registrationRequest struct {
+	rhcClient domain.ConnectorClientState
+	result    chan error
+}
+
+// SqlBatchingConnectionRegistrar coalesces the registrations that arrive within a short
+// window into a single multi-row upsert.  Unregister and FindConnectionByClientID are
+// handled by the wrapped registrar.  If a batch fails, the registrations in the batch are
+// retried one at a time using the wrapped registrar.
+type SqlBatchingConnectionRegistrar struct {
+	ConnectionRegistrar
+	database     *sql.DB
+	queryTimeout time.Duration
+	batchWindow  time.Duration
+	maxBatchSize int
+	requests     chan registrationRequest
+	done         chan struct{}
+}
+
+// NewSqlBatchingConnectionRegistrar starts collecting registrations into batches.  The
+// batches stop being processed once the context is cancelled.
+func NewSqlBatchingConnectionRegistrar(ctx context.Context, cfg *config.Config, database *sql.DB, registrar ConnectionRegistrar) (*SqlBatchingConnectionRegistrar, error) {
+
+	// Each kafka consumer worker waits for its registration to be processed, so
+	// a single worker would only ever add latency without batching anything
+	if cfg.KafkaMessageConsumerConcurrency < 2 {
+		return nil, fmt.Errorf("batching registrations requires a kafka message consumer concurrency greater than 1, got %d", cfg.KafkaMessageConsumerConcurrency)
+	}
+
+	maxBatchSize := cfg.ConnectionRegistrarMaxBatchSize
+	if maxBatchSize < 1 {
+		return nil, fmt.Errorf("invalid max batch size: %d", maxBatchSize)
+	}
+
+	// Postgres limits the number of parameters in a single statement
+	if maxBatchSize*batchRegistrationColumnCount > 65535 {
+		return nil, fmt.Errorf("max batch size %d exceeds the postgres parameter limit", maxBatchSize)
+	}
+
+	batchingRegistrar := &SqlBatchingConnectionRegistrar{
+		ConnectionRegistrar: registrar,
+		database:            database,
+		queryTimeout:        cfg.ConnectionDatabaseQueryTimeout,
+		batchWindow:         cfg.ConnectionRegistrarBatchWindow,
+		maxBatchSize:        maxBatchSize,
+		requests:            make(chan registrationRequest, maxBatchSize),
+		done:                make(chan struct{}),
+	}
+
+	go batchingRegistrar.processRegistrations(ctx)
+
+	return batchingRegistrar, nil
+}
+
+func (sbcr *SqlBatchingConnectionRegistrar) Register(ctx context.Context, rhcClient domain.ConnectorClientState) error {
+
+	request := registrationRequest{rhcClient: rhcClient, result: make(chan error, 1)}
+
+	select {
+	case sbcr.requests <- request:
+	case <-sbcr.done:
+		return errBatchingRegistrarStopped
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case err := <-request.result:
+		return err
+	case <-sbcr.done:
+		// The final batch is completed before the registrar stops
+		select {
+		case err := <-request.result:
+			return err
+		default:
+			return errBatchingRegistrarStopped
+		}
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (sbcr *SqlBatchingConnectionRegistrar) processRegistrations(ctx context.Context) {
+	defer close(sbcr.done)
+
+	for {
+		var batch []registrationRequest
+
+		select {
+		case request := <-sbcr.requests:
+			batch = append(batch, request)
+		case <-ctx.Done():
+			return
+		}
+
+		timer := time.NewTimer(sbcr.batchWindow)
+
+	collectLoop:
+		for len(batch) < sbcr.maxBatchSize {
+			select {
+			case request := <-sbcr.requests:
+				batch = append(batch, request)
+			case <-timer.C:
+				break collectLoop
+			}
+		}
+
+		timer.Stop()
+
+		registrations := coalesceRegistrations(batch)
+
+		registered, err := sbcr.registerBatch(registrations)
+		if err != nil && errors.As(err, &FatalError{}) == false {
+			logger.Log.WithFields(logrus.Fields{"error": err, "batch_size": len(batch)}).Warn("Batch registration failed...registering the connections one at a time")
+			sbcr.registerIndividually(batch)
+			continue
+		}
+
+		for _, request := range batch {
+			request.result <- buildRegistrationResult(request.rhcClient, registrations, registered, err)
+		}
+	}
+}
+
+// registerIndividually registers the connections in the order they were received so that a
+// single bad registration does not fail the rest of the batch
+func (sbcr *SqlBatchingConnectionRegistrar) registerIndividually(batch []registrationRequest) {
+	for _, request := range batch {
+		request.result <- sbcr.ConnectionRegistrar.Register(context.Background(), request.rhcClient)
+	}
+}
+
+// coalesceRegistrations keeps a single registration per client id.  A later registration
+// only replaces an earlier one if it is not a duplicate or older message.  Postgres does not
+// allow a single INSERT ... ON CONFLICT statement to update the same row twice.
+func coalesceRegistrations(batch []registrationRequest) []domain.ConnectorClientState {
+
+	registrations := make([]domain.ConnectorClientState, 0, len(batch))
+	index := make(map[domain.ClientID]int, len(batch))
+
+	for _, request := range batch {
+		i, found := index[request.rhcClient.ClientID]
+		if found == false {
+			index[request.rhcClient.ClientID] = len(registrations)
+			registrations = append(registrations, request.rhcClient)
+			continue
+		}
+
+		if isDuplicateOrOldRegistration(registrations[i], request.rhcClient) == false {
+			registrations[i] = request.rhcClient
+		}
+	}
+
+	return registrations
+}
+
+// buildRegistrationResult returns StaleConnectionStateError for the registrations that were
+// dropped while coalescing the batch or that were older than the connection in the database
+func buildRegistrationResult(rhcClient domain.ConnectorClientState, registrations []domain.ConnectorClientState, registered map[domain.ClientID]bool, err error) error {
+
+	if err != nil {
+		return err
+	}
+
+	if registered[rhcClient.ClientID] == false {
+		return StaleConnectionStateError
+	}
+
+	for _, registration := range registrations {
+		if registration.ClientID == rhcClient.ClientID && registration.MessageMetadata != rhcClient.MessageMetadata {
+			return StaleConnectionStateError
+		}
+	}
+
+	return nil
+}
+
+func isDuplicateOrOldRegistration(current domain.ConnectorClientState, incoming domain.ConnectorClientState) bool {
+	return current.MessageMetadata.LatestMessageID == incoming.MessageMetadata.LatestMessageID ||
+		incoming.MessageMetadata.LatestTimestamp.Before(current.MessageMetadata.LatestTimestamp)
+}
+
+func buildBatchRegistrationQuery(registrationCount int) string {
+
+	var values strings.Builder
+
+	for i := 0; i < registrationCount; i++ {
+		if i > 0 {
+			values.WriteString(", ")
+		}
+
+		values.WriteString("(")
+		for column := 1; column <= batchRegistrationColumnCount; column++ {
+			if column > 1 {
+				values.WriteString(", ")
+			}
+			fmt.Fprintf(&values, "$%d", i*batchRegistrationColumnCount+column)
+		}
+		values.WriteString(")")
+	}
+
+	return registerConnectionInsert +
+		"VALUES " + values.String() + " " +
+		registerConnectionOnConflict + " " +
+		"RETURNING client_id"
+}
+
+// registerBatch returns the client ids of the connections that were inserted or updated
+func (sbcr *SqlBatchingConnectionRegistrar) registerBatch(registrations []domain.ConnectorClientState) (map[domain.ClientID]bool, error) {
+
+	callDurationTimer := prometheus.NewTimer(metrics.sqlConnectionBatchRegistrationDuration)
+	defer callDurationTimer.ObserveDuration()
+
+	metrics.sqlConnectionRegistrationBatchSize.Observe(float64(len(registrations)))
+
+	logger := logger.Log.WithFields(logrus.Fields{"batch_size": len(registrations)})
+
+	args := make([]interface{}, 0, len(registrations)*batchRegistrationColumnCount)
+
+	for _, rhcClient := range registrations {
+		var tenantLookupTimestamp *time.Time
+
+		if isTenantlessConnection(rhcClient) {
+			timestamp := time.Now()
+			tenantLookupTimestamp = &timestamp
+		}
+
+		dispatchersString, err := json.Marshal(rhcClient.Dispatchers)
+		if err != nil {
+			logger.WithFields(logrus.Fields{"error": err, "client_id": rhcClient.ClientID, "dispatchers": rhcClient.Dispatchers}).Error("Unable to marshal dispatchers")
+			return nil, err
+		}
+
+		canonicalFactsString, err := json.Marshal(rhcClient.CanonicalFacts)
+		if err != nil {
+			logger.WithFields(logrus.Fields{"error": err, "client_id": rhcClient.ClientID, "canonical_facts": rhcClient.CanonicalFacts}).Error("Unable to marshal canonicalfacts")
+			return nil, err
+		}
+
+		tagsString, err := json.Marshal(rhcClient.Tags)
+		if err != nil {
+			logger.WithFields(logrus.Fields{"error": err, "client_id": rhcClient.ClientID, "tags": rhcClient.Tags}).Error("Unable to marshal tags")
+			return nil, err
+		}
+
+		args = append(args, rhcClient.Account, rhcClient.OrgID, rhcClient.ClientID, dispatchersString, canonicalFactsString, tagsString, rhcClient.MessageMetadata.LatestMessageID, rhcClient.MessageMetadata.LatestTimestamp, tenantLookupTimestamp, rhcClient.ClientName, rhcClient.ClientVersion)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), sbcr.queryTimeout)
+	defer cancel()
+
+	statement, err := sbcr.database.Prepare(buildBatchRegistrationQuery(len(registrations)))
+	if err != nil {
+		logger.WithFields(logrus.Fields{"error": err}).Error("Prepare failed")
+
+		if pqErr, ok := err.(*pq.Error); ok && pgerrcode.IsConnectionException(pqErr.Code.Name()) {
+			// Only mark the error as fatal if we failed to establish a connection to the database
+			return nil, FatalError{err}
+		}
+
+		return nil, err
+	}
+	defer statement.Close()
+
+	rows, err := statement.QueryContext(ctx, args...)
+	if err != nil {
+		logger.WithFields(logrus.Fields{"error": err}).Error("Batch insert/update failed")
+
+		if pqErr, ok := err.(*pq.Error); ok && pgerrcode.IsConnectionException(pqErr.Code.Name()) {
+			// Only mark the error as fatal if we failed to establish a connection to the database
+			return nil, FatalError{err}
+		}
+
+		return nil, err
+	}
+	defer rows.Close()
+
+	registered := make(map[domain.ClientID]bool, len(registrations))
+
+	for rows.Next() {
+		var clientID domain.ClientID
+		if err := rows.Scan(&clientID); err != nil {
+			logger.WithFields(logrus.Fields{"error": err}).Error("SQL scan failed")
+			return nil, err
+		}
+		registered[clientID] = true
+	}
+
+	if err := rows.Err(); err != nil {
+		logger.WithFields(logrus.Fields{"error": err}).Error("Batch insert/update failed")
+		return nil, err
+	}
+
+	logger.WithFields(logrus.Fields{"registered": len(registered)}).Debug("Registered a batch of connections")
+	return registered, nil
+}
@@ -0,0 +1,144 @@
+//go:build sql
+// +build sql
+
+package connection_repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/cloud-connector/internal/config"
+	"github.com/RedHatInsights/cloud-connector/internal/domain"
+	"github.com/RedHatInsights/cloud-connector/internal/platform/db"
+)
+
+func buildBatchRegistration(clientID domain.ClientID, messageID string, sent time.Time) registrationRequest {
+	return registrationRequest{
+		rhcClient: domain.ConnectorClientState{
+			OrgID:           "000001",
+			ClientID:        clientID,
+			MessageMetadata: domain.MessageMetadata{LatestMessageID: messageID, LatestTimestamp: sent},
+		},
+	}
+}
+
+func TestCoalesceRegistrations(t *testing.T) {
+
+	now := time.Now()
+
+	batch := []registrationRequest{
+		buildBatchRegistration("client-1", "1", now),
+		buildBatchRegistration("client-2", "2", now),
+		buildBatchRegistration("client-1", "3", now.Add(time.Second)),  // newer message
+		buildBatchRegistration("client-1", "4", now.Add(-time.Second)), // old message
+		buildBatchRegistration("client-2", "2", now.Add(time.Second)),  // duplicate message
+	}
+
+	registrations := coalesceRegistrations(batch)
+
+	if len(registrations) != 2 {
+		t.Fatalf("expected 2 registrations, got %d", len(registrations))
+	}
+
+	if registrations[0].ClientID != "client-1" || registrations[0].MessageMetadata.LatestMessageID != "3" {
+		t.Errorf("unexpected registration for client-1: %+v", registrations[0].MessageMetadata)
+	}
+
+	if registrations[1].ClientID != "client-2" || registrations[1].MessageMetadata.LatestMessageID != "2" || registrations[1].MessageMetadata.LatestTimestamp != now {
+		t.Errorf("unexpected registration for client-2: %+v", registrations[1].MessageMetadata)
+	}
+}
+
+func TestBuildBatchRegistrationQuery(t *testing.T) {
+
+	query := buildBatchRegistrationQuery(2)
+
+	if strings.Contains(query, "($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11), ($12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22)") == false {
+		t.Fatalf("unexpected values in query: %s", query)
+	}
+
+	if strings.Contains(query, registerConnectionOnConflict+" RETURNING client_id") == false {
+		t.Fatalf("batch query does not use the single row conflict clause: %s", query)
+	}
+}
+
+func TestSqlBatchingConnectionRegistrar(t *testing.T) {
+
+	cfg := config.GetConfig()
+	cfg.ConnectionRegistrarBatchWindow = 50 * time.Millisecond
+	cfg.KafkaMessageConsumerConcurrency = 10
+
+	database, err := db.InitializeDatabaseConnection(cfg)
+	if err != nil {
+		t.Fatal("Unable to connect to database: ", err)
+	}
+
+	sqlRegistrar, err := NewSqlConnectionRegistrar(cfg, database)
+	if err != nil {
+		t.Fatal("unexpected error while creating the SqlConnectionRegistrar", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	connectionRegistrar, err := NewSqlBatchingConnectionRegistrar(ctx, cfg, database, sqlRegistrar)
+	if err != nil {
+		t.Fatal("unexpected error while creating the SqlBatchingConnectionRegistrar", err)
+	}
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	clientCount := 10
+
+	var wg sync.WaitGroup
+	for i := 0; i < clientCount; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			err := connectionRegistrar.Register(context.TODO(), domain.ConnectorClientState{
+				OrgID:           "999991",
+				Account:         "999999",
+				ClientID:        domain.ClientID(fmt.Sprintf("batching-registrar-test-client-%d", i)),
+				Dispatchers:     map[string]interface{}{},
+				MessageMetadata: domain.MessageMetadata{LatestMessageID: fmt.Sprintf("message-%d", i), LatestTimestamp: now},
+			})
+			if err != nil {
+				t.Error("unexpected error while registering a connection", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	clientID := domain.ClientID("batching-registrar-test-client-0")
+
+	verifyConnectionCountByClientID(t, database, clientID, 1)
+
+	// An older message must not overwrite the connection
+	err = connectionRegistrar.Register(context.TODO(), domain.ConnectorClientState{
+		OrgID:           "999991",
+		Account:         "999999",
+		ClientID:        clientID,
+		ClientName:      "old-client",
+		MessageMetadata: domain.MessageMetadata{LatestMessageID: "old-message", LatestTimestamp: now.Add(-time.Minute)},
+	})
+	if err != nil {
+		t.Fatal("unexpected error while registering a connection", err)
+	}
+
+	actualClientState, err := connectionRegistrar.FindConnectionByClientID(context.TODO(), clientID)
+	if err != nil {
+		t.Fatal("unexpected error while looking up a connection", err)
+	}
+
+	if actualClientState.MessageMetadata.LatestMessageID != "message-0" || actualClientState.ClientName == "old-client" {
+		t.Fatalf("connection was overwritten by an old message: %+v", actualClientState)
+	}
+
+	for i := 0; i < clientCount; i++ {
+		connectionRegistrar.Unregister(context.TODO(), domain.ClientID(fmt.Sprintf("batching-registrar-test-client-%d", i)), domain.MessageMetadata{LatestTimestamp: time.Now()})
+	}
+}
@@ -0,0 +1,145 @@
+package connection_repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/RedHatInsights/cloud-connector/internal/config"
+	"github.com/RedHatInsights/cloud-connector/internal/domain"
+	"github.com/RedHatInsights/cloud-connector/internal/platform/logger"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+type SqlConnectionEventRecorder struct {
+	database     *sql.DB
+	statements   statementPreparer
+	queryTimeout time.Duration
+}
+
+func NewSqlConnectionEventRecorder(cfg *config.Config, database *sql.DB) (*SqlConnectionEventRecorder, error) {
+	return &SqlConnectionEventRecorder{
+		database:     database,
+		statements:   newStatementPreparer(cfg, database),
+		queryTimeout: cfg.ConnectionDatabaseQueryTimeout,
+	}, nil
+}
+
+func (scer *SqlConnectionEventRecorder) RecordConnectionEvent(ctx context.Context, event domain.ConnectionEvent) error {
+
+	callDurationTimer := prometheus.NewTimer(metrics.sqlConnectionEventRecordDuration)
+	defer callDurationTimer.ObserveDuration()
+
+	logger := logger.Log.WithFields(logrus.Fields{"org_id": event.OrgID, "account": event.Account, "client_id": event.ClientID, "message_id": event.MessageID, "state": event.State})
+
+	ctx, cancel := context.WithTimeout(ctx, scer.queryTimeout)
+	defer cancel()
+
+	statement, release, err := scer.statements.prepare(
+		`INSERT INTO connection_events (org_id, account, client_id, state, message_id, sent, client_name, client_version)
+            VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`)
+	if err != nil {
+		logger.WithFields(logrus.Fields{"error": err}).Error("Prepare failed")
+		return FatalError{err}
+	}
+	defer func() { release(err) }()
+
+	_, err = statement.ExecContext(ctx, event.OrgID, event.Account, event.ClientID, event.State, event.MessageID, event.Sent, event.ClientName, event.ClientVersion)
+	if err != nil {
+		logger.WithFields(logrus.Fields{"error": err}).Error("Insert failed")
+		return translateSqlError(err)
+	}
+
+	logger.Debug("Recorded a connection event")
+	return nil
+}
+
+func NewSqlGetConnectionEventsByClientID(cfg *config.Config, database *sql.DB) (GetConnectionEventsByClientID, error) {
+
+	statements := newStatementPreparer(cfg, database)
+
+	return func(ctx context.Context, log *logrus.Entry, orgId domain.OrgID, clientId domain.ClientID, offset int, limit int) ([]domain.ConnectionEvent, int, error) {
+
+		var totalEvents int
+
+		err := verifyOrgId(orgId)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		err = verifyClientId(clientId)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		callDurationTimer := prometheus.NewTimer(metrics.sqlLookupConnectionEventsDuration)
+		defer callDurationTimer.ObserveDuration()
+
+		ctx, cancel := context.WithTimeout(ctx, cfg.ConnectionDatabaseQueryTimeout)
+		defer cancel()
+
+		statement, release, err := statements.prepare(
+			`SELECT account, state, message_id, sent, client_name, client_version, created_at, COUNT(*) OVER() FROM connection_events
+                WHERE org_id = $1 AND client_id = $2
+                ORDER BY created_at DESC, id DESC
+                OFFSET $3
+                LIMIT $4`)
+		if err != nil {
+			logger.LogWithError(log, "SQL Prepare failed", err)
+			return nil, totalEvents, err
+		}
+		defer func() { release(err) }()
+
+		rows, err := statement.QueryContext(ctx, orgId, clientId, offset, limit)
+		if err != nil {
+			logger.LogWithError(log, "SQL query failed", err)
+			return nil, totalEvents, err
+		}
+		defer rows.Close()
+
+		events := []domain.ConnectionEvent{}
+
+		for rows.Next() {
+			var accountString sql.NullString
+			var messageID sql.NullString
+			var sent sql.NullTime
+			var clientName sql.NullString
+			var clientVersion sql.NullString
+
+			event := domain.ConnectionEvent{OrgID: orgId, ClientID: clientId}
+
+			if err := rows.Scan(&accountString, &event.State, &messageID, &sent, &clientName, &clientVersion, &event.Created, &totalEvents); err != nil {
+				logger.LogWithError(log, "SQL scan failed.  Skipping row.", err)
+				continue
+			}
+
+			event.Account = domain.AccountID(accountString.String)
+			event.MessageID = messageID.String
+			event.Sent = sent.Time
+			event.ClientName = clientName.String
+			event.ClientVersion = clientVersion.String
+
+			events = append(events, event)
+		}
+
+		return events, totalEvents, nil
+	}, nil
+}
+
+// PruneConnectionEvents removes the connection events that were recorded before the cutoff.
+// The events are removed in batches so that each delete finishes within the sql timeout.
+func PruneConnectionEvents(ctx context.Context, databaseConn *sql.DB, sqlTimeout time.Duration, cutoff time.Time, batchSize int) (int64, error) {
+
+	statement, err := databaseConn.Prepare(
+		`DELETE FROM connection_events WHERE id IN
+            (SELECT id FROM connection_events WHERE created_at < $1 LIMIT $2)`)
+	if err != nil {
+		logger.LogError("SQL Prepare failed", err)
+		return 0, err
+	}
+	defer statement.Close()
+
+	return deleteInBatches(ctx, statement, sqlTimeout, cutoff, batchSize)
+}
@@ -3,34 +3,64 @@ package connection_repository
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
 
 	"github.com/RedHatInsights/cloud-connector/internal/config"
 	"github.com/RedHatInsights/cloud-connector/internal/domain"
 	"github.com/RedHatInsights/cloud-connector/internal/platform/logger"
 
+	"github.com/lib/pq"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sirupsen/logrus"
 )
 
 const (
 	satelliteWorker              = "foreman_rh_cloud"
-	connectionQueryPrefix        = "SELECT  account, org_id, dispatchers, canonical_facts, tags FROM connections "
-	strictConnectionLookupQuery  = connectionQueryPrefix + "WHERE org_id = $1 AND client_id = $2 AND org_id != '' "
-	relaxedConnectionLookupQuery = connectionQueryPrefix + "WHERE (org_id = $1 OR dispatchers ? '" + satelliteWorker + "') AND org_id != '' AND client_id = $2 "
+	onlineConnectionsOnly        = "state = '" + domain.ConnectionOnline + "' "
+	connectionQueryPrefix        = "SELECT  account, org_id, dispatchers, canonical_facts, tags, client_name, client_version, state, disconnected_at FROM connections "
+	strictConnectionLookupQuery  = connectionQueryPrefix + "WHERE org_id = $1 AND client_id = $2 AND org_id != '' AND " + onlineConnectionsOnly
+	relaxedConnectionLookupQuery = connectionQueryPrefix + "WHERE (org_id = $1 OR dispatchers ? '" + satelliteWorker + "') AND org_id != '' AND client_id = $2 AND " + onlineConnectionsOnly
+	statusConnectionLookupQuery  = connectionQueryPrefix + "WHERE org_id = $1 AND client_id = $2 AND org_id != '' "
+
+	connectionsByOrgIDQuery = `SELECT client_id, org_id, account, dispatchers, canonical_facts, tags, client_name, client_version, COUNT(*) OVER() FROM connections
+                WHERE org_id = $1 AND ` + onlineConnectionsOnly + `
+                ORDER BY client_id
+                OFFSET $2
+                LIMIT $3`
+
+	allConnectionsQuery = `SELECT account, org_id, client_id, canonical_facts, dispatchers, tags, client_name, client_version, COUNT(*) OVER() FROM connections
+                WHERE org_id != '' AND ` + onlineConnectionsOnly + `
+				ORDER BY org_id, client_id
+				OFFSET $1
+				LIMIT $2`
 )
 
 func NewSqlGetConnectionByClientID(cfg *config.Config, database *sql.DB) (GetConnectionByClientID, error) {
 
-	return createGetConnectionByClientIDImpl(cfg, database, strictConnectionLookupQuery)
+	return createGetConnectionByClientIDImpl(cfg, perCallStatementPreparer{database}, strictConnectionLookupQuery)
+}
+
+// NewSqlGetConnectionStatusByClientID returns connections regardless of their state.  This
+// allows the caller to report on connections that have been marked as offline.
+func NewSqlGetConnectionStatusByClientID(cfg *config.Config, database *sql.DB) (GetConnectionByClientID, error) {
+
+	return createGetConnectionByClientIDImpl(cfg, perCallStatementPreparer{database}, statusConnectionLookupQuery)
 }
 
 func NewPermittedTenantSqlGetConnectionByClientID(cfg *config.Config, database *sql.DB) (GetConnectionByClientID, error) {
 
+	return createPermittedTenantGetConnectionByClientIDImpl(cfg, perCallStatementPreparer{database})
+}
+
+func createPermittedTenantGetConnectionByClientIDImpl(cfg *config.Config, statements statementPreparer) (GetConnectionByClientID, error) {
+
 	// The "relaxed" / "permitted" tenant logic is basically contained here.
 	// This allows us to reuse a big chunk of the logic required to read the
 	// connection state from the database.
 
-	lookupFunc, err := createGetConnectionByClientIDImpl(cfg, database, relaxedConnectionLookupQuery)
+	lookupFunc, err := createGetConnectionByClientIDImpl(cfg, statements, relaxedConnectionLookupQuery)
 	if err != nil {
 		return lookupFunc, err
 	}
@@ -55,7 +85,7 @@ func NewPermittedTenantSqlGetConnectionByClientID(cfg *config.Config, database *
 	}, nil
 }
 
-func createGetConnectionByClientIDImpl(cfg *config.Config, database *sql.DB, sqlQuery string) (GetConnectionByClientID, error) {
+func createGetConnectionByClientIDImpl(cfg *config.Config, statements statementPreparer, sqlQuery string) (GetConnectionByClientID, error) {
 
 	return func(ctx context.Context, log *logrus.Entry, orgId domain.OrgID, clientId domain.ClientID) (domain.ConnectorClientState, error) {
 		var clientState domain.ConnectorClientState
@@ -80,20 +110,23 @@ func createGetConnectionByClientIDImpl(cfg *config.Config, database *sql.DB, sql
 		ctx, cancel := context.WithTimeout(ctx, cfg.ConnectionDatabaseQueryTimeout)
 		defer cancel()
 
-		statement, err := database.Prepare(sqlQuery)
+		statement, release, err := statements.prepare(sqlQuery)
 		if err != nil {
 			logger.LogWithError(log, "SQL Prepare failed", err)
 			return clientState, err
 		}
-		defer statement.Close()
+		defer func() { release(err) }()
 
 		var accountString sql.NullString
 		var orgID string
 		var serializedCanonicalFacts sql.NullString
 		var serializedDispatchers sql.NullString
 		var serializedTags sql.NullString
+		var clientName sql.NullString
+		var clientVersion sql.NullString
+		var disconnectedAt sql.NullTime
 
-		err = statement.QueryRowContext(ctx, orgId, clientId).Scan(&accountString, &orgID, &serializedDispatchers, &serializedCanonicalFacts, &serializedTags)
+		err = statement.QueryRowContext(ctx, orgId, clientId).Scan(&accountString, &orgID, &serializedDispatchers, &serializedCanonicalFacts, &serializedTags, &clientName, &clientVersion, &clientState.State, &disconnectedAt)
 
 		if err != nil {
 			if err == sql.ErrNoRows {
@@ -109,6 +142,9 @@ func createGetConnectionByClientIDImpl(cfg *config.Config, database *sql.DB, sql
 		clientState.CanonicalFacts = deserializeCanonicalFacts(log, serializedCanonicalFacts)
 		clientState.Dispatchers = deserializeDispatchers(log, serializedDispatchers)
 		clientState.Tags = deserializeTags(log, serializedTags)
+		clientState.ClientName = clientName.String
+		clientState.ClientVersion = clientVersion.String
+		clientState.DisconnectedAt = disconnectedAt.Time
 
 		if accountString.Valid {
 			clientState.Account = domain.AccountID(accountString.String)
@@ -120,6 +156,11 @@ func createGetConnectionByClientIDImpl(cfg *config.Config, database *sql.DB, sql
 
 func NewSqlGetConnectionsByOrgID(cfg *config.Config, database *sql.DB) (GetConnectionsByOrgID, error) {
 
+	return createGetConnectionsByOrgIDImpl(cfg, perCallStatementPreparer{database})
+}
+
+func createGetConnectionsByOrgIDImpl(cfg *config.Config, statements statementPreparer) (GetConnectionsByOrgID, error) {
+
 	return func(ctx context.Context, log *logrus.Entry, orgId domain.OrgID, offset int, limit int) (map[domain.ClientID]domain.ConnectorClientState, int, error) {
 
 		var totalConnections int
@@ -137,17 +178,12 @@ func NewSqlGetConnectionsByOrgID(cfg *config.Config, database *sql.DB) (GetConne
 
 		connectionsPerAccount := make(map[domain.ClientID]domain.ConnectorClientState)
 
-		statement, err := database.Prepare(
-			`SELECT client_id, org_id, account, dispatchers, canonical_facts, tags, COUNT(*) OVER() FROM connections
-                WHERE org_id = $1
-                ORDER BY client_id
-                OFFSET $2
-                LIMIT $3`)
+		statement, release, err := statements.prepare(connectionsByOrgIDQuery)
 		if err != nil {
 			logger.LogWithError(log, "SQL Prepare failed", err)
 			return nil, totalConnections, err
 		}
-		defer statement.Close()
+		defer func() { release(err) }()
 
 		rows, err := statement.QueryContext(ctx, orgId, offset, limit)
 		if err != nil {
@@ -163,8 +199,10 @@ func NewSqlGetConnectionsByOrgID(cfg *config.Config, database *sql.DB) (GetConne
 			var serializedCanonicalFacts sql.NullString
 			var serializedDispatchers sql.NullString
 			var serializedTags sql.NullString
+			var clientName sql.NullString
+			var clientVersion sql.NullString
 
-			if err := rows.Scan(&clientId, &orgId, &accountString, &serializedDispatchers, &serializedCanonicalFacts, &serializedTags, &totalConnections); err != nil {
+			if err := rows.Scan(&clientId, &orgId, &accountString, &serializedDispatchers, &serializedCanonicalFacts, &serializedTags, &clientName, &clientVersion, &totalConnections); err != nil {
 				logger.LogWithError(log, "SQL scan failed.  Skipping row.", err)
 				continue
 			}
@@ -179,6 +217,8 @@ func NewSqlGetConnectionsByOrgID(cfg *config.Config, database *sql.DB) (GetConne
 				CanonicalFacts: canonicalFacts,
 				Dispatchers:    dispatchers,
 				Tags:           tags,
+				ClientName:     clientName.String,
+				ClientVersion:  clientVersion.String,
 			}
 
 			if accountString.Valid {
@@ -192,7 +232,141 @@ func NewSqlGetConnectionsByOrgID(cfg *config.Config, database *sql.DB) (GetConne
 	}, nil
 }
 
+func NewSqlGetConnectionsBySelector(cfg *config.Config, database *sql.DB) (GetConnectionsBySelector, error) {
+
+	return func(ctx context.Context, log *logrus.Entry, orgId domain.OrgID, selector ConnectionSelector, offset int, limit int) (map[domain.ClientID]domain.ConnectorClientState, int, error) {
+
+		var totalConnections int
+
+		err := verifyOrgId(orgId)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		query, queryArgs, err := buildConnectionSelectorQuery(orgId, selector, offset, limit)
+		if err != nil {
+			logger.LogWithError(log, "Unable to build connection selector query", err)
+			return nil, 0, err
+		}
+
+		callDurationTimer := prometheus.NewTimer(metrics.sqlLookupConnectionsBySelectorDuration)
+		defer callDurationTimer.ObserveDuration()
+
+		ctx, cancel := context.WithTimeout(ctx, cfg.ConnectionDatabaseQueryTimeout)
+		defer cancel()
+
+		connections := make(map[domain.ClientID]domain.ConnectorClientState)
+
+		statement, err := database.Prepare(query)
+		if err != nil {
+			logger.LogWithError(log, "SQL Prepare failed", err)
+			return nil, totalConnections, err
+		}
+		defer statement.Close()
+
+		rows, err := statement.QueryContext(ctx, queryArgs...)
+		if err != nil {
+			logger.LogWithError(log, "SQL query failed", err)
+			return nil, totalConnections, err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var clientId domain.ClientID
+			var orgId string
+			var accountString sql.NullString
+			var serializedCanonicalFacts sql.NullString
+			var serializedDispatchers sql.NullString
+			var serializedTags sql.NullString
+			var clientName sql.NullString
+			var clientVersion sql.NullString
+
+			if err := rows.Scan(&clientId, &orgId, &accountString, &serializedDispatchers, &serializedCanonicalFacts, &serializedTags, &clientName, &clientVersion, &totalConnections); err != nil {
+				logger.LogWithError(log, "SQL scan failed.  Skipping row.", err)
+				continue
+			}
+
+			clientState := domain.ConnectorClientState{
+				OrgID:          domain.OrgID(orgId),
+				ClientID:       domain.ClientID(clientId),
+				CanonicalFacts: deserializeCanonicalFacts(log, serializedCanonicalFacts),
+				Dispatchers:    deserializeDispatchers(log, serializedDispatchers),
+				Tags:           deserializeTags(log, serializedTags),
+				ClientName:     clientName.String,
+				ClientVersion:  clientVersion.String,
+			}
+
+			if accountString.Valid {
+				clientState.Account = domain.AccountID(accountString.String)
+			}
+
+			connections[clientId] = clientState
+		}
+
+		return connections, totalConnections, nil
+	}, nil
+}
+
+// buildConnectionSelectorQuery only adds the conditions that are present in the
+// selector.  The tags and canonical facts conditions use jsonb containment so
+// that the gin indexes on those columns can be used.  The dispatchers condition
+// uses the jsonb existence operator and is applied to the rows within the org.
+func buildConnectionSelectorQuery(orgId domain.OrgID, selector ConnectionSelector, offset int, limit int) (string, []interface{}, error) {
+
+	conditions := []string{"org_id = $1", strings.TrimSpace(onlineConnectionsOnly)}
+	queryArgs := []interface{}{orgId}
+
+	addCondition := func(condition string, arg interface{}) {
+		queryArgs = append(queryArgs, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(queryArgs)))
+	}
+
+	if len(selector.Tags) > 0 {
+		serializedTags, err := json.Marshal(selector.Tags)
+		if err != nil {
+			return "", nil, err
+		}
+		addCondition("tags @> $%d", string(serializedTags))
+	}
+
+	if len(selector.CanonicalFacts) > 0 {
+		serializedCanonicalFacts, err := json.Marshal(selector.CanonicalFacts)
+		if err != nil {
+			return "", nil, err
+		}
+		addCondition("canonical_facts @> $%d", string(serializedCanonicalFacts))
+	}
+
+	if len(selector.Dispatchers) > 0 {
+		addCondition("dispatchers ?& $%d", pq.Array(selector.Dispatchers))
+	}
+
+	if len(selector.ClientName) > 0 {
+		addCondition("client_name = $%d", selector.ClientName)
+	}
+
+	if len(selector.ClientVersion) > 0 {
+		addCondition("client_version = $%d", selector.ClientVersion)
+	}
+
+	queryArgs = append(queryArgs, offset, limit)
+
+	query := fmt.Sprintf(
+		`SELECT client_id, org_id, account, dispatchers, canonical_facts, tags, client_name, client_version, COUNT(*) OVER() FROM connections
+                WHERE %s
+                ORDER BY client_id
+                OFFSET $%d
+                LIMIT $%d`, strings.Join(conditions, " AND "), len(queryArgs)-1, len(queryArgs))
+
+	return query, queryArgs, nil
+}
+
 func NewGetAllConnections(cfg *config.Config, database *sql.DB) (GetAllConnections, error) {
+
+	return createGetAllConnectionsImpl(cfg, perCallStatementPreparer{database})
+}
+
+func createGetAllConnectionsImpl(cfg *config.Config, statements statementPreparer) (GetAllConnections, error) {
 	return func(ctx context.Context, offset int, limit int) (map[domain.AccountID]map[domain.ClientID]domain.ConnectorClientState, int, error) {
 		var totalConnections int
 
@@ -204,17 +378,12 @@ func NewGetAllConnections(cfg *config.Config, database *sql.DB) (GetAllConnectio
 
 		connectionMap := make(map[domain.AccountID]map[domain.ClientID]domain.ConnectorClientState)
 
-		statement, err := database.Prepare(
-			`SELECT account, org_id, client_id, canonical_facts, dispatchers, tags, COUNT(*) OVER() FROM connections
-                WHERE org_id != ''
-				ORDER BY org_id, client_id
-				OFFSET $1
-				LIMIT $2`)
+		statement, release, err := statements.prepare(allConnectionsQuery)
 		if err != nil {
 			logger.LogError("SQL Prepare failed", err)
 			return nil, totalConnections, err
 		}
-		defer statement.Close()
+		defer func() { release(err) }()
 
 		rows, err := statement.QueryContext(ctx, offset, limit)
 		if err != nil {
@@ -230,8 +399,10 @@ func NewGetAllConnections(cfg *config.Config, database *sql.DB) (GetAllConnectio
 			var serializedCanonicalFacts sql.NullString
 			var serializedDispatchers sql.NullString
 			var serializedTags sql.NullString
+			var clientName sql.NullString
+			var clientVersion sql.NullString
 
-			if err := rows.Scan(&account, &orgIdString, &clientId, &serializedCanonicalFacts, &serializedDispatchers, &serializedTags, &totalConnections); err != nil {
+			if err := rows.Scan(&account, &orgIdString, &clientId, &serializedCanonicalFacts, &serializedDispatchers, &serializedTags, &clientName, &clientVersion, &totalConnections); err != nil {
 				logger.LogError("SQL scan failed.  Skipping row.", err)
 				continue
 			}
@@ -251,6 +422,8 @@ func NewGetAllConnections(cfg *config.Config, database *sql.DB) (GetAllConnectio
 				CanonicalFacts: canonicalFacts,
 				Dispatchers:    dispatchers,
 				Tags:           tags,
+				ClientName:     clientName.String,
+				ClientVersion:  clientVersion.String,
 			}
 
 			if _, exists := connectionMap[account]; !exists {
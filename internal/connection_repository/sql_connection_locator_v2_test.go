@@ -7,7 +7,9 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/RedHatInsights/cloud-connector/internal/config"
 	"github.com/RedHatInsights/cloud-connector/internal/domain"
@@ -128,7 +130,7 @@ func runSqlConnectionLocatorV2Test(t *testing.T, tc connectionLocatorV2TestCaseD
 		verifyConnectorClientState(t, connectorClientState, actualClientState)
 	}
 
-	err = connectionRegistrar.Unregister(context.TODO(), tc.clientID)
+	err = connectionRegistrar.Unregister(context.TODO(), tc.clientID, domain.MessageMetadata{LatestTimestamp: time.Now()})
 	if err != nil {
 		t.Fatal("unexpected error while registering a connection", err)
 	}
@@ -138,3 +140,44 @@ func runSqlConnectionLocatorV2Test(t *testing.T, tc connectionLocatorV2TestCaseD
 		t.Fatal("found a connection when the connection was not supposed to exist", err)
 	}
 }
+
+func TestBuildConnectionSelectorQuery(t *testing.T) {
+
+	testCases := []struct {
+		testName           string
+		selector           ConnectionSelector
+		expectedConditions string
+		expectedArgCount   int
+	}{
+		{"empty selector", ConnectionSelector{}, "WHERE org_id = $1 AND state = 'online'\n", 3},
+		{"tags", ConnectionSelector{Tags: map[string]string{"env": "prod"}}, "WHERE org_id = $1 AND state = 'online' AND tags @> $2\n", 4},
+		{"canonical facts", ConnectionSelector{CanonicalFacts: map[string]interface{}{"fqdn": "fred.flintstone.com"}}, "WHERE org_id = $1 AND state = 'online' AND canonical_facts @> $2\n", 4},
+		{"dispatchers", ConnectionSelector{Dispatchers: []string{"rhc-worker-playbook"}}, "WHERE org_id = $1 AND state = 'online' AND dispatchers ?& $2\n", 4},
+		{"client name and version", ConnectionSelector{ClientName: "rhc", ClientVersion: "0.2.1"}, "WHERE org_id = $1 AND state = 'online' AND client_name = $2 AND client_version = $3\n", 5},
+		{"everything",
+			ConnectionSelector{
+				Tags:           map[string]string{"env": "prod"},
+				CanonicalFacts: map[string]interface{}{"fqdn": "fred.flintstone.com"},
+				Dispatchers:    []string{"rhc-worker-playbook"},
+				ClientName:     "rhc",
+			},
+			"WHERE org_id = $1 AND state = 'online' AND tags @> $2 AND canonical_facts @> $3 AND dispatchers ?& $4 AND client_name = $5\n", 7},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			query, queryArgs, err := buildConnectionSelectorQuery("12345", tc.selector, 0, 10)
+			if err != nil {
+				t.Fatal("unexpected error while building the selector query", err)
+			}
+
+			if strings.Contains(query, tc.expectedConditions) == false {
+				t.Fatalf("expected query to contain %q, got %q", tc.expectedConditions, query)
+			}
+
+			if len(queryArgs) != tc.expectedArgCount {
+				t.Fatalf("expected %d query args, got %d", tc.expectedArgCount, len(queryArgs))
+			}
+		})
+	}
+}
@@ -5,7 +5,6 @@ import (
 	"database/sql"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"time"
 
 	"github.com/RedHatInsights/cloud-connector/internal/config"
@@ -18,14 +17,48 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+const (
+	registerConnectionInsert = "INSERT INTO connections (account, org_id, client_id, dispatchers, canonical_facts, tags, message_id, message_sent, tenant_lookup_timestamp, client_name, client_version) "
+
+	// The WHERE clause keeps an older message from overwriting the connection.  If the
+	// connection has a tenant, then reset the tenant lookup failure count.  The clause is
+	// shared by the single row and the batched registrations.
+	registerConnectionOnConflict = "ON CONFLICT (client_id) DO UPDATE SET dispatchers = EXCLUDED.dispatchers, tags = EXCLUDED.tags, updated_at = NOW(), " +
+		"message_id = EXCLUDED.message_id, message_sent = EXCLUDED.message_sent, org_id = EXCLUDED.org_id, account = EXCLUDED.account, " +
+		"tenant_lookup_timestamp = EXCLUDED.tenant_lookup_timestamp, client_name = EXCLUDED.client_name, client_version = EXCLUDED.client_version, " +
+		"state = 'online', disconnected_at = NULL, " +
+		"tenant_lookup_failure_count = CASE WHEN COALESCE(EXCLUDED.org_id, '') = '' THEN connections.tenant_lookup_failure_count ELSE 0 END " +
+		"WHERE connections.message_sent <= EXCLUDED.message_sent"
+
+	registerConnectionQuery = registerConnectionInsert +
+		"VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) " +
+		registerConnectionOnConflict
+
+	// An offline message removes the connection regardless of when it was sent.  The last will
+	// and testament message is built when the client connects, so it is older than the online
+	// message.  The soft delete only ignores an exact duplicate of the offline message and keeps
+	// the newest message_sent so that a replayed online message cannot bring the connection back.
+	unregisterConnectionQuery           = "DELETE FROM connections WHERE client_id = $1"
+	softDeleteUnregisterConnectionQuery = "UPDATE connections SET state = 'offline', disconnected_at = NOW(), updated_at = NOW(), " +
+		"message_id = $2, message_sent = GREATEST(message_sent, $3) " +
+		"WHERE client_id = $1 AND message_id IS DISTINCT FROM $2"
+
+	// The connection is only removed if it is still registered using the provided message
+	unregisterUnchangedConnectionQuery           = "DELETE FROM connections WHERE client_id = $1 AND message_id = $2"
+	softDeleteUnregisterUnchangedConnectionQuery = "UPDATE connections SET state = 'offline', disconnected_at = NOW(), updated_at = NOW() " +
+		"WHERE client_id = $1 AND state = 'online' AND message_id = $2"
+
+	findConnectionByClientIDQuery = "SELECT account, org_id, client_id, dispatchers, canonical_facts, tags, message_id, message_sent, client_name, client_version, state, disconnected_at FROM connections WHERE client_id = $1"
+)
+
 type SqlConnectionRegistrar struct {
-	database     *sql.DB
+	statements   statementPreparer
 	queryTimeout time.Duration
 }
 
 func NewSqlConnectionRegistrar(cfg *config.Config, database *sql.DB) (*SqlConnectionRegistrar, error) {
 	return &SqlConnectionRegistrar{
-		database:     database,
+		statements:   perCallStatementPreparer{database},
 		queryTimeout: cfg.ConnectionDatabaseQueryTimeout,
 	}, nil
 }
@@ -41,32 +74,23 @@ func (scm *SqlConnectionRegistrar) Register(ctx context.Context, rhcClient domai
 
 	logger := logger.Log.WithFields(logrus.Fields{"account": account, "org_id": org_id, "client_id": client_id})
 
-	var resetTenantLookupCountClause string
 	var tenantLookupTimestamp *time.Time
 
 	if isTenantlessConnection(rhcClient) {
 		logger.Debug("Registering tenantless connection")
 		timestamp := time.Now()
 		tenantLookupTimestamp = &timestamp
-	} else {
-		// If the connection has a tenant, then reset the tenant lookup failure count
-		resetTenantLookupCountClause = ", tenant_lookup_failure_count = 0 "
 	}
 
 	ctx, cancel := context.WithTimeout(ctx, scm.queryTimeout)
 	defer cancel()
 
-	update := fmt.Sprintf("UPDATE connections SET dispatchers=$1, tags = $2, updated_at = NOW(), message_id = $3, message_sent = $4, org_id = $5, account = $6, tenant_lookup_timestamp = $7 %s WHERE client_id=$8", resetTenantLookupCountClause)
-	insert := "INSERT INTO connections (account, org_id, client_id, dispatchers, canonical_facts, tags, message_id, message_sent, tenant_lookup_timestamp) SELECT $9, $10, $11, $12, $13, $14, $15, $16, $17"
-
-	insertOrUpdate := fmt.Sprintf("WITH upsert AS (%s RETURNING *) %s WHERE NOT EXISTS (SELECT * FROM upsert)", update, insert)
-
-	statement, err := scm.database.Prepare(insertOrUpdate)
+	statement, release, err := scm.statements.prepare(registerConnectionQuery)
 	if err != nil {
 		logger.WithFields(logrus.Fields{"error": err}).Error("Prepare failed")
 		return FatalError{err}
 	}
-	defer statement.Close()
+	defer func() { release(err) }()
 
 	dispatchersString, err := json.Marshal(rhcClient.Dispatchers)
 	if err != nil {
@@ -86,7 +110,7 @@ func (scm *SqlConnectionRegistrar) Register(ctx context.Context, rhcClient domai
 		return err
 	}
 
-	_, err = statement.ExecContext(ctx, dispatchersString, tagsString, rhcClient.MessageMetadata.LatestMessageID, rhcClient.MessageMetadata.LatestTimestamp, org_id, account, tenantLookupTimestamp, client_id, account, org_id, client_id, dispatchersString, canonicalFactsString, tagsString, rhcClient.MessageMetadata.LatestMessageID, rhcClient.MessageMetadata.LatestTimestamp, tenantLookupTimestamp)
+	result, err := statement.ExecContext(ctx, account, org_id, client_id, dispatchersString, canonicalFactsString, tagsString, rhcClient.MessageMetadata.LatestMessageID, rhcClient.MessageMetadata.LatestTimestamp, tenantLookupTimestamp, rhcClient.ClientName, rhcClient.ClientVersion)
 	if err != nil {
 		logger.WithFields(logrus.Fields{"error": err}).Error("Insert/update failed")
 
@@ -98,11 +122,45 @@ func (scm *SqlConnectionRegistrar) Register(ctx context.Context, rhcClient domai
 		return err
 	}
 
+	rowsAffected, err := result.RowsAffected()
+	if err == nil && rowsAffected == 0 {
+		logger.Debug("Connection was not registered...the message is older than the current connection state")
+		return StaleConnectionStateError
+	}
+
 	logger.Debug("Registered a connection")
 	return nil
 }
 
-func (scm *SqlConnectionRegistrar) Unregister(ctx context.Context, client_id domain.ClientID) error {
+func (scm *SqlConnectionRegistrar) Unregister(ctx context.Context, client_id domain.ClientID, latestMessage domain.MessageMetadata) error {
+
+	rowsAffected, err := scm.unregister(ctx, client_id, unregisterConnectionQuery, client_id)
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return NotFoundError
+	}
+
+	return nil
+}
+
+func (scm *SqlConnectionRegistrar) UnregisterIfUnchanged(ctx context.Context, client_id domain.ClientID, latestMessage domain.MessageMetadata) error {
+
+	rowsAffected, err := scm.unregister(ctx, client_id, unregisterUnchangedConnectionQuery, client_id, latestMessage.LatestMessageID)
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return StaleConnectionStateError
+	}
+
+	return nil
+}
+
+func (scm *SqlConnectionRegistrar) unregister(ctx context.Context, client_id domain.ClientID, query string, args ...interface{}) (int64, error) {
 
 	callDurationTimer := prometheus.NewTimer(metrics.sqlConnectionUnregistrationDuration)
 	defer callDurationTimer.ObserveDuration()
@@ -112,23 +170,93 @@ func (scm *SqlConnectionRegistrar) Unregister(ctx context.Context, client_id dom
 
 	logger := logger.Log.WithFields(logrus.Fields{"client_id": client_id})
 
-	statement, err := scm.database.Prepare("DELETE FROM connections WHERE client_id = $1")
+	statement, release, err := scm.statements.prepare(query)
 	if err != nil {
 		logger.WithFields(logrus.Fields{"error": err}).Error("Prepare failed")
-		return FatalError{err}
+		return 0, FatalError{err}
 	}
-	defer statement.Close()
+	defer func() { release(err) }()
 
-	_, err = statement.ExecContext(ctx, client_id)
+	result, err := statement.ExecContext(ctx, args...)
 	if err != nil {
-		logger.WithFields(logrus.Fields{"error": err}).Error("Delete failed")
-		return FatalError{err}
+		logger.WithFields(logrus.Fields{"error": err}).Error("Unregister failed")
+		return 0, FatalError{err}
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		logger.WithFields(logrus.Fields{"error": err}).Error("Unable to determine the number of unregistered connections")
+		return 0, err
+	}
+
+	if rowsAffected == 0 {
+		logger.Debug("No connection was unregistered")
+		return 0, nil
 	}
 
 	logger.Debug("Unregistered a connection")
+	return rowsAffected, nil
+}
+
+// SqlSoftDeleteConnectionRegistrar marks connections as offline instead of removing
+// them from the connections table.  This allows the api to report when a client
+// was last seen.
+type SqlSoftDeleteConnectionRegistrar struct {
+	*SqlConnectionRegistrar
+}
+
+func NewSqlSoftDeleteConnectionRegistrar(cfg *config.Config, database *sql.DB) (*SqlSoftDeleteConnectionRegistrar, error) {
+	registrar, err := NewSqlConnectionRegistrar(cfg, database)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SqlSoftDeleteConnectionRegistrar{registrar}, nil
+}
+
+func (sdcr *SqlSoftDeleteConnectionRegistrar) Unregister(ctx context.Context, client_id domain.ClientID, latestMessage domain.MessageMetadata) error {
+
+	rowsAffected, err := sdcr.unregister(ctx, client_id, softDeleteUnregisterConnectionQuery, client_id, latestMessage.LatestMessageID, latestMessage.LatestTimestamp)
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return NotFoundError
+	}
+
+	return nil
+}
+
+func (sdcr *SqlSoftDeleteConnectionRegistrar) UnregisterIfUnchanged(ctx context.Context, client_id domain.ClientID, latestMessage domain.MessageMetadata) error {
+
+	rowsAffected, err := sdcr.unregister(ctx, client_id, softDeleteUnregisterUnchangedConnectionQuery, client_id, latestMessage.LatestMessageID)
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return StaleConnectionStateError
+	}
+
 	return nil
 }
 
+// PruneOfflineConnections removes the connections that were marked as offline before the cutoff
+func PruneOfflineConnections(ctx context.Context, databaseConn *sql.DB, sqlTimeout time.Duration, cutoff time.Time, batchSize int) (int64, error) {
+
+	statement, err := databaseConn.Prepare(
+		`DELETE FROM connections WHERE id IN
+            (SELECT id FROM connections WHERE state = 'offline' AND disconnected_at < $1 LIMIT $2)`)
+	if err != nil {
+		logger.LogError("SQL Prepare failed", err)
+		return 0, err
+	}
+	defer statement.Close()
+
+	return deleteInBatches(ctx, statement, sqlTimeout, cutoff, batchSize)
+}
+
 func (scm *SqlConnectionRegistrar) FindConnectionByClientID(ctx context.Context, client_id domain.ClientID) (domain.ConnectorClientState, error) {
 	var connectorClient domain.ConnectorClientState
 	var err error
@@ -141,12 +269,12 @@ func (scm *SqlConnectionRegistrar) FindConnectionByClientID(ctx context.Context,
 	ctx, cancel := context.WithTimeout(ctx, scm.queryTimeout)
 	defer cancel()
 
-	statement, err := scm.database.Prepare("SELECT account, org_id, client_id, dispatchers, canonical_facts, tags, message_id, message_sent FROM connections WHERE client_id = $1")
+	statement, release, err := scm.statements.prepare(findConnectionByClientIDQuery)
 	if err != nil {
 		logger.WithFields(logrus.Fields{"error": err}).Error("SQL prepare failed")
 		return connectorClient, FatalError{err}
 	}
-	defer statement.Close()
+	defer func() { release(err) }()
 
 	var account sql.NullString
 	var orgID domain.OrgID
@@ -154,6 +282,9 @@ func (scm *SqlConnectionRegistrar) FindConnectionByClientID(ctx context.Context,
 	var serializedDispatchers sql.NullString
 	var serializedTags sql.NullString
 	var latestMessageID sql.NullString
+	var clientName sql.NullString
+	var clientVersion sql.NullString
+	var disconnectedAt sql.NullTime
 
 	err = statement.QueryRowContext(ctx, client_id).Scan(&account,
 		&orgID,
@@ -162,7 +293,11 @@ func (scm *SqlConnectionRegistrar) FindConnectionByClientID(ctx context.Context,
 		&serializedCanonicalFacts,
 		&serializedTags,
 		&latestMessageID,
-		&connectorClient.MessageMetadata.LatestTimestamp)
+		&connectorClient.MessageMetadata.LatestTimestamp,
+		&clientName,
+		&clientVersion,
+		&connectorClient.State,
+		&disconnectedAt)
 
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -177,6 +312,9 @@ func (scm *SqlConnectionRegistrar) FindConnectionByClientID(ctx context.Context,
 	}
 
 	connectorClient.OrgID = domain.OrgID(orgID)
+	connectorClient.ClientName = clientName.String
+	connectorClient.ClientVersion = clientVersion.String
+	connectorClient.DisconnectedAt = disconnectedAt.Time
 
 	if account.Valid {
 		connectorClient.Account = domain.AccountID(account.String)
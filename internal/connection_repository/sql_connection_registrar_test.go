@@ -76,7 +76,7 @@ func TestSqlConnectionRegistrar(t *testing.T) {
 
 			verifyConnectorClientState(t, connectorClientState, actualClientState)
 
-			err = connectionRegistrar.Unregister(context.TODO(), tc.clientID)
+			err = connectionRegistrar.Unregister(context.TODO(), tc.clientID, domain.MessageMetadata{LatestTimestamp: time.Now()})
 			if err != nil {
 				t.Fatal("unexpected error while registering a connection", err)
 			}
@@ -141,13 +141,170 @@ func TestSqlConnectionRegistrarUpdateTenantlessConnection(t *testing.T) {
 
 	verifyConnectionCountByClientID(t, database, connectorClientState.ClientID, 1)
 
-	err = connectionRegistrar.Unregister(context.TODO(), connectorClientState.ClientID)
+	err = connectionRegistrar.Unregister(context.TODO(), connectorClientState.ClientID, domain.MessageMetadata{LatestTimestamp: time.Now()})
 	if err != nil {
 		t.Fatal("unexpected error while registering a connection", err)
 	}
 
 }
 
+func TestSqlConnectionRegistrarIgnoresOlderMessages(t *testing.T) {
+	cfg := config.GetConfig()
+
+	database, err := db.InitializeDatabaseConnection(cfg)
+	if err != nil {
+		t.Fatal("Unable to connect to database: ", err)
+	}
+
+	connectionRegistrar, err := NewSqlConnectionRegistrar(cfg, database)
+	if err != nil {
+		t.Fatal("unexpected error while creating the SqlConnectionRegistrar", err)
+	}
+
+	now := time.Now().UTC()
+
+	connectorClientState := domain.ConnectorClientState{
+		OrgID:           "999991",
+		Account:         "999999",
+		ClientID:        "stale-message-test-client-1",
+		MessageMetadata: domain.MessageMetadata{LatestMessageID: "message-2", LatestTimestamp: now},
+	}
+
+	err = connectionRegistrar.Register(context.TODO(), connectorClientState)
+	if err != nil {
+		t.Fatal("unexpected error while registering a connection", err)
+	}
+
+	olderClientState := connectorClientState
+	olderClientState.OrgID = "888881"
+	olderClientState.MessageMetadata = domain.MessageMetadata{LatestMessageID: "message-1", LatestTimestamp: now.Add(-time.Minute)}
+
+	err = connectionRegistrar.Register(context.TODO(), olderClientState)
+	if err != StaleConnectionStateError {
+		t.Fatal("expected a stale connection state error", err)
+	}
+
+	actualClientState, err := connectionRegistrar.FindConnectionByClientID(context.TODO(), connectorClientState.ClientID)
+	if err != nil {
+		t.Fatal("unexpected error while looking up a connection", err)
+	}
+
+	verifyConnectorClientState(t, connectorClientState, actualClientState)
+	verifyConnectionCountByClientID(t, database, connectorClientState.ClientID, 1)
+
+	err = connectionRegistrar.UnregisterIfUnchanged(context.TODO(), connectorClientState.ClientID, olderClientState.MessageMetadata)
+	if err != StaleConnectionStateError {
+		t.Fatal("expected a stale connection state error", err)
+	}
+
+	verifyConnectionCountByClientID(t, database, connectorClientState.ClientID, 1)
+
+	err = connectionRegistrar.UnregisterIfUnchanged(context.TODO(), connectorClientState.ClientID, connectorClientState.MessageMetadata)
+	if err != nil {
+		t.Fatal("unexpected error while unregistering a connection", err)
+	}
+
+	verifyConnectionCountByClientID(t, database, connectorClientState.ClientID, 0)
+}
+
+func TestSqlConnectionRegistrarUnregistersUsingOlderOfflineMessage(t *testing.T) {
+	cfg := config.GetConfig()
+
+	database, err := db.InitializeDatabaseConnection(cfg)
+	if err != nil {
+		t.Fatal("Unable to connect to database: ", err)
+	}
+
+	hardDeleteRegistrar, err := NewSqlConnectionRegistrar(cfg, database)
+	if err != nil {
+		t.Fatal("unexpected error while creating the SqlConnectionRegistrar", err)
+	}
+
+	softDeleteRegistrar, err := NewSqlSoftDeleteConnectionRegistrar(cfg, database)
+	if err != nil {
+		t.Fatal("unexpected error while creating the SqlSoftDeleteConnectionRegistrar", err)
+	}
+
+	testCases := []struct {
+		testName  string
+		registrar ConnectionRegistrar
+		clientID  domain.ClientID
+	}{
+		{"hard delete", hardDeleteRegistrar, "lwt-test-client-1"},
+		{"soft delete", softDeleteRegistrar, "lwt-test-client-2"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+
+			now := time.Now().UTC()
+
+			connectorClientState := domain.ConnectorClientState{
+				OrgID:           "999991",
+				Account:         "999999",
+				ClientID:        tc.clientID,
+				MessageMetadata: domain.MessageMetadata{LatestMessageID: "online-message", LatestTimestamp: now},
+			}
+
+			err := tc.registrar.Register(context.TODO(), connectorClientState)
+			if err != nil {
+				t.Fatal("unexpected error while registering a connection", err)
+			}
+
+			// The last will and testament message is built before the client connects
+			lastWillMessage := domain.MessageMetadata{LatestMessageID: "lwt-message", LatestTimestamp: now.Add(-time.Second)}
+
+			err = tc.registrar.Unregister(context.TODO(), tc.clientID, lastWillMessage)
+			if err != nil {
+				t.Fatal("unexpected error while unregistering a connection", err)
+			}
+
+			actualClientState, err := tc.registrar.FindConnectionByClientID(context.TODO(), tc.clientID)
+			if err != NotFoundError && actualClientState.State != domain.ConnectionOffline {
+				t.Fatal("expected the connection to be unregistered", actualClientState, err)
+			}
+
+			err = tc.registrar.Unregister(context.TODO(), tc.clientID, lastWillMessage)
+			if err != NotFoundError {
+				t.Fatal("expected a duplicate offline message to return a not found error", err)
+			}
+
+			// A replayed older online message must not bring the connection back
+			olderClientState := connectorClientState
+			olderClientState.MessageMetadata = domain.MessageMetadata{LatestMessageID: "older-online-message", LatestTimestamp: now.Add(-time.Minute)}
+
+			err = tc.registrar.Register(context.TODO(), olderClientState)
+			if tc.registrar == softDeleteRegistrar && err != StaleConnectionStateError {
+				t.Fatal("expected a stale connection state error", err)
+			}
+
+			_, err = database.Exec("DELETE FROM connections WHERE client_id = $1", tc.clientID)
+			if err != nil {
+				t.Fatal("unexpected error while removing the connection", err)
+			}
+		})
+	}
+}
+
+func TestSqlConnectionRegistrarUnregisterUnknownClient(t *testing.T) {
+	cfg := config.GetConfig()
+
+	database, err := db.InitializeDatabaseConnection(cfg)
+	if err != nil {
+		t.Fatal("Unable to connect to database: ", err)
+	}
+
+	connectionRegistrar, err := NewSqlSoftDeleteConnectionRegistrar(cfg, database)
+	if err != nil {
+		t.Fatal("unexpected error while creating the SqlSoftDeleteConnectionRegistrar", err)
+	}
+
+	err = connectionRegistrar.Unregister(context.TODO(), "unknown-offline-test-client", domain.MessageMetadata{LatestMessageID: "lwt-message", LatestTimestamp: time.Now()})
+	if err != NotFoundError {
+		t.Fatal("expected a not found error", err)
+	}
+}
+
 func verifyConnectionCountByClientID(t *testing.T, database *sql.DB, clientID domain.ClientID, expectedConnectionCount int) {
 
 	connectionCount, err := getConnectionCountFromDatabase(database, clientID)
@@ -0,0 +1,76 @@
+package connection_repository
+
+import (
+	"database/sql"
+
+	"github.com/RedHatInsights/cloud-connector/internal/config"
+)
+
+// SqlConnectionRepository hands out the same function types and registrars as the NewSqlXxx
+// constructors.  If prepared statements are reused, the connection queries are prepared once
+// when the repository is created instead of on every call.
+type SqlConnectionRepository struct {
+	cfg        *config.Config
+	statements statementPreparer
+}
+
+func NewSqlConnectionRepository(cfg *config.Config, database *sql.DB) (*SqlConnectionRepository, error) {
+
+	if cfg.ConnectionDatabaseReusePreparedStatements == false {
+		return &SqlConnectionRepository{cfg: cfg, statements: perCallStatementPreparer{database}}, nil
+	}
+
+	statements, err := NewSqlStatementCache(database,
+		strictConnectionLookupQuery,
+		relaxedConnectionLookupQuery,
+		statusConnectionLookupQuery,
+		connectionsByOrgIDQuery,
+		allConnectionsQuery,
+		registerConnectionQuery,
+		unregisterConnectionQuery,
+		softDeleteUnregisterConnectionQuery,
+		findConnectionByClientIDQuery)
+	if err != nil {
+		return nil, FatalError{err}
+	}
+
+	return &SqlConnectionRepository{cfg: cfg, statements: statements}, nil
+}
+
+func (scr *SqlConnectionRepository) GetConnectionByClientID() (GetConnectionByClientID, error) {
+	return createGetConnectionByClientIDImpl(scr.cfg, scr.statements, strictConnectionLookupQuery)
+}
+
+func (scr *SqlConnectionRepository) GetConnectionStatusByClientID() (GetConnectionByClientID, error) {
+	return createGetConnectionByClientIDImpl(scr.cfg, scr.statements, statusConnectionLookupQuery)
+}
+
+func (scr *SqlConnectionRepository) PermittedTenantGetConnectionByClientID() (GetConnectionByClientID, error) {
+	return createPermittedTenantGetConnectionByClientIDImpl(scr.cfg, scr.statements)
+}
+
+func (scr *SqlConnectionRepository) GetConnectionsByOrgID() (GetConnectionsByOrgID, error) {
+	return createGetConnectionsByOrgIDImpl(scr.cfg, scr.statements)
+}
+
+func (scr *SqlConnectionRepository) GetAllConnections() (GetAllConnections, error) {
+	return createGetAllConnectionsImpl(scr.cfg, scr.statements)
+}
+
+func (scr *SqlConnectionRepository) ConnectionRegistrar() *SqlConnectionRegistrar {
+	return &SqlConnectionRegistrar{
+		statements:   scr.statements,
+		queryTimeout: scr.cfg.ConnectionDatabaseQueryTimeout,
+	}
+}
+
+func (scr *SqlConnectionRepository) SoftDeleteConnectionRegistrar() *SqlSoftDeleteConnectionRegistrar {
+	return &SqlSoftDeleteConnectionRegistrar{scr.ConnectionRegistrar()}
+}
+
+// Close closes the prepared statements
+func (scr *SqlConnectionRepository) Close() {
+	if statementCache, ok := scr.statements.(*SqlStatementCache); ok {
+		statementCache.Close()
+	}
+}
@@ -0,0 +1,154 @@
+//go:build sql
+// +build sql
+
+package connection_repository
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/cloud-connector/internal/config"
+	"github.com/RedHatInsights/cloud-connector/internal/domain"
+	"github.com/RedHatInsights/cloud-connector/internal/platform/db"
+	"github.com/RedHatInsights/cloud-connector/internal/platform/logger"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestSqlConnectionRepositoryPreparesStatementAgainAfterConnectionLoss(t *testing.T) {
+
+	cfg := config.GetConfig()
+	cfg.ConnectionDatabaseReusePreparedStatements = true
+
+	database, err := db.InitializeDatabaseConnection(cfg)
+	if err != nil {
+		t.Fatal("Unable to connect to database: ", err)
+	}
+
+	connectionRepository, err := NewSqlConnectionRepository(cfg, database)
+	if err != nil {
+		t.Fatal("unexpected error while creating the SqlConnectionRepository", err)
+	}
+	defer connectionRepository.Close()
+
+	getConnectionByClientID, err := connectionRepository.GetConnectionByClientID()
+	if err != nil {
+		t.Fatal("unexpected error while creating the GetConnectionByClientID function", err)
+	}
+
+	log := logger.Log.WithFields(logrus.Fields{})
+
+	// Simulate losing the prepared statement
+	statement, release, _ := connectionRepository.statements.prepare(strictConnectionLookupQuery)
+	statement.Close()
+	release(nil)
+
+	_, err = getConnectionByClientID(context.TODO(), log, "999991", "sql-repository-test-client")
+	if isConnectionLossError(err) == false {
+		t.Fatalf("expected the closed statement to fail, got %v", err)
+	}
+
+	_, err = getConnectionByClientID(context.TODO(), log, "999991", "sql-repository-test-client")
+	if err != NotFoundError {
+		t.Fatalf("expected the statement to be prepared again, got %v", err)
+	}
+}
+
+func buildBenchmarkConnectionRepository(b *testing.B, reusePreparedStatements bool) (*config.Config, *SqlConnectionRepository) {
+
+	cfg := config.GetConfig()
+	cfg.ConnectionDatabaseReusePreparedStatements = reusePreparedStatements
+
+	database, err := db.InitializeDatabaseConnection(cfg)
+	if err != nil {
+		b.Fatal("Unable to connect to database: ", err)
+	}
+
+	connectionRepository, err := NewSqlConnectionRepository(cfg, database)
+	if err != nil {
+		b.Fatal("unexpected error while creating the SqlConnectionRepository", err)
+	}
+
+	return cfg, connectionRepository
+}
+
+func benchmarkGetConnectionByClientID(b *testing.B, reusePreparedStatements bool) {
+
+	_, connectionRepository := buildBenchmarkConnectionRepository(b, reusePreparedStatements)
+	defer connectionRepository.Close()
+
+	registrar := connectionRepository.ConnectionRegistrar()
+
+	clientID := domain.ClientID("sql-repository-benchmark-lookup-client")
+
+	err := registrar.Register(context.TODO(), domain.ConnectorClientState{
+		OrgID:           "999991",
+		Account:         "999999",
+		ClientID:        clientID,
+		MessageMetadata: domain.MessageMetadata{LatestMessageID: "message", LatestTimestamp: time.Now()},
+	})
+	if err != nil && err != StaleConnectionStateError {
+		b.Fatal("unexpected error while registering a connection", err)
+	}
+	defer registrar.Unregister(context.TODO(), clientID, domain.MessageMetadata{LatestTimestamp: time.Now()})
+
+	getConnectionByClientID, err := connectionRepository.GetConnectionByClientID()
+	if err != nil {
+		b.Fatal("unexpected error while creating the GetConnectionByClientID function", err)
+	}
+
+	log := logger.Log.WithFields(logrus.Fields{})
+
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := getConnectionByClientID(context.TODO(), log, "999991", clientID); err != nil {
+				b.Error("unexpected error while looking up a connection", err)
+			}
+		}
+	})
+}
+
+func BenchmarkGetConnectionByClientIDPreparePerCall(b *testing.B) {
+	benchmarkGetConnectionByClientID(b, false)
+}
+
+func BenchmarkGetConnectionByClientIDReusePreparedStatement(b *testing.B) {
+	benchmarkGetConnectionByClientID(b, true)
+}
+
+func benchmarkRegister(b *testing.B, reusePreparedStatements bool) {
+
+	_, connectionRepository := buildBenchmarkConnectionRepository(b, reusePreparedStatements)
+	defer connectionRepository.Close()
+
+	registrar := connectionRepository.ConnectionRegistrar()
+
+	clientID := domain.ClientID(fmt.Sprintf("sql-repository-benchmark-register-client-%t", reusePreparedStatements))
+	defer registrar.Unregister(context.TODO(), clientID, domain.MessageMetadata{LatestTimestamp: time.Now()})
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		err := registrar.Register(context.TODO(), domain.ConnectorClientState{
+			OrgID:           "999991",
+			Account:         "999999",
+			ClientID:        clientID,
+			MessageMetadata: domain.MessageMetadata{LatestMessageID: fmt.Sprintf("message-%d", i), LatestTimestamp: time.Now()},
+		})
+		if err != nil && err != StaleConnectionStateError {
+			b.Fatal("unexpected error while registering a connection", err)
+		}
+	}
+}
+
+func BenchmarkRegisterPreparePerCall(b *testing.B) {
+	benchmarkRegister(b, false)
+}
+
+func BenchmarkRegisterReusePreparedStatement(b *testing.B) {
+	benchmarkRegister(b, true)
+}
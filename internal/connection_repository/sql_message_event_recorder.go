@@ -0,0 +1,152 @@
+package connection_repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/RedHatInsights/cloud-connector/internal/config"
+	"github.com/RedHatInsights/cloud-connector/internal/domain"
+	"github.com/RedHatInsights/cloud-connector/internal/platform/logger"
+
+	"github.com/jackc/pgerrcode"
+	"github.com/lib/pq"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	insertMessageEvent = "INSERT INTO message_events (org_id, client_id, message_id, response_to, content, message_sent) VALUES ($1, $2, $3, $4, $5, $6)"
+
+	// Every recorded event is sent to the SqlMessageResponseListener, whether or not anyone
+	// is waiting for it.  Each NOTIFY is added to the database's shared notification queue
+	// and is delivered to every listening connection.  The notification is only delivered
+	// once the insert commits.
+	insertMessageEventAndNotify = `WITH inserted AS (
+           ` + insertMessageEvent + `
+           RETURNING response_to
+         )
+         SELECT pg_notify('` + messageResponseNotificationChannel + `', response_to) FROM inserted`
+)
+
+type SqlMessageEventRecorder struct {
+	database        *sql.DB
+	statements      statementPreparer
+	queryTimeout    time.Duration
+	insertStatement string
+}
+
+func NewSqlMessageEventRecorder(cfg *config.Config, database *sql.DB) (*SqlMessageEventRecorder, error) {
+
+	insertStatement := insertMessageEvent
+	if cfg.MessageResponseNotificationsEnabled {
+		insertStatement = insertMessageEventAndNotify
+	}
+
+	return &SqlMessageEventRecorder{
+		database:        database,
+		statements:      newStatementPreparer(cfg, database),
+		queryTimeout:    cfg.ConnectionDatabaseQueryTimeout,
+		insertStatement: insertStatement,
+	}, nil
+}
+
+func (smer *SqlMessageEventRecorder) RecordMessageEvent(ctx context.Context, event domain.MessageEvent) error {
+
+	callDurationTimer := prometheus.NewTimer(metrics.sqlMessageEventRecordDuration)
+	defer callDurationTimer.ObserveDuration()
+
+	logger := logger.Log.WithFields(logrus.Fields{"org_id": event.OrgID, "client_id": event.ClientID, "message_id": event.MessageID, "response_to": event.ResponseTo})
+
+	ctx, cancel := context.WithTimeout(ctx, smer.queryTimeout)
+	defer cancel()
+
+	statement, release, err := smer.statements.prepare(smer.insertStatement)
+	if err != nil {
+		logger.WithFields(logrus.Fields{"error": err}).Error("Prepare failed")
+		return FatalError{err}
+	}
+	defer func() { release(err) }()
+
+	contentString, err := json.Marshal(event.Content)
+	if err != nil {
+		logger.WithFields(logrus.Fields{"error": err, "content": event.Content}).Error("Unable to marshal event content")
+		return err
+	}
+
+	_, err = statement.ExecContext(ctx, event.OrgID, event.ClientID, event.MessageID, event.ResponseTo, contentString, event.Sent)
+	if err != nil {
+		logger.WithFields(logrus.Fields{"error": err}).Error("Insert failed")
+
+		if pqErr, ok := err.(*pq.Error); ok && pgerrcode.IsConnectionException(pqErr.Code.Name()) {
+			// Only mark the error as fatal if we failed to establish a connection to the database
+			return FatalError{err}
+		}
+
+		return err
+	}
+
+	logger.Debug("Recorded a message event")
+	return nil
+}
+
+func NewSqlGetMessageEventsByMessageID(cfg *config.Config, database *sql.DB) (GetMessageEventsByMessageID, error) {
+
+	statements := newStatementPreparer(cfg, database)
+
+	return func(ctx context.Context, log *logrus.Entry, orgId domain.OrgID, clientId domain.ClientID, messageId string) ([]domain.MessageEvent, error) {
+
+		err := verifyOrgId(orgId)
+		if err != nil {
+			return nil, err
+		}
+
+		err = verifyClientId(clientId)
+		if err != nil {
+			return nil, err
+		}
+
+		callDurationTimer := prometheus.NewTimer(metrics.sqlLookupMessageEventsDuration)
+		defer callDurationTimer.ObserveDuration()
+
+		ctx, cancel := context.WithTimeout(ctx, cfg.ConnectionDatabaseQueryTimeout)
+		defer cancel()
+
+		statement, release, err := statements.prepare(
+			`SELECT message_id, response_to, content, message_sent FROM message_events
+                WHERE org_id = $1 AND client_id = $2 AND response_to = $3
+                ORDER BY message_sent, id`)
+		if err != nil {
+			logger.LogWithError(log, "SQL Prepare failed", err)
+			return nil, err
+		}
+		defer func() { release(err) }()
+
+		rows, err := statement.QueryContext(ctx, orgId, clientId, messageId)
+		if err != nil {
+			logger.LogWithError(log, "SQL query failed", err)
+			return nil, err
+		}
+		defer rows.Close()
+
+		events := []domain.MessageEvent{}
+
+		for rows.Next() {
+			var serializedContent sql.NullString
+
+			event := domain.MessageEvent{OrgID: orgId, ClientID: clientId}
+
+			if err := rows.Scan(&event.MessageID, &event.ResponseTo, &serializedContent, &event.Sent); err != nil {
+				logger.LogWithError(log, "SQL scan failed.  Skipping row.", err)
+				continue
+			}
+
+			event.Content = deserializeEventContent(log, serializedContent)
+
+			events = append(events, event)
+		}
+
+		return events, nil
+	}, nil
+}
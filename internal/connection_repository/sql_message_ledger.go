@@ -0,0 +1,169 @@
+package connection_repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/RedHatInsights/cloud-connector/internal/config"
+	"github.com/RedHatInsights/cloud-connector/internal/domain"
+	"github.com/RedHatInsights/cloud-connector/internal/platform/logger"
+
+	"github.com/jackc/pgerrcode"
+	"github.com/lib/pq"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+type SqlMessageLedger struct {
+	database     *sql.DB
+	statements   statementPreparer
+	queryTimeout time.Duration
+}
+
+func NewSqlMessageLedger(cfg *config.Config, database *sql.DB) (*SqlMessageLedger, error) {
+	return &SqlMessageLedger{
+		database:     database,
+		statements:   newStatementPreparer(cfg, database),
+		queryTimeout: cfg.ConnectionDatabaseQueryTimeout,
+	}, nil
+}
+
+func (sml *SqlMessageLedger) RecordMessage(ctx context.Context, message domain.Message) error {
+
+	callDurationTimer := prometheus.NewTimer(metrics.sqlMessageRecordDuration)
+	defer callDurationTimer.ObserveDuration()
+
+	logger := logger.Log.WithFields(logrus.Fields{"org_id": message.OrgID, "account": message.Account, "client_id": message.ClientID, "message_id": message.MessageID, "status": message.Status})
+
+	ctx, cancel := context.WithTimeout(ctx, sml.queryTimeout)
+	defer cancel()
+
+	statement, release, err := sml.statements.prepare(
+		`INSERT INTO messages (message_id, org_id, account, client_id, directive, status, error)
+            VALUES ($1, $2, $3, $4, $5, $6, $7)
+            ON CONFLICT (message_id) DO UPDATE SET status = EXCLUDED.status, error = EXCLUDED.error, updated_at = NOW()`)
+	if err != nil {
+		logger.WithFields(logrus.Fields{"error": err}).Error("Prepare failed")
+		return FatalError{err}
+	}
+	defer func() { release(err) }()
+
+	var errorString sql.NullString
+	if len(message.Error) > 0 {
+		errorString = sql.NullString{String: message.Error, Valid: true}
+	}
+
+	_, err = statement.ExecContext(ctx, message.MessageID, message.OrgID, message.Account, message.ClientID, message.Directive, message.Status, errorString)
+	if err != nil {
+		logger.WithFields(logrus.Fields{"error": err}).Error("Insert failed")
+
+		if pqErr, ok := err.(*pq.Error); ok && pgerrcode.IsConnectionException(pqErr.Code.Name()) {
+			// Only mark the error as fatal if we failed to establish a connection to the database
+			return FatalError{err}
+		}
+
+		return err
+	}
+
+	logger.Debug("Recorded message in the message ledger")
+	return nil
+}
+
+func NewSqlGetMessageByMessageID(cfg *config.Config, database *sql.DB) (GetMessageByMessageID, error) {
+
+	statements := newStatementPreparer(cfg, database)
+
+	return func(ctx context.Context, log *logrus.Entry, orgId domain.OrgID, messageId string) (domain.Message, error) {
+		var message domain.Message
+
+		err := verifyOrgId(orgId)
+		if err != nil {
+			return message, err
+		}
+
+		callDurationTimer := prometheus.NewTimer(metrics.sqlLookupMessageDuration)
+		defer callDurationTimer.ObserveDuration()
+
+		ctx, cancel := context.WithTimeout(ctx, cfg.ConnectionDatabaseQueryTimeout)
+		defer cancel()
+
+		// A message is considered acknowledged once the client has sent an event in response to it
+		statement, release, err := statements.prepare(
+			`SELECT m.message_id, m.org_id, m.account, m.client_id, m.directive, m.status, m.error, m.created_at, m.updated_at,
+                EXISTS (SELECT 1 FROM message_events e WHERE e.response_to = m.message_id AND e.client_id = m.client_id),
+                (SELECT p.expires_at FROM pending_messages p WHERE p.message_id = m.message_id)
+                FROM messages m
+                WHERE m.org_id = $1 AND m.message_id = $2`)
+		if err != nil {
+			logger.LogWithError(log, "SQL Prepare failed", err)
+			return message, err
+		}
+		defer func() { release(err) }()
+
+		var accountString sql.NullString
+		var errorString sql.NullString
+		var status string
+		var acknowledged bool
+		var pendingExpiration sql.NullTime
+
+		err = statement.QueryRowContext(ctx, orgId, messageId).Scan(
+			&message.MessageID,
+			&message.OrgID,
+			&accountString,
+			&message.ClientID,
+			&message.Directive,
+			&status,
+			&errorString,
+			&message.Created,
+			&message.Updated,
+			&acknowledged,
+			&pendingExpiration)
+
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return message, NotFoundError
+			}
+
+			logger.LogWithError(log, "SQL query failed:", err)
+			return message, err
+		}
+
+		if accountString.Valid {
+			message.Account = domain.AccountID(accountString.String)
+		}
+
+		if errorString.Valid {
+			message.Error = errorString.String
+		}
+
+		message.Status = determineMessageStatus(domain.MessageStatus(status), acknowledged, pendingExpiration, message.Updated, cfg.MessageAcknowledgementTimeout, time.Now())
+
+		return message, nil
+	}, nil
+}
+
+func determineMessageStatus(recordedStatus domain.MessageStatus, acknowledged bool, pendingExpiration sql.NullTime, published time.Time, acknowledgementTimeout time.Duration, now time.Time) domain.MessageStatus {
+
+	if recordedStatus == domain.MessagePending {
+		// A pending message that is no longer waiting for delivery was never delivered
+		if pendingExpiration.Valid == false || pendingExpiration.Time.Before(now) {
+			return domain.MessageExpired
+		}
+		return domain.MessagePending
+	}
+
+	if recordedStatus != domain.MessagePublished {
+		return recordedStatus
+	}
+
+	if acknowledged {
+		return domain.MessageAcknowledged
+	}
+
+	if published.Add(acknowledgementTimeout).Before(now) {
+		return domain.MessageExpired
+	}
+
+	return domain.MessagePublished
+}
@@ -0,0 +1,134 @@
+//go:build sql
+// +build sql
+
+package connection_repository
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/cloud-connector/internal/config"
+	"github.com/RedHatInsights/cloud-connector/internal/domain"
+	"github.com/RedHatInsights/cloud-connector/internal/platform/db"
+	"github.com/RedHatInsights/cloud-connector/internal/platform/logger"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+func TestSqlMessageLedger(t *testing.T) {
+
+	cfg := config.GetConfig()
+
+	database, err := db.InitializeDatabaseConnection(cfg)
+	if err != nil {
+		t.Fatal("Unable to connect to database: ", err)
+	}
+
+	messageLedger, err := NewSqlMessageLedger(cfg, database)
+	if err != nil {
+		t.Fatal("unexpected error while creating the SqlMessageLedger", err)
+	}
+
+	messageEventRecorder, err := NewSqlMessageEventRecorder(cfg, database)
+	if err != nil {
+		t.Fatal("unexpected error while creating the SqlMessageEventRecorder", err)
+	}
+
+	getMessageByMessageID, err := NewSqlGetMessageByMessageID(cfg, database)
+	if err != nil {
+		t.Fatal("unexpected error while creating the GetMessageByMessageID function", err)
+	}
+
+	testCases := []struct {
+		testName       string
+		status         domain.MessageStatus
+		acknowledge    bool
+		requestedOrgID domain.OrgID
+		expectedStatus domain.MessageStatus
+		expectedError  error
+	}{
+		{"published message", domain.MessagePublished, false, "ledger-org-1", domain.MessagePublished, nil},
+		{"failed message", domain.MessageFailedPublish, false, "ledger-org-1", domain.MessageFailedPublish, nil},
+		{"acknowledged message", domain.MessagePublished, true, "ledger-org-1", domain.MessageAcknowledged, nil},
+		{"message from a different org", domain.MessagePublished, false, "ledger-org-2", "", NotFoundError},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+
+			log := logger.Log.WithFields(logrus.Fields{"test_name": tc.testName})
+
+			message := domain.Message{
+				MessageID: uuid.NewString(),
+				OrgID:     "ledger-org-1",
+				Account:   "ledger-account-1",
+				ClientID:  "ledger-test-client",
+				Directive: "rhc-worker-playbook",
+				Status:    tc.status,
+			}
+
+			err := messageLedger.RecordMessage(context.TODO(), message)
+			if err != nil {
+				t.Fatal("unexpected error while recording a message", err)
+			}
+
+			if tc.acknowledge {
+				event := domain.MessageEvent{
+					OrgID:      message.OrgID,
+					ClientID:   message.ClientID,
+					MessageID:  uuid.NewString(),
+					ResponseTo: message.MessageID,
+					Content:    "received",
+					Sent:       time.Now(),
+				}
+
+				err = messageEventRecorder.RecordMessageEvent(context.TODO(), event)
+				if err != nil {
+					t.Fatal("unexpected error while recording a message event", err)
+				}
+			}
+
+			actualMessage, err := getMessageByMessageID(context.TODO(), log, tc.requestedOrgID, message.MessageID)
+			if err != tc.expectedError {
+				t.Fatal("unexpected error while looking up a message", err)
+			}
+
+			if err == nil && actualMessage.Status != tc.expectedStatus {
+				t.Fatalf("expected status %s, got %s", tc.expectedStatus, actualMessage.Status)
+			}
+		})
+	}
+}
+
+func TestDetermineMessageStatus(t *testing.T) {
+
+	now := time.Now()
+
+	testCases := []struct {
+		testName          string
+		recordedStatus    domain.MessageStatus
+		acknowledged      bool
+		pendingExpiration sql.NullTime
+		published         time.Time
+		expectedStatus    domain.MessageStatus
+	}{
+		{"recently published", domain.MessagePublished, false, sql.NullTime{}, now, domain.MessagePublished},
+		{"acknowledged", domain.MessagePublished, true, sql.NullTime{}, now.Add(-2 * time.Hour), domain.MessageAcknowledged},
+		{"expired", domain.MessagePublished, false, sql.NullTime{}, now.Add(-2 * time.Hour), domain.MessageExpired},
+		{"failed publish", domain.MessageFailedPublish, false, sql.NullTime{}, now.Add(-2 * time.Hour), domain.MessageFailedPublish},
+		{"pending", domain.MessagePending, false, sql.NullTime{Time: now.Add(time.Hour), Valid: true}, now, domain.MessagePending},
+		{"pending past its ttl", domain.MessagePending, false, sql.NullTime{Time: now.Add(-time.Hour), Valid: true}, now, domain.MessageExpired},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			actualStatus := determineMessageStatus(tc.recordedStatus, tc.acknowledged, tc.pendingExpiration, tc.published, time.Hour, now)
+			if actualStatus != tc.expectedStatus {
+				t.Fatalf("expected status %s, got %s", tc.expectedStatus, actualStatus)
+			}
+		})
+	}
+}
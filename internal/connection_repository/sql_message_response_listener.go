@@ -0,0 +1,237 @@
+package connection_repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/RedHatInsights/cloud-connector/internal/config"
+	"github.com/RedHatInsights/cloud-connector/internal/domain"
+	"github.com/RedHatInsights/cloud-connector/internal/platform/logger"
+
+	"github.com/lib/pq"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// The message event recorder sends the response_to of each recorded event on this channel
+	messageResponseNotificationChannel = "message_event_response"
+
+	// The listener connection is pinged when idle so that a dead connection is detected
+	listenerPingInterval = 90 * time.Second
+
+	defaultResponsePollInterval = time.Second
+)
+
+// SqlMessageResponseListener waits for the event a client sends in response to a message.
+// The kafka consumer records the event in the message_events table.  The waiters check
+// the message_events table every poll interval.  If MESSAGE_RESPONSE_NOTIFICATIONS_ENABLED
+// is set, the kafka consumer also notifies the listener using LISTEN/NOTIFY so that the
+// waiters are woken up as soon as the response is recorded.  The notifications only wake
+// up the waiters.  The message_events table is always checked for the response so that a
+// response recorded before the waiter was added, or while the listener was reconnecting,
+// is not missed.
+type SqlMessageResponseListener struct {
+	database     *sql.DB
+	listener     *pq.Listener
+	queryTimeout time.Duration
+	pollInterval time.Duration
+
+	lock    sync.Mutex
+	waiters map[string]map[chan struct{}]struct{}
+}
+
+// NewSqlMessageResponseListener creates a listener that only polls for responses if
+// listener is nil
+func NewSqlMessageResponseListener(cfg *config.Config, database *sql.DB, listener *pq.Listener) (*SqlMessageResponseListener, error) {
+
+	pollInterval := cfg.MessageResponsePollInterval
+	if pollInterval <= 0 {
+		pollInterval = defaultResponsePollInterval
+	}
+
+	smrl := &SqlMessageResponseListener{
+		database:     database,
+		listener:     listener,
+		queryTimeout: cfg.ConnectionDatabaseQueryTimeout,
+		pollInterval: pollInterval,
+		waiters:      make(map[string]map[chan struct{}]struct{}),
+	}
+
+	if listener == nil {
+		return smrl, nil
+	}
+
+	err := listener.Listen(messageResponseNotificationChannel)
+	if err != nil {
+		return nil, err
+	}
+
+	go smrl.processNotifications()
+
+	return smrl, nil
+}
+
+// WaitForResponse blocks until the client responds to the message or the context is done
+func (smrl *SqlMessageResponseListener) WaitForResponse(ctx context.Context, clientID domain.ClientID, messageID string) (domain.MessageEvent, error) {
+
+	log := logger.Log.WithFields(logrus.Fields{"client_id": clientID, "message_id": messageID})
+
+	wakeup := smrl.addWaiter(messageID)
+	defer smrl.removeWaiter(messageID, wakeup)
+
+	pollTicker := time.NewTicker(smrl.pollInterval)
+	defer pollTicker.Stop()
+
+	for {
+		event, err := smrl.lookupResponse(ctx, log, clientID, messageID)
+		if err == nil {
+			return event, nil
+		}
+
+		if errors.Is(err, NotFoundError) == false {
+			return event, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return domain.MessageEvent{}, ctx.Err()
+		case <-wakeup:
+		case <-pollTicker.C:
+		}
+	}
+}
+
+// Close stops the listener.  Callers that are waiting for a response wait until
+// their context is done.
+func (smrl *SqlMessageResponseListener) Close() error {
+	if smrl.listener == nil {
+		return nil
+	}
+
+	return smrl.listener.Close()
+}
+
+func (smrl *SqlMessageResponseListener) processNotifications() {
+
+	pingTicker := time.NewTicker(listenerPingInterval)
+	defer pingTicker.Stop()
+
+	for {
+		select {
+		case notification, ok := <-smrl.listener.Notify:
+			if ok == false {
+				return
+			}
+
+			if notification == nil {
+				// Notifications sent while the listener was reconnecting are lost
+				logger.Log.Info("Database listener reconnected...checking for missed message responses")
+				smrl.wakeupAll()
+				continue
+			}
+
+			smrl.wakeup(notification.Extra)
+
+		case <-pingTicker.C:
+			go func() {
+				if err := smrl.listener.Ping(); err != nil {
+					logger.Log.WithFields(logrus.Fields{"error": err}).Warn("Unable to ping database listener connection")
+				}
+			}()
+		}
+	}
+}
+
+func (smrl *SqlMessageResponseListener) lookupResponse(ctx context.Context, log *logrus.Entry, clientID domain.ClientID, messageID string) (domain.MessageEvent, error) {
+
+	callDurationTimer := prometheus.NewTimer(metrics.sqlLookupMessageEventsDuration)
+	defer callDurationTimer.ObserveDuration()
+
+	ctx, cancel := context.WithTimeout(ctx, smrl.queryTimeout)
+	defer cancel()
+
+	event := domain.MessageEvent{ClientID: clientID}
+
+	statement, err := smrl.database.Prepare(
+		`SELECT org_id, message_id, response_to, content, message_sent FROM message_events
+            WHERE client_id = $1 AND response_to = $2
+            ORDER BY message_sent, id
+            LIMIT 1`)
+	if err != nil {
+		return event, FatalError{err}
+	}
+	defer statement.Close()
+
+	var serializedContent sql.NullString
+
+	err = statement.QueryRowContext(ctx, clientID, messageID).Scan(&event.OrgID, &event.MessageID, &event.ResponseTo, &serializedContent, &event.Sent)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return event, NotFoundError
+		}
+
+		return event, translateSqlError(err)
+	}
+
+	event.Content = deserializeEventContent(log, serializedContent)
+
+	return event, nil
+}
+
+func (smrl *SqlMessageResponseListener) addWaiter(messageID string) chan struct{} {
+	smrl.lock.Lock()
+	defer smrl.lock.Unlock()
+
+	wakeup := make(chan struct{}, 1)
+
+	if _, found := smrl.waiters[messageID]; found == false {
+		smrl.waiters[messageID] = make(map[chan struct{}]struct{})
+	}
+
+	smrl.waiters[messageID][wakeup] = struct{}{}
+
+	return wakeup
+}
+
+func (smrl *SqlMessageResponseListener) removeWaiter(messageID string, wakeup chan struct{}) {
+	smrl.lock.Lock()
+	defer smrl.lock.Unlock()
+
+	delete(smrl.waiters[messageID], wakeup)
+
+	if len(smrl.waiters[messageID]) == 0 {
+		delete(smrl.waiters, messageID)
+	}
+}
+
+func (smrl *SqlMessageResponseListener) wakeup(messageID string) {
+	smrl.lock.Lock()
+	defer smrl.lock.Unlock()
+
+	for wakeup := range smrl.waiters[messageID] {
+		signalWaiter(wakeup)
+	}
+}
+
+func (smrl *SqlMessageResponseListener) wakeupAll() {
+	smrl.lock.Lock()
+	defer smrl.lock.Unlock()
+
+	for _, waiters := range smrl.waiters {
+		for wakeup := range waiters {
+			signalWaiter(wakeup)
+		}
+	}
+}
+
+func signalWaiter(wakeup chan struct{}) {
+	// The waiter checks the database after each wakeup, so a pending wakeup is enough
+	select {
+	case wakeup <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,106 @@
+//go:build sql
+// +build sql
+
+package connection_repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/cloud-connector/internal/config"
+	"github.com/RedHatInsights/cloud-connector/internal/domain"
+	"github.com/RedHatInsights/cloud-connector/internal/platform/db"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+func TestWaitForResponse(t *testing.T) {
+
+	cfg := config.GetConfig()
+	cfg.MessageResponseNotificationsEnabled = true
+
+	databaseListener, err := db.InitializeListener(cfg)
+	if err != nil {
+		t.Fatal("Unable to create database listener: ", err)
+	}
+
+	testWaitForResponse(t, cfg, databaseListener)
+}
+
+func TestWaitForResponseWithoutNotifications(t *testing.T) {
+
+	cfg := config.GetConfig()
+	cfg.MessageResponseNotificationsEnabled = false
+	cfg.MessageResponsePollInterval = 50 * time.Millisecond
+
+	testWaitForResponse(t, cfg, nil)
+}
+
+func testWaitForResponse(t *testing.T, cfg *config.Config, databaseListener *pq.Listener) {
+
+	database, err := db.InitializeDatabaseConnection(cfg)
+	if err != nil {
+		t.Fatal("Unable to connect to database: ", err)
+	}
+
+	responseListener, err := NewSqlMessageResponseListener(cfg, database, databaseListener)
+	if err != nil {
+		t.Fatal("unexpected error while creating the SqlMessageResponseListener", err)
+	}
+	defer responseListener.Close()
+
+	eventRecorder, err := NewSqlMessageEventRecorder(cfg, database)
+	if err != nil {
+		t.Fatal("unexpected error while creating the SqlMessageEventRecorder", err)
+	}
+
+	clientID := domain.ClientID("response-listener-test-client")
+	messageID := uuid.New().String()
+
+	go func() {
+		// Record the response after the waiter has started waiting
+		time.Sleep(100 * time.Millisecond)
+
+		err := eventRecorder.RecordMessageEvent(context.TODO(), domain.MessageEvent{
+			OrgID:      "1234",
+			ClientID:   clientID,
+			MessageID:  uuid.New().String(),
+			ResponseTo: messageID,
+			Content:    "RECEIVED",
+			Sent:       time.Now(),
+		})
+		if err != nil {
+			t.Error("unexpected error while recording the response", err)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	event, err := responseListener.WaitForResponse(ctx, clientID, messageID)
+	if err != nil {
+		t.Fatal("expected the response to be received, got", err)
+	}
+
+	if event.ResponseTo != messageID || event.Content != "RECEIVED" {
+		t.Fatalf("unexpected response: %+v", event)
+	}
+
+	// The response was recorded before the waiter started waiting
+	event, err = responseListener.WaitForResponse(ctx, clientID, messageID)
+	if err != nil || event.ResponseTo != messageID {
+		t.Fatal("expected the recorded response to be found, got", err)
+	}
+
+	// A response from a different client does not count
+	ctx, cancel = context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	_, err = responseListener.WaitForResponse(ctx, "response-listener-test-other-client", messageID)
+	if errors.Is(err, context.DeadlineExceeded) == false {
+		t.Fatal("expected the wait to time out, got", err)
+	}
+}
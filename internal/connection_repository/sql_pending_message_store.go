@@ -0,0 +1,214 @@
+package connection_repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/RedHatInsights/cloud-connector/internal/config"
+	"github.com/RedHatInsights/cloud-connector/internal/domain"
+	"github.com/RedHatInsights/cloud-connector/internal/platform/logger"
+
+	"github.com/jackc/pgerrcode"
+	"github.com/lib/pq"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+type SqlPendingMessageStore struct {
+	database     *sql.DB
+	statements   statementPreparer
+	queryTimeout time.Duration
+}
+
+func NewSqlPendingMessageStore(cfg *config.Config, database *sql.DB) (*SqlPendingMessageStore, error) {
+	return &SqlPendingMessageStore{
+		database:     database,
+		statements:   newStatementPreparer(cfg, database),
+		queryTimeout: cfg.ConnectionDatabaseQueryTimeout,
+	}, nil
+}
+
+// StorePendingMessage stores the message until the client comes online.  The message
+// is also added to the message ledger so that its status can be tracked.
+func (spms *SqlPendingMessageStore) StorePendingMessage(ctx context.Context, message domain.PendingMessage) error {
+
+	callDurationTimer := prometheus.NewTimer(metrics.sqlPendingMessageStoreDuration)
+	defer callDurationTimer.ObserveDuration()
+
+	logger := logger.Log.WithFields(logrus.Fields{"org_id": message.OrgID, "account": message.Account, "client_id": message.ClientID, "message_id": message.MessageID})
+
+	serializedMetadata, err := json.Marshal(message.Metadata)
+	if err != nil {
+		logger.WithFields(logrus.Fields{"error": err}).Error("Unable to marshal message metadata")
+		return err
+	}
+
+	serializedPayload, err := json.Marshal(message.Payload)
+	if err != nil {
+		logger.WithFields(logrus.Fields{"error": err}).Error("Unable to marshal message payload")
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, spms.queryTimeout)
+	defer cancel()
+
+	tx, err := spms.database.BeginTx(ctx, nil)
+	if err != nil {
+		logger.WithFields(logrus.Fields{"error": err}).Error("Unable to start transaction")
+		return translateSqlError(err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO pending_messages (message_id, org_id, account, client_id, directive, metadata, payload, expires_at)
+            VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		message.MessageID, message.OrgID, message.Account, message.ClientID, message.Directive, serializedMetadata, serializedPayload, message.Expires)
+	if err != nil {
+		logger.WithFields(logrus.Fields{"error": err}).Error("Insert into pending_messages failed")
+		return translateSqlError(err)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO messages (message_id, org_id, account, client_id, directive, status)
+            VALUES ($1, $2, $3, $4, $5, $6)`,
+		message.MessageID, message.OrgID, message.Account, message.ClientID, message.Directive, domain.MessagePending)
+	if err != nil {
+		logger.WithFields(logrus.Fields{"error": err}).Error("Insert into messages failed")
+		return translateSqlError(err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		logger.WithFields(logrus.Fields{"error": err}).Error("Commit failed")
+		return translateSqlError(err)
+	}
+
+	logger.Debug("Stored pending message")
+	return nil
+}
+
+// GetPendingMessages returns the unexpired messages waiting to be delivered to the client
+// in the order in which they were sent
+func (spms *SqlPendingMessageStore) GetPendingMessages(ctx context.Context, orgID domain.OrgID, clientID domain.ClientID) ([]domain.PendingMessage, error) {
+
+	callDurationTimer := prometheus.NewTimer(metrics.sqlPendingMessageLookupDuration)
+	defer callDurationTimer.ObserveDuration()
+
+	log := logger.Log.WithFields(logrus.Fields{"org_id": orgID, "client_id": clientID})
+
+	ctx, cancel := context.WithTimeout(ctx, spms.queryTimeout)
+	defer cancel()
+
+	statement, release, err := spms.statements.prepare(
+		`SELECT message_id, account, directive, metadata, payload, created_at, expires_at FROM pending_messages
+            WHERE org_id = $1 AND client_id = $2 AND expires_at > NOW()
+            ORDER BY created_at, id`)
+	if err != nil {
+		log.WithFields(logrus.Fields{"error": err}).Error("Prepare failed")
+		return nil, FatalError{err}
+	}
+	defer func() { release(err) }()
+
+	rows, err := statement.QueryContext(ctx, orgID, clientID)
+	if err != nil {
+		log.WithFields(logrus.Fields{"error": err}).Error("Query failed")
+		return nil, translateSqlError(err)
+	}
+	defer rows.Close()
+
+	pendingMessages := []domain.PendingMessage{}
+
+	for rows.Next() {
+		var accountString sql.NullString
+		var serializedMetadata sql.NullString
+		var serializedPayload sql.NullString
+
+		message := domain.PendingMessage{OrgID: orgID, ClientID: clientID}
+
+		if err := rows.Scan(&message.MessageID, &accountString, &message.Directive, &serializedMetadata, &serializedPayload, &message.Created, &message.Expires); err != nil {
+			logger.LogWithError(log, "SQL scan failed.  Skipping row.", err)
+			continue
+		}
+
+		if accountString.Valid {
+			message.Account = domain.AccountID(accountString.String)
+		}
+
+		message.Metadata = deserializeMessageMetadata(log, serializedMetadata)
+		message.Payload = deserializeMessagePayload(log, serializedPayload)
+
+		pendingMessages = append(pendingMessages, message)
+	}
+
+	return pendingMessages, nil
+}
+
+// MarkPendingMessageDelivered removes the message from the pending messages and
+// marks the message as published in the message ledger
+func (spms *SqlPendingMessageStore) MarkPendingMessageDelivered(ctx context.Context, messageID string) error {
+
+	logger := logger.Log.WithFields(logrus.Fields{"message_id": messageID})
+
+	ctx, cancel := context.WithTimeout(ctx, spms.queryTimeout)
+	defer cancel()
+
+	tx, err := spms.database.BeginTx(ctx, nil)
+	if err != nil {
+		logger.WithFields(logrus.Fields{"error": err}).Error("Unable to start transaction")
+		return translateSqlError(err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM pending_messages WHERE message_id = $1", messageID)
+	if err != nil {
+		logger.WithFields(logrus.Fields{"error": err}).Error("Delete from pending_messages failed")
+		return translateSqlError(err)
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE messages SET status = $1, updated_at = NOW() WHERE message_id = $2", domain.MessagePublished, messageID)
+	if err != nil {
+		logger.WithFields(logrus.Fields{"error": err}).Error("Update of messages failed")
+		return translateSqlError(err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		logger.WithFields(logrus.Fields{"error": err}).Error("Commit failed")
+		return translateSqlError(err)
+	}
+
+	logger.Debug("Pending message delivered")
+	return nil
+}
+
+// PruneExpiredPendingMessages removes the pending messages that expired before the cutoff.
+// A message without a pending_messages row is reported as expired by the message ledger.
+func PruneExpiredPendingMessages(ctx context.Context, databaseConn *sql.DB, sqlTimeout time.Duration, cutoff time.Time) (int64, error) {
+
+	queryCtx, cancel := context.WithTimeout(ctx, sqlTimeout)
+	defer cancel()
+
+	statement, err := databaseConn.Prepare("DELETE FROM pending_messages WHERE expires_at < $1")
+	if err != nil {
+		logger.LogError("SQL Prepare failed", err)
+		return 0, err
+	}
+	defer statement.Close()
+
+	result, err := statement.ExecContext(queryCtx, cutoff)
+	if err != nil {
+		logger.LogError("SQL delete failed", err)
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+func translateSqlError(err error) error {
+	if pqErr, ok := err.(*pq.Error); ok && pgerrcode.IsConnectionException(pqErr.Code.Name()) {
+		// Only mark the error as fatal if we failed to establish a connection to the database
+		return FatalError{err}
+	}
+
+	return err
+}
@@ -0,0 +1,177 @@
+package connection_repository
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/RedHatInsights/cloud-connector/internal/config"
+
+	"github.com/jackc/pgerrcode"
+	"github.com/lib/pq"
+)
+
+// statementPreparer hands out prepared statements.  The returned release function must be
+// called with the error (if any) returned while using the statement once the statement is
+// no longer needed.
+type statementPreparer interface {
+	prepare(query string) (*sql.Stmt, func(error), error)
+}
+
+// perCallStatementPreparer prepares the query every time a statement is requested and
+// closes the statement when it is released
+type perCallStatementPreparer struct {
+	database *sql.DB
+}
+
+func (p perCallStatementPreparer) prepare(query string) (*sql.Stmt, func(error), error) {
+	statement, err := p.database.Prepare(query)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return statement, func(error) { statement.Close() }, nil
+}
+
+// newStatementPreparer returns a statement cache that prepares each query the first time
+// it is used if prepared statements are reused.  Otherwise, the queries are prepared on
+// every call.
+func newStatementPreparer(cfg *config.Config, database *sql.DB) statementPreparer {
+	if cfg.ConnectionDatabaseReusePreparedStatements == false {
+		return perCallStatementPreparer{database}
+	}
+
+	return newSqlStatementCache(database)
+}
+
+type cachedStatement struct {
+	statement *sql.Stmt
+	users     int
+	evicted   bool
+}
+
+// SqlStatementCache prepares each query once and hands out the same prepared statement to
+// every caller.  database/sql takes care of preparing the statement on each pooled
+// connection.  If a statement fails because the connection to the database was lost, the
+// statement is evicted and the query is prepared again on the next request.  An evicted
+// statement is only closed once the callers that are still using it have released it.
+type SqlStatementCache struct {
+	database   *sql.DB
+	lock       sync.Mutex
+	statements map[string]*cachedStatement
+}
+
+func NewSqlStatementCache(database *sql.DB, queries ...string) (*SqlStatementCache, error) {
+
+	cache := newSqlStatementCache(database)
+
+	for _, query := range queries {
+		_, release, err := cache.prepare(query)
+		if err != nil {
+			cache.Close()
+			return nil, err
+		}
+		release(nil)
+	}
+
+	return cache, nil
+}
+
+func newSqlStatementCache(database *sql.DB) *SqlStatementCache {
+	return &SqlStatementCache{
+		database:   database,
+		statements: make(map[string]*cachedStatement),
+	}
+}
+
+func (c *SqlStatementCache) prepare(query string) (*sql.Stmt, func(error), error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	cached, found := c.statements[query]
+	if found == false {
+		statement, err := c.database.Prepare(query)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		metrics.sqlStatementPrepareCount.Inc()
+
+		cached = &cachedStatement{statement: statement}
+		c.statements[query] = cached
+	}
+
+	cached.users++
+
+	return cached.statement, func(err error) {
+		c.release(query, cached, isConnectionLossError(err))
+	}, nil
+}
+
+func (c *SqlStatementCache) release(query string, cached *cachedStatement, evict bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	cached.users--
+
+	// Another caller might have already replaced the statement
+	if evict && cached.evicted == false && c.statements[query] == cached {
+		delete(c.statements, query)
+		cached.evicted = true
+
+		metrics.sqlStatementEvictionCount.Inc()
+	}
+
+	if cached.evicted && cached.users == 0 {
+		cached.statement.Close()
+	}
+}
+
+// Close closes all of the prepared statements.  Statements that are still in use are
+// closed once they are released.
+func (c *SqlStatementCache) Close() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for query, cached := range c.statements {
+		delete(c.statements, query)
+		cached.evicted = true
+
+		if cached.users == 0 {
+			cached.statement.Close()
+		}
+	}
+}
+
+func isConnectionLossError(err error) bool {
+
+	if err == nil {
+		return false
+	}
+
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		code := string(pqErr.Code)
+		// The prepared statement no longer exists if the server side session was reset
+		return pgerrcode.IsConnectionException(code) ||
+			code == pgerrcode.AdminShutdown ||
+			code == pgerrcode.CrashShutdown ||
+			code == pgerrcode.CannotConnectNow ||
+			code == pgerrcode.InvalidSQLStatementName
+	}
+
+	// database/sql does not export the error returned when using a closed statement
+	return err.Error() == "sql: statement is closed"
+}
@@ -0,0 +1,118 @@
+package connection_repository
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/jackc/pgerrcode"
+	"github.com/lib/pq"
+)
+
+// The fake driver only needs to support preparing and executing statements
+type fakeDriver struct{}
+
+func (fakeDriver) Open(name string) (driver.Conn, error) { return fakeConn{}, nil }
+
+type fakeConn struct{}
+
+func (fakeConn) Prepare(query string) (driver.Stmt, error) { return fakeStmt{}, nil }
+func (fakeConn) Close() error                              { return nil }
+func (fakeConn) Begin() (driver.Tx, error)                 { return nil, errors.New("not supported") }
+
+type fakeStmt struct{}
+
+func (fakeStmt) Close() error  { return nil }
+func (fakeStmt) NumInput() int { return -1 }
+func (fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return driver.RowsAffected(1), nil
+}
+func (fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return nil, errors.New("not supported")
+}
+
+func init() {
+	sql.Register("fake-statement-cache", fakeDriver{})
+}
+
+func TestIsConnectionLossError(t *testing.T) {
+
+	testCases := []struct {
+		err      error
+		expected bool
+	}{
+		{nil, false},
+		{sql.ErrNoRows, false},
+		{driver.ErrBadConn, true},
+		{sql.ErrConnDone, true},
+		{io.ErrUnexpectedEOF, true},
+		{FatalError{driver.ErrBadConn}, true},
+		{&pq.Error{Code: pgerrcode.ConnectionFailure}, true},
+		{&pq.Error{Code: pgerrcode.AdminShutdown}, true},
+		{&pq.Error{Code: pgerrcode.InvalidSQLStatementName}, true},
+		{&pq.Error{Code: pgerrcode.QueryCanceled}, false},
+		{&pq.Error{Code: pgerrcode.UniqueViolation}, false},
+		{errors.New("sql: statement is closed"), true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%v", tc.err), func(t *testing.T) {
+			if actual := isConnectionLossError(tc.err); actual != tc.expected {
+				t.Fatalf("expected %t, got %t", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestSqlStatementCacheKeepsEvictedStatementOpenUntilReleased(t *testing.T) {
+
+	database, err := sql.Open("fake-statement-cache", "")
+	if err != nil {
+		t.Fatal("unable to open the fake database", err)
+	}
+	defer database.Close()
+
+	cache := newSqlStatementCache(database)
+	defer cache.Close()
+
+	query := "SELECT 1"
+
+	statement, release, err := cache.prepare(query)
+	if err != nil {
+		t.Fatal("unexpected error preparing the statement", err)
+	}
+
+	inFlightStatement, releaseInFlight, err := cache.prepare(query)
+	if err != nil {
+		t.Fatal("unexpected error preparing the statement", err)
+	}
+
+	if inFlightStatement != statement {
+		t.Fatal("expected the cached statement to be reused")
+	}
+
+	release(driver.ErrBadConn)
+
+	if _, err := inFlightStatement.Exec(); err != nil {
+		t.Fatalf("expected the evicted statement to stay open while it is in use, got %v", err)
+	}
+
+	releaseInFlight(nil)
+
+	if _, err := inFlightStatement.Exec(); isConnectionLossError(err) == false {
+		t.Fatalf("expected the evicted statement to be closed once it was released, got %v", err)
+	}
+
+	preparedAgain, releasePreparedAgain, err := cache.prepare(query)
+	if err != nil {
+		t.Fatal("unexpected error preparing the statement", err)
+	}
+	defer releasePreparedAgain(nil)
+
+	if preparedAgain == statement {
+		t.Fatal("expected the evicted statement to be prepared again")
+	}
+}
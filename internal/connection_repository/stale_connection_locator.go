@@ -21,6 +21,7 @@ func ProcessStaleConnections(ctx context.Context, databaseConn *sql.DB, sqlTimeo
 	statement, err := databaseConn.Prepare(
 		`SELECT account, org_id, client_id, canonical_facts, tags, dispatchers, tenant_lookup_failure_count FROM connections
            WHERE org_id != '' AND
+              state = 'online' AND
               canonical_facts != '{}' AND
            ( dispatchers ? 'rhc-worker-playbook' OR dispatchers ? 'package-manager' ) AND
              stale_timestamp < $1
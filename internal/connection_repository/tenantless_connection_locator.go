@@ -3,6 +3,8 @@ package connection_repository
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/RedHatInsights/cloud-connector/internal/domain"
@@ -19,6 +21,7 @@ func ProcessTenantlessConnections(ctx context.Context, databaseConn *sql.DB, sql
 	statement, err := databaseConn.Prepare(
 		`SELECT account, org_id, client_id, canonical_facts, tags, dispatchers, tenant_lookup_timestamp, tenant_lookup_failure_count FROM connections
            WHERE org_id = '' AND
+             state = 'online' AND
              tenant_lookup_timestamp IS NOT NULL AND
              tenant_lookup_timestamp < $1 AND 
              tenant_lookup_failure_count < $2
@@ -35,8 +38,56 @@ func ProcessTenantlessConnections(ctx context.Context, databaseConn *sql.DB, sql
 		logger.LogFatalError("SQL query failed", err)
 		return nil
 	}
+
+	connections, err := readTenantlessConnectionRows(rows)
+	if err != nil {
+		return err
+	}
+
+	return processTenantlessConnections(ctx, connections, processConnection)
+}
+
+// ProcessPurgeableTenantlessConnections passes the tenantless connections whose tenant lookup
+// has failed at least purgeThreshold times to processConnection
+func ProcessPurgeableTenantlessConnections(ctx context.Context, databaseConn *sql.DB, sqlTimeout time.Duration, chunkSize int, purgeThreshold int, processConnection ConnectionProcessor) error {
+
+	queryCtx, cancel := context.WithTimeout(ctx, sqlTimeout)
+	defer cancel()
+
+	statement, err := databaseConn.Prepare(
+		`SELECT account, org_id, client_id, canonical_facts, tags, dispatchers, tenant_lookup_timestamp, tenant_lookup_failure_count FROM connections
+           WHERE org_id = '' AND
+             tenant_lookup_timestamp IS NOT NULL AND
+             tenant_lookup_failure_count >= $1
+             order by tenant_lookup_timestamp asc
+             limit $2`)
+	if err != nil {
+		return FatalError{err}
+	}
+	defer statement.Close()
+
+	rows, err := statement.QueryContext(queryCtx, purgeThreshold, chunkSize)
+	if err != nil {
+		return err
+	}
+
+	connections, err := readTenantlessConnectionRows(rows)
+	if err != nil {
+		return err
+	}
+
+	return processTenantlessConnections(ctx, connections, processConnection)
+}
+
+// readTenantlessConnectionRows reads all of the rows before any of the connections are
+// processed.  Processing a connection can take a while (tenant lookups, mqtt publishes)
+// and the query's timeout would otherwise cut the iteration short.
+func readTenantlessConnectionRows(rows *sql.Rows) ([]domain.ConnectorClientState, error) {
+
 	defer rows.Close()
 
+	var connections []domain.ConnectorClientState
+
 	for rows.Next() {
 		var account sql.NullString
 		var orgId sql.NullString
@@ -59,21 +110,90 @@ func ProcessTenantlessConnections(ctx context.Context, databaseConn *sql.DB, sql
 		tags := deserializeTags(log, serializedTags)
 
 		connectorClientState := domain.ConnectorClientState{
-			OrgID:                 domain.OrgID(orgId.String),
-			ClientID:              domain.ClientID(clientId),
-			CanonicalFacts:        canonicalFacts,
-			Dispatchers:           dispatchers,
-			Tags:                  tags,
-			TenantLookupTimestamp: tenantLookupTimestamp,
+			OrgID:                    domain.OrgID(orgId.String),
+			ClientID:                 domain.ClientID(clientId),
+			CanonicalFacts:           canonicalFacts,
+			Dispatchers:              dispatchers,
+			Tags:                     tags,
+			TenantLookupTimestamp:    tenantLookupTimestamp,
+			TenantLookupFailureCount: tenantCheckCount,
 		}
 
 		if account.Valid {
 			connectorClientState.Account = domain.AccountID(account.String)
 		}
 
-		processConnection(ctx, connectorClientState)
+		connections = append(connections, connectorClientState)
+	}
+
+	return connections, rows.Err()
+}
+
+// processTenantlessConnections passes each connection to processConnection.  Processing stops
+// on a fatal error.  Otherwise the failures are counted and reported once all of the
+// connections have been processed.
+func processTenantlessConnections(ctx context.Context, connections []domain.ConnectorClientState, processConnection ConnectionProcessor) error {
+
+	var failures int
+	var lastErr error
+
+	for _, connection := range connections {
+		err := processConnection(ctx, connection)
+		if err == nil {
+			continue
+		}
+
+		if errors.As(err, &FatalError{}) {
+			return err
+		}
+
+		failures++
+		lastErr = err
+	}
+
+	if failures > 0 {
+		return fmt.Errorf("unable to process %d of %d tenantless connections: %w", failures, len(connections), lastErr)
+	}
+
+	return nil
+}
+
+// PurgeTenantlessConnection removes a tenantless connection.  The connection is only removed
+// if it is still tenantless and its tenant lookup has failed at least purgeThreshold times.
+// NotFoundError is returned if the connection was not removed.
+func PurgeTenantlessConnection(ctx context.Context, databaseConn *sql.DB, sqlTimeout time.Duration, rhcClient domain.ConnectorClientState, purgeThreshold int) error {
+
+	log := logger.Log.WithFields(logrus.Fields{"account": rhcClient.Account, "org_id": rhcClient.OrgID, "client_id": rhcClient.ClientID})
+
+	log.Debug("Purging tenantless connection")
+
+	ctx, cancel := context.WithTimeout(ctx, sqlTimeout)
+	defer cancel()
+
+	statement, err := databaseConn.Prepare(
+		`DELETE FROM connections WHERE client_id = $1 AND org_id = '' AND tenant_lookup_failure_count >= $2`)
+	if err != nil {
+		return FatalError{err}
+	}
+	defer statement.Close()
+
+	results, err := statement.ExecContext(ctx, rhcClient.ClientID, purgeThreshold)
+	if err != nil {
+		return translateSqlError(err)
 	}
 
+	rowsAffected, err := results.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return NotFoundError
+	}
+
+	metrics.tenantlessConnectionPurgeCount.Inc()
+
+	log.Debug("rowsAffected:", rowsAffected)
 	return nil
 }
 
@@ -0,0 +1,89 @@
+//go:build sql
+// +build sql
+
+package connection_repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/cloud-connector/internal/config"
+	"github.com/RedHatInsights/cloud-connector/internal/domain"
+	"github.com/RedHatInsights/cloud-connector/internal/platform/db"
+)
+
+func TestPurgeTenantlessConnection(t *testing.T) {
+
+	cfg := config.GetConfig()
+
+	database, err := db.InitializeDatabaseConnection(cfg)
+	if err != nil {
+		t.Fatal("Unable to connect to database: ", err)
+	}
+
+	connectionRegistrar, err := NewSqlConnectionRegistrar(cfg, database)
+	if err != nil {
+		t.Fatal("unexpected error while creating the SqlConnectionRegistrar", err)
+	}
+
+	purgeThreshold := 3
+
+	testCases := []struct {
+		clientID           domain.ClientID
+		failureCount       int
+		expectedToBePurged bool
+	}{
+		{"purge-test-client-below-threshold", purgeThreshold - 1, false},
+		{"purge-test-client-at-threshold", purgeThreshold, true},
+	}
+
+	for _, tc := range testCases {
+		err := connectionRegistrar.Register(context.TODO(), domain.ConnectorClientState{
+			ClientID:        tc.clientID,
+			MessageMetadata: domain.MessageMetadata{LatestMessageID: "message", LatestTimestamp: time.Now()},
+		})
+		if err != nil {
+			t.Fatal("unexpected error while registering a tenantless connection", err)
+		}
+		defer connectionRegistrar.Unregister(context.TODO(), tc.clientID, domain.MessageMetadata{LatestTimestamp: time.Now()})
+
+		_, err = database.Exec("UPDATE connections SET tenant_lookup_timestamp = NOW(), tenant_lookup_failure_count = $1 WHERE client_id = $2", tc.failureCount, tc.clientID)
+		if err != nil {
+			t.Fatal("unexpected error while updating the tenant lookup failure count", err)
+		}
+	}
+
+	purgeable := make(map[domain.ClientID]domain.ConnectorClientState)
+
+	err = ProcessPurgeableTenantlessConnections(context.TODO(), database, cfg.ConnectionDatabaseQueryTimeout, 1000, purgeThreshold,
+		func(ctx context.Context, rhcClient domain.ConnectorClientState) error {
+			purgeable[rhcClient.ClientID] = rhcClient
+			return nil
+		})
+	if err != nil {
+		t.Fatal("unexpected error while locating purgeable connections", err)
+	}
+
+	for _, tc := range testCases {
+		if _, found := purgeable[tc.clientID]; found != tc.expectedToBePurged {
+			t.Fatalf("expected %s to be purgeable: %t, got %t", tc.clientID, tc.expectedToBePurged, found)
+		}
+
+		err := PurgeTenantlessConnection(context.TODO(), database, cfg.ConnectionDatabaseQueryTimeout, domain.ConnectorClientState{ClientID: tc.clientID}, purgeThreshold)
+
+		if tc.expectedToBePurged && err != nil {
+			t.Fatalf("expected %s to be purged, got %v", tc.clientID, err)
+		}
+
+		if tc.expectedToBePurged == false && err != NotFoundError {
+			t.Fatalf("expected %s not to be purged, got %v", tc.clientID, err)
+		}
+	}
+
+	// The connection has already been purged
+	err = PurgeTenantlessConnection(context.TODO(), database, cfg.ConnectionDatabaseQueryTimeout, domain.ConnectorClientState{ClientID: "purge-test-client-at-threshold"}, purgeThreshold)
+	if err != NotFoundError {
+		t.Fatalf("expected NotFoundError, got %v", err)
+	}
+}
@@ -0,0 +1,55 @@
+package connection_repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/RedHatInsights/cloud-connector/internal/domain"
+)
+
+func TestProcessTenantlessConnectionsReportsFailures(t *testing.T) {
+
+	connections := []domain.ConnectorClientState{{ClientID: "1"}, {ClientID: "2"}, {ClientID: "3"}}
+	lookupErr := errors.New("tenant lookup failed")
+
+	var processed []domain.ClientID
+
+	err := processTenantlessConnections(context.TODO(), connections,
+		func(ctx context.Context, rhcClient domain.ConnectorClientState) error {
+			processed = append(processed, rhcClient.ClientID)
+			if rhcClient.ClientID == "2" {
+				return lookupErr
+			}
+			return nil
+		})
+
+	if len(processed) != len(connections) {
+		t.Fatalf("expected all %d connections to be processed, got %d", len(connections), len(processed))
+	}
+
+	if errors.Is(err, lookupErr) == false {
+		t.Fatalf("expected the processing error to be reported, got %v", err)
+	}
+}
+
+func TestProcessTenantlessConnectionsStopsOnFatalError(t *testing.T) {
+
+	connections := []domain.ConnectorClientState{{ClientID: "1"}, {ClientID: "2"}}
+
+	var processed int
+
+	err := processTenantlessConnections(context.TODO(), connections,
+		func(ctx context.Context, rhcClient domain.ConnectorClientState) error {
+			processed++
+			return FatalError{errors.New("database is gone")}
+		})
+
+	if processed != 1 {
+		t.Fatalf("expected processing to stop after the fatal error, processed %d connections", processed)
+	}
+
+	if errors.As(err, &FatalError{}) == false {
+		t.Fatalf("expected a FatalError, got %v", err)
+	}
+}
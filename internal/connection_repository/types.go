@@ -14,17 +14,70 @@ type FatalError struct {
 }
 
 func (fe FatalError) Error() string { return "FATAL: " + fe.Err.Error() }
+func (fe FatalError) Unwrap() error { return fe.Err }
 
 var NotFoundError = errors.New("Not found")
 var InvalidOrgIDError = errors.New("Invalid OrgID")
 var InvalidClientIDError = errors.New("Invalid ClientID")
 
+// StaleConnectionStateError is returned by Register when the connection was not updated
+// because it was registered using a newer message.  UnregisterIfUnchanged returns it when
+// the connection was registered again using a different message.
+var StaleConnectionStateError = errors.New("Stale connection state")
+
 type ConnectionRegistrar interface {
 	Register(context.Context, domain.ConnectorClientState) error
-	Unregister(context.Context, domain.ClientID) error
+	// Unregister removes the connection using the provided offline message.  NotFoundError
+	// is returned if there was no connection left to remove.
+	Unregister(context.Context, domain.ClientID, domain.MessageMetadata) error
+	// UnregisterIfUnchanged only removes the connection if it is still registered using
+	// the provided message
+	UnregisterIfUnchanged(context.Context, domain.ClientID, domain.MessageMetadata) error
 	FindConnectionByClientID(context.Context, domain.ClientID) (domain.ConnectorClientState, error)
 }
 
+type MessageEventRecorder interface {
+	RecordMessageEvent(context.Context, domain.MessageEvent) error
+}
+
+type MessageLedger interface {
+	RecordMessage(context.Context, domain.Message) error
+}
+
+type ConnectionEventRecorder interface {
+	RecordConnectionEvent(context.Context, domain.ConnectionEvent) error
+}
+
+type MessageResponseWaiter interface {
+	WaitForResponse(context.Context, domain.ClientID, string) (domain.MessageEvent, error)
+}
+
+type PendingMessageStore interface {
+	StorePendingMessage(context.Context, domain.PendingMessage) error
+	GetPendingMessages(context.Context, domain.OrgID, domain.ClientID) ([]domain.PendingMessage, error)
+	MarkPendingMessageDelivered(context.Context, string) error
+}
+
+// ConnectionSelector narrows down a set of connections.  A connection matches the
+// selector when it has all of the tags, all of the canonical facts and all of the
+// dispatchers listed in the selector along with the client name and client version
+// (if provided).
+type ConnectionSelector struct {
+	Tags           map[string]string
+	CanonicalFacts map[string]interface{}
+	Dispatchers    []string
+	ClientName     string
+	ClientVersion  string
+}
+
+func (cs ConnectionSelector) IsEmpty() bool {
+	return len(cs.Tags) == 0 && len(cs.CanonicalFacts) == 0 && len(cs.Dispatchers) == 0 && cs.ClientName == "" && cs.ClientVersion == ""
+}
+
 type GetConnectionByClientID func(context.Context, *logrus.Entry, domain.OrgID, domain.ClientID) (domain.ConnectorClientState, error)
 type GetConnectionsByOrgID func(context.Context, *logrus.Entry, domain.OrgID, int, int) (map[domain.ClientID]domain.ConnectorClientState, int, error)
+type GetConnectionsBySelector func(context.Context, *logrus.Entry, domain.OrgID, ConnectionSelector, int, int) (map[domain.ClientID]domain.ConnectorClientState, int, error)
 type GetAllConnections func(context.Context, int, int) (map[domain.AccountID]map[domain.ClientID]domain.ConnectorClientState, int, error)
+type GetConnectionEventsByClientID func(context.Context, *logrus.Entry, domain.OrgID, domain.ClientID, int, int) ([]domain.ConnectionEvent, int, error)
+type GetMessageEventsByMessageID func(context.Context, *logrus.Entry, domain.OrgID, domain.ClientID, string) ([]domain.MessageEvent, error)
+type GetMessageByMessageID func(context.Context, *logrus.Entry, domain.OrgID, string) (domain.Message, error)
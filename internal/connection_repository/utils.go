@@ -1,7 +1,12 @@
 package connection_repository
 
 import (
+	"context"
+	"database/sql"
+	"time"
+
 	"github.com/RedHatInsights/cloud-connector/internal/domain"
+	"github.com/RedHatInsights/cloud-connector/internal/platform/logger"
 )
 
 func verifyOrgId(orgId domain.OrgID) error {
@@ -19,3 +24,38 @@ func verifyClientId(clientId domain.ClientID) error {
 
 	return nil
 }
+
+// deleteInBatches runs the delete statement until it removes fewer than batchSize rows.
+// The statement must take the cutoff and the batch size as its parameters.  Each batch
+// gets its own sql timeout.
+func deleteInBatches(ctx context.Context, statement *sql.Stmt, sqlTimeout time.Duration, cutoff time.Time, batchSize int) (int64, error) {
+
+	var totalDeleted int64
+
+	for {
+		deleted, err := deleteBatch(ctx, statement, sqlTimeout, cutoff, batchSize)
+		if err != nil {
+			return totalDeleted, err
+		}
+
+		totalDeleted += deleted
+
+		if deleted < int64(batchSize) {
+			return totalDeleted, nil
+		}
+	}
+}
+
+func deleteBatch(ctx context.Context, statement *sql.Stmt, sqlTimeout time.Duration, cutoff time.Time, batchSize int) (int64, error) {
+
+	queryCtx, cancel := context.WithTimeout(ctx, sqlTimeout)
+	defer cancel()
+
+	result, err := statement.ExecContext(queryCtx, cutoff, batchSize)
+	if err != nil {
+		logger.LogError("SQL delete failed", err)
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
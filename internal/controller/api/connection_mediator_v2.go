@@ -1,8 +1,14 @@
 package api
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/RedHatInsights/cloud-connector/internal/config"
 	"github.com/RedHatInsights/cloud-connector/internal/connection_repository"
@@ -13,27 +19,40 @@ import (
 	"github.com/redhatinsights/platform-go-middlewares/v2/identity"
 	"github.com/redhatinsights/platform-go-middlewares/v2/request_id"
 
+	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
 )
 
 type ConnectionMediatorV2 struct {
-	getConnectionByClientID connection_repository.GetConnectionByClientID
-	getConnectionsByOrgID   connection_repository.GetConnectionsByOrgID
-	router                  *mux.Router
-	config                  *config.Config
-	urlPrefix               string
-	proxyFactory            controller.ConnectorClientProxyFactory
+	getConnectionByClientID  connection_repository.GetConnectionByClientID
+	getConnectionStatus      connection_repository.GetConnectionByClientID
+	getConnectionsByOrgID    connection_repository.GetConnectionsByOrgID
+	getConnectionsBySelector connection_repository.GetConnectionsBySelector
+	getConnectionEvents      connection_repository.GetConnectionEventsByClientID
+	getMessageEvents         connection_repository.GetMessageEventsByMessageID
+	getMessage               connection_repository.GetMessageByMessageID
+	pendingMessageStore      connection_repository.PendingMessageStore
+	router                   *mux.Router
+	config                   *config.Config
+	urlPrefix                string
+	proxyFactory             controller.ConnectorClientProxyFactory
 }
 
-func NewConnectionMediatorV2(byClientID connection_repository.GetConnectionByClientID, byOrgID connection_repository.GetConnectionsByOrgID, proxyFactory controller.ConnectorClientProxyFactory, r *mux.Router, urlPrefix string, cfg *config.Config) *ConnectionMediatorV2 {
+func NewConnectionMediatorV2(byClientID connection_repository.GetConnectionByClientID, statusByClientID connection_repository.GetConnectionByClientID, byOrgID connection_repository.GetConnectionsByOrgID, bySelector connection_repository.GetConnectionsBySelector, connectionEvents connection_repository.GetConnectionEventsByClientID, messageEvents connection_repository.GetMessageEventsByMessageID, message connection_repository.GetMessageByMessageID, pendingMessageStore connection_repository.PendingMessageStore, proxyFactory controller.ConnectorClientProxyFactory, r *mux.Router, urlPrefix string, cfg *config.Config) *ConnectionMediatorV2 {
 	return &ConnectionMediatorV2{
-		getConnectionByClientID: byClientID,
-		getConnectionsByOrgID:   byOrgID,
-		router:                  r,
-		config:                  cfg,
-		urlPrefix:               urlPrefix,
-		proxyFactory:            proxyFactory,
+		getConnectionByClientID:  byClientID,
+		getConnectionStatus:      statusByClientID,
+		getConnectionsByOrgID:    byOrgID,
+		getConnectionsBySelector: bySelector,
+		getConnectionEvents:      connectionEvents,
+		getMessageEvents:         messageEvents,
+		getMessage:               message,
+		pendingMessageStore:      pendingMessageStore,
+		router:                   r,
+		config:                   cfg,
+		urlPrefix:                urlPrefix,
+		proxyFactory:             proxyFactory,
 	}
 }
 
@@ -50,15 +69,27 @@ func (this *ConnectionMediatorV2) Routes() {
 		mmw.RecordHTTPMetrics,
 		amw.Authenticate)
 
+	securedSubRouter.HandleFunc("/v2/connections/message", this.handleSendBulkMessage()).Methods(http.MethodPost)
 	securedSubRouter.HandleFunc("/v2/connections/{id}/message", this.handleSendMessage()).Methods(http.MethodPost)
 	securedSubRouter.HandleFunc("/v2/connections/{id}/status", this.handleConnectionStatus()).Methods(http.MethodGet)
+	securedSubRouter.HandleFunc("/v2/connections/{id}/history", this.handleConnectionHistory()).Methods(http.MethodGet)
+	securedSubRouter.HandleFunc("/v2/connections/{id}/messages/{message_id}/events", this.handleMessageEvents()).Methods(http.MethodGet)
 	securedSubRouter.HandleFunc("/v2/connections", this.handleConnectionListByOrgId()).Methods(http.MethodGet)
+	securedSubRouter.HandleFunc("/v2/messages/{message_id}", this.handleMessageStatus()).Methods(http.MethodGet)
 }
 
 type messageRequestV2 struct {
-	Payload   interface{} `json:"payload"`
-	Metadata  interface{} `json:"metadata"`
-	Directive string      `json:"directive" validate:"required"`
+	Payload           interface{} `json:"payload"`
+	Metadata          interface{} `json:"metadata"`
+	Directive         string      `json:"directive" validate:"required"`
+	DeliverWhenOnline bool        `json:"deliver_when_online"`
+	TTL               int         `json:"ttl"`
+	Strict            *bool       `json:"strict,omitempty"`
+}
+
+type directiveNotSupportedResponse struct {
+	errorResponse
+	SupportedDirectives []string `json:"supported_directives"`
 }
 
 func (this *ConnectionMediatorV2) handleSendMessage() http.HandlerFunc {
@@ -100,15 +131,29 @@ func (this *ConnectionMediatorV2) handleSendMessage() http.HandlerFunc {
 			return
 		}
 
+		ttl, err := this.determinePendingMessageTTL(msgRequest)
+		if err != nil {
+			logger.Debug(err.Error())
+			errorResponse := errorResponse{Title: err.Error(),
+				Status: http.StatusBadRequest,
+				Detail: err.Error()}
+			writeJSONResponse(w, errorResponse.Status, errorResponse)
+			return
+		}
+
 		logger.Infof("Looking up connection for org_id:%s - client id:%s",
 			principal.GetOrgID(), recipient)
 
 		var clientState domain.ConnectorClientState
-		var err error
 		clientState, err = this.getConnectionByClientID(req.Context(), logger, domain.OrgID(principal.GetOrgID()), recipient)
 		if err != nil {
 
 			if err == connection_repository.NotFoundError {
+				if msgRequest.DeliverWhenOnline {
+					this.storePendingMessage(req.Context(), logger, w, principal, recipient, msgRequest, ttl)
+					return
+				}
+
 				writeConnectionFailureResponse(logger, w)
 				return
 			}
@@ -119,6 +164,18 @@ func (this *ConnectionMediatorV2) handleSendMessage() http.HandlerFunc {
 			return
 		}
 
+		logger = logger.WithFields(logrus.Fields{"directive": msgRequest.Directive})
+
+		if this.isStrictDirectiveCheckEnabled(msgRequest.Strict) {
+			supportedDirectives := getSupportedDirectives(clientState.Dispatchers)
+
+			if !isDirectiveSupported(msgRequest.Directive, supportedDirectives) {
+				logger.Info("Directive is not supported by the client")
+				writeDirectiveNotSupportedResponse(w, msgRequest.Directive, supportedDirectives)
+				return
+			}
+		}
+
 		client, err := this.proxyFactory.CreateProxy(req.Context(), clientState.OrgID, clientState.Account, clientState.ClientID, clientState.CanonicalFacts, clientState.Dispatchers, clientState.Tags)
 		if err != nil {
 			logging.LogWithError(logger, "Unable to create proxy for connection", err)
@@ -126,7 +183,6 @@ func (this *ConnectionMediatorV2) handleSendMessage() http.HandlerFunc {
 			return
 		}
 
-		logger = logger.WithFields(logrus.Fields{"directive": msgRequest.Directive})
 		logger.Info("Sending a message")
 
 		jobID, err := client.SendMessage(req.Context(),
@@ -156,6 +212,322 @@ func (this *ConnectionMediatorV2) handleSendMessage() http.HandlerFunc {
 	}
 }
 
+func (this *ConnectionMediatorV2) isStrictDirectiveCheckEnabled(strict *bool) bool {
+	if strict != nil {
+		return *strict
+	}
+
+	return this.config.SendMessageStrictDirectiveCheck
+}
+
+// getSupportedDirectives returns the names of the workers the client advertised
+// within the dispatchers section of its connection-status message
+func getSupportedDirectives(dispatchers domain.Dispatchers) []string {
+	supportedDirectives := []string{}
+
+	dispatchersMap, ok := dispatchers.(map[string]interface{})
+	if !ok {
+		return supportedDirectives
+	}
+
+	for directive := range dispatchersMap {
+		supportedDirectives = append(supportedDirectives, directive)
+	}
+
+	sort.Strings(supportedDirectives)
+
+	return supportedDirectives
+}
+
+func isDirectiveSupported(directive string, supportedDirectives []string) bool {
+	for _, supportedDirective := range supportedDirectives {
+		if directive == supportedDirective {
+			return true
+		}
+	}
+
+	return false
+}
+
+func writeDirectiveNotSupportedResponse(w http.ResponseWriter, directive string, supportedDirectives []string) {
+	response := directiveNotSupportedResponse{
+		errorResponse: errorResponse{Title: "Directive not supported by client",
+			Status: http.StatusUnprocessableEntity,
+			Detail: fmt.Sprintf("The client does not have a worker for directive \"%s\"", directive)},
+		SupportedDirectives: supportedDirectives,
+	}
+	writeJSONResponse(w, response.Status, response)
+}
+
+func (this *ConnectionMediatorV2) determinePendingMessageTTL(msgRequest messageRequestV2) (time.Duration, error) {
+	if msgRequest.DeliverWhenOnline == false {
+		return 0, nil
+	}
+
+	if msgRequest.TTL < 0 {
+		return 0, errors.New("ttl must not be negative")
+	}
+
+	if msgRequest.TTL == 0 {
+		return this.config.PendingMessageDefaultTTL, nil
+	}
+
+	ttl := time.Duration(msgRequest.TTL) * time.Second
+	if ttl > this.config.PendingMessageMaxTTL {
+		return 0, fmt.Errorf("ttl must not exceed %d seconds", int(this.config.PendingMessageMaxTTL.Seconds()))
+	}
+
+	return ttl, nil
+}
+
+// storePendingMessage holds on to the message so that it can be delivered once
+// the client connects
+func (this *ConnectionMediatorV2) storePendingMessage(ctx context.Context, logger *logrus.Entry, w http.ResponseWriter, principal middlewares.Principal, recipient domain.ClientID, msgRequest messageRequestV2, ttl time.Duration) {
+
+	messageID := uuid.New()
+
+	logger = logger.WithFields(logrus.Fields{"directive": msgRequest.Directive, "message_id": messageID})
+
+	pendingMessage := domain.PendingMessage{
+		MessageID: messageID.String(),
+		OrgID:     domain.OrgID(principal.GetOrgID()),
+		Account:   domain.AccountID(principal.GetAccount()),
+		ClientID:  recipient,
+		Directive: msgRequest.Directive,
+		Metadata:  msgRequest.Metadata,
+		Payload:   msgRequest.Payload,
+		Expires:   time.Now().Add(ttl),
+	}
+
+	err := this.pendingMessageStore.StorePendingMessage(ctx, pendingMessage)
+	if err != nil {
+		logging.LogWithError(logger, "Unable to store pending message", err)
+		errorResponse := errorResponse{Title: "Unable to store pending message",
+			Status: http.StatusInternalServerError,
+			Detail: err.Error()}
+		writeJSONResponse(w, errorResponse.Status, errorResponse)
+		return
+	}
+
+	logger.Info("Client is not connected.  Message will be delivered when the client connects.")
+
+	writeJSONResponse(w, http.StatusAccepted, messageResponse{messageID.String()})
+}
+
+type connectionSelectorV2 struct {
+	Tags           map[string]string      `json:"tags"`
+	CanonicalFacts map[string]interface{} `json:"canonical_facts"`
+	Dispatchers    []string               `json:"dispatchers"`
+	ClientName     string                 `json:"client_name"`
+	ClientVersion  string                 `json:"client_version"`
+}
+
+type bulkMessageRequestV2 struct {
+	Recipients []domain.ClientID     `json:"recipients"`
+	Selector   *connectionSelectorV2 `json:"selector"`
+	Payload    interface{}           `json:"payload"`
+	Metadata   interface{}           `json:"metadata"`
+	Directive  string                `json:"directive" validate:"required"`
+	Strict     *bool                 `json:"strict,omitempty"`
+}
+
+const (
+	bulkMessageSent   = "sent"
+	bulkMessageFailed = "failed"
+
+	emptyRecipientErrorMsg = "Recipient client id must not be empty"
+)
+
+type bulkMessageResultV2 struct {
+	ID     string `json:"id,omitempty"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type bulkMessageResponseV2 struct {
+	Results map[domain.ClientID]bulkMessageResultV2 `json:"results"`
+}
+
+func (this *ConnectionMediatorV2) handleSendBulkMessage() http.HandlerFunc {
+
+	return func(w http.ResponseWriter, req *http.Request) {
+
+		principal, _ := middlewares.GetPrincipal(req.Context())
+		requestId := request_id.GetReqID(req.Context())
+
+		logger := logging.Log.WithFields(logrus.Fields{
+			"account":    principal.GetAccount(),
+			"org_id":     principal.GetOrgID(),
+			"request_id": requestId,
+		})
+
+		writeBadRequestResponse := func(errMsg string) {
+			logger.Debug(errMsg)
+			errorResponse := errorResponse{Title: errMsg,
+				Status: http.StatusBadRequest,
+				Detail: errMsg}
+			writeJSONResponse(w, errorResponse.Status, errorResponse)
+		}
+
+		var msgRequest bulkMessageRequestV2
+
+		body := http.MaxBytesReader(w, req.Body, 1048576)
+
+		if err := decodeJSON(body, &msgRequest); err != nil {
+			errMsg := "Unable to process json input"
+			logging.LogWithError(logger, errMsg, err)
+			errorResponse := errorResponse{Title: errMsg,
+				Status: http.StatusBadRequest,
+				Detail: err.Error()}
+			writeJSONResponse(w, errorResponse.Status, errorResponse)
+			return
+		}
+
+		if len(strings.TrimSpace(msgRequest.Directive)) == 0 {
+			writeBadRequestResponse(emptyDirectictiveErrorMsg)
+			return
+		}
+
+		if (len(msgRequest.Recipients) > 0) == (msgRequest.Selector != nil) {
+			writeBadRequestResponse("Exactly one of recipients or selector must be provided")
+			return
+		}
+
+		orgID := domain.OrgID(principal.GetOrgID())
+
+		var recipients map[domain.ClientID]*domain.ConnectorClientState
+
+		if msgRequest.Selector != nil {
+			selector := connection_repository.ConnectionSelector{
+				Tags:           msgRequest.Selector.Tags,
+				CanonicalFacts: msgRequest.Selector.CanonicalFacts,
+				Dispatchers:    msgRequest.Selector.Dispatchers,
+				ClientName:     msgRequest.Selector.ClientName,
+				ClientVersion:  msgRequest.Selector.ClientVersion,
+			}
+
+			if selector.IsEmpty() {
+				writeBadRequestResponse("Selector must contain at least one tag, canonical fact, dispatcher, client name or client version")
+				return
+			}
+
+			connections, totalConnections, err := this.getConnectionsBySelector(req.Context(), logger, orgID, selector, 0, this.config.BulkMessageMaxRecipients)
+			if err != nil {
+				logging.LogWithError(logger, "Error looking up connections by selector", err)
+				errorResponse := errorResponse{Title: "Error looking up connections by selector",
+					Status: http.StatusInternalServerError,
+					Detail: err.Error()}
+				writeJSONResponse(w, errorResponse.Status, errorResponse)
+				return
+			}
+
+			if totalConnections > this.config.BulkMessageMaxRecipients {
+				writeBadRequestResponse(fmt.Sprintf("Selector matches more than %d connections", this.config.BulkMessageMaxRecipients))
+				return
+			}
+
+			recipients = make(map[domain.ClientID]*domain.ConnectorClientState, len(connections))
+			for clientID, clientState := range connections {
+				clientState := clientState
+				recipients[clientID] = &clientState
+			}
+		} else {
+			recipients = make(map[domain.ClientID]*domain.ConnectorClientState, len(msgRequest.Recipients))
+			for _, clientID := range msgRequest.Recipients {
+				recipients[clientID] = nil
+			}
+
+			if len(recipients) > this.config.BulkMessageMaxRecipients {
+				writeBadRequestResponse(fmt.Sprintf("Number of recipients must not exceed %d", this.config.BulkMessageMaxRecipients))
+				return
+			}
+		}
+
+		logger = logger.WithFields(logrus.Fields{"directive": msgRequest.Directive})
+		logger.Infof("Sending a message to %d connections", len(recipients))
+
+		response := bulkMessageResponseV2{Results: make(map[domain.ClientID]bulkMessageResultV2, len(recipients))}
+
+		var resultsMutex sync.Mutex
+		var wg sync.WaitGroup
+		concurrency := this.config.BulkMessageConcurrency
+		if concurrency < 1 {
+			concurrency = 1
+		}
+		semaphore := make(chan struct{}, concurrency)
+
+		for clientID, clientState := range recipients {
+			wg.Add(1)
+			semaphore <- struct{}{}
+
+			go func(clientID domain.ClientID, clientState *domain.ConnectorClientState) {
+				defer func() {
+					<-semaphore
+					wg.Done()
+				}()
+
+				result := this.sendMessageToRecipient(req.Context(), logger.WithFields(logrus.Fields{"recipient": clientID}), orgID, clientID, clientState, msgRequest)
+
+				resultsMutex.Lock()
+				response.Results[clientID] = result
+				resultsMutex.Unlock()
+			}(clientID, clientState)
+		}
+
+		wg.Wait()
+
+		writeJSONResponse(w, http.StatusOK, response)
+	}
+}
+
+// sendMessageToRecipient looks up the connection if the connection state is not
+// already known and then sends the message.  Failures are reported in the result
+// rather than failing the entire request.
+func (this *ConnectionMediatorV2) sendMessageToRecipient(ctx context.Context, logger *logrus.Entry, orgID domain.OrgID, clientID domain.ClientID, clientState *domain.ConnectorClientState, msgRequest bulkMessageRequestV2) bulkMessageResultV2 {
+
+	if len(strings.TrimSpace(string(clientID))) == 0 {
+		return bulkMessageResultV2{Status: bulkMessageFailed, Error: emptyRecipientErrorMsg}
+	}
+
+	if clientState == nil {
+		lookedUpClientState, err := this.getConnectionByClientID(ctx, logger, orgID, clientID)
+		if err != nil {
+			if err != connection_repository.NotFoundError {
+				logging.LogWithError(logger, "Unable to locate connection", err)
+			}
+			return bulkMessageResultV2{Status: bulkMessageFailed, Error: "No connection to the rhc client"}
+		}
+		clientState = &lookedUpClientState
+	}
+
+	if this.isStrictDirectiveCheckEnabled(msgRequest.Strict) {
+		if !isDirectiveSupported(msgRequest.Directive, getSupportedDirectives(clientState.Dispatchers)) {
+			logger.Info("Directive is not supported by the client")
+			return bulkMessageResultV2{Status: bulkMessageFailed, Error: fmt.Sprintf("The client does not have a worker for directive \"%s\"", msgRequest.Directive)}
+		}
+	}
+
+	client, err := this.proxyFactory.CreateProxy(ctx, clientState.OrgID, clientState.Account, clientState.ClientID, clientState.CanonicalFacts, clientState.Dispatchers, clientState.Tags)
+	if err != nil {
+		logging.LogWithError(logger, "Unable to create proxy for connection", err)
+		return bulkMessageResultV2{Status: bulkMessageFailed, Error: "No connection to the rhc client"}
+	}
+
+	jobID, err := client.SendMessage(ctx, msgRequest.Directive, msgRequest.Metadata, msgRequest.Payload)
+	if err == controller.ErrDisconnectedNode {
+		return bulkMessageResultV2{Status: bulkMessageFailed, Error: "No connection to the rhc client"}
+	}
+
+	if err != nil {
+		logging.LogWithError(logger, "Error passing message to rhc client", err)
+		return bulkMessageResultV2{Status: bulkMessageFailed, Error: err.Error()}
+	}
+
+	logger.WithFields(logrus.Fields{"message_id": jobID}).Debug("Message sent")
+
+	return bulkMessageResultV2{ID: jobID.String(), Status: bulkMessageSent}
+}
+
 func getClientIDFromRequestPath(req *http.Request) domain.ClientID {
 	params := mux.Vars(req)
 	return domain.ClientID(params["id"])
@@ -168,10 +540,13 @@ type connectionResponseV2 struct {
 	CanonicalFacts domain.CanonicalFacts `json:"canonical_facts,omitempty"`
 	Dispatchers    domain.Dispatchers    `json:"dispatchers,omitempty"`
 	Tags           domain.Tags           `json:"tags,omitempty"`
+	ClientName     string                `json:"client_name,omitempty"`
+	ClientVersion  string                `json:"client_version,omitempty"`
 }
 
 type connectionStatusResponseV2 struct {
-	Status string `json:"status"`
+	Status   string     `json:"status"`
+	LastSeen *time.Time `json:"last_seen,omitempty"`
 	connectionResponseV2
 }
 
@@ -200,7 +575,7 @@ func (this *ConnectionMediatorV2) handleConnectionStatus() http.HandlerFunc {
 		if err != nil {
 			if err == connection_repository.NotFoundError {
 				logger.Debug("Connection not found")
-				response := connectionStatusResponseV2{Status: DISCONNECTED_STATUS}
+				response := this.buildDisconnectedStatusResponse(req.Context(), logger, domain.OrgID(principal.GetOrgID()), recipient)
 				writeJSONResponse(w, http.StatusOK, response)
 				return
 			}
@@ -219,8 +594,30 @@ func (this *ConnectionMediatorV2) handleConnectionStatus() http.HandlerFunc {
 	}
 }
 
+// buildDisconnectedStatusResponse includes the time the client was last seen
+// when the connection has been marked as offline (rather than deleted)
+func (this *ConnectionMediatorV2) buildDisconnectedStatusResponse(ctx context.Context, logger *logrus.Entry, orgID domain.OrgID, clientID domain.ClientID) connectionStatusResponseV2 {
+
+	response := connectionStatusResponseV2{Status: DISCONNECTED_STATUS}
+
+	clientState, err := this.getConnectionStatus(ctx, logger, orgID, clientID)
+	if err != nil {
+		if err != connection_repository.NotFoundError {
+			logging.LogWithError(logger, "Failed to lookup offline connection", err)
+		}
+		return response
+	}
+
+	if clientState.State == domain.ConnectionOffline && !clientState.DisconnectedAt.IsZero() {
+		lastSeen := clientState.DisconnectedAt
+		response.LastSeen = &lastSeen
+	}
+
+	return response
+}
+
 func convertConnectorClientStateToConnectionStatusResponseV2(clientState domain.ConnectorClientState) connectionStatusResponseV2 {
-	return connectionStatusResponseV2{"connected", convertConnectorClientStateToConnectionResponseV2(clientState)}
+	return connectionStatusResponseV2{Status: "connected", connectionResponseV2: convertConnectorClientStateToConnectionResponseV2(clientState)}
 }
 
 func convertConnectorClientStateToConnectionResponseV2(clientState domain.ConnectorClientState) connectionResponseV2 {
@@ -231,6 +628,8 @@ func convertConnectorClientStateToConnectionResponseV2(clientState domain.Connec
 		CanonicalFacts: clientState.CanonicalFacts,
 		Dispatchers:    clientState.Dispatchers,
 		Tags:           clientState.Tags,
+		ClientName:     clientState.ClientName,
+		ClientVersion:  clientState.ClientVersion,
 	}
 }
 
@@ -257,12 +656,28 @@ func (this *ConnectionMediatorV2) handleConnectionListByOrgId() http.HandlerFunc
 			return
 		}
 
-		accountConnections, totalConnections, err := this.getConnectionsByOrgID(
-			req.Context(),
-			logger,
-			domain.OrgID(principal.GetOrgID()),
-			offset,
-			limit)
+		var accountConnections map[domain.ClientID]domain.ConnectorClientState
+		var totalConnections int
+
+		selector := getConnectionSelectorFromQueryParams(req)
+		if selector.IsEmpty() {
+			accountConnections, totalConnections, err = this.getConnectionsByOrgID(
+				req.Context(),
+				logger,
+				domain.OrgID(principal.GetOrgID()),
+				offset,
+				limit)
+		} else {
+			logger.Debugf("Filtering connections using selector: %+v", selector)
+
+			accountConnections, totalConnections, err = this.getConnectionsBySelector(
+				req.Context(),
+				logger,
+				domain.OrgID(principal.GetOrgID()),
+				selector,
+				offset,
+				limit)
+		}
 
 		if err != nil {
 			logging.LogWithError(logger, "Error looking up connections by org_id", err)
@@ -270,6 +685,7 @@ func (this *ConnectionMediatorV2) handleConnectionListByOrgId() http.HandlerFunc
 				Status: http.StatusInternalServerError,
 				Detail: err.Error()}
 			writeJSONResponse(w, errorResponse.Status, errorResponse)
+			return
 		}
 
 		connections := make([]connectionResponseV2, len(accountConnections))
@@ -285,3 +701,272 @@ func (this *ConnectionMediatorV2) handleConnectionListByOrgId() http.HandlerFunc
 		writeJSONResponse(w, http.StatusOK, response)
 	}
 }
+
+const (
+	tagFilterPrefix           = "tag."
+	canonicalFactFilterPrefix = "canonical_fact."
+	dispatcherFilter          = "dispatcher"
+	clientNameFilter          = "client_name"
+	clientVersionFilter       = "client_version"
+)
+
+// arrayValuedCanonicalFacts are stored as json arrays within the canonical facts
+var arrayValuedCanonicalFacts = map[string]bool{
+	"ip_addresses":  true,
+	"mac_addresses": true,
+}
+
+// getConnectionSelectorFromQueryParams builds a selector from query parameters
+// like tag.<key>=<value>, canonical_fact.<key>=<value>, dispatcher=<name>,
+// client_name=<name> and client_version=<version>.  Unknown query parameters
+// are ignored.
+func getConnectionSelectorFromQueryParams(req *http.Request) connection_repository.ConnectionSelector {
+	var selector connection_repository.ConnectionSelector
+
+	for key, values := range req.URL.Query() {
+		if len(values) == 0 {
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(key, tagFilterPrefix) && len(key) > len(tagFilterPrefix):
+			if selector.Tags == nil {
+				selector.Tags = make(map[string]string)
+			}
+			selector.Tags[strings.TrimPrefix(key, tagFilterPrefix)] = values[0]
+		case strings.HasPrefix(key, canonicalFactFilterPrefix) && len(key) > len(canonicalFactFilterPrefix):
+			if selector.CanonicalFacts == nil {
+				selector.CanonicalFacts = make(map[string]interface{})
+			}
+			factName := strings.TrimPrefix(key, canonicalFactFilterPrefix)
+			if arrayValuedCanonicalFacts[factName] {
+				// The connection matches when the array contains all of the requested values
+				factValues := make([]interface{}, len(values))
+				for i, value := range values {
+					factValues[i] = value
+				}
+				selector.CanonicalFacts[factName] = factValues
+			} else {
+				selector.CanonicalFacts[factName] = values[0]
+			}
+		case key == dispatcherFilter:
+			selector.Dispatchers = append(selector.Dispatchers, values...)
+		case key == clientNameFilter:
+			selector.ClientName = values[0]
+		case key == clientVersionFilter:
+			selector.ClientVersion = values[0]
+		}
+	}
+
+	return selector
+}
+
+type connectionEventResponseV2 struct {
+	State         string           `json:"state"`
+	Account       domain.AccountID `json:"account,omitempty"`
+	OrgID         domain.OrgID     `json:"org_id,omitempty"`
+	ClientID      domain.ClientID  `json:"client_id"`
+	MessageID     string           `json:"message_id,omitempty"`
+	Sent          time.Time        `json:"sent"`
+	ClientName    string           `json:"client_name,omitempty"`
+	ClientVersion string           `json:"client_version,omitempty"`
+	Recorded      time.Time        `json:"recorded"`
+}
+
+func (this *ConnectionMediatorV2) handleConnectionHistory() http.HandlerFunc {
+
+	return func(w http.ResponseWriter, req *http.Request) {
+		principal, _ := middlewares.GetPrincipal(req.Context())
+		requestId := request_id.GetReqID(req.Context())
+
+		recipient := getClientIDFromRequestPath(req)
+
+		logger := logging.Log.WithFields(logrus.Fields{
+			"account":    principal.GetAccount(),
+			"org_id":     principal.GetOrgID(),
+			"request_id": requestId,
+			"recipient":  recipient,
+		})
+
+		logger.Debug("Looking up connection history")
+
+		offset, limit, err := getOffsetAndLimitFromQueryParams(req)
+		if err != nil {
+			logging.LogWithError(logger, "Unable to retrieve offset/limit from request", err)
+			errorResponse := errorResponse{Title: "Invalid request",
+				Status: http.StatusBadRequest,
+				Detail: err.Error()}
+			writeJSONResponse(w, errorResponse.Status, errorResponse)
+			return
+		}
+
+		events, totalEvents, err := this.getConnectionEvents(req.Context(), logger, domain.OrgID(principal.GetOrgID()), recipient, offset, limit)
+		if err != nil {
+			logging.LogWithError(logger, "Error looking up connection history", err)
+			errorResponse := errorResponse{Title: "Error looking up connection history",
+				Status: http.StatusInternalServerError,
+				Detail: err.Error()}
+			writeJSONResponse(w, errorResponse.Status, errorResponse)
+			return
+		}
+
+		history := make([]connectionEventResponseV2, len(events))
+		for i, event := range events {
+			history[i] = connectionEventResponseV2{
+				State:         event.State,
+				Account:       event.Account,
+				OrgID:         event.OrgID,
+				ClientID:      event.ClientID,
+				MessageID:     event.MessageID,
+				Sent:          event.Sent,
+				ClientName:    event.ClientName,
+				ClientVersion: event.ClientVersion,
+				Recorded:      event.Created,
+			}
+		}
+
+		response := buildPaginatedResponse(req.URL, offset, limit, totalEvents, history)
+
+		writeJSONResponse(w, http.StatusOK, response)
+	}
+}
+
+type messageEventResponseV2 struct {
+	MessageID  string          `json:"message_id"`
+	ResponseTo string          `json:"response_to"`
+	ClientID   domain.ClientID `json:"client_id"`
+	Content    interface{}     `json:"content"`
+	Sent       time.Time       `json:"sent"`
+}
+
+type messageEventListResponseV2 struct {
+	Data []messageEventResponseV2 `json:"data"`
+}
+
+func (this *ConnectionMediatorV2) handleMessageEvents() http.HandlerFunc {
+
+	return func(w http.ResponseWriter, req *http.Request) {
+		principal, _ := middlewares.GetPrincipal(req.Context())
+		requestId := request_id.GetReqID(req.Context())
+
+		recipient := getClientIDFromRequestPath(req)
+		messageID := mux.Vars(req)["message_id"]
+
+		logger := logging.Log.WithFields(logrus.Fields{
+			"account":    principal.GetAccount(),
+			"org_id":     principal.GetOrgID(),
+			"request_id": requestId,
+			"recipient":  recipient,
+			"message_id": messageID,
+		})
+
+		logger.Debug("Looking up events for message")
+
+		events, err := this.getMessageEvents(req.Context(), logger, domain.OrgID(principal.GetOrgID()), recipient, messageID)
+		if err != nil {
+			logging.LogWithError(logger, "Error looking up message events", err)
+			errorResponse := errorResponse{Title: "Error looking up message events",
+				Status: http.StatusInternalServerError,
+				Detail: err.Error()}
+			writeJSONResponse(w, errorResponse.Status, errorResponse)
+			return
+		}
+
+		response := messageEventListResponseV2{Data: convertMessageEventsToMessageEventResponsesV2(events)}
+
+		writeJSONResponse(w, http.StatusOK, response)
+	}
+}
+
+func convertMessageEventsToMessageEventResponsesV2(events []domain.MessageEvent) []messageEventResponseV2 {
+	eventResponses := make([]messageEventResponseV2, len(events))
+
+	for i, event := range events {
+		eventResponses[i] = messageEventResponseV2{
+			MessageID:  event.MessageID,
+			ResponseTo: event.ResponseTo,
+			ClientID:   event.ClientID,
+			Content:    event.Content,
+			Sent:       event.Sent,
+		}
+	}
+
+	return eventResponses
+}
+
+type messageStatusResponseV2 struct {
+	MessageID string                   `json:"message_id"`
+	Account   domain.AccountID         `json:"account,omitempty"`
+	OrgID     domain.OrgID             `json:"org_id"`
+	ClientID  domain.ClientID          `json:"client_id"`
+	Directive string                   `json:"directive"`
+	Status    domain.MessageStatus     `json:"status"`
+	Error     string                   `json:"error,omitempty"`
+	Created   time.Time                `json:"created_at"`
+	Updated   time.Time                `json:"updated_at"`
+	Events    []messageEventResponseV2 `json:"events"`
+}
+
+func (this *ConnectionMediatorV2) handleMessageStatus() http.HandlerFunc {
+
+	return func(w http.ResponseWriter, req *http.Request) {
+		principal, _ := middlewares.GetPrincipal(req.Context())
+		requestId := request_id.GetReqID(req.Context())
+
+		messageID := mux.Vars(req)["message_id"]
+
+		logger := logging.Log.WithFields(logrus.Fields{
+			"account":    principal.GetAccount(),
+			"org_id":     principal.GetOrgID(),
+			"request_id": requestId,
+			"message_id": messageID,
+		})
+
+		logger.Debug("Looking up message status")
+
+		message, err := this.getMessage(req.Context(), logger, domain.OrgID(principal.GetOrgID()), messageID)
+		if err != nil {
+			if err == connection_repository.NotFoundError {
+				errMsg := "Message not found"
+				logger.Debug(errMsg)
+				errorResponse := errorResponse{Title: errMsg,
+					Status: http.StatusNotFound,
+					Detail: errMsg}
+				writeJSONResponse(w, errorResponse.Status, errorResponse)
+				return
+			}
+
+			logging.LogWithError(logger, "Error looking up message", err)
+			errorResponse := errorResponse{Title: "Error looking up message",
+				Status: http.StatusInternalServerError,
+				Detail: err.Error()}
+			writeJSONResponse(w, errorResponse.Status, errorResponse)
+			return
+		}
+
+		events, err := this.getMessageEvents(req.Context(), logger, message.OrgID, message.ClientID, message.MessageID)
+		if err != nil {
+			logging.LogWithError(logger, "Error looking up message events", err)
+			errorResponse := errorResponse{Title: "Error looking up message events",
+				Status: http.StatusInternalServerError,
+				Detail: err.Error()}
+			writeJSONResponse(w, errorResponse.Status, errorResponse)
+			return
+		}
+
+		response := messageStatusResponseV2{
+			MessageID: message.MessageID,
+			Account:   message.Account,
+			OrgID:     message.OrgID,
+			ClientID:  message.ClientID,
+			Directive: message.Directive,
+			Status:    message.Status,
+			Error:     message.Error,
+			Created:   message.Created,
+			Updated:   message.Updated,
+			Events:    convertMessageEventsToMessageEventResponsesV2(events),
+		}
+
+		writeJSONResponse(w, http.StatusOK, response)
+	}
+}
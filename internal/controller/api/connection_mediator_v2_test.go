@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -33,6 +34,28 @@ func mockedGetConnectionByClientID(expectedClientState domain.ConnectorClientSta
 	}
 }
 
+func mockedGetConnectionByClientIDWithNotFound(expectedClientState domain.ConnectorClientState) connection_repository.GetConnectionByClientID {
+	return func(ctx context.Context, log *logrus.Entry, actualOrgId domain.OrgID, actualClientId domain.ClientID) (domain.ConnectorClientState, error) {
+		if actualOrgId != expectedClientState.OrgID || actualClientId != expectedClientState.ClientID {
+			return domain.ConnectorClientState{}, connection_repository.NotFoundError
+		}
+
+		return expectedClientState, nil
+	}
+}
+
+func mockedGetConnectionStatusByClientID(onlineClientState domain.ConnectorClientState, offlineClientState domain.ConnectorClientState) connection_repository.GetConnectionByClientID {
+	return func(ctx context.Context, log *logrus.Entry, actualOrgId domain.OrgID, actualClientId domain.ClientID) (domain.ConnectorClientState, error) {
+		for _, clientState := range []domain.ConnectorClientState{onlineClientState, offlineClientState} {
+			if actualOrgId == clientState.OrgID && actualClientId == clientState.ClientID {
+				return clientState, nil
+			}
+		}
+
+		return domain.ConnectorClientState{}, connection_repository.NotFoundError
+	}
+}
+
 func mockedGetConnectionsByOrgID(expectedClientState domain.ConnectorClientState) connection_repository.GetConnectionsByOrgID {
 	return func(ctx context.Context, log *logrus.Entry, actualOrgId domain.OrgID, offset int, limit int) (map[domain.ClientID]domain.ConnectorClientState, int, error) {
 		if actualOrgId != expectedClientState.OrgID {
@@ -43,6 +66,76 @@ func mockedGetConnectionsByOrgID(expectedClientState domain.ConnectorClientState
 	}
 }
 
+func mockedGetConnectionsBySelector(expectedClientState domain.ConnectorClientState) connection_repository.GetConnectionsBySelector {
+	return func(ctx context.Context, log *logrus.Entry, actualOrgId domain.OrgID, selector connection_repository.ConnectionSelector, offset int, limit int) (map[domain.ClientID]domain.ConnectorClientState, int, error) {
+		if actualOrgId != expectedClientState.OrgID || selector.Tags["env"] != "prod" {
+			return map[domain.ClientID]domain.ConnectorClientState{}, 0, nil
+		}
+
+		return map[domain.ClientID]domain.ConnectorClientState{expectedClientState.ClientID: expectedClientState}, 1, nil
+	}
+}
+
+func mockedGetConnectionEventsByClientID(expectedClientState domain.ConnectorClientState) connection_repository.GetConnectionEventsByClientID {
+	return func(ctx context.Context, log *logrus.Entry, actualOrgId domain.OrgID, actualClientId domain.ClientID, offset int, limit int) ([]domain.ConnectionEvent, int, error) {
+		if actualOrgId != expectedClientState.OrgID || actualClientId != expectedClientState.ClientID {
+			return []domain.ConnectionEvent{}, 0, nil
+		}
+
+		events := []domain.ConnectionEvent{
+			{OrgID: actualOrgId, ClientID: actualClientId, State: domain.ConnectionOffline, MessageID: "offline-1"},
+			{OrgID: actualOrgId, ClientID: actualClientId, State: domain.ConnectionOnline, MessageID: "online-1", ClientName: "rhc"},
+		}
+
+		return events, len(events), nil
+	}
+}
+
+func mockedGetMessageEventsByMessageID(expectedClientState domain.ConnectorClientState, expectedMessageId string) connection_repository.GetMessageEventsByMessageID {
+	return func(ctx context.Context, log *logrus.Entry, actualOrgId domain.OrgID, actualClientId domain.ClientID, actualMessageId string) ([]domain.MessageEvent, error) {
+		if actualOrgId != expectedClientState.OrgID || actualClientId != expectedClientState.ClientID || actualMessageId != expectedMessageId {
+			return []domain.MessageEvent{}, nil
+		}
+
+		event := domain.MessageEvent{
+			OrgID:      actualOrgId,
+			ClientID:   actualClientId,
+			MessageID:  "event-1",
+			ResponseTo: actualMessageId,
+			Content:    "running",
+		}
+
+		return []domain.MessageEvent{event}, nil
+	}
+}
+
+func mockedGetMessageByMessageID(expectedMessage domain.Message) connection_repository.GetMessageByMessageID {
+	return func(ctx context.Context, log *logrus.Entry, actualOrgId domain.OrgID, actualMessageId string) (domain.Message, error) {
+		if actualOrgId != expectedMessage.OrgID || actualMessageId != expectedMessage.MessageID {
+			return domain.Message{}, connection_repository.NotFoundError
+		}
+
+		return expectedMessage, nil
+	}
+}
+
+type mockPendingMessageStore struct {
+	pendingMessages []domain.PendingMessage
+}
+
+func (this *mockPendingMessageStore) StorePendingMessage(ctx context.Context, message domain.PendingMessage) error {
+	this.pendingMessages = append(this.pendingMessages, message)
+	return nil
+}
+
+func (this *mockPendingMessageStore) GetPendingMessages(ctx context.Context, orgID domain.OrgID, clientID domain.ClientID) ([]domain.PendingMessage, error) {
+	return this.pendingMessages, nil
+}
+
+func (this *mockPendingMessageStore) MarkPendingMessageDelivered(ctx context.Context, messageID string) error {
+	return nil
+}
+
 func mockedGetAllConnections(expectedAccount domain.AccountID, expectedClientId domain.ClientID) connection_repository.GetAllConnections {
 	return func(ctx context.Context, offset int, limit int) (map[domain.AccountID]map[domain.ClientID]domain.ConnectorClientState, int, error) {
 		allConnections := map[domain.AccountID]map[domain.ClientID]domain.ConnectorClientState{expectedAccount: {expectedClientId: {Account: expectedAccount, ClientID: expectedClientId}}}
@@ -54,7 +147,15 @@ var _ = Describe("ConnectionMediatorV2", func() {
 
 	var (
 		cm                  *ConnectionMediatorV2
+		pendingMessageStore *mockPendingMessageStore
 		messageEndpointV2   string
+		bulkMessageV2       string
+		connectionListV2    string
+		historyEndpointV2   string
+		statusEndpointV2    string
+		offlineEndpointV2   string
+		eventsEndpointV2    string
+		messageStatusV2     string
 		validIdentityHeader string
 	)
 
@@ -65,19 +166,50 @@ var _ = Describe("ConnectionMediatorV2", func() {
 		proxyFactory := &MockClientProxyFactory{}
 
 		messageEndpointV2 = URL_BASE_PATH + "/v2/connections/345/message"
+		offlineEndpointV2 = URL_BASE_PATH + "/v2/connections/678/message"
+		bulkMessageV2 = URL_BASE_PATH + "/v2/connections/message"
+		connectionListV2 = URL_BASE_PATH + "/v2/connections"
+		historyEndpointV2 = URL_BASE_PATH + "/v2/connections/%s/history"
+		statusEndpointV2 = URL_BASE_PATH + "/v2/connections/%s/status"
+		eventsEndpointV2 = URL_BASE_PATH + "/v2/connections/345/messages/%s/events"
+		messageStatusV2 = URL_BASE_PATH + "/v2/messages/%s"
 		accountNumber := domain.AccountID("1234")
 		validIdentityHeader = buildIdentityHeader(accountNumber, "Associate")
 
 		connectorClient := domain.ConnectorClientState{
-			Account:  accountNumber,
-			OrgID:    domain.OrgID("1979710"),
-			ClientID: domain.ClientID("345"),
+			Account:       accountNumber,
+			OrgID:         domain.OrgID("1979710"),
+			ClientID:      domain.ClientID("345"),
+			ClientName:    "rhc",
+			ClientVersion: "0.2.4",
+			Dispatchers:   map[string]interface{}{"rhc-worker-playbook": map[string]interface{}{}, "package-manager": map[string]interface{}{}},
+		}
+
+		offlineConnectorClient := domain.ConnectorClientState{
+			Account:        accountNumber,
+			OrgID:          connectorClient.OrgID,
+			ClientID:       domain.ClientID("901"),
+			State:          domain.ConnectionOffline,
+			DisconnectedAt: time.Date(2023, time.March, 14, 15, 9, 26, 0, time.UTC),
 		}
 
-		getConnByClientID := mockedGetConnectionByClientID(connectorClient)
+		getConnByClientID := mockedGetConnectionByClientIDWithNotFound(connectorClient)
+		getConnStatus := mockedGetConnectionStatusByClientID(connectorClient, offlineConnectorClient)
 		getConnByOrgID := mockedGetConnectionsByOrgID(connectorClient)
+		getConnBySelector := mockedGetConnectionsBySelector(connectorClient)
+		getConnectionEvents := mockedGetConnectionEventsByClientID(connectorClient)
+		getMessageEvents := mockedGetMessageEventsByMessageID(connectorClient, "abc-123")
+		getMessage := mockedGetMessageByMessageID(domain.Message{
+			MessageID: "abc-123",
+			OrgID:     connectorClient.OrgID,
+			ClientID:  connectorClient.ClientID,
+			Directive: "fred:flintstone",
+			Status:    domain.MessageAcknowledged,
+		})
+
+		pendingMessageStore = &mockPendingMessageStore{}
 
-		cm = NewConnectionMediatorV2(getConnByClientID, getConnByOrgID, proxyFactory, apiMux, URL_BASE_PATH, cfg)
+		cm = NewConnectionMediatorV2(getConnByClientID, getConnStatus, getConnByOrgID, getConnBySelector, getConnectionEvents, getMessageEvents, getMessage, pendingMessageStore, proxyFactory, apiMux, URL_BASE_PATH, cfg)
 		cm.Routes()
 
 	})
@@ -122,6 +254,40 @@ var _ = Describe("ConnectionMediatorV2", func() {
 				Expect(m).Should(HaveKey("id"))
 			})
 
+			It("Should be able to send a supported directive to a client in strict mode", func() {
+				postBody := "{\"directive\": \"rhc-worker-playbook\", \"strict\": true}"
+
+				req, err := http.NewRequest("POST", messageEndpointV2, strings.NewReader(postBody))
+				Expect(err).NotTo(HaveOccurred())
+
+				req.Header.Add(IDENTITY_HEADER_NAME, validIdentityHeader)
+
+				rr := httptest.NewRecorder()
+
+				cm.router.ServeHTTP(rr, req)
+
+				Expect(rr.Code).To(Equal(http.StatusCreated))
+			})
+
+			It("Should reject an unsupported directive in strict mode", func() {
+				postBody := "{\"directive\": \"fred:flintstone\", \"strict\": true}"
+
+				req, err := http.NewRequest("POST", messageEndpointV2, strings.NewReader(postBody))
+				Expect(err).NotTo(HaveOccurred())
+
+				req.Header.Add(IDENTITY_HEADER_NAME, validIdentityHeader)
+
+				rr := httptest.NewRecorder()
+
+				cm.router.ServeHTTP(rr, req)
+
+				Expect(rr.Code).To(Equal(http.StatusUnprocessableEntity))
+
+				var response directiveNotSupportedResponse
+				json.Unmarshal(rr.Body.Bytes(), &response)
+				Expect(response.SupportedDirectives).Should(Equal([]string{"package-manager", "rhc-worker-playbook"}))
+			})
+
 			It("Should not be able to send a job to a client with empty post body", func() {
 				postBody := "{}"
 
@@ -136,6 +302,454 @@ var _ = Describe("ConnectionMediatorV2", func() {
 
 				Expect(rr.Code).To(Equal(http.StatusBadRequest))
 			})
+
+			It("Should not be able to send a job to a client that is not connected", func() {
+				postBody := "{\"directive\": \"fred:flintstone\"}"
+
+				req, err := http.NewRequest("POST", offlineEndpointV2, strings.NewReader(postBody))
+				Expect(err).NotTo(HaveOccurred())
+
+				req.Header.Add(IDENTITY_HEADER_NAME, validIdentityHeader)
+
+				rr := httptest.NewRecorder()
+
+				cm.router.ServeHTTP(rr, req)
+
+				Expect(rr.Code).To(Equal(http.StatusNotFound))
+				Expect(pendingMessageStore.pendingMessages).Should(BeEmpty())
+			})
+
+			It("Should queue a job for a client that is not connected when requested", func() {
+				postBody := "{\"directive\": \"fred:flintstone\", \"deliver_when_online\": true, \"ttl\": 600}"
+
+				req, err := http.NewRequest("POST", offlineEndpointV2, strings.NewReader(postBody))
+				Expect(err).NotTo(HaveOccurred())
+
+				req.Header.Add(IDENTITY_HEADER_NAME, validIdentityHeader)
+
+				rr := httptest.NewRecorder()
+
+				cm.router.ServeHTTP(rr, req)
+
+				Expect(rr.Code).To(Equal(http.StatusAccepted))
+
+				var m map[string]string
+				json.Unmarshal(rr.Body.Bytes(), &m)
+				Expect(m).Should(HaveKey("id"))
+
+				Expect(pendingMessageStore.pendingMessages).Should(HaveLen(1))
+				Expect(pendingMessageStore.pendingMessages[0].MessageID).Should(Equal(m["id"]))
+				Expect(pendingMessageStore.pendingMessages[0].ClientID).Should(Equal(domain.ClientID("678")))
+				Expect(pendingMessageStore.pendingMessages[0].Directive).Should(Equal("fred:flintstone"))
+			})
+
+			It("Should not queue a job with a ttl larger than the max ttl", func() {
+				postBody := "{\"directive\": \"fred:flintstone\", \"deliver_when_online\": true, \"ttl\": 999999999}"
+
+				req, err := http.NewRequest("POST", offlineEndpointV2, strings.NewReader(postBody))
+				Expect(err).NotTo(HaveOccurred())
+
+				req.Header.Add(IDENTITY_HEADER_NAME, validIdentityHeader)
+
+				rr := httptest.NewRecorder()
+
+				cm.router.ServeHTTP(rr, req)
+
+				Expect(rr.Code).To(Equal(http.StatusBadRequest))
+				Expect(pendingMessageStore.pendingMessages).Should(BeEmpty())
+			})
+		})
+	})
+
+	Describe("Connecting to the v2 bulk message endpoint", func() {
+		Context("With valid identity header", func() {
+			It("Should report a result for each recipient", func() {
+				postBody := "{\"recipients\": [\"345\", \"678\"], \"directive\": \"fred:flintstone\"}"
+
+				req, err := http.NewRequest("POST", bulkMessageV2, strings.NewReader(postBody))
+				Expect(err).NotTo(HaveOccurred())
+
+				req.Header.Add(IDENTITY_HEADER_NAME, validIdentityHeader)
+
+				rr := httptest.NewRecorder()
+
+				cm.router.ServeHTTP(rr, req)
+
+				Expect(rr.Code).To(Equal(http.StatusOK))
+
+				var response bulkMessageResponseV2
+				json.Unmarshal(rr.Body.Bytes(), &response)
+				Expect(response.Results).Should(HaveLen(2))
+				Expect(response.Results["345"].Status).Should(Equal(bulkMessageSent))
+				Expect(response.Results["345"].ID).ShouldNot(BeEmpty())
+				Expect(response.Results["678"].Status).Should(Equal(bulkMessageFailed))
+				Expect(response.Results["678"].ID).Should(BeEmpty())
+				Expect(response.Results["678"].Error).ShouldNot(BeEmpty())
+			})
+
+			It("Should report an error for an empty recipient", func() {
+				postBody := "{\"recipients\": [\"345\", \" \"], \"directive\": \"fred:flintstone\"}"
+
+				req, err := http.NewRequest("POST", bulkMessageV2, strings.NewReader(postBody))
+				Expect(err).NotTo(HaveOccurred())
+
+				req.Header.Add(IDENTITY_HEADER_NAME, validIdentityHeader)
+
+				rr := httptest.NewRecorder()
+
+				cm.router.ServeHTTP(rr, req)
+
+				Expect(rr.Code).To(Equal(http.StatusOK))
+
+				var response bulkMessageResponseV2
+				json.Unmarshal(rr.Body.Bytes(), &response)
+				Expect(response.Results).Should(HaveLen(2))
+				Expect(response.Results["345"].Status).Should(Equal(bulkMessageSent))
+				Expect(response.Results[" "].Status).Should(Equal(bulkMessageFailed))
+				Expect(response.Results[" "].Error).Should(Equal(emptyRecipientErrorMsg))
+			})
+
+			It("Should report an error for a recipient without a worker for the directive in strict mode", func() {
+				postBody := "{\"recipients\": [\"345\"], \"directive\": \"fred:flintstone\", \"strict\": true}"
+
+				req, err := http.NewRequest("POST", bulkMessageV2, strings.NewReader(postBody))
+				Expect(err).NotTo(HaveOccurred())
+
+				req.Header.Add(IDENTITY_HEADER_NAME, validIdentityHeader)
+
+				rr := httptest.NewRecorder()
+
+				cm.router.ServeHTTP(rr, req)
+
+				Expect(rr.Code).To(Equal(http.StatusOK))
+
+				var response bulkMessageResponseV2
+				json.Unmarshal(rr.Body.Bytes(), &response)
+				Expect(response.Results).Should(HaveLen(1))
+				Expect(response.Results["345"].Status).Should(Equal(bulkMessageFailed))
+				Expect(response.Results["345"].Error).Should(ContainSubstring("fred:flintstone"))
+			})
+
+			It("Should send to the connections matching a selector", func() {
+				postBody := "{\"selector\": {\"tags\": {\"env\": \"prod\"}}, \"directive\": \"fred:flintstone\"}"
+
+				req, err := http.NewRequest("POST", bulkMessageV2, strings.NewReader(postBody))
+				Expect(err).NotTo(HaveOccurred())
+
+				req.Header.Add(IDENTITY_HEADER_NAME, validIdentityHeader)
+
+				rr := httptest.NewRecorder()
+
+				cm.router.ServeHTTP(rr, req)
+
+				Expect(rr.Code).To(Equal(http.StatusOK))
+
+				var response bulkMessageResponseV2
+				json.Unmarshal(rr.Body.Bytes(), &response)
+				Expect(response.Results).Should(HaveLen(1))
+				Expect(response.Results["345"].Status).Should(Equal(bulkMessageSent))
+			})
+
+			It("Should not allow both recipients and a selector", func() {
+				postBody := "{\"recipients\": [\"345\"], \"selector\": {\"tags\": {\"env\": \"prod\"}}, \"directive\": \"fred:flintstone\"}"
+
+				req, err := http.NewRequest("POST", bulkMessageV2, strings.NewReader(postBody))
+				Expect(err).NotTo(HaveOccurred())
+
+				req.Header.Add(IDENTITY_HEADER_NAME, validIdentityHeader)
+
+				rr := httptest.NewRecorder()
+
+				cm.router.ServeHTTP(rr, req)
+
+				Expect(rr.Code).To(Equal(http.StatusBadRequest))
+			})
+
+			It("Should not allow an empty selector", func() {
+				postBody := "{\"selector\": {}, \"directive\": \"fred:flintstone\"}"
+
+				req, err := http.NewRequest("POST", bulkMessageV2, strings.NewReader(postBody))
+				Expect(err).NotTo(HaveOccurred())
+
+				req.Header.Add(IDENTITY_HEADER_NAME, validIdentityHeader)
+
+				rr := httptest.NewRecorder()
+
+				cm.router.ServeHTTP(rr, req)
+
+				Expect(rr.Code).To(Equal(http.StatusBadRequest))
+			})
+		})
+	})
+
+	Describe("Connecting to the v2 connection list endpoint", func() {
+		Context("With valid identity header", func() {
+			It("Should be able to list the connections for an org", func() {
+				req, err := http.NewRequest("GET", connectionListV2, nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				req.Header.Add(IDENTITY_HEADER_NAME, validIdentityHeader)
+
+				rr := httptest.NewRecorder()
+
+				cm.router.ServeHTTP(rr, req)
+
+				Expect(rr.Code).To(Equal(http.StatusOK))
+
+				var response paginatedResponse
+				json.Unmarshal(rr.Body.Bytes(), &response)
+				Expect(response.Meta.Count).Should(Equal(1))
+			})
+
+			It("Should be able to filter the connections using tags", func() {
+				req, err := http.NewRequest("GET", connectionListV2+"?tag.env=prod", nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				req.Header.Add(IDENTITY_HEADER_NAME, validIdentityHeader)
+
+				rr := httptest.NewRecorder()
+
+				cm.router.ServeHTTP(rr, req)
+
+				Expect(rr.Code).To(Equal(http.StatusOK))
+
+				var response paginatedResponse
+				json.Unmarshal(rr.Body.Bytes(), &response)
+				Expect(response.Meta.Count).Should(Equal(1))
+			})
+
+			It("Should return an empty list when no connections match the filters", func() {
+				req, err := http.NewRequest("GET", connectionListV2+"?tag.env=dev&dispatcher=rhc-worker-playbook", nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				req.Header.Add(IDENTITY_HEADER_NAME, validIdentityHeader)
+
+				rr := httptest.NewRecorder()
+
+				cm.router.ServeHTTP(rr, req)
+
+				Expect(rr.Code).To(Equal(http.StatusOK))
+
+				var response paginatedResponse
+				json.Unmarshal(rr.Body.Bytes(), &response)
+				Expect(response.Meta.Count).Should(Equal(0))
+			})
+		})
+	})
+
+	Describe("Building a connection selector from query parameters", func() {
+		It("Should translate the query parameters into a selector", func() {
+			req, err := http.NewRequest("GET", connectionListV2+"?tag.env=prod&canonical_fact.fqdn=fred.flintstone.com&dispatcher=rhc-worker-playbook&dispatcher=package-manager&client_name=rhc&client_version=0.2.1&offset=10&tag.=ignored", nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			selector := getConnectionSelectorFromQueryParams(req)
+			Expect(selector.Tags).Should(Equal(map[string]string{"env": "prod"}))
+			Expect(selector.CanonicalFacts).Should(Equal(map[string]interface{}{"fqdn": "fred.flintstone.com"}))
+			Expect(selector.Dispatchers).Should(ConsistOf("rhc-worker-playbook", "package-manager"))
+			Expect(selector.ClientName).Should(Equal("rhc"))
+			Expect(selector.ClientVersion).Should(Equal("0.2.1"))
+		})
+
+		It("Should use array containment for the array valued canonical facts", func() {
+			req, err := http.NewRequest("GET", connectionListV2+"?canonical_fact.ip_addresses=192.168.1.1&canonical_fact.ip_addresses=10.0.0.1&canonical_fact.mac_addresses=aa:bb:cc:dd:ee:ff", nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			selector := getConnectionSelectorFromQueryParams(req)
+			Expect(selector.CanonicalFacts).Should(Equal(map[string]interface{}{
+				"ip_addresses":  []interface{}{"192.168.1.1", "10.0.0.1"},
+				"mac_addresses": []interface{}{"aa:bb:cc:dd:ee:ff"},
+			}))
+		})
+
+		It("Should return an empty selector when there are no filters", func() {
+			req, err := http.NewRequest("GET", connectionListV2+"?offset=10&limit=5", nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			selector := getConnectionSelectorFromQueryParams(req)
+			Expect(selector.IsEmpty()).Should(BeTrue())
+		})
+	})
+
+	Describe("Connecting to the v2 connection status endpoint", func() {
+		Context("With valid identity header", func() {
+			It("Should report an online connection as connected", func() {
+				req, err := http.NewRequest("GET", fmt.Sprintf(statusEndpointV2, "345"), nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				req.Header.Add(IDENTITY_HEADER_NAME, validIdentityHeader)
+
+				rr := httptest.NewRecorder()
+
+				cm.router.ServeHTTP(rr, req)
+
+				Expect(rr.Code).To(Equal(http.StatusOK))
+
+				var response connectionStatusResponseV2
+				json.Unmarshal(rr.Body.Bytes(), &response)
+				Expect(response.Status).Should(Equal("connected"))
+				Expect(response.LastSeen).Should(BeNil())
+				Expect(response.ClientName).Should(Equal("rhc"))
+				Expect(response.ClientVersion).Should(Equal("0.2.4"))
+			})
+
+			It("Should report when an offline connection was last seen", func() {
+				req, err := http.NewRequest("GET", fmt.Sprintf(statusEndpointV2, "901"), nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				req.Header.Add(IDENTITY_HEADER_NAME, validIdentityHeader)
+
+				rr := httptest.NewRecorder()
+
+				cm.router.ServeHTTP(rr, req)
+
+				Expect(rr.Code).To(Equal(http.StatusOK))
+
+				var response connectionStatusResponseV2
+				json.Unmarshal(rr.Body.Bytes(), &response)
+				Expect(response.Status).Should(Equal(DISCONNECTED_STATUS))
+				Expect(response.LastSeen).ShouldNot(BeNil())
+				Expect(response.LastSeen.Equal(time.Date(2023, time.March, 14, 15, 9, 26, 0, time.UTC))).Should(BeTrue())
+			})
+
+			It("Should report an unknown connection as disconnected", func() {
+				req, err := http.NewRequest("GET", fmt.Sprintf(statusEndpointV2, "678"), nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				req.Header.Add(IDENTITY_HEADER_NAME, validIdentityHeader)
+
+				rr := httptest.NewRecorder()
+
+				cm.router.ServeHTTP(rr, req)
+
+				Expect(rr.Code).To(Equal(http.StatusOK))
+
+				var response connectionStatusResponseV2
+				json.Unmarshal(rr.Body.Bytes(), &response)
+				Expect(response.Status).Should(Equal(DISCONNECTED_STATUS))
+				Expect(response.LastSeen).Should(BeNil())
+			})
+		})
+	})
+
+	Describe("Connecting to the v2 connection history endpoint", func() {
+		Context("With valid identity header", func() {
+			It("Should be able to retrieve the history of a connection", func() {
+				req, err := http.NewRequest("GET", fmt.Sprintf(historyEndpointV2, "345"), nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				req.Header.Add(IDENTITY_HEADER_NAME, validIdentityHeader)
+
+				rr := httptest.NewRecorder()
+
+				cm.router.ServeHTTP(rr, req)
+
+				Expect(rr.Code).To(Equal(http.StatusOK))
+
+				var response struct {
+					Meta meta                        `json:"meta"`
+					Data []connectionEventResponseV2 `json:"data"`
+				}
+				json.Unmarshal(rr.Body.Bytes(), &response)
+				Expect(response.Meta.Count).Should(Equal(2))
+				Expect(response.Data).Should(HaveLen(2))
+				Expect(response.Data[0].State).Should(Equal(domain.ConnectionOffline))
+				Expect(response.Data[1].State).Should(Equal(domain.ConnectionOnline))
+				Expect(response.Data[1].ClientName).Should(Equal("rhc"))
+			})
+
+			It("Should return an empty history for an unknown connection", func() {
+				req, err := http.NewRequest("GET", fmt.Sprintf(historyEndpointV2, "678"), nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				req.Header.Add(IDENTITY_HEADER_NAME, validIdentityHeader)
+
+				rr := httptest.NewRecorder()
+
+				cm.router.ServeHTTP(rr, req)
+
+				Expect(rr.Code).To(Equal(http.StatusOK))
+
+				var response paginatedResponse
+				json.Unmarshal(rr.Body.Bytes(), &response)
+				Expect(response.Meta.Count).Should(Equal(0))
+			})
+		})
+	})
+
+	Describe("Connecting to the v2 message events endpoint", func() {
+		Context("With valid identity header", func() {
+			It("Should be able to retrieve the events for a message", func() {
+				req, err := http.NewRequest("GET", fmt.Sprintf(eventsEndpointV2, "abc-123"), nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				req.Header.Add(IDENTITY_HEADER_NAME, validIdentityHeader)
+
+				rr := httptest.NewRecorder()
+
+				cm.router.ServeHTTP(rr, req)
+
+				Expect(rr.Code).To(Equal(http.StatusOK))
+
+				var response messageEventListResponseV2
+				json.Unmarshal(rr.Body.Bytes(), &response)
+				Expect(response.Data).Should(HaveLen(1))
+				Expect(response.Data[0].MessageID).Should(Equal("event-1"))
+				Expect(response.Data[0].ResponseTo).Should(Equal("abc-123"))
+			})
+
+			It("Should return an empty list for a message without events", func() {
+				req, err := http.NewRequest("GET", fmt.Sprintf(eventsEndpointV2, "unknown"), nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				req.Header.Add(IDENTITY_HEADER_NAME, validIdentityHeader)
+
+				rr := httptest.NewRecorder()
+
+				cm.router.ServeHTTP(rr, req)
+
+				Expect(rr.Code).To(Equal(http.StatusOK))
+
+				var m map[string][]interface{}
+				json.Unmarshal(rr.Body.Bytes(), &m)
+				Expect(m).Should(HaveKey("data"))
+				Expect(m["data"]).Should(BeEmpty())
+			})
+		})
+	})
+
+	Describe("Connecting to the v2 message status endpoint", func() {
+		Context("With valid identity header", func() {
+			It("Should be able to retrieve the status of a message", func() {
+				req, err := http.NewRequest("GET", fmt.Sprintf(messageStatusV2, "abc-123"), nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				req.Header.Add(IDENTITY_HEADER_NAME, validIdentityHeader)
+
+				rr := httptest.NewRecorder()
+
+				cm.router.ServeHTTP(rr, req)
+
+				Expect(rr.Code).To(Equal(http.StatusOK))
+
+				var response messageStatusResponseV2
+				json.Unmarshal(rr.Body.Bytes(), &response)
+				Expect(response.MessageID).Should(Equal("abc-123"))
+				Expect(response.Status).Should(Equal(domain.MessageAcknowledged))
+				Expect(response.Events).Should(HaveLen(1))
+			})
+
+			It("Should return a 404 for an unknown message", func() {
+				req, err := http.NewRequest("GET", fmt.Sprintf(messageStatusV2, "unknown"), nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				req.Header.Add(IDENTITY_HEADER_NAME, validIdentityHeader)
+
+				rr := httptest.NewRecorder()
+
+				cm.router.ServeHTTP(rr, req)
+
+				Expect(rr.Code).To(Equal(http.StatusNotFound))
+			})
 		})
 	})
 })
@@ -2,8 +2,10 @@ package api
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/RedHatInsights/cloud-connector/internal/config"
 	"github.com/RedHatInsights/cloud-connector/internal/connection_repository"
@@ -20,11 +22,15 @@ import (
 )
 
 const (
-	CONNECTED_STATUS     = "connected"
-	DISCONNECTED_STATUS  = "disconnected"
-	DECODE_ERROR         = "Unable to process json input"
-	NEGATIVE_DELAY_ERROR = "Delay field cannot be negative"
-	PING_ERROR           = "Ping failed"
+	CONNECTED_STATUS            = "connected"
+	DISCONNECTED_STATUS         = "disconnected"
+	DECODE_ERROR                = "Unable to process json input"
+	NEGATIVE_DELAY_ERROR        = "Delay field cannot be negative"
+	PING_ERROR                  = "Ping failed"
+	NEGATIVE_TIMEOUT_ERROR      = "Timeout field cannot be negative"
+	PING_WAIT_UNSUPPORTED_ERROR = "Waiting for a ping response is not supported"
+	PING_TIMEOUT_ERROR          = "Timed out waiting for a ping response"
+	PING_RESPONSE_ERROR         = "Unable to wait for a ping response"
 )
 
 type ManagementServer struct {
@@ -36,9 +42,11 @@ type ManagementServer struct {
 	getConnectionsByOrgID   connection_repository.GetConnectionsByOrgID
 	getAllConnections       connection_repository.GetAllConnections
 	proxyFactory            controller.ConnectorClientProxyFactory
+	responseWaiter          connection_repository.MessageResponseWaiter
 }
 
-func NewManagementServer(byClientID connection_repository.GetConnectionByClientID, byOrgID connection_repository.GetConnectionsByOrgID, allConnections connection_repository.GetAllConnections, tenantTranslator tenantid.Translator, proxyFactory controller.ConnectorClientProxyFactory, r *mux.Router, urlPrefix string, cfg *config.Config) *ManagementServer {
+// The responseWaiter is optional.  Synchronous pings are rejected when it is nil.
+func NewManagementServer(byClientID connection_repository.GetConnectionByClientID, byOrgID connection_repository.GetConnectionsByOrgID, allConnections connection_repository.GetAllConnections, tenantTranslator tenantid.Translator, proxyFactory controller.ConnectorClientProxyFactory, responseWaiter connection_repository.MessageResponseWaiter, r *mux.Router, urlPrefix string, cfg *config.Config) *ManagementServer {
 
 	return &ManagementServer{
 		getConnectionByClientID: byClientID,
@@ -49,6 +57,7 @@ func NewManagementServer(byClientID connection_repository.GetConnectionByClientI
 		config:                  cfg,
 		urlPrefix:               urlPrefix,
 		proxyFactory:            proxyFactory,
+		responseWaiter:          responseWaiter,
 	}
 }
 
@@ -90,6 +99,15 @@ type connectionStatusResponse struct {
 	Dispatchers    interface{} `json:"dispatchers,omitempty"`
 	CanonicalFacts interface{} `json:"canonical_facts,omitempty"`
 	Tags           interface{} `json:"tags,omitempty"`
+	ClientName     string      `json:"client_name,omitempty"`
+	ClientVersion  string      `json:"client_version,omitempty"`
+}
+
+type connectionPingRequest struct {
+	Account         string `json:"account" validate:"required"`
+	NodeID          string `json:"node_id" validate:"required"`
+	WaitForResponse bool   `json:"wait_for_response"`
+	Timeout         int    `json:"timeout"`
 }
 
 type connectionPingResponse struct {
@@ -97,6 +115,12 @@ type connectionPingResponse struct {
 	Payload interface{} `json:"payload"`
 }
 
+type connectionPingPayload struct {
+	MessageID       string      `json:"message_id"`
+	RoundTripTimeMs *int64      `json:"round_trip_time_ms,omitempty"`
+	Response        interface{} `json:"response,omitempty"`
+}
+
 func (s *ManagementServer) handleDisconnect() http.HandlerFunc {
 
 	type disconnectRequest struct {
@@ -378,7 +402,7 @@ func (s *ManagementServer) handleConnectionPing() http.HandlerFunc {
 
 		body := http.MaxBytesReader(w, req.Body, 1048576)
 
-		var connID connectionID
+		var connID connectionPingRequest
 
 		if err := decodeJSON(body, &connID); err != nil {
 			errorResponse := errorResponse{Title: DECODE_ERROR,
@@ -388,6 +412,26 @@ func (s *ManagementServer) handleConnectionPing() http.HandlerFunc {
 			return
 		}
 
+		if connID.Timeout < 0 {
+			errMsg := NEGATIVE_TIMEOUT_ERROR
+			logger.Info(errMsg)
+			errorResponse := errorResponse{Title: errMsg,
+				Status: http.StatusBadRequest,
+				Detail: errMsg}
+			writeJSONResponse(w, errorResponse.Status, errorResponse)
+			return
+		}
+
+		if connID.WaitForResponse && s.responseWaiter == nil {
+			errMsg := PING_WAIT_UNSUPPORTED_ERROR
+			logger.Info(errMsg)
+			errorResponse := errorResponse{Title: errMsg,
+				Status: http.StatusBadRequest,
+				Detail: errMsg}
+			writeJSONResponse(w, errorResponse.Status, errorResponse)
+			return
+		}
+
 		logger.Infof("Submitting ping for account:%s - node id:%s",
 			connID.Account, connID.NodeID)
 
@@ -402,7 +446,9 @@ func (s *ManagementServer) handleConnectionPing() http.HandlerFunc {
 
 		pingResponse.Status = CONNECTED_STATUS
 
-		pingErr := client.Ping(req.Context())
+		pingSent := time.Now()
+
+		messageID, pingErr := client.Ping(req.Context())
 
 		if pingErr != nil {
 			errorResponse := errorResponse{Title: PING_ERROR,
@@ -412,10 +458,60 @@ func (s *ManagementServer) handleConnectionPing() http.HandlerFunc {
 			return
 		}
 
+		payload := connectionPingPayload{MessageID: messageID.String()}
+		pingResponse.Payload = payload
+
+		if connID.WaitForResponse == false {
+			writeJSONResponse(w, http.StatusOK, pingResponse)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(req.Context(), s.pingResponseTimeout(connID.Timeout))
+		defer cancel()
+
+		event, err := s.responseWaiter.WaitForResponse(ctx, domain.ClientID(connID.NodeID), payload.MessageID)
+		if err != nil {
+			logger.WithFields(logrus.Fields{"error": err, "message_id": payload.MessageID}).Info("Unable to get a response to the ping")
+
+			errorResponse := errorResponse{Title: PING_RESPONSE_ERROR,
+				Status: http.StatusInternalServerError,
+				Detail: err.Error()}
+
+			if errors.Is(err, context.DeadlineExceeded) {
+				errorResponse.Title = PING_TIMEOUT_ERROR
+				errorResponse.Status = http.StatusGatewayTimeout
+				errorResponse.Detail = PING_TIMEOUT_ERROR
+			}
+
+			writeJSONResponse(w, errorResponse.Status, errorResponse)
+			return
+		}
+
+		roundTripTime := time.Since(pingSent).Milliseconds()
+		payload.RoundTripTimeMs = &roundTripTime
+		payload.Response = event.Content
+		pingResponse.Payload = payload
+
 		writeJSONResponse(w, http.StatusOK, pingResponse)
 	}
 }
 
+// pingResponseTimeout uses the default timeout if the request does not include a timeout.
+// The timeout is limited so that a request cannot hold a connection open indefinitely.
+func (s *ManagementServer) pingResponseTimeout(requestedTimeout int) time.Duration {
+
+	timeout := s.config.PingResponseDefaultTimeout
+	if requestedTimeout > 0 {
+		timeout = time.Duration(requestedTimeout) * time.Second
+	}
+
+	if timeout > s.config.PingResponseMaxTimeout {
+		timeout = s.config.PingResponseMaxTimeout
+	}
+
+	return timeout
+}
+
 func (s *ManagementServer) createConnectorClient(ctx context.Context, log *logrus.Entry, account domain.AccountID, clientId domain.ClientID) (controller.ConnectorClient, error) {
 	return createConnectorClientProxy(ctx, log, s.tenantTranslator, s.getConnectionByClientID, s.proxyFactory, account, clientId)
 }
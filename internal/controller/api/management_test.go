@@ -1,12 +1,14 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -39,6 +41,29 @@ func createConnectionStatusPostBody(account_number string, node_id string) io.Re
 	return strings.NewReader(jsonString)
 }
 
+func createConnectionPingPostBody(account_number string, node_id string, waitForResponse bool, timeout int) io.Reader {
+	jsonString := fmt.Sprintf("{\"account\": \"%s\", \"node_id\": \"%s\", \"wait_for_response\": %t, \"timeout\": %d}", account_number, node_id, waitForResponse, timeout)
+	return strings.NewReader(jsonString)
+}
+
+type MockMessageResponseWaiter struct {
+	respond         bool
+	waitedFor       string
+	waitedUntil     time.Time
+	responseContent interface{}
+}
+
+func (m *MockMessageResponseWaiter) WaitForResponse(ctx context.Context, clientID domain.ClientID, messageID string) (domain.MessageEvent, error) {
+	m.waitedFor = messageID
+	m.waitedUntil, _ = ctx.Deadline()
+
+	if m.respond == false {
+		return domain.MessageEvent{}, context.DeadlineExceeded
+	}
+
+	return domain.MessageEvent{ClientID: clientID, ResponseTo: messageID, Content: m.responseContent}, nil
+}
+
 func createConnectionReconnectPostBody(account_number string, node_id string, delay int, message string) io.Reader {
 	jsonString := fmt.Sprintf("{\"account\": \"%s\", \"node_id\": \"%s\", \"delay\": %d, \"message\": \"%s\"}", account_number, node_id, delay, message)
 	return strings.NewReader(jsonString)
@@ -48,6 +73,7 @@ var _ = Describe("Management", func() {
 
 	var (
 		ms                  *ManagementServer
+		responseWaiter      *MockMessageResponseWaiter
 		validIdentityHeader string
 	)
 
@@ -68,6 +94,7 @@ var _ = Describe("Management", func() {
 		getConnByOrgID := mockedGetConnectionsByOrgID(connectorClient)
 		getAllConnections := mockedGetAllConnections(domain.AccountID(accountNumber), connectorClient.ClientID)
 		proxyFactory := &MockClientProxyFactory{}
+		responseWaiter = &MockMessageResponseWaiter{respond: true, responseContent: "RECEIVED"}
 
 		mapping := map[string]*string{
 			string(connectorClient.OrgID): &accountNumber,
@@ -75,7 +102,7 @@ var _ = Describe("Management", func() {
 
 		tenantTranslator := tenantid.NewTranslatorMockWithMapping(mapping)
 
-		ms = NewManagementServer(getConnByClientID, getConnByOrgID, getAllConnections, tenantTranslator, proxyFactory, apiMux, URL_BASE_PATH, cfg)
+		ms = NewManagementServer(getConnByClientID, getConnByOrgID, getAllConnections, tenantTranslator, proxyFactory, responseWaiter, apiMux, URL_BASE_PATH, cfg)
 		ms.Routes()
 
 		validIdentityHeader = buildIdentityHeader("540155", "Associate")
@@ -541,6 +568,74 @@ var _ = Describe("Management", func() {
 		})
 	})
 
+	Describe("Waiting for a response to a ping", func() {
+
+		sendPing := func(postBody io.Reader) (*httptest.ResponseRecorder, map[string]interface{}) {
+			req, err := http.NewRequest("POST", CONNECTION_PING_ENDPOINT, postBody)
+			Expect(err).NotTo(HaveOccurred())
+
+			req.Header.Add(IDENTITY_HEADER_NAME, validIdentityHeader)
+
+			rr := httptest.NewRecorder()
+
+			ms.router.ServeHTTP(rr, req)
+
+			var m map[string]interface{}
+			json.Unmarshal(rr.Body.Bytes(), &m)
+
+			return rr, m
+		}
+
+		It("Should return the message id without waiting by default", func() {
+
+			rr, m := sendPing(createConnectionStatusPostBody(CONNECTED_ACCOUNT_NUMBER, CONNECTED_NODE_ID))
+
+			Expect(rr.Code).To(Equal(http.StatusOK))
+			Expect(m).Should(HaveKeyWithValue("status", CONNECTED_STATUS))
+			Expect(m["payload"]).Should(HaveKey("message_id"))
+			Expect(m["payload"]).ShouldNot(HaveKey("round_trip_time_ms"))
+			Expect(responseWaiter.waitedFor).To(BeEmpty())
+		})
+
+		It("Should return the round trip time once the client responds", func() {
+
+			rr, m := sendPing(createConnectionPingPostBody(CONNECTED_ACCOUNT_NUMBER, CONNECTED_NODE_ID, true, 0))
+
+			Expect(rr.Code).To(Equal(http.StatusOK))
+			Expect(m).Should(HaveKeyWithValue("status", CONNECTED_STATUS))
+
+			payload := m["payload"].(map[string]interface{})
+			Expect(payload).Should(HaveKeyWithValue("message_id", responseWaiter.waitedFor))
+			Expect(payload).Should(HaveKey("round_trip_time_ms"))
+			Expect(payload).Should(HaveKeyWithValue("response", "RECEIVED"))
+		})
+
+		It("Should time out if the client does not respond", func() {
+
+			responseWaiter.respond = false
+
+			rr, _ := sendPing(createConnectionPingPostBody(CONNECTED_ACCOUNT_NUMBER, CONNECTED_NODE_ID, true, 1))
+
+			Expect(rr.Code).To(Equal(http.StatusGatewayTimeout))
+			verifyErrorResponse(rr.Body, PING_TIMEOUT_ERROR)
+		})
+
+		It("Should limit the timeout", func() {
+
+			sendPing(createConnectionPingPostBody(CONNECTED_ACCOUNT_NUMBER, CONNECTED_NODE_ID, true, 3600))
+
+			Expect(responseWaiter.waitedUntil).To(BeTemporally("<=", time.Now().Add(ms.config.PingResponseMaxTimeout)))
+		})
+
+		It("Should reject a negative timeout", func() {
+
+			rr, _ := sendPing(createConnectionPingPostBody(CONNECTED_ACCOUNT_NUMBER, CONNECTED_NODE_ID, true, -1))
+
+			Expect(rr.Code).To(Equal(http.StatusBadRequest))
+			verifyErrorResponse(rr.Body, NEGATIVE_TIMEOUT_ERROR)
+		})
+	})
+
 	DescribeTable("Connecting to the connection/ping endpoint",
 		func(expectedStatusCode int, headers map[string]string) {
 
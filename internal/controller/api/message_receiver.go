@@ -231,6 +231,8 @@ func getConnectionStatus(w http.ResponseWriter, req *http.Request, tenantTransla
 		connectionStatus.Dispatchers = clientState.Dispatchers
 		connectionStatus.CanonicalFacts = clientState.CanonicalFacts
 		connectionStatus.Tags = clientState.Tags
+		connectionStatus.ClientName = clientState.ClientName
+		connectionStatus.ClientVersion = clientState.ClientVersion
 	}
 
 	logger.Infof("Connection status for account:%s - node id:%s => %s\n",
@@ -59,11 +59,12 @@ func (mc MockClient) SendMessage(ctx context.Context, directive string, metadata
 	return &myUUID, nil
 }
 
-func (mc MockClient) Ping(ctx context.Context) error {
+func (mc MockClient) Ping(ctx context.Context) (*uuid.UUID, error) {
 	if mc.returnAnError {
-		return errors.New("ImaError")
+		return nil, errors.New("ImaError")
 	}
-	return nil
+	myUUID, _ := uuid.NewRandom()
+	return &myUUID, nil
 }
 
 func (mc MockClient) Reconnect(ctx context.Context, message string, delay int) error {
@@ -1,25 +1,49 @@
 package api
 
 import (
+	"context"
 	"net/http"
 	_ "net/http/pprof"
+	"sync"
 
 	"github.com/RedHatInsights/cloud-connector/internal/config"
 	"github.com/RedHatInsights/cloud-connector/internal/platform/logger"
 
 	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sirupsen/logrus"
 )
 
+var readinessCheckStatus = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "cloud_connector_readiness_check_status",
+	Help: "The result of the most recent readiness check of a dependency (1 = ready, 0 = not ready)",
+}, []string{"check"})
+
+// ReadinessCheck verifies that a dependency of the component (database, mqtt broker,
+// kafka, etc) is reachable
+type ReadinessCheck struct {
+	Name  string
+	Check func(context.Context) error
+}
+
+type readinessResponse struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+}
+
 type MonitoringServer struct {
-	router *mux.Router
-	config *config.Config
+	router          *mux.Router
+	config          *config.Config
+	readinessChecks []ReadinessCheck
 }
 
-func NewMonitoringServer(r *mux.Router, cfg *config.Config) *MonitoringServer {
+func NewMonitoringServer(r *mux.Router, cfg *config.Config, readinessChecks ...ReadinessCheck) *MonitoringServer {
 	return &MonitoringServer{
-		router: r,
-		config: cfg,
+		router:          r,
+		config:          cfg,
+		readinessChecks: readinessChecks,
 	}
 }
 
@@ -42,6 +66,45 @@ func (s *MonitoringServer) handleLiveness() http.HandlerFunc {
 
 func (s *MonitoringServer) handleReadiness() http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
-		w.WriteHeader(http.StatusOK)
+
+		if len(s.readinessChecks) == 0 {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(req.Context(), s.config.ReadinessCheckTimeout)
+		defer cancel()
+
+		response := readinessResponse{Status: "ready", Checks: make(map[string]string, len(s.readinessChecks))}
+		status := http.StatusOK
+
+		results := make([]error, len(s.readinessChecks))
+
+		var wg sync.WaitGroup
+		for i, readinessCheck := range s.readinessChecks {
+			wg.Add(1)
+			go func(i int, readinessCheck ReadinessCheck) {
+				defer wg.Done()
+				results[i] = readinessCheck.Check(ctx)
+			}(i, readinessCheck)
+		}
+		wg.Wait()
+
+		for i, readinessCheck := range s.readinessChecks {
+			if results[i] != nil {
+				logger.Log.WithFields(logrus.Fields{"check": readinessCheck.Name, "error": results[i]}).Warn("Readiness check failed")
+
+				readinessCheckStatus.WithLabelValues(readinessCheck.Name).Set(0)
+				response.Checks[readinessCheck.Name] = results[i].Error()
+				response.Status = "not ready"
+				status = http.StatusServiceUnavailable
+				continue
+			}
+
+			readinessCheckStatus.WithLabelValues(readinessCheck.Name).Set(1)
+			response.Checks[readinessCheck.Name] = "ok"
+		}
+
+		writeJSONResponse(w, status, response)
 	}
 }
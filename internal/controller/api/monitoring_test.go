@@ -1,8 +1,11 @@
 package api
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/RedHatInsights/cloud-connector/internal/config"
@@ -67,3 +70,50 @@ func TestMonitoringEndpoints(t *testing.T) {
 		})
 	}
 }
+
+func TestReadinessChecks(t *testing.T) {
+	tests := []struct {
+		name           string
+		checks         []ReadinessCheck
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name: "all checks pass",
+			checks: []ReadinessCheck{
+				{Name: "database", Check: func(context.Context) error { return nil }},
+				{Name: "mqtt", Check: func(context.Context) error { return nil }},
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"status":"ready","checks":{"database":"ok","mqtt":"ok"}}`,
+		},
+		{
+			name: "a check fails",
+			checks: []ReadinessCheck{
+				{Name: "database", Check: func(context.Context) error { return nil }},
+				{Name: "kafka", Check: func(context.Context) error { return errors.New("broker unavailable") }},
+			},
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedBody:   `{"status":"not ready","checks":{"database":"ok","kafka":"broker unavailable"}}`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest("GET", "/readiness", nil)
+			assert.Equal(t, err, nil)
+
+			rr := httptest.NewRecorder()
+
+			cfg := config.GetConfig()
+			apiMux := mux.NewRouter()
+			monitoringServer := NewMonitoringServer(apiMux, cfg, tc.checks...)
+			monitoringServer.Routes()
+
+			monitoringServer.router.ServeHTTP(rr, req)
+
+			assert.Equal(t, rr.Code, tc.expectedStatus)
+			assert.Equal(t, strings.TrimSpace(rr.Body.String()), tc.expectedBody)
+		})
+	}
+}
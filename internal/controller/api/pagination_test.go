@@ -193,7 +193,7 @@ func testSetup(connectionCount int) (*ManagementServer, string) {
 
 	tenantTranslator := tenantid.NewTranslatorMockWithMapping(mapping)
 
-	managementServer := NewManagementServer(getConnByClientID, getConnByOrgID, getAllConnections, tenantTranslator, proxyFactory, apiMux, URL_BASE_PATH, cfg)
+	managementServer := NewManagementServer(getConnByClientID, getConnByOrgID, getAllConnections, tenantTranslator, proxyFactory, nil, apiMux, URL_BASE_PATH, cfg)
 	managementServer.Routes()
 
 	return managementServer, buildIdentityHeader("540155", "Associate")
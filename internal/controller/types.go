@@ -16,7 +16,7 @@ var (
 
 type ConnectorClient interface {
 	SendMessage(context.Context, string, interface{}, interface{}) (*uuid.UUID, error)
-	Ping(context.Context) error
+	Ping(context.Context) (*uuid.UUID, error)
 	Reconnect(context.Context, string, int) error
 	GetDispatchers(context.Context) (domain.Dispatchers, error)
 	GetCanonicalFacts(context.Context) (domain.CanonicalFacts, error)
@@ -43,4 +43,70 @@ type ConnectorClientState struct {
 	MessageMetadata          MessageMetadata
 	TenantLookupTimestamp    time.Time
 	TenantLookupFailureCount int
+	ClientName               string
+	ClientVersion            string
+	State                    string
+	DisconnectedAt           time.Time
+}
+
+type MessageEvent struct {
+	OrgID      OrgID
+	ClientID   ClientID
+	MessageID  string
+	ResponseTo string
+	Content    interface{}
+	Sent       time.Time
+}
+
+type MessageStatus string
+
+const (
+	MessagePending       MessageStatus = "pending"
+	MessagePublished     MessageStatus = "published"
+	MessageFailedPublish MessageStatus = "failed_publish"
+	MessageAcknowledged  MessageStatus = "acknowledged"
+	MessageExpired       MessageStatus = "expired"
+)
+
+type Message struct {
+	MessageID string
+	OrgID     OrgID
+	Account   AccountID
+	ClientID  ClientID
+	Directive string
+	Status    MessageStatus
+	Error     string
+	Created   time.Time
+	Updated   time.Time
+}
+
+type PendingMessage struct {
+	MessageID string
+	OrgID     OrgID
+	Account   AccountID
+	ClientID  ClientID
+	Directive string
+	Metadata  interface{}
+	Payload   interface{}
+	Created   time.Time
+	Expires   time.Time
+}
+
+const (
+	ConnectionOnline  = "online"
+	ConnectionOffline = "offline"
+	ConnectionReaped  = "reaped"
+	ConnectionPurged  = "purged"
+)
+
+type ConnectionEvent struct {
+	OrgID         OrgID
+	Account       AccountID
+	ClientID      ClientID
+	State         string
+	MessageID     string
+	Sent          time.Time
+	ClientName    string
+	ClientVersion string
+	Created       time.Time
 }
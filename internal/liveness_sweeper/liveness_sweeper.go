@@ -0,0 +1,294 @@
+package liveness_sweeper
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/RedHatInsights/cloud-connector/internal/config"
+	"github.com/RedHatInsights/cloud-connector/internal/connection_repository"
+	"github.com/RedHatInsights/cloud-connector/internal/controller"
+	"github.com/RedHatInsights/cloud-connector/internal/domain"
+	"github.com/RedHatInsights/cloud-connector/internal/platform/logger"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+// RecordSuccessfulLivenessCheck is called when a connection responds to a ping
+type RecordSuccessfulLivenessCheck func(context.Context, domain.ClientID) error
+
+type livenessCheckResult int
+
+const (
+	connectionAlive livenessCheckResult = iota
+	connectionUnresponsive
+	connectionReaped
+	livenessCheckFailed
+)
+
+// SweepResult counts the outcome of the liveness check for each connection in a sweep.
+// Skipped counts the unresponsive connections that were left in place because the
+// sweep itself looked unhealthy.
+type SweepResult struct {
+	Alive   int
+	Reaped  int
+	Failed  int
+	Skipped int
+}
+
+// LivenessSweeper detects connections whose host went away without the broker
+// delivering the offline message.  Each connection is sent a ping command.  A
+// connection that does not respond with an event after the configured number of
+// attempts is unregistered.  Nothing is unregistered if none of the connections
+// responded or if too many of them did not respond.  Either usually means the
+// responses are not making it back (broker or kafka trouble), not that the hosts
+// went away.
+type LivenessSweeper struct {
+	proxyFactory            controller.ConnectorClientProxyFactory
+	getMessageEvents        connection_repository.GetMessageEventsByMessageID
+	connectionRegistrar     connection_repository.ConnectionRegistrar
+	connectionEventRecorder connection_repository.ConnectionEventRecorder
+	recordLivenessCheck     RecordSuccessfulLivenessCheck
+	concurrency             int
+	pingAttempts            int
+	responseTimeout         time.Duration
+	pollInterval            time.Duration
+	maxReapedPercent        int
+}
+
+func NewLivenessSweeper(cfg *config.Config, proxyFactory controller.ConnectorClientProxyFactory, getMessageEvents connection_repository.GetMessageEventsByMessageID, connectionRegistrar connection_repository.ConnectionRegistrar, connectionEventRecorder connection_repository.ConnectionEventRecorder, recordLivenessCheck RecordSuccessfulLivenessCheck) *LivenessSweeper {
+
+	concurrency := cfg.LivenessSweepConcurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	pingAttempts := cfg.LivenessSweepPingAttempts
+	if pingAttempts < 1 {
+		pingAttempts = 1
+	}
+
+	return &LivenessSweeper{
+		proxyFactory:            proxyFactory,
+		getMessageEvents:        getMessageEvents,
+		connectionRegistrar:     connectionRegistrar,
+		connectionEventRecorder: connectionEventRecorder,
+		recordLivenessCheck:     recordLivenessCheck,
+		concurrency:             concurrency,
+		pingAttempts:            pingAttempts,
+		responseTimeout:         cfg.LivenessSweepResponseTimeout,
+		pollInterval:            cfg.LivenessSweepPollInterval,
+		maxReapedPercent:        cfg.LivenessSweepMaxReapedPercent,
+	}
+}
+
+// Sweep checks the connections, at most concurrency connections at a time, and waits for
+// every check to finish before the unresponsive connections are unregistered
+func (ls *LivenessSweeper) Sweep(ctx context.Context, connections []domain.ConnectorClientState) SweepResult {
+
+	sweepDurationTimer := prometheus.NewTimer(metrics.sweepDuration)
+	defer sweepDurationTimer.ObserveDuration()
+
+	metrics.sweptConnectionsGauge.Set(float64(len(connections)))
+
+	var result SweepResult
+	var unresponsive []domain.ConnectorClientState
+	var resultLock sync.Mutex
+	var wg sync.WaitGroup
+
+	semaphore := make(chan struct{}, ls.concurrency)
+
+checkConnections:
+	for _, connection := range connections {
+
+		select {
+		case semaphore <- struct{}{}:
+		case <-ctx.Done():
+			break checkConnections
+		}
+
+		wg.Add(1)
+
+		go func(connection domain.ConnectorClientState) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+
+			checkResult := ls.checkConnection(ctx, connection)
+
+			resultLock.Lock()
+			defer resultLock.Unlock()
+
+			switch checkResult {
+			case connectionAlive:
+				result.Alive++
+			case connectionUnresponsive:
+				unresponsive = append(unresponsive, connection)
+			default:
+				result.Failed++
+			}
+		}(connection)
+	}
+
+	wg.Wait()
+
+	if ctx.Err() != nil || ls.reapAllowed(result.Alive, len(unresponsive)) == false {
+		result.Skipped = len(unresponsive)
+		metrics.skippedCounter.Add(float64(result.Skipped))
+		return result
+	}
+
+	for _, connection := range unresponsive {
+		switch ls.reapConnection(ctx, connection) {
+		case connectionAlive:
+			result.Alive++
+		case connectionReaped:
+			result.Reaped++
+		default:
+			result.Failed++
+		}
+	}
+
+	return result
+}
+
+func (ls *LivenessSweeper) reapAllowed(alive int, unresponsive int) bool {
+
+	if unresponsive == 0 {
+		return true
+	}
+
+	log := logger.Log.WithFields(logrus.Fields{"alive": alive, "unresponsive": unresponsive, "max_reaped_percent": ls.maxReapedPercent})
+
+	if alive == 0 {
+		log.Warn("None of the connections responded to the liveness check...not unregistering any connections")
+		return false
+	}
+
+	if unresponsive*100 > ls.maxReapedPercent*(alive+unresponsive) {
+		log.Warn("Too many connections did not respond to the liveness check...not unregistering any connections")
+		return false
+	}
+
+	return true
+}
+
+func (ls *LivenessSweeper) checkConnection(ctx context.Context, connection domain.ConnectorClientState) livenessCheckResult {
+
+	log := logger.Log.WithFields(logrus.Fields{"org_id": connection.OrgID, "account": connection.Account, "client_id": connection.ClientID})
+
+	client, err := ls.proxyFactory.CreateProxy(ctx, connection.OrgID, connection.Account, connection.ClientID, connection.CanonicalFacts, connection.Dispatchers, connection.Tags)
+	if err != nil {
+		log.WithFields(logrus.Fields{"error": err}).Error("Unable to create proxy for connection")
+		metrics.failureCounter.Inc()
+		return livenessCheckFailed
+	}
+
+	for attempt := 1; attempt <= ls.pingAttempts; attempt++ {
+
+		attemptLog := log.WithFields(logrus.Fields{"attempt": attempt})
+
+		pingSent := time.Now()
+
+		messageID, err := client.Ping(ctx)
+		if err != nil {
+			// The ping never reached the broker...this says nothing about the connection
+			attemptLog.WithFields(logrus.Fields{"error": err}).Error("Unable to ping connection")
+			metrics.failureCounter.Inc()
+			return livenessCheckFailed
+		}
+
+		metrics.pingCounter.Inc()
+
+		responded, err := ls.waitForResponse(ctx, attemptLog, connection, messageID.String())
+		if err != nil {
+			attemptLog.WithFields(logrus.Fields{"error": err}).Error("Liveness check interrupted")
+			metrics.failureCounter.Inc()
+			return livenessCheckFailed
+		}
+
+		if responded {
+			metrics.responseDuration.Observe(time.Since(pingSent).Seconds())
+			metrics.aliveCounter.Inc()
+
+			if err := ls.recordLivenessCheck(ctx, connection.ClientID); err != nil {
+				attemptLog.WithFields(logrus.Fields{"error": err}).Error("Unable to record liveness check")
+			}
+
+			attemptLog.Debug("Connection responded to ping")
+			return connectionAlive
+		}
+
+		attemptLog.Debug("Connection did not respond to ping")
+	}
+
+	return connectionUnresponsive
+}
+
+// waitForResponse polls for an event sent in response to the ping.  An error is only
+// returned if the sweep is cancelled.
+func (ls *LivenessSweeper) waitForResponse(ctx context.Context, log *logrus.Entry, connection domain.ConnectorClientState, messageID string) (bool, error) {
+
+	responseCtx, cancel := context.WithTimeout(ctx, ls.responseTimeout)
+	defer cancel()
+
+	ticker := time.NewTicker(ls.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-responseCtx.Done():
+			return false, ctx.Err()
+		case <-ticker.C:
+			events, err := ls.getMessageEvents(responseCtx, log, connection.OrgID, connection.ClientID, messageID)
+			if err != nil {
+				log.WithFields(logrus.Fields{"error": err}).Warn("Unable to lookup ping response")
+				continue
+			}
+
+			if len(events) > 0 {
+				return true, nil
+			}
+		}
+	}
+}
+
+// reapConnection unregisters an unresponsive connection
+func (ls *LivenessSweeper) reapConnection(ctx context.Context, connection domain.ConnectorClientState) livenessCheckResult {
+
+	log := logger.Log.WithFields(logrus.Fields{"org_id": connection.OrgID, "account": connection.Account, "client_id": connection.ClientID})
+
+	// The unregister only succeeds if the client has not sent a connection-status
+	// message since the connection was selected for the sweep
+	err := ls.connectionRegistrar.UnregisterIfUnchanged(ctx, connection.ClientID, connection.MessageMetadata)
+	if errors.Is(err, connection_repository.StaleConnectionStateError) {
+		log.Debug("Connection sent a connection-status message during the liveness check...leaving it in place")
+		metrics.aliveCounter.Inc()
+		return connectionAlive
+	}
+
+	if err != nil {
+		log.WithFields(logrus.Fields{"error": err}).Error("Unable to unregister unresponsive connection")
+		metrics.failureCounter.Inc()
+		return livenessCheckFailed
+	}
+
+	metrics.reapedCounter.Inc()
+
+	log.Info("Unregistered connection that did not respond to ping")
+
+	event := domain.ConnectionEvent{
+		OrgID:    connection.OrgID,
+		Account:  connection.Account,
+		ClientID: connection.ClientID,
+		State:    domain.ConnectionReaped,
+		Sent:     time.Now(),
+	}
+
+	if err := ls.connectionEventRecorder.RecordConnectionEvent(ctx, event); err != nil {
+		log.WithFields(logrus.Fields{"error": err}).Error("Unable to record connection event for unresponsive connection")
+	}
+
+	return connectionReaped
+}
@@ -0,0 +1,272 @@
+package liveness_sweeper
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/cloud-connector/internal/config"
+	"github.com/RedHatInsights/cloud-connector/internal/connection_repository"
+	"github.com/RedHatInsights/cloud-connector/internal/controller"
+	"github.com/RedHatInsights/cloud-connector/internal/domain"
+	"github.com/RedHatInsights/cloud-connector/internal/platform/logger"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+func init() {
+	logger.InitLogger()
+}
+
+type mockClient struct {
+	controller.ConnectorClient
+	clientID domain.ClientID
+	network  *mockNetwork
+}
+
+func (mc mockClient) Ping(ctx context.Context) (*uuid.UUID, error) {
+	return mc.network.ping(mc.clientID)
+}
+
+type mockProxyFactory struct {
+	network *mockNetwork
+}
+
+func (mpf mockProxyFactory) CreateProxy(ctx context.Context, orgID domain.OrgID, account domain.AccountID, clientID domain.ClientID, canonicalFacts domain.CanonicalFacts, dispatchers domain.Dispatchers, tags domain.Tags) (controller.ConnectorClient, error) {
+	return mockClient{clientID: clientID, network: mpf.network}, nil
+}
+
+// mockNetwork records the pings and answers them for the responsive clients
+type mockNetwork struct {
+	sync.Mutex
+	responsive    map[domain.ClientID]bool
+	brokenClients map[domain.ClientID]bool
+	pings         map[domain.ClientID]int
+	responses     map[string]bool
+	unregistered  map[domain.ClientID]bool
+	reconnected   map[domain.ClientID]bool
+	events        []domain.ConnectionEvent
+	livenessCheck map[domain.ClientID]bool
+}
+
+func newMockNetwork() *mockNetwork {
+	return &mockNetwork{
+		responsive:    make(map[domain.ClientID]bool),
+		brokenClients: make(map[domain.ClientID]bool),
+		pings:         make(map[domain.ClientID]int),
+		responses:     make(map[string]bool),
+		unregistered:  make(map[domain.ClientID]bool),
+		reconnected:   make(map[domain.ClientID]bool),
+		livenessCheck: make(map[domain.ClientID]bool),
+	}
+}
+
+func (mn *mockNetwork) ping(clientID domain.ClientID) (*uuid.UUID, error) {
+	mn.Lock()
+	defer mn.Unlock()
+
+	if mn.brokenClients[clientID] {
+		return nil, errors.New("unable to publish")
+	}
+
+	mn.pings[clientID]++
+
+	messageID := uuid.New()
+	if mn.responsive[clientID] {
+		mn.responses[messageID.String()] = true
+	}
+
+	return &messageID, nil
+}
+
+func (mn *mockNetwork) getMessageEvents(ctx context.Context, log *logrus.Entry, orgID domain.OrgID, clientID domain.ClientID, messageID string) ([]domain.MessageEvent, error) {
+	mn.Lock()
+	defer mn.Unlock()
+
+	if mn.responses[messageID] == false {
+		return nil, nil
+	}
+
+	return []domain.MessageEvent{{OrgID: orgID, ClientID: clientID, ResponseTo: messageID, Content: "RECEIVED"}}, nil
+}
+
+func (mn *mockNetwork) Register(ctx context.Context, state domain.ConnectorClientState) error {
+	return nil
+}
+
+func (mn *mockNetwork) Unregister(ctx context.Context, clientID domain.ClientID, latestMessage domain.MessageMetadata) error {
+	return nil
+}
+
+func (mn *mockNetwork) UnregisterIfUnchanged(ctx context.Context, clientID domain.ClientID, latestMessage domain.MessageMetadata) error {
+	mn.Lock()
+	defer mn.Unlock()
+
+	// The client sent a newer connection-status message after it was selected for the sweep
+	if mn.reconnected[clientID] {
+		return connection_repository.StaleConnectionStateError
+	}
+
+	mn.unregistered[clientID] = true
+	return nil
+}
+
+func (mn *mockNetwork) FindConnectionByClientID(ctx context.Context, clientID domain.ClientID) (domain.ConnectorClientState, error) {
+	return domain.ConnectorClientState{}, nil
+}
+
+func (mn *mockNetwork) RecordConnectionEvent(ctx context.Context, event domain.ConnectionEvent) error {
+	mn.Lock()
+	defer mn.Unlock()
+	mn.events = append(mn.events, event)
+	return nil
+}
+
+func (mn *mockNetwork) recordLivenessCheck(ctx context.Context, clientID domain.ClientID) error {
+	mn.Lock()
+	defer mn.Unlock()
+	mn.livenessCheck[clientID] = true
+	return nil
+}
+
+func newTestSweeper(network *mockNetwork) *LivenessSweeper {
+	cfg := config.GetConfig()
+	cfg.LivenessSweepConcurrency = 2
+	cfg.LivenessSweepPingAttempts = 3
+	cfg.LivenessSweepResponseTimeout = 50 * time.Millisecond
+	cfg.LivenessSweepPollInterval = 5 * time.Millisecond
+	cfg.LivenessSweepMaxReapedPercent = 50
+
+	return NewLivenessSweeper(cfg, mockProxyFactory{network}, network.getMessageEvents, network, network, network.recordLivenessCheck)
+}
+
+func TestSweep(t *testing.T) {
+
+	network := newMockNetwork()
+	network.responsive["alive-1"] = true
+	network.responsive["alive-2"] = true
+	network.brokenClients["broken"] = true
+
+	connections := []domain.ConnectorClientState{
+		{OrgID: "1234", ClientID: "alive-1"},
+		{OrgID: "1234", ClientID: "ghost-1"},
+		{OrgID: "1234", ClientID: "alive-2"},
+		{OrgID: "1234", ClientID: "ghost-2"},
+		{OrgID: "1234", ClientID: "broken"},
+	}
+
+	result := newTestSweeper(network).Sweep(context.Background(), connections)
+
+	if result != (SweepResult{Alive: 2, Reaped: 2, Failed: 1}) {
+		t.Fatalf("unexpected sweep result: %+v", result)
+	}
+
+	for _, clientID := range []domain.ClientID{"alive-1", "alive-2"} {
+		if network.pings[clientID] != 1 {
+			t.Fatalf("expected %s to be pinged once, got %d", clientID, network.pings[clientID])
+		}
+
+		if network.livenessCheck[clientID] == false || network.unregistered[clientID] {
+			t.Fatalf("expected %s to be recorded as alive", clientID)
+		}
+	}
+
+	for _, clientID := range []domain.ClientID{"ghost-1", "ghost-2"} {
+		if network.pings[clientID] != 3 {
+			t.Fatalf("expected %s to be pinged 3 times, got %d", clientID, network.pings[clientID])
+		}
+
+		if network.unregistered[clientID] == false || network.livenessCheck[clientID] {
+			t.Fatalf("expected %s to be unregistered", clientID)
+		}
+	}
+
+	if network.unregistered["broken"] {
+		t.Fatal("a connection must not be unregistered when the ping cannot be published")
+	}
+
+	if len(network.events) != 2 {
+		t.Fatalf("expected 2 connection events, got %d", len(network.events))
+	}
+
+	for _, event := range network.events {
+		if event.State != domain.ConnectionReaped {
+			t.Fatalf("expected a reaped connection event, got %s", event.State)
+		}
+	}
+}
+
+func TestSweepCancelledDoesNotReap(t *testing.T) {
+
+	network := newMockNetwork()
+
+	connections := []domain.ConnectorClientState{
+		{OrgID: "1234", ClientID: "ghost-1"},
+		{OrgID: "1234", ClientID: "ghost-2"},
+		{OrgID: "1234", ClientID: "ghost-3"},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	result := newTestSweeper(network).Sweep(ctx, connections)
+
+	if result.Reaped != 0 || len(network.unregistered) != 0 {
+		t.Fatalf("expected no connections to be unregistered after the sweep was cancelled: %+v", result)
+	}
+}
+
+func TestSweepDoesNotReapWhenNoConnectionResponds(t *testing.T) {
+
+	network := newMockNetwork()
+
+	connections := []domain.ConnectorClientState{
+		{OrgID: "1234", ClientID: "ghost-1"},
+		{OrgID: "1234", ClientID: "ghost-2"},
+	}
+
+	result := newTestSweeper(network).Sweep(context.Background(), connections)
+
+	if result != (SweepResult{Skipped: 2}) || len(network.unregistered) != 0 {
+		t.Fatalf("expected no connections to be unregistered when no connection responded: %+v", result)
+	}
+}
+
+func TestSweepDoesNotReapTooManyConnections(t *testing.T) {
+
+	network := newMockNetwork()
+	network.responsive["alive-1"] = true
+
+	connections := []domain.ConnectorClientState{
+		{OrgID: "1234", ClientID: "alive-1"},
+		{OrgID: "1234", ClientID: "ghost-1"},
+		{OrgID: "1234", ClientID: "ghost-2"},
+	}
+
+	result := newTestSweeper(network).Sweep(context.Background(), connections)
+
+	if result != (SweepResult{Alive: 1, Skipped: 2}) || len(network.unregistered) != 0 {
+		t.Fatalf("expected no connections to be unregistered when most connections did not respond: %+v", result)
+	}
+}
+
+func TestSweepLeavesReconnectedConnection(t *testing.T) {
+
+	network := newMockNetwork()
+	network.responsive["alive-1"] = true
+	network.reconnected["ghost-1"] = true
+
+	connections := []domain.ConnectorClientState{
+		{OrgID: "1234", ClientID: "alive-1"},
+		{OrgID: "1234", ClientID: "ghost-1"},
+	}
+
+	result := newTestSweeper(network).Sweep(context.Background(), connections)
+
+	if result != (SweepResult{Alive: 2}) || len(network.unregistered) != 0 || len(network.events) != 0 {
+		t.Fatalf("expected the reconnected connection to be left in place: %+v", result)
+	}
+}
@@ -0,0 +1,67 @@
+package liveness_sweeper
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+type livenessSweeperMetrics struct {
+	pingCounter           prometheus.Counter
+	aliveCounter          prometheus.Counter
+	reapedCounter         prometheus.Counter
+	failureCounter        prometheus.Counter
+	skippedCounter        prometheus.Counter
+	responseDuration      prometheus.Histogram
+	sweepDuration         prometheus.Histogram
+	sweptConnectionsGauge prometheus.Gauge
+}
+
+func newLivenessSweeperMetrics() *livenessSweeperMetrics {
+	metrics := new(livenessSweeperMetrics)
+
+	metrics.pingCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "cloud_connector_liveness_sweep_ping_count",
+		Help: "The number of ping commands sent by the liveness sweeper",
+	})
+
+	metrics.aliveCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "cloud_connector_liveness_sweep_alive_count",
+		Help: "The number of connections that responded to a liveness sweep ping",
+	})
+
+	metrics.reapedCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "cloud_connector_liveness_sweep_reaped_connection_count",
+		Help: "The number of connections unregistered because they did not respond to a liveness sweep ping",
+	})
+
+	metrics.failureCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "cloud_connector_liveness_sweep_failure_count",
+		Help: "The number of connections that could not be checked by the liveness sweeper",
+	})
+
+	metrics.skippedCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "cloud_connector_liveness_sweep_skipped_reap_count",
+		Help: "The number of unresponsive connections left in place because the liveness sweep looked unhealthy",
+	})
+
+	metrics.responseDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name: "cloud_connector_liveness_sweep_response_duration",
+		Help: "The amount of time it took a connection to respond to a liveness sweep ping",
+	})
+
+	metrics.sweepDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name: "cloud_connector_liveness_sweep_duration",
+		Help: "The amount of time it took to check a batch of connections",
+	})
+
+	metrics.sweptConnectionsGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "cloud_connector_liveness_sweep_connection_count",
+		Help: "The number of connections checked during the last liveness sweep",
+	})
+
+	return metrics
+}
+
+var (
+	metrics = newLivenessSweeperMetrics()
+)
@@ -2,6 +2,7 @@ package mqtt
 
 import (
 	"context"
+	"errors"
 	"net"
 	"net/url"
 	"strings"
@@ -68,7 +69,9 @@ func logBrokerNode(brokerUrl string) {
 	fields["broker_node"] = strings.TrimSuffix(hostnames[0], ".")
 }
 
-func CreateBrokerConnection(brokerUrl string, brokerConfigFuncs ...MqttClientOptionsFunc) (MQTT.Client, error) {
+// CreateBrokerConnection connects to the broker using the MQTT 5 client when protocolVersion
+// is 5.  Otherwise the paho MQTT 3.1.1 client is used.
+func CreateBrokerConnection(brokerUrl string, protocolVersion int, brokerConfigFuncs ...MqttClientOptionsFunc) (MQTT.Client, error) {
 
 	connOpts, err := NewBrokerOptions(brokerUrl, brokerConfigFuncs...)
 	if err != nil {
@@ -76,6 +79,11 @@ func CreateBrokerConnection(brokerUrl string, brokerConfigFuncs ...MqttClientOpt
 		return nil, err
 	}
 
+	if protocolVersion == MQTTProtocolVersion5 {
+		logger.Log.Info("Using MQTT 5 broker connection")
+		return createMqtt5BrokerConnection(connOpts)
+	}
+
 	mqttClient := MQTT.NewClient(connOpts)
 	if token := mqttClient.Connect(); token.Wait() && token.Error() != nil {
 		logger.Log.WithFields(logrus.Fields{"error": token.Error()}).Error("Unable to connect to MQTT broker")
@@ -84,3 +92,14 @@ func CreateBrokerConnection(brokerUrl string, brokerConfigFuncs ...MqttClientOpt
 
 	return mqttClient, nil
 }
+
+// BuildConnectionCheck returns a readiness check that fails while the client is not
+// connected to the broker
+func BuildConnectionCheck(mqttClient MQTT.Client) func(context.Context) error {
+	return func(ctx context.Context) error {
+		if mqttClient.IsConnectionOpen() == false {
+			return errors.New("not connected to the MQTT broker")
+		}
+		return nil
+	}
+}
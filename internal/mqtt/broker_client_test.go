@@ -0,0 +1,101 @@
+package mqtt
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"testing"
+)
+
+// startFakeBroker accepts connections and acknowledges the CONNECT packet using the
+// protocol version requested by the client.  Everything sent after the CONNECT packet
+// is discarded.
+func startFakeBroker(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("unable to start fake broker", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go handleFakeBrokerConnection(conn)
+		}
+	}()
+
+	return "tcp://" + listener.Addr().String()
+}
+
+func handleFakeBrokerConnection(conn net.Conn) {
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+
+	// Fixed header: packet type followed by the variable length remaining length
+	if _, err := reader.ReadByte(); err != nil {
+		return
+	}
+
+	remainingLength := 0
+	for multiplier := 1; ; multiplier *= 128 {
+		encodedByte, err := reader.ReadByte()
+		if err != nil {
+			return
+		}
+
+		remainingLength += int(encodedByte&127) * multiplier
+		if encodedByte&128 == 0 {
+			break
+		}
+	}
+
+	connect := make([]byte, remainingLength)
+	if _, err := io.ReadFull(reader, connect); err != nil || len(connect) < 7 {
+		return
+	}
+
+	// The protocol level follows the protocol name ("MQTT")
+	connack := []byte{0x20, 0x02, 0x00, 0x00}
+	if connect[6] == MQTTProtocolVersion5 {
+		// MQTT 5 adds an (empty) properties section
+		connack = []byte{0x20, 0x03, 0x00, 0x00, 0x00}
+	}
+
+	if _, err := conn.Write(connack); err != nil {
+		return
+	}
+
+	io.Copy(io.Discard, reader)
+}
+
+func TestCreateBrokerConnectionUsesConfiguredProtocolVersion(t *testing.T) {
+
+	brokerUrl := startFakeBroker(t)
+
+	testCases := []struct {
+		protocolVersion   int
+		expectMqtt5Client bool
+	}{
+		{4, false},
+		{MQTTProtocolVersion5, true},
+	}
+
+	for _, tc := range testCases {
+		client, err := CreateBrokerConnection(brokerUrl, tc.protocolVersion, WithClientID("protocol-version-test"), WithProtocolVersion(4))
+		if err != nil {
+			t.Fatalf("unexpected error connecting with protocol version %d: %s", tc.protocolVersion, err)
+		}
+
+		_, isMqtt5Client := client.(*mqtt5Client)
+		if isMqtt5Client != tc.expectMqtt5Client {
+			t.Errorf("protocol version %d: expected the MQTT 5 client to be %v, got %T", tc.protocolVersion, tc.expectMqtt5Client, client)
+		}
+
+		client.Disconnect(100)
+	}
+}
@@ -18,6 +18,11 @@ var (
 	errUnableToSendMessage = errors.New("unable to send message")
 )
 
+// MessageRecorder records the messages that were sent to connected clients
+type MessageRecorder interface {
+	RecordMessage(context.Context, domain.Message) error
+}
+
 type ConnectorClientMQTTProxy struct {
 	Logger         *logrus.Entry
 	Config         *config.Config
@@ -29,6 +34,7 @@ type ConnectorClientMQTTProxy struct {
 	Dispatchers    domain.Dispatchers
 	CanonicalFacts domain.CanonicalFacts
 	Tags           domain.Tags
+	MessageLedger  MessageRecorder
 }
 
 func (cc *ConnectorClientMQTTProxy) SendMessage(ctx context.Context, directive string, metadata interface{}, payload interface{}) (*uuid.UUID, error) {
@@ -43,12 +49,45 @@ func (cc *ConnectorClientMQTTProxy) SendMessage(ctx context.Context, directive s
 
 	topic := cc.TopicBuilder.BuildOutgoingDataTopic(cc.ClientID)
 
-	err = sendMessage(cc.Client, logger, cc.ClientID, messageID, topic, cc.Config.MqttDataPublishQoS, cc.Config.MqttPublishTimeout, message)
+	err = sendMessage(cc.Client, logger, cc.ClientID, messageID, topic, cc.Config.MqttDataPublishQoS, cc.Config.MqttPublishTimeout, cc.Config.MqttDataMessageExpiry, message)
+
+	// The message has already been published, so record it even if the sender has gone away.
+	// The ledger bounds the call with its own query timeout.
+	cc.recordMessage(context.WithoutCancel(ctx), logger, messageID, directive, err)
 
 	return messageID, err
 }
 
-func (cc *ConnectorClientMQTTProxy) Ping(ctx context.Context) error {
+func (cc *ConnectorClientMQTTProxy) recordMessage(ctx context.Context, logger *logrus.Entry, messageID *uuid.UUID, directive string, publishErr error) {
+
+	if messageID == nil || cc.MessageLedger == nil {
+		return
+	}
+
+	message := domain.Message{
+		MessageID: messageID.String(),
+		OrgID:     cc.OrgID,
+		Account:   cc.AccountID,
+		ClientID:  cc.ClientID,
+		Directive: directive,
+		Status:    domain.MessagePublished,
+	}
+
+	if publishErr != nil {
+		message.Status = domain.MessageFailedPublish
+		message.Error = publishErr.Error()
+	}
+
+	// Failing to record the message should not cause the message to be treated as undelivered
+	if err := cc.MessageLedger.RecordMessage(ctx, message); err != nil {
+		metrics.messageLedgerFailureCounter.Inc()
+		logger.WithFields(logrus.Fields{"error": err}).Error("Unable to record message in the message ledger")
+	}
+}
+
+// Ping returns the id of the ping message.  The client responds with an event message
+// whose response_to is set to this id.
+func (cc *ConnectorClientMQTTProxy) Ping(ctx context.Context) (*uuid.UUID, error) {
 
 	commandMessageContent := protocol.CommandMessageContent{Command: "ping"}
 
@@ -56,9 +95,7 @@ func (cc *ConnectorClientMQTTProxy) Ping(ctx context.Context) error {
 
 	qos := cc.Config.MqttControlPublishQoS
 
-	_, err := sendControlMessage(cc.Client, cc.Logger, topic, qos, cc.Config.MqttPublishTimeout, cc.ClientID, "command", &commandMessageContent)
-
-	return err
+	return sendControlMessage(cc.Client, cc.Logger, topic, qos, cc.Config.MqttPublishTimeout, cc.ClientID, "command", &commandMessageContent)
 }
 
 func (cc *ConnectorClientMQTTProxy) Reconnect(ctx context.Context, message string, delay int) error {
@@ -16,10 +16,11 @@ type ConnectorClientMQTTProxyFactory struct {
 	mqttClient   MQTT.Client
 	topicBuilder *TopicBuilder
 	config       *config.Config
+	ledger       MessageRecorder
 }
 
-func NewConnectorClientMQTTProxyFactory(cfg *config.Config, mqttClient MQTT.Client, topicBuilder *TopicBuilder) (controller.ConnectorClientProxyFactory, error) {
-	proxyFactory := ConnectorClientMQTTProxyFactory{mqttClient: mqttClient, topicBuilder: topicBuilder, config: cfg}
+func NewConnectorClientMQTTProxyFactory(cfg *config.Config, mqttClient MQTT.Client, topicBuilder *TopicBuilder, messageLedger MessageRecorder) (controller.ConnectorClientProxyFactory, error) {
+	proxyFactory := ConnectorClientMQTTProxyFactory{mqttClient: mqttClient, topicBuilder: topicBuilder, config: cfg, ledger: messageLedger}
 	return &proxyFactory, nil
 }
 
@@ -38,6 +39,7 @@ func (ccpf *ConnectorClientMQTTProxyFactory) CreateProxy(ctx context.Context, or
 		CanonicalFacts: canonicalFacts,
 		Dispatchers:    dispatchers,
 		Tags:           tags,
+		MessageLedger:  ccpf.ledger,
 	}
 
 	return &proxy, nil
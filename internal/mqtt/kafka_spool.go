@@ -0,0 +1,463 @@
+package mqtt
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/RedHatInsights/cloud-connector/internal/platform/logger"
+
+	kafka "github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	spoolSegmentSuffix = ".seg"
+	spoolRecordHeader  = 4
+)
+
+var ErrSpoolFull = errors.New("kafka spool is full")
+
+// KafkaMessageWriter is the subset of the kafka.Writer used by the mqtt message handlers
+type KafkaMessageWriter interface {
+	WriteMessages(context.Context, ...kafka.Message) error
+}
+
+type KafkaSpoolConfig struct {
+	Directory       string
+	MaxBytes        int64
+	SegmentMaxBytes int64
+	ReplayInterval  time.Duration
+	ReplayBatchSize int
+}
+
+type spooledHeader struct {
+	Key   string `json:"key"`
+	Value []byte `json:"value"`
+}
+
+type spooledMessage struct {
+	Key     []byte          `json:"key"`
+	Value   []byte          `json:"value"`
+	Headers []spooledHeader `json:"headers"`
+}
+
+type spoolSegment struct {
+	id       uint64
+	path     string
+	size     int64
+	messages int
+}
+
+// SpoolingKafkaWriter writes messages to kafka.  If kafka is unavailable, the messages
+// are appended to on-disk segment files and replayed in the order they were received
+// once kafka is available again.  Once anything has been spooled, all new messages are
+// spooled as well until the spool has been drained.  This keeps the messages for
+// a client id in order.
+type SpoolingKafkaWriter struct {
+	writer KafkaMessageWriter
+	cfg    KafkaSpoolConfig
+
+	lock          sync.Mutex
+	segments      []*spoolSegment
+	activeFile    *os.File
+	totalBytes    int64
+	totalMessages int
+
+	// Position of the replay within the oldest segment
+	replayOffset   int64
+	replayMessages int
+}
+
+func NewSpoolingKafkaWriter(writer KafkaMessageWriter, cfg KafkaSpoolConfig) (*SpoolingKafkaWriter, error) {
+
+	if err := os.MkdirAll(cfg.Directory, 0700); err != nil {
+		return nil, fmt.Errorf("unable to create kafka spool directory: %w", err)
+	}
+
+	spool := &SpoolingKafkaWriter{writer: writer, cfg: cfg}
+
+	if err := spool.loadSegments(); err != nil {
+		return nil, err
+	}
+
+	spool.updateMetrics()
+
+	return spool, nil
+}
+
+func (s *SpoolingKafkaWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+
+	if s.isEmpty() {
+		err := s.writer.WriteMessages(ctx, msgs...)
+		if err == nil || errors.Is(err, context.Canceled) {
+			return err
+		}
+
+		logger.Log.WithFields(logrus.Fields{"error": err}).Warn("Error writing to kafka, spooling messages to disk")
+	}
+
+	return s.append(msgs)
+}
+
+// Depth returns the number of messages that are waiting to be replayed
+func (s *SpoolingKafkaWriter) Depth() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.totalMessages - s.replayMessages
+}
+
+// StartReplayer periodically replays the spooled messages until the context is cancelled
+func (s *SpoolingKafkaWriter) StartReplayer(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(s.cfg.ReplayInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := s.Replay(ctx); err != nil && !errors.Is(err, context.Canceled) {
+					logger.Log.WithFields(logrus.Fields{"error": err, "depth": s.Depth()}).Warn("Unable to replay spooled messages to kafka")
+				}
+			}
+		}
+	}()
+}
+
+// Replay writes the spooled messages to kafka, oldest first, until the spool is empty
+func (s *SpoolingKafkaWriter) Replay(ctx context.Context) error {
+	for {
+		segment, offset, limit := s.nextReplayBatch()
+		if segment == nil {
+			return nil
+		}
+
+		msgs, bytesRead, err := readSpoolSegment(segment.path, offset, limit, s.cfg.ReplayBatchSize)
+		if err != nil {
+			return err
+		}
+
+		if len(msgs) == 0 && offset < limit {
+			return fmt.Errorf("unable to read kafka spool segment %s at offset %d", segment.path, offset)
+		}
+
+		if len(msgs) > 0 {
+			if err := s.writer.WriteMessages(ctx, msgs...); err != nil {
+				return err
+			}
+
+			metrics.kafkaSpoolReplayedCounter.Add(float64(len(msgs)))
+		}
+
+		if err := s.advance(segment, bytesRead, len(msgs)); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *SpoolingKafkaWriter) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.activeFile == nil {
+		return nil
+	}
+
+	err := s.activeFile.Close()
+	s.activeFile = nil
+	return err
+}
+
+func (s *SpoolingKafkaWriter) isEmpty() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return len(s.segments) == 0
+}
+
+func (s *SpoolingKafkaWriter) append(msgs []kafka.Message) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	records := make([][]byte, 0, len(msgs))
+	var recordBytes int64
+
+	for _, msg := range msgs {
+		record, err := encodeSpoolRecord(msg)
+		if err != nil {
+			return err
+		}
+		records = append(records, record)
+		recordBytes += int64(len(record))
+	}
+
+	if s.totalBytes+recordBytes > s.cfg.MaxBytes {
+		metrics.kafkaSpoolRejectedCounter.Add(float64(len(msgs)))
+		return ErrSpoolFull
+	}
+
+	segment, err := s.activeSegment(recordBytes)
+	if err != nil {
+		return err
+	}
+
+	for _, record := range records {
+		if _, err := s.activeFile.Write(record); err != nil {
+			return s.truncateActiveSegment(segment, fmt.Errorf("unable to write to kafka spool: %w", err))
+		}
+	}
+
+	if err := s.activeFile.Sync(); err != nil {
+		return s.truncateActiveSegment(segment, fmt.Errorf("unable to sync kafka spool: %w", err))
+	}
+
+	segment.size += recordBytes
+	segment.messages += len(msgs)
+	s.totalBytes += recordBytes
+	s.totalMessages += len(msgs)
+
+	metrics.kafkaSpooledCounter.Add(float64(len(msgs)))
+	s.updateMetrics()
+
+	return nil
+}
+
+// truncateActiveSegment drops any partially written records from the end of the active
+// segment so that the records appended after a failed write are still readable
+func (s *SpoolingKafkaWriter) truncateActiveSegment(segment *spoolSegment, writeErr error) error {
+	if err := s.activeFile.Truncate(segment.size); err != nil {
+		return errors.Join(writeErr, fmt.Errorf("unable to truncate kafka spool segment: %w", err))
+	}
+	return writeErr
+}
+
+// activeSegment returns the segment new records should be appended to, rolling over
+// to a new segment when the current one would grow past the configured size
+func (s *SpoolingKafkaWriter) activeSegment(recordBytes int64) (*spoolSegment, error) {
+
+	if len(s.segments) > 0 && s.activeFile != nil {
+		segment := s.segments[len(s.segments)-1]
+		if segment.size == 0 || segment.size+recordBytes <= s.cfg.SegmentMaxBytes {
+			return segment, nil
+		}
+
+		if err := s.activeFile.Close(); err != nil {
+			return nil, fmt.Errorf("unable to close kafka spool segment: %w", err)
+		}
+		s.activeFile = nil
+	}
+
+	var id uint64
+	if len(s.segments) > 0 {
+		id = s.segments[len(s.segments)-1].id + 1
+	}
+
+	segment := &spoolSegment{
+		id:   id,
+		path: filepath.Join(s.cfg.Directory, fmt.Sprintf("%020d%s", id, spoolSegmentSuffix)),
+	}
+
+	file, err := os.OpenFile(segment.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create kafka spool segment: %w", err)
+	}
+
+	s.activeFile = file
+	s.segments = append(s.segments, segment)
+
+	return segment, nil
+}
+
+func (s *SpoolingKafkaWriter) nextReplayBatch() (*spoolSegment, int64, int64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if len(s.segments) == 0 {
+		return nil, 0, 0
+	}
+
+	segment := s.segments[0]
+	return segment, s.replayOffset, segment.size
+}
+
+func (s *SpoolingKafkaWriter) advance(segment *spoolSegment, bytesRead int64, messagesRead int) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.replayOffset += bytesRead
+	s.replayMessages += messagesRead
+
+	defer s.updateMetrics()
+
+	if s.replayOffset < segment.size {
+		return nil
+	}
+
+	// The segment has been replayed completely
+	if len(s.segments) == 1 && s.activeFile != nil {
+		if err := s.activeFile.Close(); err != nil {
+			return fmt.Errorf("unable to close kafka spool segment: %w", err)
+		}
+		s.activeFile = nil
+	}
+
+	if err := os.Remove(segment.path); err != nil {
+		return fmt.Errorf("unable to remove kafka spool segment: %w", err)
+	}
+
+	s.segments = s.segments[1:]
+	s.totalBytes -= segment.size
+	s.totalMessages -= segment.messages
+	s.replayOffset = 0
+	s.replayMessages = 0
+
+	return nil
+}
+
+// loadSegments picks up any segments that were left behind by a previous process.
+// Messages in a partially replayed segment will be replayed again.
+func (s *SpoolingKafkaWriter) loadSegments() error {
+
+	entries, err := os.ReadDir(s.cfg.Directory)
+	if err != nil {
+		return fmt.Errorf("unable to read kafka spool directory: %w", err)
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), spoolSegmentSuffix) {
+			continue
+		}
+
+		var id uint64
+		if _, err := fmt.Sscanf(strings.TrimSuffix(entry.Name(), spoolSegmentSuffix), "%d", &id); err != nil {
+			continue
+		}
+
+		path := filepath.Join(s.cfg.Directory, entry.Name())
+
+		msgs, size, err := readSpoolSegment(path, 0, -1, -1)
+		if err != nil {
+			return err
+		}
+
+		if len(msgs) == 0 {
+			os.Remove(path)
+			continue
+		}
+
+		s.segments = append(s.segments, &spoolSegment{id: id, path: path, size: size, messages: len(msgs)})
+		s.totalBytes += size
+		s.totalMessages += len(msgs)
+	}
+
+	sort.Slice(s.segments, func(i, j int) bool { return s.segments[i].id < s.segments[j].id })
+
+	if len(s.segments) > 0 {
+		logger.Log.WithFields(logrus.Fields{"segments": len(s.segments), "depth": s.totalMessages}).Info("Found spooled kafka messages")
+	}
+
+	return nil
+}
+
+func (s *SpoolingKafkaWriter) updateMetrics() {
+	metrics.kafkaSpoolDepthGauge.Set(float64(s.totalMessages - s.replayMessages))
+	metrics.kafkaSpoolBytesGauge.Set(float64(s.totalBytes))
+	metrics.kafkaSpoolSegmentsGauge.Set(float64(len(s.segments)))
+}
+
+func encodeSpoolRecord(msg kafka.Message) ([]byte, error) {
+
+	spooled := spooledMessage{Key: msg.Key, Value: msg.Value}
+	for _, header := range msg.Headers {
+		spooled.Headers = append(spooled.Headers, spooledHeader{Key: header.Key, Value: header.Value})
+	}
+
+	payload, err := json.Marshal(spooled)
+	if err != nil {
+		return nil, fmt.Errorf("unable to encode spooled message: %w", err)
+	}
+
+	record := make([]byte, spoolRecordHeader+len(payload))
+	binary.BigEndian.PutUint32(record, uint32(len(payload)))
+	copy(record[spoolRecordHeader:], payload)
+
+	return record, nil
+}
+
+// readSpoolSegment reads up to maxMessages records, starting at offset and stopping at limit.
+// A negative limit or maxMessages reads the whole segment.  A truncated record at the end of
+// the segment (from a crash during a write) is ignored.  A record length that runs past the
+// end of the segment is treated the same way, so a corrupt header can not cause a huge allocation.
+func readSpoolSegment(path string, offset int64, limit int64, maxMessages int) ([]kafka.Message, int64, error) {
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, 0, fmt.Errorf("unable to open kafka spool segment: %w", err)
+	}
+	defer file.Close()
+
+	if limit < 0 {
+		info, err := file.Stat()
+		if err != nil {
+			return nil, 0, fmt.Errorf("unable to stat kafka spool segment: %w", err)
+		}
+		limit = info.Size()
+	}
+
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return nil, 0, fmt.Errorf("unable to seek kafka spool segment: %w", err)
+	}
+
+	reader := bufio.NewReader(file)
+
+	var msgs []kafka.Message
+	var bytesRead int64
+	header := make([]byte, spoolRecordHeader)
+
+	for maxMessages < 0 || len(msgs) < maxMessages {
+		remaining := limit - (offset + bytesRead)
+		if remaining < spoolRecordHeader {
+			break
+		}
+
+		if _, err := io.ReadFull(reader, header); err != nil {
+			break
+		}
+
+		payloadLength := int64(binary.BigEndian.Uint32(header))
+		if payloadLength > remaining-spoolRecordHeader {
+			break
+		}
+
+		payload := make([]byte, payloadLength)
+		if _, err := io.ReadFull(reader, payload); err != nil {
+			break
+		}
+
+		var spooled spooledMessage
+		if err := json.Unmarshal(payload, &spooled); err != nil {
+			return nil, 0, fmt.Errorf("unable to decode spooled message: %w", err)
+		}
+
+		msg := kafka.Message{Key: spooled.Key, Value: spooled.Value}
+		for _, h := range spooled.Headers {
+			msg.Headers = append(msg.Headers, kafka.Header{Key: h.Key, Value: h.Value})
+		}
+
+		msgs = append(msgs, msg)
+		bytesRead += int64(spoolRecordHeader + len(payload))
+	}
+
+	return msgs, bytesRead, nil
+}
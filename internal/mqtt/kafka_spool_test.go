@@ -0,0 +1,246 @@
+package mqtt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	kafka "github.com/segmentio/kafka-go"
+)
+
+type fakeKafkaWriter struct {
+	err      error
+	messages []kafka.Message
+}
+
+func (w *fakeKafkaWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	if w.err != nil {
+		return w.err
+	}
+	w.messages = append(w.messages, msgs...)
+	return nil
+}
+
+func buildSpoolTestMessage(clientID string, i int) kafka.Message {
+	return kafka.Message{
+		Key:     []byte(clientID),
+		Value:   []byte(fmt.Sprintf("payload-%d", i)),
+		Headers: []kafka.Header{{Key: MessageIDKafkaHeaderKey, Value: []byte(fmt.Sprintf("%d", i))}},
+	}
+}
+
+func buildSpoolTestConfig(t *testing.T) KafkaSpoolConfig {
+	return KafkaSpoolConfig{
+		Directory:       t.TempDir(),
+		MaxBytes:        1024 * 1024,
+		SegmentMaxBytes: 256,
+		ReplayInterval:  time.Second,
+		ReplayBatchSize: 3,
+	}
+}
+
+func TestSpoolingKafkaWriterReplaysInOrder(t *testing.T) {
+
+	writer := &fakeKafkaWriter{err: errors.New("kafka is down")}
+
+	spool, err := NewSpoolingKafkaWriter(writer, buildSpoolTestConfig(t))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer spool.Close()
+
+	for i := 0; i < 10; i++ {
+		if err := spool.WriteMessages(context.TODO(), buildSpoolTestMessage("client-1", i)); err != nil {
+			t.Fatalf("unexpected error spooling message: %s", err)
+		}
+
+		// Once kafka is back, messages must still be spooled until the spool is drained
+		if i == 5 {
+			writer.err = nil
+		}
+	}
+
+	if len(writer.messages) != 0 {
+		t.Fatalf("messages should not have been written directly to kafka while the spool was not empty")
+	}
+
+	if spool.Depth() != 10 {
+		t.Fatalf("unexpected spool depth: %d", spool.Depth())
+	}
+
+	segments, _ := filepath.Glob(filepath.Join(spool.cfg.Directory, "*"+spoolSegmentSuffix))
+	if len(segments) < 2 {
+		t.Fatalf("expected the spool to roll over to multiple segments, found %d", len(segments))
+	}
+
+	if err := spool.Replay(context.TODO()); err != nil {
+		t.Fatalf("unexpected error replaying spool: %s", err)
+	}
+
+	if spool.Depth() != 0 {
+		t.Fatalf("unexpected spool depth after replay: %d", spool.Depth())
+	}
+
+	if len(writer.messages) != 10 {
+		t.Fatalf("unexpected number of replayed messages: %d", len(writer.messages))
+	}
+
+	for i, msg := range writer.messages {
+		if string(msg.Value) != fmt.Sprintf("payload-%d", i) || string(msg.Key) != "client-1" {
+			t.Fatalf("message %d replayed out of order: %s", i, msg.Value)
+		}
+
+		if string(msg.Headers[0].Value) != fmt.Sprintf("%d", i) {
+			t.Fatalf("message %d header was not preserved", i)
+		}
+	}
+
+	segments, _ = filepath.Glob(filepath.Join(spool.cfg.Directory, "*"+spoolSegmentSuffix))
+	if len(segments) != 0 {
+		t.Fatalf("replayed segments should have been removed, found %d", len(segments))
+	}
+
+	// Now that the spool is empty, messages should go straight to kafka
+	if err := spool.WriteMessages(context.TODO(), buildSpoolTestMessage("client-1", 10)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if len(writer.messages) != 11 || spool.Depth() != 0 {
+		t.Fatalf("message should have been written directly to kafka")
+	}
+}
+
+func TestSpoolingKafkaWriterReplayFailureKeepsMessages(t *testing.T) {
+
+	writer := &fakeKafkaWriter{err: errors.New("kafka is down")}
+
+	spool, err := NewSpoolingKafkaWriter(writer, buildSpoolTestConfig(t))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer spool.Close()
+
+	for i := 0; i < 4; i++ {
+		spool.WriteMessages(context.TODO(), buildSpoolTestMessage("client-1", i))
+	}
+
+	if err := spool.Replay(context.TODO()); err == nil {
+		t.Fatal("expected an error replaying while kafka is down")
+	}
+
+	if spool.Depth() != 4 {
+		t.Fatalf("unexpected spool depth: %d", spool.Depth())
+	}
+}
+
+func TestSpoolingKafkaWriterFull(t *testing.T) {
+
+	writer := &fakeKafkaWriter{err: errors.New("kafka is down")}
+
+	cfg := buildSpoolTestConfig(t)
+	cfg.MaxBytes = 200
+
+	spool, err := NewSpoolingKafkaWriter(writer, cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer spool.Close()
+
+	var spoolErr error
+	for i := 0; i < 10 && spoolErr == nil; i++ {
+		spoolErr = spool.WriteMessages(context.TODO(), buildSpoolTestMessage("client-1", i))
+	}
+
+	if errors.Is(spoolErr, ErrSpoolFull) == false {
+		t.Fatalf("expected the spool to fill up, got %v", spoolErr)
+	}
+}
+
+func TestSpoolingKafkaWriterCanceledContextIsNotSpooled(t *testing.T) {
+
+	writer := &fakeKafkaWriter{err: context.Canceled}
+
+	spool, err := NewSpoolingKafkaWriter(writer, buildSpoolTestConfig(t))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer spool.Close()
+
+	if err := spool.WriteMessages(context.TODO(), buildSpoolTestMessage("client-1", 0)); errors.Is(err, context.Canceled) == false {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+
+	if spool.Depth() != 0 {
+		t.Fatalf("unexpected spool depth: %d", spool.Depth())
+	}
+}
+
+func TestSpoolingKafkaWriterLoadsExistingSegments(t *testing.T) {
+
+	writer := &fakeKafkaWriter{err: errors.New("kafka is down")}
+	cfg := buildSpoolTestConfig(t)
+
+	spool, err := NewSpoolingKafkaWriter(writer, cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	for i := 0; i < 5; i++ {
+		spool.WriteMessages(context.TODO(), buildSpoolTestMessage("client-1", i))
+	}
+	spool.Close()
+
+	// Simulate a crash in the middle of writing a record
+	segments, _ := filepath.Glob(filepath.Join(cfg.Directory, "*"+spoolSegmentSuffix))
+	f, _ := os.OpenFile(segments[len(segments)-1], os.O_WRONLY|os.O_APPEND, 0600)
+	f.Write([]byte{0, 0, 0, 42, '{'})
+	f.Close()
+
+	writer.err = nil
+
+	restartedSpool, err := NewSpoolingKafkaWriter(writer, cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer restartedSpool.Close()
+
+	if restartedSpool.Depth() != 5 {
+		t.Fatalf("unexpected spool depth after restart: %d", restartedSpool.Depth())
+	}
+
+	if err := restartedSpool.Replay(context.TODO()); err != nil {
+		t.Fatalf("unexpected error replaying spool: %s", err)
+	}
+
+	if len(writer.messages) != 5 {
+		t.Fatalf("unexpected number of replayed messages: %d", len(writer.messages))
+	}
+}
+
+func TestReadSpoolSegmentIgnoresRecordLengthPastEndOfSegment(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "corrupt"+spoolSegmentSuffix)
+
+	record, err := encodeSpoolRecord(buildSpoolTestMessage("client-1", 0))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// A corrupt header claiming a ~4GB record follows the valid record
+	if err := os.WriteFile(path, append(record, 0xff, 0xff, 0xff, 0xff, '{'), 0600); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	msgs, bytesRead, err := readSpoolSegment(path, 0, -1, -1)
+	if err != nil {
+		t.Fatalf("unexpected error reading spool segment: %s", err)
+	}
+
+	if len(msgs) != 1 || bytesRead != int64(len(record)) {
+		t.Fatalf("unexpected result reading spool segment: %d messages, %d bytes", len(msgs), bytesRead)
+	}
+}
@@ -20,7 +20,7 @@ const (
 	DateReceivedHeaderKey   = "date_received"
 )
 
-func ControlMessageHandler(ctx context.Context, kafkaWriter *kafka.Writer, topicVerifier *TopicVerifier) func(MQTT.Client, MQTT.Message) {
+func ControlMessageHandler(ctx context.Context, kafkaWriter KafkaMessageWriter, topicVerifier *TopicVerifier) func(MQTT.Client, MQTT.Message) {
 	return func(client MQTT.Client, message MQTT.Message) {
 
 		metrics.kafkaWriterGoRoutineGauge.Inc()
@@ -28,64 +28,89 @@ func ControlMessageHandler(ctx context.Context, kafkaWriter *kafka.Writer, topic
 
 		metrics.controlMessageReceivedCounter.Inc()
 
-		mqttMessageID := fmt.Sprintf("%d", message.MessageID())
+		writeMessageToKafka(ctx, kafkaWriter, topicVerifier, message)
+	}
+}
 
-		_, clientID, err := topicVerifier.VerifyIncomingTopic(message.Topic())
-		if err != nil {
-			logger.Log.WithFields(logrus.Fields{"error": err}).Error("Failed to verify topic")
-			return
-		}
+// ForwardDataMessageHandler returns a function that writes data messages to kafka
+// so that they can be routed to the kafka topic registered for the message's directive
+func ForwardDataMessageHandler(ctx context.Context, kafkaWriter KafkaMessageWriter, topicVerifier *TopicVerifier) func(MQTT.Client, MQTT.Message) {
+	return func(client MQTT.Client, message MQTT.Message) {
 
-		log := logger.Log.WithFields(logrus.Fields{"client_id": clientID,
-			"mqtt_message_id": mqttMessageID,
-			"duplicate":       message.Duplicate(),
-			"topic":           message.Topic()})
+		metrics.kafkaWriterGoRoutineGauge.Inc()
+		defer metrics.kafkaWriterGoRoutineGauge.Dec()
 
-		if len(message.Payload()) == 0 {
-			// This will happen when a retained message is removed
-			// This can also happen when rhcd is "priming the pump" as required by the akamai broker
-			log.Trace("client sent an empty payload")
-			return
-		}
+		metrics.dataMessageReceivedCounter.Inc()
 
-		kafkwWriteDurationTimer := prometheus.NewTimer(metrics.kafkaWriterPublishDuration)
+		writeMessageToKafka(ctx, kafkaWriter, topicVerifier, message)
+	}
+}
 
-		// Use the client id as the message key.  All messages with the same key,
-		// get sent to the same partitions.  This is important so that the ordering
-		// of the messages is retained.
-		err = kafkaWriter.WriteMessages(ctx,
-			kafka.Message{
-				Headers: []kafka.Header{
-					{TopicKafkaHeaderKey, []byte(message.Topic())},
-					{MessageIDKafkaHeaderKey, []byte(mqttMessageID)},
-					{DateReceivedHeaderKey, []byte(time.Now().UTC().Format(time.RFC3339Nano))},
-				},
-				Key:   []byte(clientID),
-				Value: message.Payload(),
-			})
+func writeMessageToKafka(ctx context.Context, kafkaWriter KafkaMessageWriter, topicVerifier *TopicVerifier, message MQTT.Message) {
 
-		kafkwWriteDurationTimer.ObserveDuration()
+	mqttMessageID := fmt.Sprintf("%d", message.MessageID())
 
-		log.Debug("MQTT message written to kafka")
+	_, clientID, err := topicVerifier.VerifyIncomingTopic(message.Topic())
+	if err != nil {
+		logger.Log.WithFields(logrus.Fields{"error": err}).Error("Failed to verify topic")
+		return
+	}
 
-		if err != nil {
-			log.WithFields(logrus.Fields{"error": err}).Error("Error writing MQTT message to kafka")
-
-			if errors.Is(err, context.Canceled) == true {
-				// The context was canceled.  This likely happened due to the process shutting down,
-				// so just return and allow things to shutdown cleanly
-				return
-			}
-
-			// This is gross, but we need to try to push the log messages to cloudwatch
-			// before the panic is triggered below.
-			logger.FlushLogger()
-
-			// If writing to kafka fails, then just fall over and do not read anymore
-			// messages from the mqtt broker.  We need to panic here so that the mqtt broker
-			// is not sent an ACK for the message.
-			log.Fatal("Failed writing to kafka")
+	log := logger.Log.WithFields(logrus.Fields{"client_id": clientID,
+		"mqtt_message_id": mqttMessageID,
+		"duplicate":       message.Duplicate(),
+		"topic":           message.Topic()})
+
+	if len(message.Payload()) == 0 {
+		// This will happen when a retained message is removed
+		// This can also happen when rhcd is "priming the pump" as required by the akamai broker
+		log.Trace("client sent an empty payload")
+		return
+	}
+
+	kafkwWriteDurationTimer := prometheus.NewTimer(metrics.kafkaWriterPublishDuration)
+
+	// Use the client id as the message key.  All messages with the same key,
+	// get sent to the same partitions.  This is important so that the ordering
+	// of the messages is retained.
+	err = kafkaWriter.WriteMessages(ctx,
+		kafka.Message{
+			Headers: []kafka.Header{
+				{TopicKafkaHeaderKey, []byte(message.Topic())},
+				{MessageIDKafkaHeaderKey, []byte(mqttMessageID)},
+				{DateReceivedHeaderKey, []byte(time.Now().UTC().Format(time.RFC3339Nano))},
+			},
+			Key:   []byte(clientID),
+			Value: message.Payload(),
+		})
+
+	kafkwWriteDurationTimer.ObserveDuration()
+
+	log.Debug("MQTT message written to kafka")
+
+	if err != nil {
+		log.WithFields(logrus.Fields{"error": err}).Error("Error writing MQTT message to kafka")
+
+		if errors.Is(err, context.Canceled) == true {
+			// The context was canceled.  This likely happened due to the process shutting down,
+			// so just return and allow things to shutdown cleanly
+			return
 		}
+
+		if errors.Is(err, ErrSpoolFull) == true {
+			// The spool is bounded.  Once it fills up, drop the message rather than
+			// crash looping while kafka is unavailable.
+			return
+		}
+
+		// This is gross, but we need to try to push the log messages to cloudwatch
+		// before the panic is triggered below.
+		logger.FlushLogger()
+
+		// If writing to kafka (or to the spool) fails, then just fall over and do not
+		// read anymore messages from the mqtt broker.  We need to panic here so that
+		// the mqtt broker is not sent an ACK for the message.
+		log.Fatal("Failed writing to kafka")
 	}
 }
 
@@ -12,8 +12,16 @@ type mqttMetrics struct {
 	sentMessageDirectiveCounter    *prometheus.CounterVec
 	messagePublishedSuccessCounter prometheus.Counter
 	messagePublishedFailureCounter prometheus.Counter
+	mqttPublishReasonCodeCounter   *prometheus.CounterVec
+	messageLedgerFailureCounter    prometheus.Counter
 	kafkaWriterGoRoutineGauge      prometheus.Gauge
 	kafkaWriterPublishDuration     prometheus.Histogram
+	kafkaSpoolDepthGauge           prometheus.Gauge
+	kafkaSpoolBytesGauge           prometheus.Gauge
+	kafkaSpoolSegmentsGauge        prometheus.Gauge
+	kafkaSpooledCounter            prometheus.Counter
+	kafkaSpoolReplayedCounter      prometheus.Counter
+	kafkaSpoolRejectedCounter      prometheus.Counter
 }
 
 func newMqttMetrics() *mqttMetrics {
@@ -49,6 +57,16 @@ func newMqttMetrics() *mqttMetrics {
 		Help: "The number of messages published failures",
 	})
 
+	metrics.mqttPublishReasonCodeCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cloud_connector_mqtt_message_published_reason_code_count",
+		Help: "The number of MQTT 5 publish acknowledgements per reason code",
+	}, []string{"reason_code"})
+
+	metrics.messageLedgerFailureCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "cloud_connector_mqtt_message_ledger_failure_count",
+		Help: "The number of sent messages that could not be recorded in the message ledger",
+	})
+
 	metrics.kafkaWriterGoRoutineGauge = promauto.NewGauge(prometheus.GaugeOpts{
 		Name: "cloud_connector_mqtt_message_consumer_kafka_writer_go_routine_count",
 		Help: "The total number of active kafka writer go routines for the mqtt message consumer",
@@ -59,6 +77,36 @@ func newMqttMetrics() *mqttMetrics {
 		Help: "The amount of time the mqtt consumer spends waiting on a kafka write",
 	})
 
+	metrics.kafkaSpoolDepthGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "cloud_connector_mqtt_message_consumer_kafka_spool_depth",
+		Help: "The number of messages waiting in the kafka spool to be replayed",
+	})
+
+	metrics.kafkaSpoolBytesGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "cloud_connector_mqtt_message_consumer_kafka_spool_bytes",
+		Help: "The number of bytes used by the kafka spool segments",
+	})
+
+	metrics.kafkaSpoolSegmentsGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "cloud_connector_mqtt_message_consumer_kafka_spool_segment_count",
+		Help: "The number of kafka spool segment files",
+	})
+
+	metrics.kafkaSpooledCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "cloud_connector_mqtt_message_consumer_kafka_spooled_count",
+		Help: "The number of messages written to the kafka spool",
+	})
+
+	metrics.kafkaSpoolReplayedCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "cloud_connector_mqtt_message_consumer_kafka_spool_replayed_count",
+		Help: "The number of spooled messages replayed to kafka",
+	})
+
+	metrics.kafkaSpoolRejectedCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "cloud_connector_mqtt_message_consumer_kafka_spool_rejected_count",
+		Help: "The number of messages rejected because the kafka spool was full",
+	})
+
 	return metrics
 }
 
@@ -0,0 +1,428 @@
+package mqtt
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"time"
+
+	"github.com/RedHatInsights/cloud-connector/internal/platform/logger"
+
+	"github.com/eclipse/paho.golang/autopaho"
+	"github.com/eclipse/paho.golang/paho"
+	MQTT "github.com/eclipse/paho.mqtt.golang"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	MQTTProtocolVersion5 = 5
+
+	// mqtt5SessionExpiryInterval determines how long (in seconds) the broker holds on to a
+	// non-clean session after the connection drops.  This is long enough to ride
+	// out a pod restart.
+	mqtt5SessionExpiryInterval = 60 * 60
+
+	messageIDUserPropertyKey = "message_id"
+
+	// mqtt5OperationTimeout caps the time spent waiting on the broker to acknowledge a
+	// publish, subscribe or unsubscribe when the client options do not set a write timeout
+	mqtt5OperationTimeout = 30 * time.Second
+)
+
+var errMqtt5ConnectionLost = errors.New("connection to MQTT broker lost")
+
+// PublishProperties are the MQTT 5 properties that can be attached to an outgoing message.
+// The properties are ignored when the broker connection uses an older protocol version.
+type PublishProperties struct {
+	MessageExpiry  time.Duration
+	UserProperties map[string]string
+}
+
+// PropertiesPublisher is implemented by MQTT clients that are able to attach
+// MQTT 5 properties to a published message
+type PropertiesPublisher interface {
+	PublishWithProperties(topic string, qos byte, retained bool, payload interface{}, properties PublishProperties) MQTT.Token
+}
+
+// mqtt5Client adapts the paho MQTT 5 client to the paho MQTT 3.1.1 MQTT.Client interface.
+// This allows the rest of the code base to remain unaware of the protocol version used
+// to talk to the broker.
+type mqtt5Client struct {
+	options           *MQTT.ClientOptions
+	connectionManager *autopaho.ConnectionManager
+	router            *paho.StandardRouter
+	connected         atomic.Bool
+	connectionCount   atomic.Int64
+}
+
+func createMqtt5BrokerConnection(connOpts *MQTT.ClientOptions) (MQTT.Client, error) {
+
+	client := &mqtt5Client{
+		options: connOpts,
+		router:  paho.NewStandardRouter(),
+	}
+
+	if connOpts.DefaultPublishHandler != nil {
+		client.router.DefaultHandler(client.wrapMessageHandler(connOpts.DefaultPublishHandler))
+	}
+
+	clientConfig, err := client.buildClientConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	connectionManager, err := autopaho.NewConnection(context.Background(), clientConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	client.connectionManager = connectionManager
+
+	ctx, cancel := context.WithTimeout(context.Background(), connOpts.ConnectTimeout)
+	defer cancel()
+
+	if err = connectionManager.AwaitConnection(ctx); err != nil {
+		connectionManager.Disconnect(context.Background())
+		return nil, fmt.Errorf("unable to connect to MQTT broker: %w", err)
+	}
+
+	return client, nil
+}
+
+func (c *mqtt5Client) buildClientConfig() (autopaho.ClientConfig, error) {
+
+	if len(c.options.Servers) == 0 {
+		return autopaho.ClientConfig{}, errors.New("no MQTT broker address provided")
+	}
+
+	clientConfig := autopaho.ClientConfig{
+		ServerUrls:                    c.options.Servers,
+		TlsCfg:                        c.options.TLSConfig,
+		KeepAlive:                     uint16(c.options.KeepAlive),
+		CleanStartOnInitialConnection: c.options.CleanSession,
+		ConnectTimeout:                c.options.ConnectTimeout,
+		ConnectPacketBuilder:          c.buildConnectPacket,
+		WebSocketCfg: &autopaho.WebSocketConfig{
+			Header: c.buildWebSocketHeaders,
+		},
+		OnConnectionUp:   c.onConnectionUp,
+		OnConnectionDown: c.onConnectionDown,
+		OnConnectError: func(err error) {
+			logger.Log.WithFields(logrus.Fields{"error": err}).Error("Unable to connect to MQTT broker")
+		},
+		ClientConfig: paho.ClientConfig{
+			ClientID: c.options.ClientID,
+			OnPublishReceived: []func(paho.PublishReceived) (bool, error){
+				func(pr paho.PublishReceived) (bool, error) {
+					if pr.Packet.Properties == nil {
+						pr.Packet.Properties = &paho.PublishProperties{}
+					}
+					c.router.Route(pr.Packet.Packet())
+					return true, nil
+				},
+			},
+			OnServerDisconnect: func(d *paho.Disconnect) {
+				logger.Log.WithFields(logrus.Fields{"reason_code": d.ReasonCode}).Info("MQTT broker sent a disconnect")
+			},
+		},
+	}
+
+	if c.options.CleanSession == false {
+		clientConfig.SessionExpiryInterval = mqtt5SessionExpiryInterval
+	}
+
+	return clientConfig, nil
+}
+
+// buildConnectPacket is called before each connection attempt so that the credentials
+// are read when the connection is made instead of when the client is created
+func (c *mqtt5Client) buildConnectPacket(connect *paho.Connect, serverUrl *url.URL) (*paho.Connect, error) {
+	username, password := c.options.Username, c.options.Password
+	if c.options.CredentialsProvider != nil {
+		username, password = c.options.CredentialsProvider()
+	}
+
+	connect.UsernameFlag = len(username) > 0
+	connect.Username = username
+	connect.PasswordFlag = len(password) > 0
+	connect.Password = []byte(password)
+
+	return connect, nil
+}
+
+// operationContext bounds the time spent waiting on the broker
+func (c *mqtt5Client) operationContext() (context.Context, context.CancelFunc) {
+	timeout := c.options.WriteTimeout
+	if timeout <= 0 {
+		timeout = mqtt5OperationTimeout
+	}
+
+	return context.WithTimeout(context.Background(), timeout)
+}
+
+// buildWebSocketHeaders is called before each connection attempt.  The reconnecting
+// handler is given the chance to update the headers (refresh the JWT, etc) before
+// any attempt other than the first one.
+func (c *mqtt5Client) buildWebSocketHeaders(url *url.URL, tlsCfg *tls.Config) http.Header {
+	if c.connectionCount.Load() > 0 && c.options.OnReconnecting != nil {
+		c.options.OnReconnecting(c, c.options)
+	}
+
+	return c.options.HTTPHeaders
+}
+
+func (c *mqtt5Client) onConnectionUp(cm *autopaho.ConnectionManager, connack *paho.Connack) {
+	c.connected.Store(true)
+	c.connectionCount.Add(1)
+
+	if c.options.OnConnect != nil {
+		// The connection manager does not allow this callback to block...the on-connect
+		// handlers typically wait on subscriptions
+		go c.options.OnConnect(c)
+	}
+}
+
+func (c *mqtt5Client) onConnectionDown() bool {
+	c.connected.Store(false)
+
+	if c.options.OnConnectionLost != nil {
+		c.options.OnConnectionLost(c, errMqtt5ConnectionLost)
+	}
+
+	return c.options.AutoReconnect
+}
+
+func (c *mqtt5Client) wrapMessageHandler(callback MQTT.MessageHandler) paho.MessageHandler {
+	return func(p *paho.Publish) {
+		callback(c, &mqtt5Message{publish: p})
+	}
+}
+
+func (c *mqtt5Client) IsConnected() bool {
+	return c.connected.Load()
+}
+
+func (c *mqtt5Client) IsConnectionOpen() bool {
+	return c.connected.Load()
+}
+
+func (c *mqtt5Client) Connect() MQTT.Token {
+	return newMqtt5Token(func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), c.options.ConnectTimeout)
+		defer cancel()
+		return c.connectionManager.AwaitConnection(ctx)
+	})
+}
+
+func (c *mqtt5Client) Disconnect(quiesce uint) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(quiesce)*time.Millisecond)
+	defer cancel()
+
+	if err := c.connectionManager.Disconnect(ctx); err != nil {
+		logger.Log.WithFields(logrus.Fields{"error": err}).Warn("Unable to cleanly disconnect from MQTT broker")
+	}
+
+	c.connected.Store(false)
+}
+
+func (c *mqtt5Client) Publish(topic string, qos byte, retained bool, payload interface{}) MQTT.Token {
+	return c.PublishWithProperties(topic, qos, retained, payload, PublishProperties{})
+}
+
+func (c *mqtt5Client) PublishWithProperties(topic string, qos byte, retained bool, payload interface{}, properties PublishProperties) MQTT.Token {
+	return newMqtt5Token(func() error {
+		payloadBytes, err := convertPayloadToBytes(payload)
+		if err != nil {
+			return err
+		}
+
+		publish := &paho.Publish{
+			Topic:      topic,
+			QoS:        qos,
+			Retain:     retained,
+			Payload:    payloadBytes,
+			Properties: buildMqtt5PublishProperties(properties),
+		}
+
+		ctx, cancel := c.operationContext()
+		defer cancel()
+
+		response, err := c.connectionManager.Publish(ctx, publish)
+		if err != nil {
+			return err
+		}
+
+		return checkPublishReasonCode(response)
+	})
+}
+
+func (c *mqtt5Client) Subscribe(topic string, qos byte, callback MQTT.MessageHandler) MQTT.Token {
+	return c.SubscribeMultiple(map[string]byte{topic: qos}, callback)
+}
+
+func (c *mqtt5Client) SubscribeMultiple(filters map[string]byte, callback MQTT.MessageHandler) MQTT.Token {
+	return newMqtt5Token(func() error {
+		subscribe := &paho.Subscribe{}
+
+		for topic, qos := range filters {
+			if callback != nil {
+				c.router.RegisterHandler(topic, c.wrapMessageHandler(callback))
+			}
+
+			subscribe.Subscriptions = append(subscribe.Subscriptions, paho.SubscribeOptions{Topic: topic, QoS: qos})
+		}
+
+		ctx, cancel := c.operationContext()
+		defer cancel()
+
+		suback, err := c.connectionManager.Subscribe(ctx, subscribe)
+		if err != nil {
+			return err
+		}
+
+		for i, reasonCode := range suback.Reasons {
+			if reasonCode >= 0x80 {
+				return fmt.Errorf("subscription to topic %s failed with reason code %d", subscribe.Subscriptions[i].Topic, reasonCode)
+			}
+		}
+
+		return nil
+	})
+}
+
+func (c *mqtt5Client) Unsubscribe(topics ...string) MQTT.Token {
+	return newMqtt5Token(func() error {
+		for _, topic := range topics {
+			c.router.UnregisterHandler(topic)
+		}
+
+		ctx, cancel := c.operationContext()
+		defer cancel()
+
+		_, err := c.connectionManager.Unsubscribe(ctx, &paho.Unsubscribe{Topics: topics})
+		return err
+	})
+}
+
+func (c *mqtt5Client) AddRoute(topic string, callback MQTT.MessageHandler) {
+	c.router.RegisterHandler(topic, c.wrapMessageHandler(callback))
+}
+
+func (c *mqtt5Client) OptionsReader() MQTT.ClientOptionsReader {
+	return MQTT.NewOptionsReader(c.options)
+}
+
+func buildMqtt5PublishProperties(properties PublishProperties) *paho.PublishProperties {
+	publishProperties := &paho.PublishProperties{}
+
+	if properties.MessageExpiry > 0 {
+		messageExpiry := uint32(properties.MessageExpiry.Seconds())
+		publishProperties.MessageExpiry = &messageExpiry
+	}
+
+	for key, value := range properties.UserProperties {
+		publishProperties.User.Add(key, value)
+	}
+
+	return publishProperties
+}
+
+func checkPublishReasonCode(response *paho.PublishResponse) error {
+	if response == nil {
+		// QoS 0 messages are not acknowledged
+		return nil
+	}
+
+	metrics.mqttPublishReasonCodeCounter.With(prometheus.Labels{"reason_code": fmt.Sprintf("%d", response.ReasonCode)}).Inc()
+
+	if response.ReasonCode < 0x80 {
+		return nil
+	}
+
+	if response.Properties != nil && response.Properties.ReasonString != "" {
+		return fmt.Errorf("publish failed with reason code %d: %s", response.ReasonCode, response.Properties.ReasonString)
+	}
+
+	return fmt.Errorf("publish failed with reason code %d", response.ReasonCode)
+}
+
+func convertPayloadToBytes(payload interface{}) ([]byte, error) {
+	switch p := payload.(type) {
+	case string:
+		return []byte(p), nil
+	case []byte:
+		return p, nil
+	case bytes.Buffer:
+		return p.Bytes(), nil
+	case *bytes.Buffer:
+		return p.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("unknown payload type %T", payload)
+	}
+}
+
+type mqtt5Token struct {
+	done chan struct{}
+	err  error
+}
+
+func newMqtt5Token(operation func() error) *mqtt5Token {
+	token := &mqtt5Token{done: make(chan struct{})}
+
+	go func() {
+		defer close(token.done)
+		token.err = operation()
+	}()
+
+	return token
+}
+
+func (t *mqtt5Token) Wait() bool {
+	<-t.done
+	return true
+}
+
+func (t *mqtt5Token) WaitTimeout(timeout time.Duration) bool {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-t.done:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+func (t *mqtt5Token) Done() <-chan struct{} {
+	return t.done
+}
+
+func (t *mqtt5Token) Error() error {
+	select {
+	case <-t.done:
+		return t.err
+	default:
+		return nil
+	}
+}
+
+type mqtt5Message struct {
+	publish *paho.Publish
+}
+
+func (m *mqtt5Message) Duplicate() bool   { return m.publish.Duplicate() }
+func (m *mqtt5Message) Qos() byte         { return m.publish.QoS }
+func (m *mqtt5Message) Retained() bool    { return m.publish.Retain }
+func (m *mqtt5Message) Topic() string     { return m.publish.Topic }
+func (m *mqtt5Message) MessageID() uint16 { return m.publish.PacketID }
+func (m *mqtt5Message) Payload() []byte   { return m.publish.Payload }
+
+// Ack is a no-op.  The MQTT 5 client acknowledges the message once the handler returns.
+func (m *mqtt5Message) Ack() {}
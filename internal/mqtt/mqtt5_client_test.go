@@ -0,0 +1,126 @@
+package mqtt
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/eclipse/paho.golang/paho"
+)
+
+func TestBuildMqtt5PublishProperties(t *testing.T) {
+
+	properties := buildMqtt5PublishProperties(PublishProperties{
+		MessageExpiry:  90 * time.Second,
+		UserProperties: map[string]string{messageIDUserPropertyKey: "1234"},
+	})
+
+	if properties.MessageExpiry == nil || *properties.MessageExpiry != 90 {
+		t.Fatalf("unexpected message expiry: %v", properties.MessageExpiry)
+	}
+
+	if properties.User.Get(messageIDUserPropertyKey) != "1234" {
+		t.Fatalf("message id user property was not set")
+	}
+
+	properties = buildMqtt5PublishProperties(PublishProperties{})
+
+	if properties.MessageExpiry != nil {
+		t.Fatalf("message expiry should not be set")
+	}
+}
+
+func TestCheckPublishReasonCode(t *testing.T) {
+
+	testCases := []struct {
+		response    *paho.PublishResponse
+		expectError bool
+	}{
+		{nil, false},
+		{&paho.PublishResponse{ReasonCode: 0x00}, false},
+		{&paho.PublishResponse{ReasonCode: 0x10}, false},
+		{&paho.PublishResponse{ReasonCode: 0x87}, true},
+		{&paho.PublishResponse{ReasonCode: 0x97, Properties: &paho.PublishResponseProperties{ReasonString: "quota exceeded"}}, true},
+	}
+
+	for _, tc := range testCases {
+		err := checkPublishReasonCode(tc.response)
+
+		if tc.expectError && err == nil {
+			t.Errorf("expected an error for response %+v", tc.response)
+		} else if !tc.expectError && err != nil {
+			t.Errorf("unexpected error for response %+v: %s", tc.response, err)
+		}
+	}
+}
+
+func TestConvertPayloadToBytes(t *testing.T) {
+
+	testCases := []interface{}{
+		"fred",
+		[]byte("fred"),
+		*bytes.NewBufferString("fred"),
+		bytes.NewBufferString("fred"),
+	}
+
+	for _, payload := range testCases {
+		converted, err := convertPayloadToBytes(payload)
+		if err != nil {
+			t.Fatalf("unexpected error converting %T: %s", payload, err)
+		}
+
+		if string(converted) != "fred" {
+			t.Errorf("unexpected payload after converting %T: %s", payload, converted)
+		}
+	}
+
+	if _, err := convertPayloadToBytes(1234); err == nil {
+		t.Error("expected an error for an unsupported payload type")
+	}
+}
+
+func TestMqtt5TokenWaitTimeout(t *testing.T) {
+
+	release := make(chan struct{})
+
+	token := newMqtt5Token(func() error {
+		<-release
+		return nil
+	})
+
+	if token.WaitTimeout(10 * time.Millisecond) {
+		t.Fatal("token should not have completed")
+	}
+
+	close(release)
+
+	if token.WaitTimeout(time.Second) == false {
+		t.Fatal("token should have completed")
+	}
+
+	if token.Error() != nil {
+		t.Fatalf("unexpected error: %s", token.Error())
+	}
+}
+
+func TestBuildConnectPacketReadsCurrentCredentials(t *testing.T) {
+
+	options, err := NewBrokerOptions("tcp://localhost:1883", WithUsernameAndPassword("fred", "yabba"))
+	if err != nil {
+		t.Fatal("unexpected error building options", err)
+	}
+
+	client := &mqtt5Client{options: options}
+
+	// The password is rotated after the client is created
+	options.SetPassword("dabba-doo")
+
+	connect, err := client.buildConnectPacket(&paho.Connect{}, nil)
+	if err != nil {
+		t.Fatal("unexpected error building connect packet", err)
+	}
+
+	if connect.Username != "fred" || string(connect.Password) != "dabba-doo" || !connect.UsernameFlag || !connect.PasswordFlag {
+		t.Fatalf("unexpected credentials in connect packet: %+v", connect)
+	}
+}
@@ -14,6 +14,7 @@ const (
 	controlMessageOutgoingTopic string = "insights/%s/control/in"
 	dataMessageIncomingTopic    string = "insights/+/data/out"
 	dataMessageOutgoingTopic    string = "insights/%s/data/in"
+	sharedSubscriptionPrefix    string = "$share/%s/"
 )
 
 type TopicType int8
@@ -71,7 +72,17 @@ func NewTopicBuilder(prefix string) *TopicBuilder {
 }
 
 type TopicBuilder struct {
-	prefix string
+	prefix                  string
+	sharedSubscriptionGroup string
+}
+
+// WithSharedSubscriptionGroup causes the incoming wildcard topics to be built as
+// shared subscriptions ($share/<group>/...).  The broker distributes the messages
+// for a shared subscription across all of the subscribers in the group, which allows
+// multiple consumers to subscribe to the same topics.
+func (tb *TopicBuilder) WithSharedSubscriptionGroup(group string) *TopicBuilder {
+	tb.sharedSubscriptionGroup = group
+	return tb
 }
 
 func (tb *TopicBuilder) BuildOutgoingDataTopic(clientID domain.ClientID) string {
@@ -87,9 +98,17 @@ func (tb *TopicBuilder) BuildOutgoingControlTopic(clientID domain.ClientID) stri
 }
 
 func (tb *TopicBuilder) BuildIncomingWildcardDataTopic() string {
-	return tb.prefix + "/" + dataMessageIncomingTopic
+	return tb.buildSubscriptionPrefix() + tb.prefix + "/" + dataMessageIncomingTopic
 }
 
 func (tb *TopicBuilder) BuildIncomingWildcardControlTopic() string {
-	return tb.prefix + "/" + controlMessageIncomingTopic
+	return tb.buildSubscriptionPrefix() + tb.prefix + "/" + controlMessageIncomingTopic
+}
+
+func (tb *TopicBuilder) buildSubscriptionPrefix() string {
+	if tb.sharedSubscriptionGroup == "" {
+		return ""
+	}
+
+	return fmt.Sprintf(sharedSubscriptionPrefix, tb.sharedSubscriptionGroup)
 }
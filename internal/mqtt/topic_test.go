@@ -138,3 +138,34 @@ func buildIncomingWildcardDataTopic(tb *TopicBuilder) string {
 func buildIncomingWildcardControlTopic(tb *TopicBuilder) string {
 	return tb.BuildIncomingWildcardControlTopic()
 }
+
+func TestTopicBuilderIncomingSharedSubscriptionTopic(t *testing.T) {
+	var expectedPrefix string = "staging"
+	var expectedGroup string = "cloud-connector"
+
+	testCases := []struct {
+		prefix         string
+		group          string
+		expectedTopic  string
+		buildTopicFunc func(tb *TopicBuilder) string
+	}{
+		{"", expectedGroup, "$share/" + expectedGroup + "/redhat/insights/+/data/out", buildIncomingWildcardDataTopic},
+		{expectedPrefix, expectedGroup, "$share/" + expectedGroup + "/" + expectedPrefix + "/insights/+/data/out", buildIncomingWildcardDataTopic},
+
+		{"", expectedGroup, "$share/" + expectedGroup + "/redhat/insights/+/control/out", buildIncomingWildcardControlTopic},
+		{expectedPrefix, expectedGroup, "$share/" + expectedGroup + "/" + expectedPrefix + "/insights/+/control/out", buildIncomingWildcardControlTopic},
+
+		{expectedPrefix, "", expectedPrefix + "/insights/+/control/out", buildIncomingWildcardControlTopic},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("subtest %d", i), func(t *testing.T) {
+			topicBuilder := NewTopicBuilder(tc.prefix).WithSharedSubscriptionGroup(tc.group)
+			actualTopic := tc.buildTopicFunc(topicBuilder)
+
+			if actualTopic != tc.expectedTopic {
+				t.Fatalf("expected topic %s, but got %s!", tc.expectedTopic, actualTopic)
+			}
+		})
+	}
+}
@@ -28,11 +28,43 @@ func SendReconnectMessageToClient(mqttClient MQTT.Client, logger *logrus.Entry,
 
 	topic := topicBuilder.BuildOutgoingControlTopic(clientID)
 
-	err = sendMessage(mqttClient, logger, clientID, messageID, topic, qos, publishTimeout, message)
+	err = sendMessage(mqttClient, logger, clientID, messageID, topic, qos, publishTimeout, 0, message)
 
 	return err
 }
 
+func SendDisconnectMessageToClient(mqttClient MQTT.Client, logger *logrus.Entry, topicBuilder *TopicBuilder, qos byte, publishTimeout time.Duration, clientID domain.ClientID, disconnectMessage string) error {
+
+	messageID, message, err := protocol.BuildDisconnectMessage(disconnectMessage)
+
+	if err != nil {
+		return err
+	}
+
+	logger = logger.WithFields(logrus.Fields{"message_id": messageID, "client_id": clientID})
+
+	logger.Debug("Sending disconnect message to connected client")
+
+	topic := topicBuilder.BuildOutgoingControlTopic(clientID)
+
+	err = sendMessage(mqttClient, logger, clientID, messageID, topic, qos, publishTimeout, 0, message)
+
+	return err
+}
+
+func SendDataMessageToClient(mqttClient MQTT.Client, logger *logrus.Entry, topicBuilder *TopicBuilder, qos byte, publishTimeout time.Duration, messageExpiry time.Duration, clientID domain.ClientID, messageID uuid.UUID, directive string, metadata interface{}, payload interface{}) error {
+
+	message := protocol.BuildDataMessageWithID(messageID, directive, metadata, payload)
+
+	logger = logger.WithFields(logrus.Fields{"message_id": messageID, "client_id": clientID})
+
+	logger.Debug("Sending data message to connected client")
+
+	topic := topicBuilder.BuildOutgoingDataTopic(clientID)
+
+	return sendMessage(mqttClient, logger, clientID, &messageID, topic, qos, publishTimeout, messageExpiry, message)
+}
+
 func sendControlMessage(mqttClient MQTT.Client, logger *logrus.Entry, topic string, qos byte, publishTimeout time.Duration, clientID domain.ClientID, messageType string, content *protocol.CommandMessageContent) (*uuid.UUID, error) {
 
 	messageID, message, err := protocol.BuildControlMessage(messageType, content)
@@ -45,12 +77,12 @@ func sendControlMessage(mqttClient MQTT.Client, logger *logrus.Entry, topic stri
 
 	logger.Debug("Sending control message to connected client")
 
-	err = sendMessage(mqttClient, logger, clientID, messageID, topic, qos, publishTimeout, message)
+	err = sendMessage(mqttClient, logger, clientID, messageID, topic, qos, publishTimeout, 0, message)
 
 	return messageID, err
 }
 
-func sendMessage(mqttClient MQTT.Client, logger *logrus.Entry, clientID domain.ClientID, messageID *uuid.UUID, topic string, qos byte, publishTimeout time.Duration, message interface{}) error {
+func sendMessage(mqttClient MQTT.Client, logger *logrus.Entry, clientID domain.ClientID, messageID *uuid.UUID, topic string, qos byte, publishTimeout time.Duration, messageExpiry time.Duration, message interface{}) error {
 
 	logger = logger.WithFields(logrus.Fields{"message_id": messageID, "client_id": clientID})
 
@@ -63,7 +95,7 @@ func sendMessage(mqttClient MQTT.Client, logger *logrus.Entry, clientID domain.C
 
 	logger.Debug("Sending message to connected client on topic: ", topic, " qos: ", qos)
 
-	token := mqttClient.Publish(topic, qos, false, messageBuffer.Bytes())
+	token := publishMessage(mqttClient, topic, qos, messageID, messageExpiry, messageBuffer.Bytes())
 	if token.WaitTimeout(publishTimeout) && token.Error() != nil {
 		logger := logger.WithFields(logrus.Fields{"error": token.Error()})
 		logger.Error("Error sending a message to MQTT broker")
@@ -75,3 +107,21 @@ func sendMessage(mqttClient MQTT.Client, logger *logrus.Entry, clientID domain.C
 
 	return nil
 }
+
+// publishMessage attaches the message id and message expiry to the message when the
+// broker connection supports MQTT 5 properties
+func publishMessage(mqttClient MQTT.Client, topic string, qos byte, messageID *uuid.UUID, messageExpiry time.Duration, payload []byte) MQTT.Token {
+
+	propertiesPublisher, ok := mqttClient.(PropertiesPublisher)
+	if !ok {
+		return mqttClient.Publish(topic, qos, false, payload)
+	}
+
+	properties := PublishProperties{MessageExpiry: messageExpiry}
+
+	if messageID != nil {
+		properties.UserProperties = map[string]string{messageIDUserPropertyKey: messageID.String()}
+	}
+
+	return propertiesPublisher.PublishWithProperties(topic, qos, false, payload, properties)
+}
@@ -1,16 +1,36 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/RedHatInsights/cloud-connector/internal/config"
+	"github.com/RedHatInsights/cloud-connector/internal/platform/logger"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	listenerMinReconnectInterval = 1 * time.Second
+	listenerMaxReconnectInterval = 1 * time.Minute
 )
 
 func initializePostgresConnection(cfg *config.Config) (*sql.DB, error) {
+	psqlConnectionInfo, err := buildPostgresConnectionString(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return sql.Open("postgres", psqlConnectionInfo)
+}
+
+func buildPostgresConnectionString(cfg *config.Config) (string, error) {
 	psqlConnectionInfo := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s TimeZone=UTC",
 		cfg.ConnectionDatabaseHost,
 		cfg.ConnectionDatabasePort,
@@ -20,12 +40,10 @@ func initializePostgresConnection(cfg *config.Config) (*sql.DB, error) {
 
 	sslSettings, err := buildPostgresSslConfigString(cfg)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 
-	psqlConnectionInfo += " " + sslSettings
-
-	return sql.Open("postgres", psqlConnectionInfo)
+	return psqlConnectionInfo + " " + sslSettings, nil
 }
 
 func buildPostgresSslConfigString(cfg *config.Config) (string, error) {
@@ -53,5 +71,97 @@ func InitializeDatabaseConnection(cfg *config.Config) (*sql.DB, error) {
 		return nil, err
 	}
 
+	configureConnectionPool(cfg, database)
+
+	err = pingWithRetry(context.Background(), database, cfg.ConnectionDatabaseStartupPingAttempts, cfg.ConnectionDatabaseStartupPingBackoff, cfg.ConnectionDatabaseStartupPingMaxBackoff, cfg.ConnectionDatabaseQueryTimeout)
+	if err != nil {
+		database.Close()
+		return nil, err
+	}
+
+	registerConnectionPoolMetrics(cfg, database)
+
 	return database, nil
 }
+
+func configureConnectionPool(cfg *config.Config, database *sql.DB) {
+	database.SetMaxOpenConns(cfg.ConnectionDatabaseMaxOpenConnections)
+	database.SetMaxIdleConns(cfg.ConnectionDatabaseMaxIdleConnections)
+	database.SetConnMaxLifetime(cfg.ConnectionDatabaseConnectionMaxLifetime)
+	database.SetConnMaxIdleTime(cfg.ConnectionDatabaseConnectionMaxIdleTime)
+}
+
+// InitializeListener opens a dedicated connection for receiving notifications sent with
+// NOTIFY.  The listener reconnects on its own if the connection is lost and sends a nil
+// notification after reconnecting.
+func InitializeListener(cfg *config.Config) (*pq.Listener, error) {
+
+	if cfg.ConnectionDatabaseImpl != "postgres" {
+		return nil, errors.New("Invalid SQL database impl requested")
+	}
+
+	psqlConnectionInfo, err := buildPostgresConnectionString(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	reportListenerEvent := func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			logger.Log.WithFields(logrus.Fields{"error": err, "event": event}).Warn("Database listener connection event")
+		}
+	}
+
+	return pq.NewListener(psqlConnectionInfo, listenerMinReconnectInterval, listenerMaxReconnectInterval, reportListenerEvent), nil
+}
+
+// pingWithRetry verifies that the database is reachable.  The backoff doubles after each
+// failed attempt up to maxBackoff.
+func pingWithRetry(ctx context.Context, database *sql.DB, attempts int, backoff time.Duration, maxBackoff time.Duration, pingTimeout time.Duration) error {
+
+	var err error
+
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	for attempt := 1; attempt <= attempts; attempt++ {
+		pingCtx, cancel := context.WithTimeout(ctx, pingTimeout)
+		err = database.PingContext(pingCtx)
+		cancel()
+
+		if err == nil {
+			return nil
+		}
+
+		if attempt == attempts {
+			break
+		}
+
+		logger.Log.WithFields(logrus.Fields{"error": err, "attempt": attempt, "backoff": backoff}).Warn("Unable to ping the database...retrying")
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+
+	return fmt.Errorf("unable to ping the database after %d attempts: %w", attempts, err)
+}
+
+// registerConnectionPoolMetrics exports the sql.DBStats of the connection pool.  Only the
+// first connection pool created by the process is exported.
+func registerConnectionPoolMetrics(cfg *config.Config, database *sql.DB) {
+
+	err := prometheus.Register(collectors.NewDBStatsCollector(database, cfg.ConnectionDatabaseName))
+
+	var alreadyRegisteredError prometheus.AlreadyRegisteredError
+	if err != nil && errors.As(err, &alreadyRegisteredError) == false {
+		logger.LogError("Unable to register database connection pool metrics", err)
+	}
+}
@@ -1,6 +1,7 @@
 package queue
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/RedHatInsights/cloud-connector/internal/platform/logger"
@@ -28,3 +29,151 @@ func StartConsumer(cfg *ConsumerConfig) (*kafka.Reader, error) {
 
 	return r, nil
 }
+
+// StartPartitionConsumer creates a reader for a single partition of the topic.  The
+// reader is not part of a consumer group, so offsets are never committed.
+func StartPartitionConsumer(cfg *ConsumerConfig, partition int) (*kafka.Reader, error) {
+
+	kafkaDialer, err := createDialer(cfg.SaslConfig)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create kafka dialer: %w", err)
+	}
+
+	readerConfig := kafka.ReaderConfig{
+		Brokers:   cfg.Brokers,
+		Topic:     cfg.Topic,
+		Partition: partition,
+		Dialer:    kafkaDialer,
+	}
+
+	r := kafka.NewReader(readerConfig)
+
+	if err := r.SetOffset(cfg.ConsumerOffset); err != nil {
+		r.Close()
+		return nil, fmt.Errorf("unable to set kafka reader offset: %w", err)
+	}
+
+	return r, nil
+}
+
+type PartitionOffsets struct {
+	Partition int
+	First     int64
+	Last      int64
+}
+
+// LookupPartitionOffsets returns the first offset and the offset of the next message
+// to be written for each of the topic's partitions
+func LookupPartitionOffsets(ctx context.Context, cfg *ConsumerConfig) ([]PartitionOffsets, error) {
+
+	kafkaDialer, err := createDialer(cfg.SaslConfig)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create kafka dialer: %w", err)
+	}
+
+	if len(cfg.Brokers) == 0 {
+		return nil, fmt.Errorf("no kafka brokers configured")
+	}
+
+	partitions, err := kafkaDialer.LookupPartitions(ctx, "tcp", cfg.Brokers[0], cfg.Topic)
+	if err != nil {
+		return nil, fmt.Errorf("unable to lookup kafka partitions: %w", err)
+	}
+
+	offsets := make([]PartitionOffsets, 0, len(partitions))
+
+	for _, partition := range partitions {
+		conn, err := kafkaDialer.DialLeader(ctx, "tcp", cfg.Brokers[0], cfg.Topic, partition.ID)
+		if err != nil {
+			return nil, fmt.Errorf("unable to connect to partition leader: %w", err)
+		}
+
+		first, last, err := conn.ReadOffsets()
+		conn.Close()
+		if err != nil {
+			return nil, fmt.Errorf("unable to read partition offsets: %w", err)
+		}
+
+		offsets = append(offsets, PartitionOffsets{Partition: partition.ID, First: first, Last: last})
+	}
+
+	return offsets, nil
+}
+
+func createClient(cfg *ConsumerConfig) (*kafka.Client, error) {
+
+	if len(cfg.Brokers) == 0 {
+		return nil, fmt.Errorf("no kafka brokers configured")
+	}
+
+	transport, err := createTransport(cfg.SaslConfig)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create kafka transport: %w", err)
+	}
+
+	return &kafka.Client{Addr: kafka.TCP(cfg.Brokers...), Transport: transport}, nil
+}
+
+// FetchCommittedOffsets returns the offsets committed by the consumer group for the
+// given partitions.  Partitions without a committed offset are not included.
+func FetchCommittedOffsets(ctx context.Context, cfg *ConsumerConfig, partitions []int) (map[int]int64, error) {
+
+	client, err := createClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := client.OffsetFetch(ctx, &kafka.OffsetFetchRequest{
+		GroupID: cfg.GroupID,
+		Topics:  map[string][]int{cfg.Topic: partitions},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch committed offsets: %w", err)
+	}
+
+	if response.Error != nil {
+		return nil, fmt.Errorf("unable to fetch committed offsets: %w", response.Error)
+	}
+
+	committedOffsets := make(map[int]int64)
+
+	for _, partition := range response.Topics[cfg.Topic] {
+		if partition.Error != nil {
+			return nil, fmt.Errorf("unable to fetch committed offset for partition %d: %w", partition.Partition, partition.Error)
+		}
+
+		if partition.CommittedOffset >= 0 {
+			committedOffsets[partition.Partition] = partition.CommittedOffset
+		}
+	}
+
+	return committedOffsets, nil
+}
+
+// CommitPartitionOffset records offset as the next message to be read from the partition
+// by the consumer group.  The commit is made outside of a group generation, so this only
+// works for groups that do not have any active members.
+func CommitPartitionOffset(ctx context.Context, cfg *ConsumerConfig, partition int, offset int64) error {
+
+	client, err := createClient(cfg)
+	if err != nil {
+		return err
+	}
+
+	response, err := client.OffsetCommit(ctx, &kafka.OffsetCommitRequest{
+		GroupID:      cfg.GroupID,
+		GenerationID: -1,
+		Topics:       map[string][]kafka.OffsetCommit{cfg.Topic: {{Partition: partition, Offset: offset}}},
+	})
+	if err != nil {
+		return fmt.Errorf("unable to commit offset: %w", err)
+	}
+
+	for _, p := range response.Topics[cfg.Topic] {
+		if p.Error != nil {
+			return fmt.Errorf("unable to commit offset for partition %d: %w", p.Partition, p.Error)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,38 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// NewBrokerConnectivityCheck returns a readiness check that succeeds if a connection can be
+// established to any of the kafka brokers
+func NewBrokerConnectivityCheck(brokers []string, saslCfg *SaslConfig) (func(context.Context) error, error) {
+
+	if len(brokers) == 0 {
+		return nil, errors.New("no kafka brokers configured")
+	}
+
+	kafkaDialer, err := createDialer(saslCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(ctx context.Context) error {
+		var lastErr error
+
+		for _, broker := range brokers {
+			conn, err := kafkaDialer.DialContext(ctx, "tcp", broker)
+			if err != nil {
+				lastErr = err
+				continue
+			}
+
+			conn.Close()
+			return nil
+		}
+
+		return fmt.Errorf("unable to connect to any kafka broker: %w", lastErr)
+	}, nil
+}
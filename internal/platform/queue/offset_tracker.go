@@ -0,0 +1,124 @@
+package queue
+
+import (
+	"sync"
+
+	kafka "github.com/segmentio/kafka-go"
+)
+
+type partitionKey struct {
+	topic     string
+	partition int
+}
+
+type partitionOffsets struct {
+	inFlight  []kafka.Message
+	completed map[int64]bool
+}
+
+// OffsetTracker keeps track of the messages that have been fetched but not yet committed.
+// Messages can complete in any order, but only the highest contiguous completed offset
+// of each partition is reported as committable.  This keeps a message from being committed
+// before the messages that came before it on the same partition have been processed.
+type OffsetTracker struct {
+	lock       sync.Mutex
+	partitions map[partitionKey]*partitionOffsets
+}
+
+func NewOffsetTracker() *OffsetTracker {
+	return &OffsetTracker{partitions: make(map[partitionKey]*partitionOffsets)}
+}
+
+// Add records a fetched message.  Messages must be added in the order they were fetched.
+// The reader fetches the uncommitted messages of a partition again after a rebalance.  When
+// a fetched offset is not past the last tracked offset, the messages from that offset on are
+// no longer tracked because they are going to be fetched again.
+func (t *OffsetTracker) Add(msg kafka.Message) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	key := partitionKey{msg.Topic, msg.Partition}
+
+	offsets, found := t.partitions[key]
+	if found == false {
+		offsets = &partitionOffsets{completed: make(map[int64]bool)}
+		t.partitions[key] = offsets
+	}
+
+	if last := len(offsets.inFlight) - 1; last >= 0 && msg.Offset <= offsets.inFlight[last].Offset {
+		offsets.rewind(msg.Offset)
+	}
+
+	offsets.inFlight = append(offsets.inFlight, msg)
+}
+
+// rewind stops tracking the messages at or after the offset
+func (offsets *partitionOffsets) rewind(offset int64) {
+
+	i := len(offsets.inFlight)
+	for i > 0 && offsets.inFlight[i-1].Offset >= offset {
+		i--
+		delete(offsets.completed, offsets.inFlight[i].Offset)
+	}
+
+	offsets.inFlight = offsets.inFlight[:i]
+}
+
+// Complete marks a message as processed.  Messages that are no longer tracked are ignored.
+func (t *OffsetTracker) Complete(msg kafka.Message) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	offsets, found := t.partitions[partitionKey{msg.Topic, msg.Partition}]
+	if found == false || len(offsets.inFlight) == 0 {
+		return
+	}
+
+	if msg.Offset < offsets.inFlight[0].Offset || msg.Offset > offsets.inFlight[len(offsets.inFlight)-1].Offset {
+		return
+	}
+
+	offsets.completed[msg.Offset] = true
+}
+
+// Committable returns the last message of each partition's contiguous run of completed
+// messages.  The returned messages are no longer tracked.
+func (t *OffsetTracker) Committable() []kafka.Message {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	var committable []kafka.Message
+
+	for key, offsets := range t.partitions {
+		i := 0
+		for i < len(offsets.inFlight) && offsets.completed[offsets.inFlight[i].Offset] {
+			delete(offsets.completed, offsets.inFlight[i].Offset)
+			i++
+		}
+
+		if i == 0 {
+			continue
+		}
+
+		committable = append(committable, offsets.inFlight[i-1])
+
+		offsets.inFlight = offsets.inFlight[i:]
+		if len(offsets.inFlight) == 0 {
+			delete(t.partitions, key)
+		}
+	}
+
+	return committable
+}
+
+// InFlight returns the number of messages that have been fetched but not committed
+func (t *OffsetTracker) InFlight() int {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	count := 0
+	for _, offsets := range t.partitions {
+		count += len(offsets.inFlight)
+	}
+	return count
+}
@@ -0,0 +1,120 @@
+package queue
+
+import (
+	"testing"
+
+	kafka "github.com/segmentio/kafka-go"
+)
+
+func buildTrackedMessage(partition int, offset int64) kafka.Message {
+	return kafka.Message{Topic: "rhc-message-ingress", Partition: partition, Offset: offset}
+}
+
+func TestOffsetTrackerOnlyCommitsContiguousOffsets(t *testing.T) {
+
+	tracker := NewOffsetTracker()
+
+	for offset := int64(10); offset < 15; offset++ {
+		tracker.Add(buildTrackedMessage(0, offset))
+	}
+
+	tracker.Complete(buildTrackedMessage(0, 11))
+	tracker.Complete(buildTrackedMessage(0, 12))
+
+	if committable := tracker.Committable(); len(committable) != 0 {
+		t.Fatalf("nothing should be committable until offset 10 completes, got %v", committable)
+	}
+
+	tracker.Complete(buildTrackedMessage(0, 10))
+
+	committable := tracker.Committable()
+	if len(committable) != 1 || committable[0].Offset != 12 {
+		t.Fatalf("expected offset 12 to be committable, got %v", committable)
+	}
+
+	if tracker.InFlight() != 2 {
+		t.Fatalf("unexpected in flight count: %d", tracker.InFlight())
+	}
+
+	if committable := tracker.Committable(); len(committable) != 0 {
+		t.Fatalf("offsets should only be returned once, got %v", committable)
+	}
+
+	tracker.Complete(buildTrackedMessage(0, 14))
+	tracker.Complete(buildTrackedMessage(0, 13))
+
+	committable = tracker.Committable()
+	if len(committable) != 1 || committable[0].Offset != 14 {
+		t.Fatalf("expected offset 14 to be committable, got %v", committable)
+	}
+
+	if tracker.InFlight() != 0 {
+		t.Fatalf("unexpected in flight count: %d", tracker.InFlight())
+	}
+}
+
+func TestOffsetTrackerTracksPartitionsIndependently(t *testing.T) {
+
+	tracker := NewOffsetTracker()
+
+	tracker.Add(buildTrackedMessage(0, 1))
+	tracker.Add(buildTrackedMessage(1, 1))
+	tracker.Add(buildTrackedMessage(0, 2))
+	tracker.Add(buildTrackedMessage(1, 2))
+
+	tracker.Complete(buildTrackedMessage(1, 1))
+	tracker.Complete(buildTrackedMessage(1, 2))
+	tracker.Complete(buildTrackedMessage(0, 2))
+
+	committable := tracker.Committable()
+	if len(committable) != 1 || committable[0].Partition != 1 || committable[0].Offset != 2 {
+		t.Fatalf("expected only partition 1 offset 2 to be committable, got %v", committable)
+	}
+}
+
+func TestOffsetTrackerHandlesReplayedOffsets(t *testing.T) {
+
+	tracker := NewOffsetTracker()
+
+	for offset := int64(10); offset < 15; offset++ {
+		tracker.Add(buildTrackedMessage(0, offset))
+	}
+
+	tracker.Complete(buildTrackedMessage(0, 10))
+	tracker.Complete(buildTrackedMessage(0, 13))
+
+	// A rebalance causes the uncommitted messages from offset 11 on to be fetched again
+	for offset := int64(11); offset < 15; offset++ {
+		tracker.Add(buildTrackedMessage(0, offset))
+	}
+
+	if tracker.InFlight() != 5 {
+		t.Fatalf("replayed offsets should not be tracked twice, got %d in flight", tracker.InFlight())
+	}
+
+	committable := tracker.Committable()
+	if len(committable) != 1 || committable[0].Offset != 10 {
+		t.Fatalf("expected offset 10 to be committable, got %v", committable)
+	}
+
+	for offset := int64(11); offset < 15; offset++ {
+		tracker.Complete(buildTrackedMessage(0, offset))
+	}
+
+	committable = tracker.Committable()
+	if len(committable) != 1 || committable[0].Offset != 14 {
+		t.Fatalf("expected offset 14 to be committable, got %v", committable)
+	}
+
+	if tracker.InFlight() != 0 {
+		t.Fatalf("unexpected in flight count: %d", tracker.InFlight())
+	}
+
+	// Completing a message that is no longer tracked has no effect
+	tracker.Complete(buildTrackedMessage(0, 12))
+	tracker.Add(buildTrackedMessage(0, 15))
+
+	if committable := tracker.Committable(); len(committable) != 0 {
+		t.Fatalf("nothing should be committable until offset 15 completes, got %v", committable)
+	}
+}
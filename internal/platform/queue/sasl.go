@@ -42,6 +42,28 @@ func createDialer(cfg *SaslConfig) (*kafka.Dialer, error) {
 	}, nil
 }
 
+func createTransport(cfg *SaslConfig) (kafka.RoundTripper, error) {
+
+	if cfg == nil {
+		return kafka.DefaultTransport, nil
+	}
+
+	tlsConfig, err := createTLSConfig(cfg.KafkaCA)
+	if err != nil {
+		return nil, err
+	}
+
+	saslMechanism, err := createSaslMechanism(cfg.SaslMechanism, cfg.SaslUsername, cfg.SaslPassword)
+	if err != nil {
+		return nil, err
+	}
+
+	return &kafka.Transport{
+		SASL: saslMechanism,
+		TLS:  tlsConfig,
+	}, nil
+}
+
 func createTLSConfig(pathToCert string) (*tls.Config, error) {
 
 	tlsConfigFuncs := []tls_utils.TlsConfigFunc{}